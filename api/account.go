@@ -2,56 +2,73 @@ package api
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type createAccountRequest struct {
-	Owner    string `json:"owner" binding:"required"`
 	Currency string `json:"currency" binding:"required,oneof=USD EUR"`
 }
 
+// createAccount opens an account owned by the caller
 func (server *Server) createAccount(ctx *gin.Context) {
 	var req createAccountRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
+	payload, _ := authPayload(ctx)
 	arg := db.CreateAccountParams{
-		Owner:    req.Owner,
+		Owner:    payload.Username,
 		Currency: req.Currency,
 		Balance:  0,
 	}
 
 	account, err := server.store.CreateAccount(ctx, arg)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
+	setAuditChange(ctx, auditTarget("account", account.ID), nil, account)
 	ctx.JSON(http.StatusOK, account)
 }
 
-func (server *Server) getAccount(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
+type getAccountRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
 
+func (server *Server) getAccount(ctx *gin.Context) {
+	var req getAccountRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("account.id", req.ID))
+
 	account, err := server.store.GetAccount(ctx, req.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	// admins see any account, everyone else only their own
+	if !canAccessUser(ctx, account.Owner) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
 		return
 	}
 
@@ -66,18 +83,28 @@ type listAccountsRequest struct {
 func (server *Server) listAccounts(ctx *gin.Context) {
 	var req listAccountsRequest
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	arg := db.ListAccountsParams{
-		Limit:  req.PageSize,
-		Offset: (req.PageID - 1) * req.PageSize,
+	// admins list every account, everyone else only their own
+	var accounts []db.Account
+	var err error
+	payload, _ := authPayload(ctx)
+	if payload.Role == util.AdminRole {
+		accounts, err = server.store.ListAccounts(ctx, db.ListAccountsParams{
+			Limit:  req.PageSize,
+			Offset: (req.PageID - 1) * req.PageSize,
+		})
+	} else {
+		accounts, err = server.store.ListOwnerAccounts(ctx, db.ListOwnerAccountsParams{
+			Owner:  payload.Username,
+			Limit:  req.PageSize,
+			Offset: (req.PageID - 1) * req.PageSize,
+		})
 	}
-
-	accounts, err := server.store.ListAccounts(ctx, arg)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
@@ -88,68 +115,167 @@ type updateAccountRequest struct {
 	Balance int64 `json:"balance" binding:"required,min=0"`
 }
 
+// updateAccount sets the balance of an account (admin). The difference is
+// posted as an adjustment entry, so the ledger still reconciles.
 func (server *Server) updateAccount(ctx *gin.Context) {
 	// Get ID from URI
-	var reqURI struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
+	var reqURI getAccountRequest
 	if err := ctx.ShouldBindUri(&reqURI); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
 	// Get update data from body
 	var reqBody updateAccountRequest
 	if err := ctx.ShouldBindJSON(&reqBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
 	// Get account first to check if exists
-	account, err := server.store.GetAccount(ctx, reqURI.ID)
+	before, err := server.store.GetAccount(ctx, reqURI.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	if err := accountStatusError(before); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	// Update balance
-	arg := db.AddAccountBalanceParams{
-		ID:     reqURI.ID,
-		Amount: reqBody.Balance - account.Balance,
+	result, ok := server.adjustBalance(ctx, db.AdjustBalanceTxParams{
+		AccountID: reqURI.ID,
+		Amount:    reqBody.Balance - before.Balance,
+	})
+	if !ok {
+		return
 	}
 
-	account, err = server.store.AddAccountBalance(ctx, arg)
+	setAuditChange(ctx, auditTarget("account", result.Account.ID), before, result)
+	ctx.JSON(http.StatusOK, result.Account)
+}
+
+// adjustBalance posts a manual adjustment, or responds with the error and
+// returns false
+func (server *Server) adjustBalance(ctx *gin.Context, arg db.AdjustBalanceTxParams) (db.AdjustBalanceTxResult, bool) {
+	result, err := server.store.AdjustBalanceTx(ctx, arg)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+		case errors.Is(err, db.ErrAccountNotActive), errors.Is(err, db.ErrNegativeBalance):
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		}
+		return result, false
 	}
+	return result, true
+}
 
-	ctx.JSON(http.StatusOK, account)
+// accountStatusResponse is the outcome of a lifecycle change of an account
+type accountStatusResponse struct {
+	Account db.Account `json:"account"`
+	// Sweep is the transfer of the balance of a closed account, if any
+	Sweep *transferResponse `json:"sweep,omitempty"`
+}
+
+type closeAccountRequest struct {
+	// SweepAccountID receives the remaining balance. Without it only an
+	// account with a zero balance can be closed.
+	SweepAccountID int64 `json:"sweep_account_id" binding:"omitempty,min=1"`
 }
 
-func (server *Server) deleteAccount(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
+// freezeAccount stops an account from sending or receiving money
+func (server *Server) freezeAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountFreeze, 0)
+}
+
+// unfreezeAccount makes a frozen or dormant account active again
+func (server *Server) unfreezeAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountUnfreeze, 0)
+}
+
+// reopenAccount makes a closed account active again
+func (server *Server) reopenAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountReopen, 0)
+}
+
+// closeAccount closes an account. The rows are kept for the ledger. Money
+// left in the account is swept to sweep_account_id; the body is optional
+// when the balance is zero.
+func (server *Server) closeAccount(ctx *gin.Context) {
+	var req closeAccountRequest
+	if ctx.Request.Body != nil {
+		if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+			return
+		}
 	}
 
+	server.changeAccountStatus(ctx, db.AccountClose, req.SweepAccountID)
+}
+
+func (server *Server) changeAccountStatus(ctx *gin.Context, transition db.AccountTransition, sweepAccountID int64) {
+	var req getAccountRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	err := server.store.DeleteAccount(ctx, req.ID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("account.id", req.ID))
+
+	before, err := server.store.GetAccount(ctx, req.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+	// admins manage any account, everyone else only their own
+	payload, _ := authPayload(ctx)
+	if payload.Role != util.AdminRole && payload.Username != before.Owner {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	result, err := server.store.ChangeAccountStatusTx(ctx, db.ChangeAccountStatusTxParams{
+		AccountID:      req.ID,
+		Transition:     transition,
+		SweepAccountID: sweepAccountID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+		case errors.Is(err, db.ErrInvalidAccountTransition), errors.Is(err, db.ErrAccountNotEmpty):
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+		case errors.Is(err, db.ErrInvalidSweepAccount), errors.Is(err, db.ErrAccountNotActive):
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		}
+		return
+	}
+
+	response := accountStatusResponse{Account: result.Account}
+	if sweep := result.Sweep; sweep != nil {
+		server.metrics.TransferCompleted(fmt.Sprint(before.Currency), sweep.Transfer.Amount)
+		response.Sweep = &transferResponse{
+			Transfer:    sweep.Transfer,
+			FromAccount: sweep.FromAccount,
+			ToAccount:   sweep.ToAccount,
+		}
+	}
+
+	setAuditChange(ctx, auditTarget("account", before.ID), before, response)
+	ctx.JSON(http.StatusOK, response)
 }
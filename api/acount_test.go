@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -24,12 +25,16 @@ func TestGetAccountAPI(t *testing.T) {
 	testCases := []struct {
 		name          string
 		accountID     int64
+		username      string
+		role          string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name:      "OK",
 			accountID: account.ID,
+			username:  account.Owner,
+			role:      util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
@@ -41,9 +46,54 @@ func TestGetAccountAPI(t *testing.T) {
 				requireBodyMatchAccount(t, recorder.Body, account)
 			},
 		},
+		{
+			name:      "Admin",
+			accountID: account.ID,
+			username:  "admin",
+			role:      util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
+		{
+			name:      "OtherOwner",
+			accountID: account.ID,
+			username:  "mallory",
+			role:      util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			accountID: account.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name:      "NotFound",
 			accountID: account.ID,
+			username:  account.Owner,
+			role:      util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
@@ -57,6 +107,8 @@ func TestGetAccountAPI(t *testing.T) {
 		{
 			name:      "InternalError",
 			accountID: account.ID,
+			username:  account.Owner,
+			role:      util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
@@ -70,6 +122,8 @@ func TestGetAccountAPI(t *testing.T) {
 		{
 			name:      "InvalidID",
 			accountID: 0,
+			username:  account.Owner,
+			role:      util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Any()).
@@ -91,12 +145,15 @@ func TestGetAccountAPI(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			url := fmt.Sprintf("/api/v1/accounts/%d", tc.accountID)
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			if tc.username != "" {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			}
 
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
@@ -110,15 +167,16 @@ func TestCreateAccount(t *testing.T) {
 	testCases := []struct {
 		name          string
 		body          gin.H
+		username      string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name: "OK",
 			body: gin.H{
-				"owner":    account.Owner,
 				"currency": account.Currency,
 			},
+			username: account.Owner,
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.CreateAccountParams{
 					Owner:    account.Owner,
@@ -136,12 +194,49 @@ func TestCreateAccount(t *testing.T) {
 				requireBodyMatchAccount(t, recorder.Body, account)
 			},
 		},
+		{
+			name: "OwnerFromToken",
+			body: gin.H{
+				"owner":    "mallory",
+				"currency": account.Currency,
+			},
+			username: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateAccountParams{
+					Owner:    account.Owner,
+					Currency: account.Currency,
+					Balance:  0,
+				}
+
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: gin.H{
+				"currency": account.Currency,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name: "InvalidCurrency",
 			body: gin.H{
-				"owner":    account.Owner,
 				"currency": "invalid",
 			},
+			username: account.Owner,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					CreateAccount(gomock.Any(), gomock.Any()).
@@ -154,9 +249,9 @@ func TestCreateAccount(t *testing.T) {
 		{
 			name: "InternalError",
 			body: gin.H{
-				"owner":    account.Owner,
 				"currency": account.Currency,
 			},
+			username: account.Owner,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					CreateAccount(gomock.Any(), gomock.Any()).
@@ -179,7 +274,7 @@ func TestCreateAccount(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			data, err := json.Marshal(tc.body)
@@ -188,6 +283,9 @@ func TestCreateAccount(t *testing.T) {
 			url := "/api/v1/accounts"
 			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
 			require.NoError(t, err)
+			if tc.username != "" {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, util.DepositorRole, time.Minute)
+			}
 
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
@@ -196,16 +294,20 @@ func TestCreateAccount(t *testing.T) {
 }
 
 func TestListAccounts(t *testing.T) {
+	owner := util.RandomOwner()
 	n := 5
 	accounts := make([]db.Account, n)
 	for i := 0; i < n; i++ {
 		accounts[i] = RandomAccount()
+		accounts[i].Owner = owner
 	}
 
 	testCases := []struct {
 		name          string
 		pageID        int32
 		pageSize      int32
+		username      string
+		role          string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
@@ -213,6 +315,34 @@ func TestListAccounts(t *testing.T) {
 			name:     "OK",
 			pageID:   1,
 			pageSize: 5,
+			username: owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListOwnerAccountsParams{
+					Owner:  owner,
+					Limit:  5,
+					Offset: 0,
+				}
+
+				store.EXPECT().
+					ListOwnerAccounts(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(accounts, nil)
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccounts(t, recorder.Body, accounts)
+			},
+		},
+		{
+			name:     "Admin",
+			pageID:   1,
+			pageSize: 5,
+			username: "admin",
+			role:     util.AdminRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.ListAccountsParams{
 					Limit:  5,
@@ -229,13 +359,28 @@ func TestListAccounts(t *testing.T) {
 				requireBodyMatchAccounts(t, recorder.Body, accounts)
 			},
 		},
+		{
+			name:     "NoAuthorization",
+			pageID:   1,
+			pageSize: 5,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListOwnerAccounts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name:     "InvalidPageID",
 			pageID:   0,
 			pageSize: 5,
+			username: owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					ListAccounts(gomock.Any(), gomock.Any()).
+					ListOwnerAccounts(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -246,9 +391,11 @@ func TestListAccounts(t *testing.T) {
 			name:     "InvalidPageSize",
 			pageID:   1,
 			pageSize: 100,
+			username: owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					ListAccounts(gomock.Any(), gomock.Any()).
+					ListOwnerAccounts(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -259,9 +406,11 @@ func TestListAccounts(t *testing.T) {
 			name:     "InternalError",
 			pageID:   1,
 			pageSize: 5,
+			username: owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					ListAccounts(gomock.Any(), gomock.Any()).
+					ListOwnerAccounts(gomock.Any(), gomock.Any()).
 					Times(1).
 					Return([]db.Account{}, sql.ErrConnDone)
 			},
@@ -281,12 +430,15 @@ func TestListAccounts(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			url := fmt.Sprintf("/api/v1/accounts?page_id=%d&page_size=%d", tc.pageID, tc.pageSize)
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			if tc.username != "" {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			}
 
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
@@ -294,66 +446,240 @@ func TestListAccounts(t *testing.T) {
 	}
 }
 
-func TestDeleteAccount(t *testing.T) {
+func TestAccountStatusAPI(t *testing.T) {
 	account := RandomAccount()
+	frozen := account
+	frozen.Status = db.AccountStatusFrozen
+	closed := account
+	closed.Status = db.AccountStatusClosed
+	closed.Balance = 0
+	receiver := RandomAccount()
 
 	testCases := []struct {
 		name          string
-		accountID     int64
+		method        string
+		path          string
+		body          string
+		username      string
+		role          string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
-			name:      "OK",
-			accountID: account.ID,
+			name:     "Freeze",
+			method:   http.MethodPost,
+			path:     "/freeze",
+			username: "admin",
+			role:     util.AdminRole,
 			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				arg := db.ChangeAccountStatusTxParams{AccountID: account.ID, Transition: db.AccountFreeze}
 				store.EXPECT().
-					DeleteAccount(gomock.Any(), gomock.Eq(account.ID)).
+					ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(nil)
+					Return(db.ChangeAccountStatusTxResult{Account: frozen}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"frozen"`)
+				require.NotContains(t, recorder.Body.String(), `"sweep"`)
 			},
 		},
 		{
-			name:      "NotFound",
-			accountID: account.ID,
+			name:     "FreezeNotAdmin",
+			method:   http.MethodPost,
+			path:     "/freeze",
+			username: account.Owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "UnfreezeActive",
+			method:   http.MethodPost,
+			path:     "/unfreeze",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
 				store.EXPECT().
-					DeleteAccount(gomock.Any(), gomock.Eq(account.ID)).
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(sql.ErrNoRows)
+					Return(db.ChangeAccountStatusTxResult{}, fmt.Errorf("%w: account is active", db.ErrInvalidAccountTransition))
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder, http.StatusConflict, ErrAccountTransition.Code)
 			},
 		},
 		{
-			name:      "InternalError",
-			accountID: account.ID,
+			name:     "Reopen",
+			method:   http.MethodPost,
+			path:     "/reopen",
+			username: "admin",
+			role:     util.AdminRole,
 			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(closed, nil)
+				arg := db.ChangeAccountStatusTxParams{AccountID: account.ID, Transition: db.AccountReopen}
 				store.EXPECT().
-					DeleteAccount(gomock.Any(), gomock.Eq(account.ID)).
+					ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(sql.ErrConnDone)
+					Return(db.ChangeAccountStatusTxResult{Account: account}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.Equal(t, http.StatusOK, recorder.Code)
 			},
 		},
 		{
-			name:      "InvalidID",
-			accountID: 0,
+			name:     "CloseWithSweep",
+			method:   http.MethodPost,
+			path:     "/close",
+			body:     fmt.Sprintf(`{"sweep_account_id":%d}`, receiver.ID),
+			username: account.Owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				arg := db.ChangeAccountStatusTxParams{
+					AccountID:      account.ID,
+					Transition:     db.AccountClose,
+					SweepAccountID: receiver.ID,
+				}
 				store.EXPECT().
-					DeleteAccount(gomock.Any(), gomock.Any()).
-					Times(0)
+					ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{
+						Account: closed,
+						Sweep: &db.TransferTxResult{
+							Transfer:    db.Transfer{ID: 1, FromAccountID: account.ID, ToAccountID: receiver.ID, Amount: account.Balance},
+							FromAccount: closed,
+							ToAccount:   receiver,
+						},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response accountStatusResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, db.AccountStatusClosed, response.Account.Status)
+				require.NotNil(t, response.Sweep)
+				require.Equal(t, account.Balance, response.Sweep.Transfer.Amount)
+			},
+		},
+		{
+			name:     "CloseNotEmpty",
+			method:   http.MethodPost,
+			path:     "/close",
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{}, fmt.Errorf("%w: account has balance 10", db.ErrAccountNotEmpty))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrAccountNotEmpty.Code)
+			},
+		},
+		{
+			name:     "CloseInvalidSweepAccount",
+			method:   http.MethodPost,
+			path:     "/close",
+			body:     fmt.Sprintf(`{"sweep_account_id":%d}`, account.ID),
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{}, db.ErrInvalidSweepAccount)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "CloseOtherOwner",
+			method:   http.MethodPost,
+			path:     "/close",
+			username: "mallory",
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "CloseInvalidBody",
+			method:   http.MethodPost,
+			path:     "/close",
+			body:     `{"sweep_account_id":-1}`,
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
+		{
+			name:     "Delete",
+			method:   http.MethodDelete,
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				arg := db.ChangeAccountStatusTxParams{AccountID: account.ID, Transition: db.AccountClose}
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{Account: closed}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			method:   http.MethodPost,
+			path:     "/close",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			method:   http.MethodPost,
+			path:     "/freeze",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -366,13 +692,14 @@ func TestDeleteAccount(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
-			url := fmt.Sprintf("/api/v1/accounts/%d", tc.accountID)
-			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			url := fmt.Sprintf("/api/v1/accounts/%d%s", account.ID, tc.path)
+			request, err := http.NewRequest(tc.method, url, bytes.NewBufferString(tc.body))
 			require.NoError(t, err)
 
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
 		})
@@ -382,8 +709,12 @@ func TestDeleteAccount(t *testing.T) {
 func TestUpdateAccount(t *testing.T) {
 	account := RandomAccount()
 
+	frozen := account
+	frozen.Status = db.AccountStatusFrozen
+
 	testCases := []struct {
 		name          string
+		role          string
 		accountID     int64
 		body          gin.H
 		buildStubs    func(store *mockdb.MockStore)
@@ -402,19 +733,19 @@ func TestUpdateAccount(t *testing.T) {
 					Times(1).
 					Return(account, nil)
 
-				// Then expect AddAccountBalance with correct parameters
-				arg := db.AddAccountBalanceParams{
-					ID:     account.ID,
-					Amount: 100, // The difference between new and old balance
+				// Then expect an adjustment of the difference to the old balance
+				arg := db.AdjustBalanceTxParams{
+					AccountID: account.ID,
+					Amount:    100,
 				}
 
 				updatedAccount := account
 				updatedAccount.Balance += 100
 
 				store.EXPECT().
-					AddAccountBalance(gomock.Any(), gomock.Eq(arg)).
+					AdjustBalanceTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(updatedAccount, nil)
+					Return(db.AdjustBalanceTxResult{Account: updatedAccount}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -428,6 +759,34 @@ func TestUpdateAccount(t *testing.T) {
 				require.Equal(t, account.Currency, gotAccount.Currency)
 			},
 		},
+		{
+			name:      "NotAdmin",
+			role:      util.DepositorRole,
+			accountID: account.ID,
+			body: gin.H{
+				"balance": account.Balance + 100,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AdjustBalanceTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:      "Frozen",
+			accountID: account.ID,
+			body: gin.H{
+				"balance": account.Balance + 100,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(frozen, nil)
+				store.EXPECT().AdjustBalanceTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrAccountFrozen.Code)
+			},
+		},
 		{
 			name:      "NotFound",
 			accountID: account.ID,
@@ -503,9 +862,9 @@ func TestUpdateAccount(t *testing.T) {
 					Return(account, nil)
 
 				store.EXPECT().
-					AddAccountBalance(gomock.Any(), gomock.Any()).
+					AdjustBalanceTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.Account{}, sql.ErrConnDone)
+					Return(db.AdjustBalanceTxResult{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -523,7 +882,7 @@ func TestUpdateAccount(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			// Marshal body data to JSON
@@ -534,6 +893,11 @@ func TestUpdateAccount(t *testing.T) {
 			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
 			require.NoError(t, err)
 
+			role := tc.role
+			if role == "" {
+				role = util.AdminRole
+			}
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", role, time.Minute)
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
 		})
@@ -546,6 +910,7 @@ func RandomAccount() db.Account {
 		Owner:    util.RandomOwner(),
 		Balance:  int64(util.RandomMoney()),
 		Currency: util.RandomCurrency(),
+		Status:   db.AccountStatusActive,
 	}
 }
 
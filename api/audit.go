@@ -0,0 +1,184 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/logging"
+)
+
+// Audit actions that are not the outcome of a successful request, and so
+// are recorded by the handlers themselves
+const (
+	auditUserLocked   = "user.locked"
+	auditUserExported = "user.exported"
+)
+
+// auditActions names the audit action of every mutating route, as
+// "METHOD template". Successful requests to them are recorded by
+// auditMiddleware.
+var auditActions = map[string]string{
+	http.MethodPost + " " + apiBasePath + "/accounts":                       "account.created",
+	http.MethodPut + " " + apiBasePath + "/accounts/:id":                    "account.updated",
+	http.MethodDelete + " " + apiBasePath + "/accounts/:id":                 "account.closed",
+	http.MethodPost + " " + apiBasePath + "/accounts/:id/freeze":            "account.frozen",
+	http.MethodPost + " " + apiBasePath + "/accounts/:id/unfreeze":          "account.unfrozen",
+	http.MethodPost + " " + apiBasePath + "/accounts/:id/close":             "account.closed",
+	http.MethodPost + " " + apiBasePath + "/accounts/:id/reopen":            "account.reopened",
+	http.MethodPut + " " + apiBasePath + "/accounts/:id/approval-policy":    "account.approval_policy_set",
+	http.MethodDelete + " " + apiBasePath + "/accounts/:id/approval-policy": "account.approval_policy_deleted",
+	http.MethodPost + " " + apiBasePath + "/entries":                        "entry.created",
+	http.MethodPost + " " + apiBasePath + "/transfers":                      "transfer.created",
+	http.MethodPost + " " + apiBasePath + "/transfers/:id/reverse":          "transfer.reversed",
+	http.MethodPost + " " + apiBasePath + "/users":                          "user.created",
+	http.MethodPost + " " + apiBasePath + "/users/login":                    "user.logged_in",
+	http.MethodPost + " " + apiBasePath + "/users/totp":                     "user.totp_enrolled",
+	http.MethodPost + " " + apiBasePath + "/users/totp/activate":            "user.totp_enabled",
+	http.MethodPost + " " + apiBasePath + "/users/step-up":                  "user.stepped_up",
+	http.MethodPost + " " + apiBasePath + "/users/verify-email":             "user.verification_email_sent",
+	http.MethodPost + " " + apiBasePath + "/users/verify-email/confirm":     "user.email_verified",
+	http.MethodPost + " " + apiBasePath + "/users/password-reset":           "user.password_reset_requested",
+	http.MethodPost + " " + apiBasePath + "/users/password-reset/confirm":   "user.password_reset",
+	http.MethodPost + " " + apiBasePath + "/users/:username/unlock":         "user.unlocked",
+	http.MethodPut + " " + apiBasePath + "/users/:username":                 "user.updated",
+	http.MethodDelete + " " + apiBasePath + "/users/:username":              "user.deleted",
+	http.MethodPost + " " + apiBasePath + "/users/:username/erase":          "user.erased",
+	http.MethodPut + " " + apiBasePath + "/users/:username/kyc":             "kyc.submitted",
+	http.MethodPost + " " + apiBasePath + "/kyc/profiles/:username/approve": "kyc.approved",
+	http.MethodPost + " " + apiBasePath + "/kyc/profiles/:username/reject":  "kyc.rejected",
+	http.MethodPut + " " + apiBasePath + "/limits/defaults/:scope":          "limit.updated",
+	http.MethodPut + " " + apiBasePath + "/limits/accounts/:id":             "limit.updated",
+	http.MethodDelete + " " + apiBasePath + "/limits/accounts/:id":          "limit.deleted",
+	http.MethodPut + " " + apiBasePath + "/limits/users/:username":          "limit.updated",
+	http.MethodDelete + " " + apiBasePath + "/limits/users/:username":       "limit.deleted",
+	http.MethodPost + " " + apiBasePath + "/monitoring/alerts/:id/assign":   "alert.assigned",
+	http.MethodPost + " " + apiBasePath + "/monitoring/alerts/:id/close":    "alert.closed",
+	http.MethodPost + " " + apiBasePath + "/monitoring/alerts/:id/release":  "alert.transfer_released",
+	http.MethodPost + " " + apiBasePath + "/monitoring/alerts/:id/reject":   "alert.transfer_rejected",
+	http.MethodPost + " " + apiBasePath + "/screening/hits/:id/clear":       "screening.cleared",
+	http.MethodPost + " " + apiBasePath + "/screening/hits/:id/confirm":     "screening.confirmed",
+	http.MethodPost + " " + apiBasePath + "/transfer-approvals/:id/approve": "transfer_approval.approved",
+	http.MethodPost + " " + apiBasePath + "/transfer-approvals/:id/reject":  "transfer_approval.rejected",
+}
+
+// auditChangeKey holds the *auditChange set by a handler
+const auditChangeKey = "audit_change"
+
+// auditChange is what a handler changed: the target, such as "account:1",
+// with its state before and after. A missing state is nil.
+type auditChange struct {
+	target string
+	before any
+	after  any
+}
+
+// setAuditChange describes the change made by the current request for its
+// audit event. States must not contain secrets such as password hashes.
+func setAuditChange(ctx *gin.Context, target string, before, after any) {
+	ctx.Set(auditChangeKey, &auditChange{target: target, before: before, after: after})
+}
+
+// auditTarget formats the target of an audit event, e.g. "account:1"
+func auditTarget(kind string, id any) string {
+	return fmt.Sprintf("%s:%v", kind, id)
+}
+
+// auditMiddleware records an audit event for every successful request to a
+// route in auditActions. It runs after idempotency, so a replayed response
+// is not recorded twice.
+func (server *Server) auditMiddleware(ctx *gin.Context) {
+	ctx.Next()
+
+	action, ok := auditActions[ctx.Request.Method+" "+ctx.FullPath()]
+	if !ok || ctx.Writer.Status() >= http.StatusBadRequest {
+		return
+	}
+
+	change := &auditChange{target: ctx.Request.URL.Path}
+	if value, ok := ctx.Get(auditChangeKey); ok {
+		change = value.(*auditChange)
+	}
+	server.audit(ctx, action, change.target, change.before, change.after)
+}
+
+// audit appends an event to the audit log. The actor is the authenticated
+// user, or "anonymous". A failure is logged rather than failing a request
+// that already took effect.
+func (server *Server) audit(ctx *gin.Context, action string, target string, before, after any) {
+	actor := "anonymous"
+	if payload, ok := authPayload(ctx); ok {
+		actor = payload.Username
+	}
+
+	arg := db.AppendAuditEventTxParams{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		RequestID: logging.RequestID(ctx),
+		IP:        ctx.ClientIP(),
+	}
+	var err error
+	if arg.Before, err = marshalAuditState(before); err == nil {
+		arg.After, err = marshalAuditState(after)
+	}
+	if err == nil {
+		_, err = server.store.AppendAuditEventTx(ctx, arg)
+	}
+	if err != nil {
+		server.logger.ErrorContext(ctx, "cannot record audit event",
+			slog.String("action", action),
+			slog.String("actor", actor),
+			slog.String("target", target),
+			slog.Any("error", err),
+		)
+	}
+}
+
+func marshalAuditState(state any) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+type listAuditEventsRequest struct {
+	Actor    string    `form:"actor"`
+	Target   string    `form:"target"`
+	Since    time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until    time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	PageID   int32     `form:"page_id" binding:"required,min=1"`
+	PageSize int32     `form:"page_size" binding:"required,min=5,max=100"`
+}
+
+// listAuditEvents returns audit events newest first, filtered by actor,
+// target and a time range [since, until)
+func (server *Server) listAuditEvents(ctx *gin.Context) {
+	var req listAuditEventsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	arg := db.ListAuditEventsParams{
+		Actor:  sql.NullString{String: req.Actor, Valid: req.Actor != ""},
+		Target: sql.NullString{String: req.Target, Valid: req.Target != ""},
+		Since:  sql.NullTime{Time: req.Since, Valid: !req.Since.IsZero()},
+		Until:  sql.NullTime{Time: req.Until, Valid: !req.Until.IsZero()},
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	}
+
+	events, err := server.store.ListAuditEvents(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
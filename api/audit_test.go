@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditActionsCoverMutatingRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+	var mutating []string
+	for _, route := range server.router.Routes() {
+		if route.Method != http.MethodGet && route.Method != http.MethodHead {
+			mutating = append(mutating, route.Method+" "+route.Path)
+		}
+	}
+
+	var audited []string
+	for route := range auditActions {
+		audited = append(audited, route)
+	}
+
+	require.ElementsMatch(t, mutating, audited, "every mutating route needs an audit action")
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	account := RandomAccount()
+	updated := account
+	updated.Balance = account.Balance + 100
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().AdjustBalanceTx(gomock.Any(), gomock.Any()).Times(1).Return(db.AdjustBalanceTxResult{Account: updated}, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "alice", arg.Actor)
+						require.Equal(t, "account.updated", arg.Action)
+						require.Equal(t, fmt.Sprintf("account:%d", account.ID), arg.Target)
+						require.Contains(t, string(arg.Before), fmt.Sprintf(`"balance":%d`, account.Balance))
+						require.Contains(t, string(arg.After), fmt.Sprintf(`"balance":%d`, updated.Balance))
+						require.Equal(t, "req-1", arg.RequestID)
+						require.Equal(t, "192.0.2.1", arg.IP)
+						return db.AuditEvent{}, nil
+					})
+			},
+			check: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "FailedRequest",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().AppendAuditEventTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "AuditError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(account, nil)
+				store.EXPECT().AdjustBalanceTx(gomock.Any(), gomock.Any()).Times(1).Return(db.AdjustBalanceTxResult{Account: updated}, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AuditEvent{}, errors.New("database unavailable"))
+			},
+			check: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				// the update already took effect
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body := fmt.Sprintf(`{"balance":%d}`, updated.Balance)
+			request, err := http.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/accounts/%d", account.ID), bytes.NewBufferString(body))
+			require.NoError(t, err)
+			request.Header.Set(RequestIDHeader, "req-1")
+			request.RemoteAddr = "192.0.2.1:1234"
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "alice", util.AdminRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.check(t, recorder)
+		})
+	}
+}
+
+func TestAuditMiddlewareIdempotentReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	account := RandomAccount()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(1).Return(account, nil)
+	store.EXPECT().AppendAuditEventTx(gomock.Any(), gomock.Any()).Times(1).Return(db.AuditEvent{}, nil)
+
+	server := newTestServer(t, store)
+	body := fmt.Sprintf(`{"currency":%q}`, account.Currency)
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, "/api/v1/accounts", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		request.Header.Set(IdempotencyKeyHeader, "create-account-1")
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, util.DepositorRole, time.Minute)
+
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	}
+}
+
+func TestListAuditEventsAPI(t *testing.T) {
+	events := []db.AuditEvent{
+		{ID: 2, Actor: "alice", Action: "account.updated", Target: "account:1", Before: []byte(`{"balance":1}`), After: []byte(`{"balance":2}`)},
+		{ID: 1, Actor: "alice", Action: "account.created", Target: "account:1", Before: []byte(`null`), After: []byte(`{"balance":1}`)},
+	}
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		query         url.Values
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: url.Values{"actor": {"alice"}, "since": {since.Format(time.RFC3339)}, "page_id": {"2"}, "page_size": {"5"}},
+			role:  util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAuditEventsParams{
+					Actor:  sql.NullString{String: "alice", Valid: true},
+					Since:  sql.NullTime{Time: since, Valid: true},
+					Limit:  5,
+					Offset: 5,
+				}
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Eq(arg)).Times(1).Return(events, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				// the states are embedded as JSON, not as strings
+				require.Contains(t, recorder.Body.String(), `"before":{"balance":1}`)
+			},
+		},
+		{
+			name:  "NotAdmin",
+			query: url.Values{"page_id": {"1"}, "page_size": {"5"}},
+			role:  util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:  "InvalidSince",
+			query: url.Values{"since": {"yesterday"}, "page_id": {"1"}, "page_size": {"5"}},
+			role:  util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/api/v1/audit-events?"+tc.query.Encode(), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiiamanop/simple_bank/token"
+)
+
+const (
+	authorizationHeaderKey  = "Authorization"
+	authorizationTypeBearer = "bearer"
+	// authorizationPayloadKey holds the *token.Payload of an authenticated
+	// request
+	authorizationPayloadKey = "authorization_payload"
+)
+
+// Context keys under which authentication stores the identity of the
+// caller. Rate limits are counted per identity, and per client IP for
+// anonymous requests.
+const (
+	authUserKey   = "auth_user"
+	authAPIKeyKey = "auth_api_key"
+)
+
+// authMiddleware authenticates requests that carry a bearer token and
+// rejects invalid ones with 401. Requests without a token pass as
+// anonymous; routes that need a caller add requireAuth.
+func (server *Server) authMiddleware(ctx *gin.Context) {
+	header := ctx.GetHeader(authorizationHeaderKey)
+	if header == "" {
+		ctx.Next()
+		return
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) != 2 || !strings.EqualFold(fields[0], authorizationTypeBearer) {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(ctx, ErrUnauthorized))
+		return
+	}
+
+	payload, err := server.tokenMaker.VerifyToken(fields[1])
+	if err == nil && payload.Purpose != token.PurposeAccess {
+		err = token.ErrInvalidToken
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(ctx, &Error{
+			Code:    ErrUnauthorized.Code,
+			Message: err.Error(),
+		}))
+		return
+	}
+
+	ctx.Set(authorizationPayloadKey, payload)
+	ctx.Set(authUserKey, payload.Username)
+	ctx.Next()
+}
+
+// requireAuth rejects anonymous requests with 401 and, when roles are
+// given, callers with any other role with 403
+func requireAuth(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, ok := authPayload(ctx)
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(ctx, ErrUnauthorized))
+			return
+		}
+		if len(roles) > 0 && !slices.Contains(roles, payload.Role) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// authPayload returns the token payload of an authenticated request
+func authPayload(ctx *gin.Context) (*token.Payload, bool) {
+	value, ok := ctx.Get(authorizationPayloadKey)
+	if !ok {
+		return nil, false
+	}
+	payload, ok := value.(*token.Payload)
+	return payload, ok
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	"github.com/hiiamanop/simple_bank/token"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func addAuthorization(
+	t *testing.T,
+	request *http.Request,
+	tokenMaker token.Maker,
+	authorizationType string,
+	username string,
+	role string,
+	duration time.Duration,
+) {
+	accessToken, payload, err := tokenMaker.CreateToken(username, role, token.PurposeAccess, duration)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationType, accessToken))
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "alice", util.AdminRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrUnauthorized.Code)
+			},
+		},
+		{
+			name: "UnsupportedAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, "basic", "alice", util.AdminRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrUnauthorized.Code)
+			},
+		},
+		{
+			name: "ExpiredToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "alice", util.AdminRole, -time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrUnauthorized.Code)
+			},
+		},
+		{
+			name: "WrongRole",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "alice", util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mockdb.NewMockStore(ctrl))
+			server.router.GET(apiBasePath+"/auth-test", server.authMiddleware, requireAuth(util.AdminRole), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, apiBasePath+"/auth-test", nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/mail"
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+// WithMailSender sends the emails of the server through sender. Without it
+// the server only logs them.
+func WithMailSender(sender mail.Sender) Option {
+	return func(server *Server) {
+		server.mailer = sender
+	}
+}
+
+// sendVerificationEmail sends user a link that verifies their email.
+// Failures are logged, since the user can ask for another one.
+func (server *Server) sendVerificationEmail(ctx *gin.Context, user db.User) {
+	token, err := server.createEmailToken(ctx, user, db.VerifyEmailPurposeEmailVerification, server.config.VerifyEmailTokenDuration)
+	if err == nil {
+		err = server.mailer.Send(ctx, mail.Message{
+			To:      user.Email,
+			Subject: "Verify your email",
+			Body: fmt.Sprintf("Hello %s,\n\nplease verify your email by opening\n\n%s\n\nThe link expires in %s.\n",
+				user.FullName, server.emailLink("/verify-email", token), server.config.VerifyEmailTokenDuration),
+		})
+	}
+	if err != nil {
+		server.logger.ErrorContext(ctx, "cannot send verification email",
+			slog.String("username", user.Username), slog.Any("error", err))
+	}
+}
+
+// createEmailToken stores the hash of a new random token for user, and
+// returns the token
+func (server *Server) createEmailToken(ctx *gin.Context, user db.User, purpose string, duration time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	_, err := server.store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{
+		Username:    user.Username,
+		Email:       user.Email,
+		Purpose:     purpose,
+		HashedToken: hashEmailToken(token),
+		ExpiredAt:   time.Now().Add(duration),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// emailLink points to path of the web app, which passes the token on to
+// the confirm endpoints
+func (server *Server) emailLink(path, token string) string {
+	return strings.TrimSuffix(server.config.AppBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// hashEmailToken hashes the tokens sent by email. They are random enough
+// that a fast hash is safe.
+func hashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// resendVerificationEmail sends the caller a new verification link
+func (server *Server) resendVerificationEmail(ctx *gin.Context) {
+	payload, _ := authPayload(ctx)
+
+	user, err := server.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if user.IsEmailVerified {
+		ctx.JSON(http.StatusConflict, errorResponse(ctx, ErrEmailVerified))
+		return
+	}
+
+	server.sendVerificationEmail(ctx, user)
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, nil)
+	ctx.JSON(http.StatusAccepted, messageResponse{Message: "verification email sent"})
+}
+
+type confirmEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// verifyEmail uses a token from a verification email. It needs no login,
+// since the token itself proves access to the email.
+func (server *Server) verifyEmail(ctx *gin.Context) {
+	var req confirmEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	user, err := server.store.VerifyEmailTx(ctx, hashEmailToken(req.Token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, ErrInvalidEmailToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, newUserAuditState(user))
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type passwordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// requestPasswordReset emails a reset link to the user with the given
+// email. The answer is the same whether or not there is such a user, so
+// that it cannot be used to find out who has an account.
+func (server *Server) requestPasswordReset(ctx *gin.Context) {
+	var req passwordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := messageResponse{Message: "if the email belongs to a user, a reset link was sent to it"}
+	user, err := server.store.GetUserByEmail(ctx, req.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if err != nil || user.DeletedAt.Valid {
+		ctx.JSON(http.StatusAccepted, rsp)
+		return
+	}
+
+	token, err := server.createEmailToken(ctx, user, db.VerifyEmailPurposePasswordReset, server.config.PasswordResetTokenDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	err = server.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nyou can choose a new password by opening\n\n%s\n\n"+
+			"The link expires in %s. If you did not ask for it, you can ignore this email.\n",
+			user.FullName, server.emailLink("/reset-password", token), server.config.PasswordResetTokenDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, nil)
+	ctx.JSON(http.StatusAccepted, rsp)
+}
+
+type confirmPasswordResetRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// resetPassword uses a token from a password reset email to set a new
+// password, which also lifts a login lockout
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req confirmPasswordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.Password)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	user, err := server.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		HashedToken:    hashEmailToken(req.Token),
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, ErrInvalidEmailToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, newUserAuditState(user))
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
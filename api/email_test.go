@@ -0,0 +1,327 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/mail"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSender keeps the sent messages for assertions
+type recordingSender struct {
+	mu       sync.Mutex
+	messages []mail.Message
+	err      error
+}
+
+func (sender *recordingSender) Send(ctx context.Context, msg mail.Message) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if sender.err != nil {
+		return sender.err
+	}
+	sender.messages = append(sender.messages, msg)
+	return nil
+}
+
+// tokenFromLink extracts the token from the link in an email body
+func tokenFromLink(t *testing.T, body string) string {
+	start := strings.Index(body, "http://")
+	require.NotEqual(t, -1, start)
+	link, err := url.Parse(strings.Fields(body[start:])[0])
+	require.NoError(t, err)
+	return link.Query().Get("token")
+}
+
+func TestResendVerificationEmailAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	verified := user
+	verified.IsEmailVerified = true
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingSender)
+	}{
+		{
+			name: "OK",
+			user: user,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreateVerifyEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+						require.Equal(t, user.Email, arg.Email)
+						require.Equal(t, db.VerifyEmailPurposeEmailVerification, arg.Purpose)
+						require.WithinDuration(t, time.Now().Add(time.Hour), arg.ExpiredAt, time.Second)
+						return db.VerifyEmail{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingSender) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Len(t, mailer.messages, 1)
+				require.NotEmpty(t, tokenFromLink(t, mailer.messages[0].Body))
+			},
+		},
+		{
+			name: "AlreadyVerified",
+			user: verified,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(verified, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingSender) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrEmailVerified.Code)
+				require.Empty(t, mailer.messages)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			mailer := &recordingSender{}
+			server := newTestServer(t, store, WithMailSender(mailer))
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/verify-email", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, tc.user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, mailer)
+		})
+	}
+}
+
+func TestVerifyEmailAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.IsEmailVerified = true
+	token := util.RandomString(32)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Eq(hashEmailToken(token))).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"email_verified":true`)
+			},
+		},
+		{
+			name: "InvalidToken",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInvalidEmailToken.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body := fmt.Sprintf(`{"token":%q}`, token)
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/verify-email/confirm", bytes.NewBufferString(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRequestPasswordResetAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		mailErr       error
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingSender)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreateVerifyEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, db.VerifyEmailPurposePasswordReset, arg.Purpose)
+						return db.VerifyEmail{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingSender) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Len(t, mailer.messages, 1)
+				require.Equal(t, user.Email, mailer.messages[0].To)
+				require.Contains(t, mailer.messages[0].Body, "http://localhost:3000/reset-password?token=")
+			},
+		},
+		{
+			name: "UnknownEmail",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingSender) {
+				// the same answer as for a known email
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Empty(t, mailer.messages)
+			},
+		},
+		{
+			name:    "MailError",
+			mailErr: errors.New("smtp unavailable"),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(1).Return(user, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.VerifyEmail{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingSender) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			mailer := &recordingSender{err: tc.mailErr}
+			server := newTestServer(t, store, WithMailSender(mailer))
+			recorder := httptest.NewRecorder()
+
+			body := fmt.Sprintf(`{"email":%q}`, user.Email)
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/password-reset", bytes.NewBufferString(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, mailer)
+		})
+	}
+}
+
+func TestResetPasswordAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	token := util.RandomString(32)
+	password := util.RandomString(8)
+
+	testCases := []struct {
+		name          string
+		body          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: fmt.Sprintf(`{"token":%q,"password":%q}`, token, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ResetPasswordTxParams) (db.User, error) {
+						require.Equal(t, hashEmailToken(token), arg.HashedToken)
+						require.NoError(t, util.CheckPassword(password, arg.HashedPassword))
+						return user, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.NotContains(t, recorder.Body.String(), user.HashedPassword)
+			},
+		},
+		{
+			name: "InvalidToken",
+			body: fmt.Sprintf(`{"token":%q,"password":%q}`, token, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInvalidEmailToken.Code)
+			},
+		},
+		{
+			name: "ShortPassword",
+			body: fmt.Sprintf(`{"token":%q,"password":"abc"}`, token),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/password-reset/confirm", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
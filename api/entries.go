@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
 )
 
 type createEntryRequest struct {
@@ -13,44 +14,56 @@ type createEntryRequest struct {
 	Amount    int64 `json:"amount" binding:"required"`
 }
 
+// createEntry posts a manual adjustment to an account (admin). The balance
+// moves with the entry, so the ledger still reconciles.
 func (server *Server) createEntry(ctx *gin.Context) {
 	var req createEntryRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	arg := db.CreateEntriesParams{
+	result, ok := server.adjustBalance(ctx, db.AdjustBalanceTxParams{
 		AccountID: req.AccountID,
 		Amount:    req.Amount,
-	}
-
-	entry, err := server.store.CreateEntries(ctx, arg)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	})
+	if !ok {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, entry)
+	setAuditChange(ctx, auditTarget("entry", result.Entry.ID), nil, result)
+	ctx.JSON(http.StatusOK, result.Entry)
 }
 
-func (server *Server) getEntry(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
+type getEntryRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
 
+func (server *Server) getEntry(ctx *gin.Context) {
+	var req getEntryRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
 	entry, err := server.store.GetEntries(ctx, req.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	// admins see any entry, everyone else only those of their own accounts
+	account, err := server.store.GetAccount(ctx, entry.AccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if !canAccessUser(ctx, account.Owner) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
 		return
 	}
 
@@ -65,80 +78,30 @@ type listEntriesRequest struct {
 func (server *Server) listEntries(ctx *gin.Context) {
 	var req listEntriesRequest
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	arg := db.ListEntriesParams{
-		Limit:  req.PageSize,
-		Offset: (req.PageID - 1) * req.PageSize,
+	// admins list every entry, everyone else only those of their own accounts
+	var entries []db.Entry
+	var err error
+	payload, _ := authPayload(ctx)
+	if payload.Role == util.AdminRole {
+		entries, err = server.store.ListEntries(ctx, db.ListEntriesParams{
+			Limit:  req.PageSize,
+			Offset: (req.PageID - 1) * req.PageSize,
+		})
+	} else {
+		entries, err = server.store.ListOwnerEntries(ctx, db.ListOwnerEntriesParams{
+			Owner:  payload.Username,
+			Limit:  req.PageSize,
+			Offset: (req.PageID - 1) * req.PageSize,
+		})
 	}
-
-	entries, err := server.store.ListEntries(ctx, arg)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
 	ctx.JSON(http.StatusOK, entries)
 }
-
-type updateEntryRequest struct {
-	Amount int64 `json:"amount" binding:"required"`
-}
-
-func (server *Server) updateEntry(ctx *gin.Context) {
-	var reqURI struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
-	if err := ctx.ShouldBindUri(&reqURI); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
-
-	var reqBody updateEntryRequest
-	if err := ctx.ShouldBindJSON(&reqBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
-
-	arg := db.UpdateEntriesParams{
-		ID:     reqURI.ID,
-		Amount: reqBody.Amount,
-	}
-
-	entry, err := server.store.UpdateEntries(ctx, arg)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	ctx.JSON(http.StatusOK, entry)
-}
-
-func (server *Server) deleteEntry(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
-
-	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
-
-	err := server.store.DeleteEntries(ctx, req.ID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "Entry deleted successfully"})
-}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -34,15 +35,15 @@ func TestCreateEntry(t *testing.T) {
 				"amount":     entry.Amount,
 			},
 			buildStubs: func(store *mockdb.MockStore) {
-				arg := db.CreateEntriesParams{
+				arg := db.AdjustBalanceTxParams{
 					AccountID: entry.AccountID,
 					Amount:    entry.Amount,
 				}
 
 				store.EXPECT().
-					CreateEntries(gomock.Any(), gomock.Eq(arg)).
+					AdjustBalanceTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(entry, nil)
+					Return(db.AdjustBalanceTxResult{Entry: entry}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -57,13 +58,45 @@ func TestCreateEntry(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateEntries(gomock.Any(), gomock.Any()).
+					AdjustBalanceTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
+		{
+			name: "ClosedAccount",
+			body: gin.H{
+				"account_id": entry.AccountID,
+				"amount":     entry.Amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AdjustBalanceTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AdjustBalanceTxResult{}, fmt.Errorf("%w: account %d is closed", db.ErrAccountNotActive, entry.AccountID))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrAccountNotActive.Code)
+			},
+		},
+		{
+			name: "NegativeBalance",
+			body: gin.H{
+				"account_id": entry.AccountID,
+				"amount":     -entry.Amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AdjustBalanceTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AdjustBalanceTxResult{}, fmt.Errorf("%w: account %d would have balance -1", db.ErrNegativeBalance, entry.AccountID))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInsufficientBalance.Code)
+			},
+		},
 		{
 			name: "InternalError",
 			body: gin.H{
@@ -72,9 +105,9 @@ func TestCreateEntry(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateEntries(gomock.Any(), gomock.Any()).
+					AdjustBalanceTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.Entry{}, sql.ErrConnDone)
+					Return(db.AdjustBalanceTxResult{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -92,7 +125,7 @@ func TestCreateEntry(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			data, err := json.Marshal(tc.body)
@@ -102,6 +135,7 @@ func TestCreateEntry(t *testing.T) {
 			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
 			require.NoError(t, err)
 
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", util.AdminRole, time.Minute)
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
 		})
@@ -109,22 +143,32 @@ func TestCreateEntry(t *testing.T) {
 }
 
 func TestGetEntry(t *testing.T) {
+	account := RandomAccount()
 	entry := randomEntry()
+	entry.AccountID = account.ID
 
 	testCases := []struct {
 		name          string
 		entryID       int64
+		username      string
+		role          string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
-			name:    "OK",
-			entryID: entry.ID,
+			name:     "OK",
+			entryID:  entry.ID,
+			username: account.Owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetEntries(gomock.Any(), gomock.Eq(entry.ID)).
 					Times(1).
 					Return(entry, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -132,8 +176,61 @@ func TestGetEntry(t *testing.T) {
 			},
 		},
 		{
-			name:    "NotFound",
+			name:     "Admin",
+			entryID:  entry.ID,
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetEntries(gomock.Any(), gomock.Eq(entry.ID)).
+					Times(1).
+					Return(entry, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchEntry(t, recorder.Body, entry)
+			},
+		},
+		{
+			name:     "OtherOwner",
+			entryID:  entry.ID,
+			username: "mallory",
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetEntries(gomock.Any(), gomock.Eq(entry.ID)).
+					Times(1).
+					Return(entry, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:    "NoAuthorization",
 			entryID: entry.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetEntries(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			entryID:  entry.ID,
+			username: account.Owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetEntries(gomock.Any(), gomock.Eq(entry.ID)).
@@ -145,8 +242,10 @@ func TestGetEntry(t *testing.T) {
 			},
 		},
 		{
-			name:    "InternalError",
-			entryID: entry.ID,
+			name:     "InternalError",
+			entryID:  entry.ID,
+			username: account.Owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetEntries(gomock.Any(), gomock.Eq(entry.ID)).
@@ -169,12 +268,15 @@ func TestGetEntry(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			url := fmt.Sprintf("/api/v1/entries/%d", tc.entryID)
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			if tc.username != "" {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			}
 
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
@@ -183,6 +285,7 @@ func TestGetEntry(t *testing.T) {
 }
 
 func TestListEntries(t *testing.T) {
+	owner := util.RandomOwner()
 	n := 5
 	entries := make([]db.Entry, n)
 	for i := 0; i < n; i++ {
@@ -197,6 +300,8 @@ func TestListEntries(t *testing.T) {
 	testCases := []struct {
 		name          string
 		query         Query
+		username      string
+		role          string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
@@ -206,16 +311,22 @@ func TestListEntries(t *testing.T) {
 				pageID:   1,
 				pageSize: n,
 			},
+			username: owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
-				arg := db.ListEntriesParams{
+				arg := db.ListOwnerEntriesParams{
+					Owner:  owner,
 					Limit:  int32(n),
 					Offset: 0,
 				}
 
 				store.EXPECT().
-					ListEntries(gomock.Any(), gomock.Eq(arg)).
+					ListOwnerEntries(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
 					Return(entries, nil)
+				store.EXPECT().
+					ListEntries(gomock.Any(), gomock.Any()).
+					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -223,160 +334,60 @@ func TestListEntries(t *testing.T) {
 			},
 		},
 		{
-			name: "InternalError",
+			name: "Admin",
 			query: Query{
 				pageID:   1,
 				pageSize: n,
 			},
+			username: "admin",
+			role:     util.AdminRole,
 			buildStubs: func(store *mockdb.MockStore) {
-				store.EXPECT().
-					ListEntries(gomock.Any(), gomock.Any()).
-					Times(1).
-					Return([]db.Entry{}, sql.ErrConnDone)
-			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusInternalServerError, recorder.Code)
-			},
-		},
-	}
-
-	for i := range testCases {
-		tc := testCases[i]
-
-		t.Run(tc.name, func(t *testing.T) {
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
-
-			store := mockdb.NewMockStore(ctrl)
-			tc.buildStubs(store)
-
-			server := NewServer(store)
-			recorder := httptest.NewRecorder()
-
-			url := "/api/v1/entries"
-			request, err := http.NewRequest(http.MethodGet, url, nil)
-			require.NoError(t, err)
-
-			// Add query parameters to request URL
-			q := request.URL.Query()
-			q.Add("page_id", fmt.Sprintf("%d", tc.query.pageID))
-			q.Add("page_size", fmt.Sprintf("%d", tc.query.pageSize))
-			request.URL.RawQuery = q.Encode()
-
-			server.router.ServeHTTP(recorder, request)
-			tc.checkResponse(t, recorder)
-		})
-	}
-}
-
-func TestUpdateEntry(t *testing.T) {
-	entry := randomEntry()
-
-	testCases := []struct {
-		name          string
-		entryID       int64
-		body          gin.H
-		buildStubs    func(store *mockdb.MockStore)
-		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
-	}{
-		{
-			name:    "OK",
-			entryID: entry.ID,
-			body: gin.H{
-				"amount": entry.Amount + 100,
-			},
-			buildStubs: func(store *mockdb.MockStore) {
-				arg := db.UpdateEntriesParams{
-					ID:     entry.ID,
-					Amount: entry.Amount + 100,
+				arg := db.ListEntriesParams{
+					Limit:  int32(n),
+					Offset: 0,
 				}
 
 				store.EXPECT().
-					UpdateEntries(gomock.Any(), gomock.Eq(arg)).
+					ListEntries(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(entry, nil)
+					Return(entries, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchEntries(t, recorder.Body, entries)
 			},
 		},
 		{
-			name:    "NotFound",
-			entryID: entry.ID,
-			body: gin.H{
-				"amount": entry.Amount + 100,
+			name: "NoAuthorization",
+			query: Query{
+				pageID:   1,
+				pageSize: n,
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					UpdateEntries(gomock.Any(), gomock.Any()).
-					Times(1).
-					Return(db.Entry{}, sql.ErrNoRows)
+					ListOwnerEntries(gomock.Any(), gomock.Any()).
+					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
 		},
-	}
-
-	for i := range testCases {
-		tc := testCases[i]
-
-		t.Run(tc.name, func(t *testing.T) {
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
-
-			store := mockdb.NewMockStore(ctrl)
-			tc.buildStubs(store)
-
-			server := NewServer(store)
-			recorder := httptest.NewRecorder()
-
-			data, err := json.Marshal(tc.body)
-			require.NoError(t, err)
-
-			url := fmt.Sprintf("/api/v1/entries/%d", tc.entryID)
-			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
-			require.NoError(t, err)
-
-			server.router.ServeHTTP(recorder, request)
-			tc.checkResponse(t, recorder)
-		})
-	}
-}
-
-func TestDeleteEntry(t *testing.T) {
-	entry := randomEntry()
-
-	testCases := []struct {
-		name          string
-		entryID       int64
-		buildStubs    func(store *mockdb.MockStore)
-		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
-	}{
 		{
-			name:    "OK",
-			entryID: entry.ID,
-			buildStubs: func(store *mockdb.MockStore) {
-				store.EXPECT().
-					DeleteEntries(gomock.Any(), gomock.Eq(entry.ID)).
-					Times(1).
-					Return(nil)
-			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusOK, recorder.Code)
+			name: "InternalError",
+			query: Query{
+				pageID:   1,
+				pageSize: n,
 			},
-		},
-		{
-			name:    "NotFound",
-			entryID: entry.ID,
+			username: owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					DeleteEntries(gomock.Any(), gomock.Eq(entry.ID)).
+					ListOwnerEntries(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(sql.ErrNoRows)
+					Return([]db.Entry{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
 	}
@@ -391,12 +402,21 @@ func TestDeleteEntry(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
-			url := fmt.Sprintf("/api/v1/entries/%d", tc.entryID)
-			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			url := "/api/v1/entries"
+			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			if tc.username != "" {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			}
+
+			// Add query parameters to request URL
+			q := request.URL.Query()
+			q.Add("page_id", fmt.Sprintf("%d", tc.query.pageID))
+			q.Add("page_size", fmt.Sprintf("%d", tc.query.pageSize))
+			request.URL.RawQuery = q.Encode()
 
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// Error is the typed error carried in error response bodies. The client
+// package decodes responses back into it, so callers on both sides can
+// match errors with errors.Is against the sentinels below.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code,omitempty"`
+	Message    string `json:"error"`
+	// RequestID identifies the failed request in the server logs
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is an *Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Error codes shared by the server and the client
+var (
+	ErrInvalidRequest      = &Error{Code: "invalid_request", Message: "invalid request"}
+	ErrNotFound            = &Error{Code: "not_found", Message: "not found"}
+	ErrConflict            = &Error{Code: "conflict", Message: "conflict"}
+	ErrInsufficientBalance = &Error{Code: "insufficient_balance", Message: "insufficient balance"}
+	ErrCurrencyMismatch    = &Error{Code: "currency_mismatch", Message: "currency mismatch"}
+	ErrAccountFrozen       = &Error{Code: "account_frozen", Message: "account is frozen"}
+	ErrAccountNotActive    = &Error{Code: "account_not_active", Message: "account is not active"}
+	ErrAccountTransition   = &Error{Code: "invalid_account_transition", Message: "account status does not allow this"}
+	ErrTransferTransition  = &Error{Code: "invalid_transfer_transition", Message: "transfer status does not allow this"}
+	ErrTransferReversed    = &Error{Code: "transfer_reversed", Message: "the transfer has been reversed already"}
+	ErrTransferIsReversal  = &Error{Code: "transfer_is_reversal", Message: "a reversal cannot be reversed"}
+	ErrAccountNotEmpty     = &Error{Code: "account_not_empty", Message: "account balance is not zero"}
+	ErrUserDeleted         = &Error{Code: "user_deleted", Message: "user is deleted"}
+	ErrUserNotDeleted      = &Error{Code: "user_not_deleted", Message: "user is not deleted"}
+	ErrUserErased          = &Error{Code: "user_erased", Message: "user is already erased"}
+	ErrUserHasOpenAccounts = &Error{Code: "open_accounts", Message: "user has accounts that are not closed"}
+	ErrKYCNotPending       = &Error{Code: "kyc_not_pending", Message: "the KYC profile is not pending review"}
+	ErrTransferLimit       = &Error{Code: "transfer_limit_exceeded", Message: "transfer amount exceeds the limit of the KYC tier"}
+	ErrBalanceLimit        = &Error{Code: "balance_limit_exceeded", Message: "balance would exceed the limit of the KYC tier"}
+	ErrLimitExceeded       = &Error{Code: "limit_exceeded", Message: "transfer exceeds a transfer limit"}
+	ErrAlertClosed         = &Error{Code: "alert_closed", Message: "the monitoring alert is closed"}
+	ErrAlertNotHeld        = &Error{Code: "alert_not_held", Message: "the monitoring alert does not hold a transfer"}
+	ErrComplianceReview    = &Error{Code: "compliance_review", Message: "the request awaits a compliance review"}
+	ErrScreeningReviewed   = &Error{Code: "screening_reviewed", Message: "the screening hit was reviewed already"}
+	ErrTooFewApprovers     = &Error{Code: "too_few_approvers", Message: "the policy requires more approvals than it has approvers"}
+	ErrApprovalNotPending  = &Error{Code: "approval_not_pending", Message: "the transfer does not await approval"}
+	ErrApprovalExpired     = &Error{Code: "approval_expired", Message: "the transfer approval has expired"}
+	ErrApprovalDecided     = &Error{Code: "approval_decided", Message: "you already decided on the transfer"}
+	ErrRequestTooLarge     = &Error{Code: "request_too_large", Message: "request body too large"}
+	ErrIdempotencyMismatch = &Error{Code: "idempotency_key_reused", Message: "the idempotency key was used for a different request"}
+	ErrUnauthorized        = &Error{Code: "unauthorized", Message: "authentication required"}
+	ErrForbidden           = &Error{Code: "forbidden", Message: "permission denied"}
+	ErrInvalidCredentials  = &Error{Code: "invalid_credentials", Message: "invalid username or password"}
+	ErrUserLocked          = &Error{Code: "user_locked", Message: "too many failed logins, try again later"}
+	ErrTOTPRequired        = &Error{Code: "totp_required", Message: "two-factor code required"}
+	ErrInvalidTOTP         = &Error{Code: "invalid_totp", Message: "invalid two-factor code"}
+	ErrTOTPEnabled         = &Error{Code: "totp_enabled", Message: "two-factor authentication is already enabled"}
+	ErrTOTPNotEnrolled     = &Error{Code: "totp_not_enrolled", Message: "two-factor authentication is not set up"}
+	ErrStepUpRequired      = &Error{Code: "step_up_required", Message: "a fresh two-factor verification is required"}
+	ErrEmailNotVerified    = &Error{Code: "email_not_verified", Message: "the email of the account owner is not verified"}
+	ErrEmailVerified       = &Error{Code: "email_verified", Message: "the email is already verified"}
+	ErrInvalidEmailToken   = &Error{Code: "invalid_token", Message: "the link is invalid or has expired"}
+	ErrRateLimited         = &Error{Code: "rate_limited", Message: "too many requests"}
+	ErrInternal            = &Error{Code: "internal", Message: "internal error"}
+)
+
+// CodeForStatus returns the error code implied by an HTTP status when a
+// response carries no more specific code
+func CodeForStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return ErrUnauthorized.Code
+	case status == http.StatusForbidden:
+		return ErrForbidden.Code
+	case status == http.StatusLocked:
+		return ErrUserLocked.Code
+	case status == http.StatusNotFound:
+		return ErrNotFound.Code
+	case status == http.StatusConflict:
+		return ErrConflict.Code
+	case status == http.StatusRequestEntityTooLarge:
+		return ErrRequestTooLarge.Code
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited.Code
+	case status >= http.StatusInternalServerError:
+		return ErrInternal.Code
+	default:
+		return ErrInvalidRequest.Code
+	}
+}
+
+// errorCode finds the most specific code for err, or "" when there is none
+func errorCode(err error) string {
+	var apiErr *Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Code
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound.Code
+	case errors.Is(err, db.ErrAccountNotActive):
+		return ErrAccountNotActive.Code
+	case errors.Is(err, db.ErrInvalidAccountTransition):
+		return ErrAccountTransition.Code
+	case errors.Is(err, db.ErrInvalidTransferTransition):
+		return ErrTransferTransition.Code
+	case errors.Is(err, db.ErrTransferAlreadyReversed):
+		return ErrTransferReversed.Code
+	case errors.Is(err, db.ErrTransferIsReversal):
+		return ErrTransferIsReversal.Code
+	case errors.Is(err, db.ErrNegativeBalance):
+		return ErrInsufficientBalance.Code
+	case errors.Is(err, db.ErrAccountNotEmpty):
+		return ErrAccountNotEmpty.Code
+	case errors.Is(err, db.ErrUserDeleted):
+		return ErrUserDeleted.Code
+	case errors.Is(err, db.ErrUserNotDeleted):
+		return ErrUserNotDeleted.Code
+	case errors.Is(err, db.ErrUserErased):
+		return ErrUserErased.Code
+	case errors.Is(err, db.ErrUserHasOpenAccounts):
+		return ErrUserHasOpenAccounts.Code
+	case errors.Is(err, db.ErrTransferLimitExceeded):
+		return ErrTransferLimit.Code
+	case errors.Is(err, db.ErrBalanceLimitExceeded):
+		return ErrBalanceLimit.Code
+	case errors.Is(err, db.ErrLimitExceeded):
+		return ErrLimitExceeded.Code
+	case errors.Is(err, db.ErrAlertNotHeld):
+		return ErrAlertNotHeld.Code
+	case errors.Is(err, db.ErrTooFewApprovers):
+		return ErrTooFewApprovers.Code
+	case errors.Is(err, db.ErrApprovalNotPending):
+		return ErrApprovalNotPending.Code
+	case errors.Is(err, db.ErrApprovalExpired):
+		return ErrApprovalExpired.Code
+	case errors.Is(err, db.ErrApprovalDecided):
+		return ErrApprovalDecided.Code
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiiamanop/simple_bank/buildinfo"
+	"github.com/hiiamanop/simple_bank/health"
+)
+
+// WithReadiness makes /readyz run the checks of checker
+func WithReadiness(checker *health.Checker) Option {
+	return func(server *Server) {
+		server.readiness = checker
+	}
+}
+
+// healthz reports that the process is alive. It checks no dependencies, so
+// a database outage does not get the process restarted.
+func (server *Server) healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, health.Report{Status: health.StatusOK})
+}
+
+// readyz reports whether the server can take traffic
+func (server *Server) readyz(ctx *gin.Context) {
+	report := server.readiness.Run(ctx)
+	if !report.Ready() {
+		ctx.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// version reports the build of the running binary
+func (server *Server) version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hiiamanop/simple_bank/buildinfo"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	"github.com/hiiamanop/simple_bank/health"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	testCases := []struct {
+		name          string
+		path          string
+		checks        map[string]health.Check
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Healthz",
+			path: "/healthz",
+			checks: map[string]health.Check{
+				"database": func(ctx context.Context) error { return errors.New("down") },
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				// liveness does not depend on the database
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Ready",
+			path: "/readyz",
+			checks: map[string]health.Check{
+				"database": func(ctx context.Context) error { return nil },
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var report health.Report
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+				require.Equal(t, health.StatusOK, report.Checks["database"])
+			},
+		},
+		{
+			name: "NotReady",
+			path: "/readyz",
+			checks: map[string]health.Check{
+				"database":   func(ctx context.Context) error { return nil },
+				"migrations": func(ctx context.Context) error { return errors.New("database at version 3, expected 4") },
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				var report health.Report
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+				require.Equal(t, health.StatusUnavailable, report.Status)
+				require.Equal(t, "database at version 3, expected 4", report.Checks["migrations"])
+			},
+		},
+		{
+			name: "Version",
+			path: "/version",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var info buildinfo.Info
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &info))
+				require.Equal(t, buildinfo.Get(), info)
+				require.NotEmpty(t, info.GoVersion)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			checker := health.NewChecker(time.Second)
+			for name, check := range tc.checks {
+				checker.Add(name, check)
+			}
+
+			server := newTestServer(t, mockdb.NewMockStore(ctrl), WithReadiness(checker))
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
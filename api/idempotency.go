@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader lets clients safely retry POST requests: a repeated
+// key replays the first response instead of executing the request again.
+// Keys are scoped to the caller, and a key can only be repeated with the
+// same body.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	idempotencyKeyTTL          = 24 * time.Hour
+	idempotencyJanitorInterval = time.Minute
+)
+
+var errRequestInProgress = errors.New("a request with this idempotency key is still in progress")
+
+type idempotentResponse struct {
+	inFlight bool
+	// requestHash is the SHA-256 of the body of the first request
+	requestHash string
+	status      int
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+// idempotencyCache remembers responses to POST requests by idempotency key
+type idempotencyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	responses map[string]*idempotentResponse
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:       ttl,
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+// begin returns the stored response for key, or reserves the key for the
+// request with requestHash and returns nil if the key has not been seen
+// before
+func (cache *idempotencyCache) begin(key, requestHash string) *idempotentResponse {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if response, ok := cache.responses[key]; ok {
+		return response
+	}
+
+	cache.responses[key] = &idempotentResponse{inFlight: true, requestHash: requestHash}
+	return nil
+}
+
+// finish stores the response to the request with requestHash for key.
+// Server errors are stored too: the handler may have changed data before it
+// failed, and a retry must not execute the request a second time.
+func (cache *idempotencyCache) finish(key, requestHash string, status int, contentType string, body []byte) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.responses[key] = &idempotentResponse{
+		requestHash: requestHash,
+		status:      status,
+		contentType: contentType,
+		body:        body,
+		expiresAt:   time.Now().Add(cache.ttl),
+	}
+}
+
+// prune drops the responses that expired before now
+func (cache *idempotencyCache) prune(now time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for key, response := range cache.responses {
+		if !response.inFlight && now.After(response.expiresAt) {
+			delete(cache.responses, key)
+		}
+	}
+}
+
+// PruneIdempotencyKeys periodically forgets expired idempotency keys until
+// ctx is cancelled. It is meant to run as a background worker.
+func (server *Server) PruneIdempotencyKeys(ctx context.Context) error {
+	ticker := time.NewTicker(idempotencyJanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			server.idempotency.prune(now)
+		}
+	}
+}
+
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func idempotencyMiddleware(cache *idempotencyCache) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" || ctx.Request.Method != http.MethodPost {
+			ctx.Next()
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(ctx, err))
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+
+		// callers cannot see or block each other's keys
+		cacheKey := callerKey(ctx) + " " + ctx.Request.URL.Path + " " + key
+		if cached := cache.begin(cacheKey, requestHash); cached != nil {
+			if cached.requestHash != requestHash {
+				ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, errorResponse(ctx, ErrIdempotencyMismatch))
+				return
+			}
+			if cached.inFlight {
+				ctx.AbortWithStatusJSON(http.StatusConflict, errorResponse(ctx, errRequestInProgress))
+				return
+			}
+			ctx.Header("Idempotent-Replayed", "true")
+			ctx.Data(cached.status, cached.contentType, cached.body)
+			ctx.Abort()
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				// the recovery middleware answers with the same body
+				body, _ := json.Marshal(errorResponse(ctx, ErrInternal))
+				cache.finish(cacheKey, requestHash, http.StatusInternalServerError, "application/json; charset=utf-8", body)
+				panic(r)
+			}
+		}()
+
+		writer := &recordingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		ctx.Next()
+
+		cache.finish(cacheKey, requestHash, writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	account := RandomAccount()
+
+	testCases := []struct {
+		name string
+		keys []string
+		// currencies and callers vary the body and the caller of each
+		// request, if set
+		currencies    []string
+		callers       []string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorders []*httptest.ResponseRecorder)
+	}{
+		{
+			name: "ReplaysSameKey",
+			keys: []string{"key-1", "key-1"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorders[0].Code)
+				require.Equal(t, http.StatusOK, recorders[1].Code)
+				require.Equal(t, "true", recorders[1].Header().Get("Idempotent-Replayed"))
+				require.Equal(t, recorders[0].Body.String(), recorders[1].Body.String())
+			},
+		},
+		{
+			name: "DifferentKeys",
+			keys: []string{"key-1", "key-2"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				require.Empty(t, recorders[1].Header().Get("Idempotent-Replayed"))
+			},
+		},
+		{
+			name:       "DifferentBody",
+			keys:       []string{"key-1", "key-1"},
+			currencies: []string{"USD", "EUR"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorders[0].Code)
+				requireErrorCode(t, recorders[1], http.StatusUnprocessableEntity, ErrIdempotencyMismatch.Code)
+			},
+		},
+		{
+			name:    "OtherCaller",
+			keys:    []string{"key-1", "key-1"},
+			callers: []string{"alice", "bob"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorders[1].Code)
+				require.Empty(t, recorders[1].Header().Get("Idempotent-Replayed"))
+			},
+		},
+		{
+			name: "NoKey",
+			keys: []string{"", ""},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorders[1].Code)
+			},
+		},
+		{
+			name: "ServerErrorIsReplayed",
+			keys: []string{"key-1", "key-1"},
+			buildStubs: func(store *mockdb.MockStore) {
+				// the store may have committed before the error
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Account{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorders[0].Code)
+				require.Equal(t, http.StatusInternalServerError, recorders[1].Code)
+				require.Equal(t, "true", recorders[1].Header().Get("Idempotent-Replayed"))
+				require.Equal(t, recorders[0].Body.String(), recorders[1].Body.String())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+
+			var recorders []*httptest.ResponseRecorder
+			for j, key := range tc.keys {
+				currency := account.Currency
+				if tc.currencies != nil {
+					currency = tc.currencies[j]
+				}
+				caller := account.Owner
+				if tc.callers != nil {
+					caller = tc.callers[j]
+				}
+				data, err := json.Marshal(gin.H{
+					"currency": currency,
+				})
+				require.NoError(t, err)
+
+				recorder := httptest.NewRecorder()
+				request, err := http.NewRequest(http.MethodPost, "/api/v1/accounts", bytes.NewReader(data))
+				require.NoError(t, err)
+				if key != "" {
+					request.Header.Set(IdempotencyKeyHeader, key)
+				}
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, caller, util.DepositorRole, time.Minute)
+
+				server.router.ServeHTTP(recorder, request)
+				recorders = append(recorders, recorder)
+			}
+
+			tc.checkResponse(t, recorders)
+		})
+	}
+}
+
+func TestIdempotencyCachePrune(t *testing.T) {
+	cache := newIdempotencyCache(time.Minute)
+
+	require.Nil(t, cache.begin("done", "hash"))
+	cache.finish("done", "hash", http.StatusOK, "application/json", []byte("{}"))
+	require.Nil(t, cache.begin("in-flight", "hash"))
+
+	cache.prune(time.Now().Add(2 * time.Minute))
+
+	require.Nil(t, cache.begin("done", "hash"))
+	require.NotNil(t, cache.begin("in-flight", "hash"))
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// kycProfileResponse is a KYC profile as shown to its user and to reviewers
+type kycProfileResponse struct {
+	Username        string     `json:"username"`
+	LegalName       string     `json:"legal_name"`
+	DateOfBirth     string     `json:"date_of_birth"`
+	Address         string     `json:"address"`
+	DocumentType    string     `json:"document_type"`
+	DocumentNumber  string     `json:"document_number"`
+	DocumentCountry string     `json:"document_country"`
+	Status          string     `json:"status"`
+	Tier            int32      `json:"tier"`
+	ReviewNote      string     `json:"review_note,omitempty"`
+	ReviewedBy      string     `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	SubmittedAt     time.Time  `json:"submitted_at"`
+}
+
+func newKYCProfileResponse(profile db.KycProfile) kycProfileResponse {
+	return kycProfileResponse{
+		Username:        profile.Username,
+		LegalName:       profile.LegalName,
+		DateOfBirth:     profile.DateOfBirth,
+		Address:         profile.Address,
+		DocumentType:    profile.DocumentType,
+		DocumentNumber:  profile.DocumentNumber,
+		DocumentCountry: profile.DocumentCountry,
+		Status:          profile.Status,
+		Tier:            profile.Tier,
+		ReviewNote:      profile.ReviewNote,
+		ReviewedBy:      profile.ReviewedBy,
+		ReviewedAt:      nullTime(profile.ReviewedAt),
+		SubmittedAt:     profile.SubmittedAt,
+	}
+}
+
+// kycAuditState is what the audit log keeps of a KYC profile. The log
+// cannot be erased, so it leaves out the personal data.
+type kycAuditState struct {
+	Status          string `json:"status"`
+	Tier            int32  `json:"tier"`
+	DocumentType    string `json:"document_type"`
+	DocumentCountry string `json:"document_country"`
+	ReviewedBy      string `json:"reviewed_by,omitempty"`
+}
+
+// newKYCAuditState returns the audit state of profile, or nil for a missing
+// profile
+func newKYCAuditState(profile *db.KycProfile) any {
+	if profile == nil {
+		return nil
+	}
+	return kycAuditState{
+		Status:          profile.Status,
+		Tier:            profile.Tier,
+		DocumentType:    profile.DocumentType,
+		DocumentCountry: profile.DocumentCountry,
+		ReviewedBy:      profile.ReviewedBy,
+	}
+}
+
+type submitKYCProfileRequest struct {
+	LegalName       string `json:"legal_name" binding:"required,max=200"`
+	DateOfBirth     string `json:"date_of_birth" binding:"required,datetime=2006-01-02"`
+	Address         string `json:"address" binding:"required,max=500"`
+	DocumentType    string `json:"document_type" binding:"required,oneof=passport national_id driving_licence"`
+	DocumentNumber  string `json:"document_number" binding:"required,alphanum,max=50"`
+	DocumentCountry string `json:"document_country" binding:"required,iso3166_1_alpha2"`
+}
+
+// submitKYCProfile creates or replaces the KYC profile of a user, which then
+// waits for review. Users submit their own profile, admins anyone's.
+func (server *Server) submitKYCProfile(ctx *gin.Context) {
+	var uri getUserRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req submitKYCProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if !canAccessUser(ctx, uri.Username) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	// the binding checked the format
+	dateOfBirth, _ := time.Parse(time.DateOnly, req.DateOfBirth)
+	if dateOfBirth.After(time.Now()) {
+		err := fmt.Errorf("%w: date_of_birth is in the future", ErrInvalidRequest)
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, uri.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if user.DeletedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(ctx, ErrUserDeleted))
+		return
+	}
+
+	var before *db.KycProfile
+	if profile, err := server.store.GetKYCProfile(ctx, uri.Username); err == nil {
+		before = &profile
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	profile, err := server.store.SubmitKYCProfile(ctx, db.SubmitKYCProfileParams{
+		Username:        uri.Username,
+		LegalName:       req.LegalName,
+		DateOfBirth:     req.DateOfBirth,
+		Address:         req.Address,
+		DocumentType:    req.DocumentType,
+		DocumentNumber:  req.DocumentNumber,
+		DocumentCountry: req.DocumentCountry,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("user", uri.Username), newKYCAuditState(before), newKYCAuditState(&profile))
+	ctx.JSON(http.StatusOK, newKYCProfileResponse(profile))
+}
+
+func (server *Server) getKYCProfile(ctx *gin.Context) {
+	var req getUserRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if !canAccessUser(ctx, req.Username) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	profile, err := server.store.GetKYCProfile(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newKYCProfileResponse(profile))
+}
+
+type listKYCProfilesRequest struct {
+	Status   string `form:"status" binding:"omitempty,oneof=pending verified rejected"`
+	PageID   int32  `form:"page_id" binding:"required,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=50"`
+}
+
+// listKYCProfiles lists profiles oldest submission first, which with
+// status=pending is the review queue
+func (server *Server) listKYCProfiles(ctx *gin.Context) {
+	var req listKYCProfilesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	profiles, err := server.store.ListKYCProfiles(ctx, db.ListKYCProfilesParams{
+		Status: sql.NullString{String: req.Status, Valid: req.Status != ""},
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := make([]kycProfileResponse, len(profiles))
+	for i, profile := range profiles {
+		rsp[i] = newKYCProfileResponse(profile)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type approveKYCProfileRequest struct {
+	Tier int32  `json:"tier" binding:"required,min=1"`
+	Note string `json:"note" binding:"max=500"`
+}
+
+// approveKYCProfile verifies a pending profile and moves its user to tier
+func (server *Server) approveKYCProfile(ctx *gin.Context) {
+	var uri getUserRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req approveKYCProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if _, err := server.store.GetKYCTier(ctx, req.Tier); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err := fmt.Errorf("%w: there is no tier %d", ErrInvalidRequest, req.Tier)
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	server.reviewKYCProfile(ctx, db.ReviewKYCProfileParams{
+		Status:     db.KYCStatusVerified,
+		Tier:       req.Tier,
+		ReviewNote: req.Note,
+		Username:   uri.Username,
+	})
+}
+
+type rejectKYCProfileRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// rejectKYCProfile rejects a pending profile, leaving its user in tier 0.
+// The user can submit a corrected profile.
+func (server *Server) rejectKYCProfile(ctx *gin.Context) {
+	var uri getUserRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req rejectKYCProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	server.reviewKYCProfile(ctx, db.ReviewKYCProfileParams{
+		Status:     db.KYCStatusRejected,
+		ReviewNote: req.Reason,
+		Username:   uri.Username,
+	})
+}
+
+// reviewKYCProfile records the decision of the caller on a pending profile.
+// Nobody reviews their own profile.
+func (server *Server) reviewKYCProfile(ctx *gin.Context, arg db.ReviewKYCProfileParams) {
+	payload, _ := authPayload(ctx)
+	if payload.Username == arg.Username {
+		err := fmt.Errorf("%w: cannot review your own profile", ErrForbidden)
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, err))
+		return
+	}
+	arg.ReviewedBy = payload.Username
+
+	before, err := server.store.GetKYCProfile(ctx, arg.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	profile, err := server.store.ReviewKYCProfile(ctx, arg)
+	if err != nil {
+		// the profile exists, so it was reviewed already
+		if errors.Is(err, sql.ErrNoRows) {
+			err := fmt.Errorf("%w: profile of %s is %s", ErrKYCNotPending, arg.Username, before.Status)
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("user", arg.Username), newKYCAuditState(&before), newKYCAuditState(&profile))
+	ctx.JSON(http.StatusOK, newKYCProfileResponse(profile))
+}
+
+// listKYCTiers lists the tiers and their limits
+func (server *Server) listKYCTiers(ctx *gin.Context) {
+	tiers, err := server.store.ListKYCTiers(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	ctx.JSON(http.StatusOK, tiers)
+}
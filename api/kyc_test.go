@@ -0,0 +1,370 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomKYCProfile(username string) db.KycProfile {
+	return db.KycProfile{
+		Username:        username,
+		LegalName:       util.RandomOwner(),
+		DateOfBirth:     "1990-01-31",
+		Address:         "Main Street 1, Berlin",
+		DocumentType:    db.KYCDocumentPassport,
+		DocumentNumber:  util.RandomString(9),
+		DocumentCountry: "DE",
+		Status:          db.KYCStatusPending,
+		SubmittedAt:     time.Now(),
+	}
+}
+
+func TestSubmitKYCProfileAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	profile := randomKYCProfile(user.Username)
+	body := gin.H{
+		"legal_name":       profile.LegalName,
+		"date_of_birth":    profile.DateOfBirth,
+		"address":          profile.Address,
+		"document_type":    profile.DocumentType,
+		"document_number":  profile.DocumentNumber,
+		"document_country": profile.DocumentCountry,
+	}
+	withBody := func(key string, value any) gin.H {
+		changed := gin.H{}
+		for k, v := range body {
+			changed[k] = v
+		}
+		changed[key] = value
+		return changed
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			body:     body,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetKYCProfile(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.KycProfile{}, sql.ErrNoRows)
+				store.EXPECT().
+					SubmitKYCProfile(gomock.Any(), gomock.Eq(db.SubmitKYCProfileParams{
+						Username:        user.Username,
+						LegalName:       profile.LegalName,
+						DateOfBirth:     profile.DateOfBirth,
+						Address:         profile.Address,
+						DocumentType:    profile.DocumentType,
+						DocumentNumber:  profile.DocumentNumber,
+						DocumentCountry: profile.DocumentCountry,
+					})).
+					Times(1).
+					Return(profile, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "kyc.submitted", arg.Action)
+						require.Equal(t, "user:"+user.Username, arg.Target)
+						require.Nil(t, arg.Before)
+						// the audit log cannot be erased, so it holds no personal data
+						require.NotContains(t, string(arg.After), profile.LegalName)
+						require.NotContains(t, string(arg.After), profile.DocumentNumber)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp kycProfileResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, profile.LegalName, rsp.LegalName)
+				require.Equal(t, db.KYCStatusPending, rsp.Status)
+				require.Nil(t, rsp.ReviewedAt)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: "mallory",
+			body:     body,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SubmitKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "InvalidDocumentType",
+			username: user.Username,
+			body:     withBody("document_type", "library_card"),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SubmitKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidCountry",
+			username: user.Username,
+			body:     withBody("document_country", "XX"),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SubmitKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "FutureDateOfBirth",
+			username: user.Username,
+			body:     withBody("date_of_birth", time.Now().AddDate(1, 0, 0).Format(time.DateOnly)),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SubmitKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInvalidRequest.Code)
+			},
+		},
+		{
+			name:     "DeletedUser",
+			username: user.Username,
+			body:     body,
+			buildStubs: func(store *mockdb.MockStore) {
+				deleted := user
+				deleted.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(deleted, nil)
+				store.EXPECT().SubmitKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrUserDeleted.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := "/api/v1/users/" + user.Username + "/kyc"
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestReviewKYCProfileAPI(t *testing.T) {
+	profile := randomKYCProfile(util.RandomOwner())
+	verified := profile
+	verified.Status = db.KYCStatusVerified
+	verified.Tier = 2
+	verified.ReviewedBy = "admin"
+	verified.ReviewedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		action        string
+		reviewer      string
+		role          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Approve",
+			action:   "approve",
+			reviewer: "admin",
+			role:     util.AdminRole,
+			body:     gin.H{"tier": 2, "note": "passport checked"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetKYCTier(gomock.Any(), gomock.Eq(int32(2))).Times(1).Return(db.KycTier{Tier: 2}, nil)
+				store.EXPECT().GetKYCProfile(gomock.Any(), gomock.Eq(profile.Username)).Times(1).Return(profile, nil)
+				store.EXPECT().
+					ReviewKYCProfile(gomock.Any(), gomock.Eq(db.ReviewKYCProfileParams{
+						Status:     db.KYCStatusVerified,
+						Tier:       2,
+						ReviewNote: "passport checked",
+						ReviewedBy: "admin",
+						Username:   profile.Username,
+					})).
+					Times(1).
+					Return(verified, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp kycProfileResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.KYCStatusVerified, rsp.Status)
+				require.Equal(t, int32(2), rsp.Tier)
+				require.NotNil(t, rsp.ReviewedAt)
+			},
+		},
+		{
+			name:     "Reject",
+			action:   "reject",
+			reviewer: "admin",
+			role:     util.AdminRole,
+			body:     gin.H{"reason": "document expired"},
+			buildStubs: func(store *mockdb.MockStore) {
+				rejected := profile
+				rejected.Status = db.KYCStatusRejected
+				store.EXPECT().GetKYCProfile(gomock.Any(), gomock.Eq(profile.Username)).Times(1).Return(profile, nil)
+				store.EXPECT().
+					ReviewKYCProfile(gomock.Any(), gomock.Eq(db.ReviewKYCProfileParams{
+						Status:     db.KYCStatusRejected,
+						ReviewNote: "document expired",
+						ReviewedBy: "admin",
+						Username:   profile.Username,
+					})).
+					Times(1).
+					Return(rejected, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "RejectWithoutReason",
+			action:   "reject",
+			reviewer: "admin",
+			role:     util.AdminRole,
+			body:     gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ReviewKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "UnknownTier",
+			action:   "approve",
+			reviewer: "admin",
+			role:     util.AdminRole,
+			body:     gin.H{"tier": 9},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetKYCTier(gomock.Any(), gomock.Eq(int32(9))).Times(1).Return(db.KycTier{}, sql.ErrNoRows)
+				store.EXPECT().ReviewKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInvalidRequest.Code)
+			},
+		},
+		{
+			name:     "OwnProfile",
+			action:   "reject",
+			reviewer: profile.Username,
+			role:     util.AdminRole,
+			body:     gin.H{"reason": "no"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ReviewKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "NotAdmin",
+			action:   "approve",
+			reviewer: "mallory",
+			role:     util.DepositorRole,
+			body:     gin.H{"tier": 2},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ReviewKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "NotPending",
+			action:   "approve",
+			reviewer: "admin",
+			role:     util.AdminRole,
+			body:     gin.H{"tier": 2},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetKYCTier(gomock.Any(), gomock.Any()).Times(1).Return(db.KycTier{Tier: 2}, nil)
+				store.EXPECT().GetKYCProfile(gomock.Any(), gomock.Eq(profile.Username)).Times(1).Return(verified, nil)
+				store.EXPECT().ReviewKYCProfile(gomock.Any(), gomock.Any()).Times(1).Return(db.KycProfile{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrKYCNotPending.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			action:   "reject",
+			reviewer: "admin",
+			role:     util.AdminRole,
+			body:     gin.H{"reason": "no"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetKYCProfile(gomock.Any(), gomock.Any()).Times(1).Return(db.KycProfile{}, sql.ErrNoRows)
+				store.EXPECT().ReviewKYCProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := "/api/v1/kyc/profiles/" + profile.Username + "/" + tc.action
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.reviewer, tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyLimitMiddleware rejects request bodies larger than maxBytes with 413.
+// The body is read up front so that handlers never see a truncated payload
+// as a generic bind error. A limit of 0 or less disables the check.
+func bodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if maxBytes <= 0 || ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+			ctx.Next()
+			return
+		}
+
+		if ctx.Request.ContentLength > maxBytes {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errorResponse(ctx, ErrRequestTooLarge))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errorResponse(ctx, ErrRequestTooLarge))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(ctx, err))
+			return
+		}
+
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		ctx.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hiiamanop/simple_bank/logging"
+)
+
+// RequestIDHeader carries the ID that correlates a request with its logs,
+// traces and error response
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits caller supplied IDs to characters that are safe to
+// log and echo back
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+// requestIDMiddleware accepts the caller's X-Request-ID or generates one,
+// echoes it in the response and stores it in the request context, from
+// where it reaches the logs of the handlers and the store
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		ctx.Header(RequestIDHeader, id)
+		ctx.Request = ctx.Request.WithContext(logging.WithRequestID(ctx.Request.Context(), id))
+		ctx.Next()
+	}
+}
+
+// loggingMiddleware writes one record per request. The query string is left
+// out because it may carry credentials.
+func loggingMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("route", ctx.FullPath()),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", ctx.ClientIP()),
+			slog.Int("bytes", ctx.Writer.Size()),
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", ctx.Errors.Last().Error()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// recoveryMiddleware turns a panic into a logged 500 response
+func recoveryMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(ctx *gin.Context, recovered any) {
+		logger.ErrorContext(ctx, "panic while serving request",
+			slog.Any("panic", recovered),
+			slog.String("stack", string(debug.Stack())),
+		)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(ctx, ErrInternal))
+	})
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/logging"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	account := RandomAccount()
+
+	testCases := []struct {
+		name          string
+		requestID     string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "Accepted",
+			requestID: "client-id-123",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, "client-id-123", recorder.Header().Get(RequestIDHeader))
+			},
+		},
+		{
+			name: "Generated",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Len(t, recorder.Header().Get(RequestIDHeader), 36)
+			},
+		},
+		{
+			name:      "InvalidReplaced",
+			requestID: "bad id\nwith newline",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				id := recorder.Header().Get(RequestIDHeader)
+				require.NotEqual(t, "bad id\nwith newline", id)
+				require.Len(t, id, 36)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+				Times(1).
+				DoAndReturn(func(ctx context.Context, id int64) (db.Account, error) {
+					// the store sees the same ID as the client
+					require.NotEmpty(t, logging.RequestID(ctx))
+					return db.Account{}, sql.ErrNoRows
+				})
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/accounts/%d", account.ID), nil)
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, util.DepositorRole, time.Minute)
+			if tc.requestID != "" {
+				request.Header.Set(RequestIDHeader, tc.requestID)
+			}
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+			tc.checkResponse(t, recorder)
+
+			var body Error
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			require.Equal(t, recorder.Header().Get(RequestIDHeader), body.RequestID)
+		})
+	}
+}
+
+func TestRequestLogIsRedacted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	email := "jane.doe@example.com"
+	password := "secret123"
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateUser(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.User{}, fmt.Errorf("user with email %s already exists", email))
+
+	var logs bytes.Buffer
+	server := newTestServer(t, store, WithLogger(logging.New(&logs, slog.LevelInfo)))
+
+	data, err := json.Marshal(gin.H{
+		"username":  "jane",
+		"password":  password,
+		"full_name": "Jane Doe",
+		"email":     email,
+	})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/api/v1/users?token=abc", bytes.NewReader(data))
+	require.NoError(t, err)
+	request.Header.Set(RequestIDHeader, "req-42")
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	line := logs.String()
+	require.NotContains(t, line, email)
+	require.NotContains(t, line, password)
+	require.NotContains(t, line, "token=abc")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(line)), &record))
+	require.Equal(t, "req-42", record["request_id"])
+	require.Equal(t, "ERROR", record["level"])
+	require.Equal(t, "/api/v1/users", record["route"])
+	require.Equal(t, "user with email j***@example.com already exists", record["error"])
+}
+
+func TestRecoverPanic(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	account := RandomAccount()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, id int64) (db.Account, error) {
+			panic("boom")
+		})
+
+	var logs bytes.Buffer
+	server := newTestServer(t, store, WithLogger(logging.New(&logs, slog.LevelInfo)))
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/accounts/%d", account.ID), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, util.DepositorRole, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	requireErrorCode(t, recorder, http.StatusInternalServerError, ErrInternal.Code)
+	require.Contains(t, logs.String(), "panic while serving request")
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/token"
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+type loginUserRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+	Password string `json:"password" binding:"required,min=6"`
+	// TOTPCode or RecoveryCode is required once the user enabled 2FA
+	TOTPCode     string `json:"totp_code" binding:"omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type loginUserResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+	// StepUpToken is issued when the login was verified with a second
+	// factor
+	StepUpToken          string       `json:"step_up_token,omitempty"`
+	StepUpTokenExpiresAt *time.Time   `json:"step_up_token_expires_at,omitempty"`
+	User                 userResponse `json:"user"`
+}
+
+// dummyPasswordHash is compared against when the user does not exist, so
+// that unknown usernames take as long as wrong passwords
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := util.HashPassword("not the password")
+	return hash
+})
+
+// loginUser exchanges a username and password for an access token. Every
+// failure is answered after a delay that doubles with each consecutive
+// failure, and LoginMaxAttempts failures lock the user out for
+// LoginLockoutDuration. A locked user gets the same 401 as anyone with a
+// wrong password, so the lockout does not reveal that the user exists;
+// only the right password learns about it. Users with 2FA also need a
+// TOTP or recovery code, and then get a step-up token too.
+func (server *Server) loginUser(ctx *gin.Context) {
+	var req loginUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, req.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	// a deleted user is answered like one that never existed
+	if err != nil || user.DeletedAt.Valid {
+		_ = util.CheckPassword(req.Password, dummyPasswordHash())
+		server.rejectLogin(ctx, 1, ErrInvalidCredentials)
+		return
+	}
+
+	lockedFor := time.Until(user.LockedUntil)
+	if err := util.CheckPassword(req.Password, user.HashedPassword); err != nil {
+		// failures during a lockout are not counted, so they cannot extend it
+		if lockedFor > 0 {
+			server.rejectLogin(ctx, user.FailedLoginAttempts, ErrInvalidCredentials)
+			return
+		}
+		server.failLogin(ctx, user, ErrInvalidCredentials)
+		return
+	}
+
+	if lockedFor > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(lockedFor)))
+		ctx.JSON(http.StatusLocked, errorResponse(ctx, ErrUserLocked))
+		return
+	}
+
+	if user.TotpEnabled {
+		if req.TOTPCode == "" && req.RecoveryCode == "" {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(ctx, ErrTOTPRequired))
+			return
+		}
+		ok, err := server.verifySecondFactor(ctx, user, req.TOTPCode, req.RecoveryCode)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+			return
+		}
+		if !ok {
+			server.failLogin(ctx, user, ErrInvalidTOTP)
+			return
+		}
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		user, err = server.store.ResetFailedLogins(ctx, user.Username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+			return
+		}
+	}
+
+	accessToken, payload, err := server.tokenMaker.CreateToken(user.Username, user.Role, token.PurposeAccess, server.config.AccessTokenDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := loginUserResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: payload.ExpiredAt,
+		User:                 newUserResponse(user),
+	}
+	if user.TotpEnabled {
+		stepUpToken, stepUpPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, token.PurposeStepUp, server.config.StepUpTokenDuration)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+			return
+		}
+		rsp.StepUpToken = stepUpToken
+		rsp.StepUpTokenExpiresAt = &stepUpPayload.ExpiredAt
+	}
+
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, nil)
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// failLogin counts a failed password or second factor of user, locks the
+// user out on the last allowed attempt and rejects the request
+func (server *Server) failLogin(ctx *gin.Context, user db.User, rejection *Error) {
+	user, err := server.store.RecordFailedLogin(ctx, db.RecordFailedLoginParams{
+		MaxAttempts: int32(server.config.LoginMaxAttempts),
+		LockUntil:   time.Now().Add(server.config.LoginLockoutDuration),
+		Username:    user.Username,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	// the user was not locked before, so a lock now is a new lockout
+	if time.Until(user.LockedUntil) > 0 {
+		server.audit(ctx, auditUserLocked, auditTarget("user", user.Username), nil, gin.H{
+			"failed_login_attempts": user.FailedLoginAttempts,
+			"locked_until":          user.LockedUntil,
+		})
+	}
+	server.rejectLogin(ctx, user.FailedLoginAttempts, rejection)
+}
+
+// rejectLogin answers a failed login with 401 after the delay earned by
+// the consecutive failures
+func (server *Server) rejectLogin(ctx *gin.Context, failedAttempts int32, rejection *Error) {
+	timer := time.NewTimer(loginFailureDelay(server.config, failedAttempts))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Request.Context().Done():
+	}
+
+	ctx.JSON(http.StatusUnauthorized, errorResponse(ctx, rejection))
+}
+
+// loginFailureDelay starts at LoginFailureDelay for the first failure and
+// doubles with each further one, up to LoginMaxFailureDelay
+func loginFailureDelay(config util.Config, failedAttempts int32) time.Duration {
+	delay := config.LoginFailureDelay
+	for i := int32(1); i < failedAttempts && delay < config.LoginMaxFailureDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, config.LoginMaxFailureDelay)
+}
+
+// unlockUser lifts a lockout before it expires and resets the failed
+// login count
+func (server *Server) unlockUser(ctx *gin.Context) {
+	var req getUserRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	user, err := server.store.ResetFailedLogins(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, newUserAuditState(user))
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
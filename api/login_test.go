@@ -0,0 +1,302 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/token"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomUser(t *testing.T) (user db.User, password string) {
+	password = util.RandomString(6)
+	hashedPassword, err := util.HashPassword(password)
+	require.NoError(t, err)
+
+	user = db.User{
+		Username:       util.RandomOwner(),
+		Role:           util.DepositorRole,
+		HashedPassword: hashedPassword,
+		FullName:       util.RandomOwner(),
+		Email:          util.RandomString(6) + "@example.com",
+	}
+	return
+}
+
+func TestLoginUserAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: fmt.Sprintf(`{"username":%q,"password":%q}`, user.Username, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp.AccessToken)
+				require.Equal(t, user.Username, rsp.User.Username)
+				require.NotContains(t, recorder.Body.String(), user.HashedPassword)
+			},
+		},
+		{
+			name: "ResetsFailedAttempts",
+			body: fmt.Sprintf(`{"username":%q,"password":%q}`, user.Username, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				failed := user
+				failed.FailedLoginAttempts = 2
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(failed, nil)
+				store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: fmt.Sprintf(`{"username":%q,"password":%q}`, user.Username, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidCredentials.Code)
+			},
+		},
+		{
+			name: "DeletedUser",
+			body: fmt.Sprintf(`{"username":%q,"password":%q}`, user.Username, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				deleted := user
+				deleted.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(deleted, nil)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidCredentials.Code)
+			},
+		},
+		{
+			name: "IncorrectPassword",
+			body: fmt.Sprintf(`{"username":%q,"password":"wrong-password"}`, user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				failed := user
+				failed.FailedLoginAttempts = 1
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					RecordFailedLogin(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RecordFailedLoginParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, int32(3), arg.MaxAttempts)
+						require.WithinDuration(t, time.Now().Add(time.Minute), arg.LockUntil, time.Second)
+						return failed, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidCredentials.Code)
+			},
+		},
+		{
+			name: "LockedOnLastAttempt",
+			body: fmt.Sprintf(`{"username":%q,"password":"wrong-password"}`, user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				locked := user
+				locked.FailedLoginAttempts = 3
+				locked.LockedUntil = time.Now().Add(time.Minute)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(1).Return(locked, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, auditUserLocked, arg.Action)
+						require.Equal(t, "user:"+user.Username, arg.Target)
+						require.Contains(t, string(arg.After), `"failed_login_attempts":3`)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidCredentials.Code)
+			},
+		},
+		{
+			name: "Locked",
+			body: fmt.Sprintf(`{"username":%q,"password":%q}`, user.Username, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				locked := user
+				locked.FailedLoginAttempts = 3
+				locked.LockedUntil = time.Now().Add(time.Minute)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(locked, nil)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusLocked, ErrUserLocked.Code)
+				require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			// a lockout is only revealed with the right password
+			name: "LockedWrongPassword",
+			body: fmt.Sprintf(`{"username":%q,"password":"wrong-password"}`, user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				locked := user
+				locked.FailedLoginAttempts = 3
+				locked.LockedUntil = time.Now().Add(time.Minute)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(locked, nil)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidCredentials.Code)
+				require.Empty(t, recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "InvalidUsername",
+			body: fmt.Sprintf(`{"username":"invalid-user#1","password":%q}`, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/login", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginFailureDelay(t *testing.T) {
+	config := util.Config{
+		LoginFailureDelay:    100 * time.Millisecond,
+		LoginMaxFailureDelay: time.Second,
+	}
+
+	require.Equal(t, 100*time.Millisecond, loginFailureDelay(config, 1))
+	require.Equal(t, 200*time.Millisecond, loginFailureDelay(config, 2))
+	require.Equal(t, 800*time.Millisecond, loginFailureDelay(config, 4))
+	require.Equal(t, time.Second, loginFailureDelay(config, 5))
+	require.Equal(t, time.Second, loginFailureDelay(config, 1000))
+}
+
+func TestUnlockUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "admin", util.AdminRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "admin", util.AdminRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/api/v1/users/%s/unlock", user.Username)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -1,12 +1,52 @@
 package api
 
 import (
+	"database/sql"
+	"io"
+	"log/slog"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
 )
 
+func newTestServer(t *testing.T, store db.Store, opts ...Option) *Server {
+	config := util.Config{
+		TokenSymmetricKey:          util.RandomString(32),
+		AccessTokenDuration:        time.Minute,
+		LoginMaxAttempts:           3,
+		LoginLockoutDuration:       time.Minute,
+		TOTPIssuer:                 "SimpleBank",
+		StepUpTokenDuration:        time.Minute,
+		AppBaseURL:                 "http://localhost:3000",
+		VerifyEmailTokenDuration:   time.Hour,
+		PasswordResetTokenDuration: time.Hour,
+		HTTPReadTimeout:            time.Second,
+		HTTPWriteTimeout:           time.Second,
+		HTTPMaxBodyBytes:           1 << 20,
+		ShutdownTimeout:            time.Second,
+		TransferApprovalTTL:        time.Hour,
+		FeatureFlags:               []string{util.FeatureAPIDocs},
+	}
+
+	// tests that check audit events expect them before creating the server,
+	// so their expectations match first
+	if mock, ok := store.(*mockdb.MockStore); ok {
+		mock.EXPECT().AppendAuditEventTx(gomock.Any(), gomock.Any()).AnyTimes().Return(db.AuditEvent{}, nil)
+		// accounts have no approval policy unless a test sets one
+		mock.EXPECT().GetApprovalPolicy(gomock.Any(), gomock.Any()).AnyTimes().Return(db.ApprovalPolicy{}, sql.ErrNoRows)
+	}
+
+	// keep the request logs out of the test output unless a test asks for them
+	opts = append([]Option{WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))}, opts...)
+	return NewServer(config, store, opts...)
+}
+
 func TestMain(m *testing.M) {
 	gin.SetMode(gin.TestMode)
 	os.Exit(m.Run())
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiiamanop/simple_bank/metrics"
+)
+
+// unmatchedRoute labels requests that did not match any route, so that
+// arbitrary paths cannot create new time series
+const unmatchedRoute = "unmatched"
+
+func metricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.ObserveHTTPRequest(ctx.Request.Method, route, ctx.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	"github.com/hiiamanop/simple_bank/metrics"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	account := RandomAccount()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+		Times(1).
+		Return(account, nil)
+
+	server := newTestServer(t, store, WithMetrics(metrics.New(prometheus.NewRegistry())))
+
+	for _, url := range []string{
+		fmt.Sprintf("/api/v1/accounts/%d", account.ID),
+		"/no/such/route",
+	} {
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, util.DepositorRole, time.Minute)
+		server.router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	exposition := string(body)
+
+	require.Contains(t, exposition, `route="/api/v1/accounts/:id"`)
+	require.Contains(t, exposition, `route="unmatched"`)
+	require.False(t, strings.Contains(exposition, fmt.Sprintf("/api/v1/accounts/%d", account.ID)))
+}
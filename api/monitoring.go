@@ -0,0 +1,358 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/monitoring"
+)
+
+// WithMonitor lets analysts list the rules of engine. The store evaluates
+// the rules, see db.WithMonitor.
+func WithMonitor(engine *monitoring.Engine) Option {
+	return func(server *Server) {
+		server.monitor = engine
+	}
+}
+
+// monitoringAlertResponse is an alert as shown to analysts. Customers never
+// see alerts, nor which rule held their transfer.
+type monitoringAlertResponse struct {
+	ID             int64      `json:"id"`
+	Rule           string     `json:"rule"`
+	Severity       string     `json:"severity"`
+	Reason         string     `json:"reason"`
+	TransferID     *int64     `json:"transfer_id,omitempty"`
+	FromAccountID  int64      `json:"from_account_id"`
+	ToAccountID    int64      `json:"to_account_id"`
+	Amount         int64      `json:"amount"`
+	Status         string     `json:"status"`
+	AssignedTo     string     `json:"assigned_to,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	ResolvedBy     string     `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newMonitoringAlertResponse(alert db.MonitoringAlert) monitoringAlertResponse {
+	rsp := monitoringAlertResponse{
+		ID:             alert.ID,
+		Rule:           alert.Rule,
+		Severity:       alert.Severity,
+		Reason:         alert.Reason,
+		FromAccountID:  alert.FromAccountID,
+		ToAccountID:    alert.ToAccountID,
+		Amount:         alert.Amount,
+		Status:         alert.Status,
+		AssignedTo:     alert.AssignedTo,
+		ResolutionNote: alert.ResolutionNote,
+		ResolvedBy:     alert.ResolvedBy,
+		ResolvedAt:     nullTime(alert.ResolvedAt),
+		CreatedAt:      alert.CreatedAt,
+	}
+	if alert.TransferID.Valid {
+		rsp.TransferID = &alert.TransferID.Int64
+	}
+	return rsp
+}
+
+// heldTransferResponse answers a transfer that a blocking rule held. It
+// must not tell the customer why, so it names neither rule nor alert.
+type heldTransferResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// listMonitoringRules lists the rules every transfer is evaluated against
+func (server *Server) listMonitoringRules(ctx *gin.Context) {
+	rules := []monitoring.Rule{}
+	if server.monitor != nil {
+		rules = server.monitor.Rules()
+	}
+	ctx.JSON(http.StatusOK, rules)
+}
+
+type listMonitoringAlertsRequest struct {
+	Status   string `form:"status" binding:"omitempty,oneof=open investigating dismissed reported held released rejected"`
+	PageID   int32  `form:"page_id" binding:"required,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=50"`
+}
+
+// listMonitoringAlerts lists alerts oldest first, which with status=open or
+// status=held is the case queue
+func (server *Server) listMonitoringAlerts(ctx *gin.Context) {
+	var req listMonitoringAlertsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	alerts, err := server.store.ListMonitoringAlerts(ctx, db.ListMonitoringAlertsParams{
+		Status: sql.NullString{String: req.Status, Valid: req.Status != ""},
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := make([]monitoringAlertResponse, len(alerts))
+	for i, alert := range alerts {
+		rsp[i] = newMonitoringAlertResponse(alert)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type getMonitoringAlertRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getMonitoringAlert(ctx *gin.Context) {
+	var req getMonitoringAlertRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	alert, ok := server.findMonitoringAlert(ctx, req.ID)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, newMonitoringAlertResponse(alert))
+}
+
+// findMonitoringAlert loads an alert, or responds with the error and
+// returns false
+func (server *Server) findMonitoringAlert(ctx *gin.Context, id int64) (db.MonitoringAlert, bool) {
+	alert, err := server.store.GetMonitoringAlert(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return alert, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return alert, false
+	}
+	return alert, true
+}
+
+type assignMonitoringAlertRequest struct {
+	// Assignee is the analyst to work on the alert, the caller when empty
+	Assignee string `json:"assignee" binding:"omitempty,alphanum"`
+}
+
+// assignMonitoringAlert hands an alert that is not closed to an analyst,
+// which puts an open alert under investigation
+func (server *Server) assignMonitoringAlert(ctx *gin.Context) {
+	var uri getMonitoringAlertRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req assignMonitoringAlertRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	if req.Assignee == "" {
+		payload, _ := authPayload(ctx)
+		req.Assignee = payload.Username
+	}
+
+	before, ok := server.findMonitoringAlert(ctx, uri.ID)
+	if !ok {
+		return
+	}
+
+	alert, err := server.store.AssignMonitoringAlert(ctx, db.AssignMonitoringAlertParams{
+		AssignedTo: req.Assignee,
+		ID:         uri.ID,
+	})
+	if err != nil {
+		// the alert exists, so it is closed
+		if errors.Is(err, sql.ErrNoRows) {
+			err := fmt.Errorf("%w: alert %d is %s", ErrAlertClosed, uri.ID, before.Status)
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("alert", alert.ID), newMonitoringAlertResponse(before), newMonitoringAlertResponse(alert))
+	ctx.JSON(http.StatusOK, newMonitoringAlertResponse(alert))
+}
+
+type closeMonitoringAlertRequest struct {
+	// Resolution is dismissed for a false positive, reported when the
+	// activity was reported to the authorities
+	Resolution string `json:"resolution" binding:"required,oneof=dismissed reported"`
+	Note       string `json:"note" binding:"required,max=1000"`
+}
+
+// closeMonitoringAlert closes the alert of an executed transfer. Held
+// transfers are released or rejected instead.
+func (server *Server) closeMonitoringAlert(ctx *gin.Context) {
+	var uri getMonitoringAlertRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req closeMonitoringAlertRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	before, ok := server.findMonitoringAlert(ctx, uri.ID)
+	if !ok {
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	alert, err := server.store.CloseMonitoringAlert(ctx, db.CloseMonitoringAlertParams{
+		Status:         req.Resolution,
+		ResolutionNote: req.Note,
+		ResolvedBy:     payload.Username,
+		ID:             uri.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err := fmt.Errorf("%w: alert %d is %s", ErrAlertClosed, uri.ID, before.Status)
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("alert", alert.ID), newMonitoringAlertResponse(before), newMonitoringAlertResponse(alert))
+	ctx.JSON(http.StatusOK, newMonitoringAlertResponse(alert))
+}
+
+type decideHeldTransferRequest struct {
+	Note string `json:"note" binding:"required,max=1000"`
+}
+
+// releaseHeldTransferResponse is the released alert with the transfer that
+// was executed
+type releaseHeldTransferResponse struct {
+	Alert    monitoringAlertResponse `json:"alert"`
+	Transfer transferResponse        `json:"transfer"`
+}
+
+// releaseHeldTransfer executes a held transfer after review. The accounts
+// and limits are checked again, as time has passed since it was held.
+func (server *Server) releaseHeldTransfer(ctx *gin.Context) {
+	var uri getMonitoringAlertRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req decideHeldTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	before, ok := server.findMonitoringAlert(ctx, uri.ID)
+	if !ok {
+		return
+	}
+	if before.Status != db.AlertStatusHeld {
+		err := fmt.Errorf("%w: alert %d is %s", ErrAlertNotHeld, uri.ID, before.Status)
+		ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+		return
+	}
+
+	fromAccount, err := server.store.GetAccount(ctx, before.FromAccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if fromAccount.Balance < before.Amount {
+		err := fmt.Errorf("%w: account %d has %d, transfer needs %d",
+			ErrInsufficientBalance, fromAccount.ID, fromAccount.Balance, before.Amount)
+		ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	result, err := server.store.ReleaseHeldTransferTx(ctx, db.ReleaseHeldTransferTxParams{
+		AlertID:        uri.ID,
+		ResolutionNote: req.Note,
+		ResolvedBy:     payload.Username,
+	})
+	if err != nil {
+		// an account was frozen or closed, its balance spent, or a limit
+		// used up while the transfer was held
+		if errors.Is(err, db.ErrAlertNotHeld) || errors.Is(err, db.ErrAccountNotActive) ||
+			errors.Is(err, db.ErrNegativeBalance) {
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+			return
+		}
+		if errors.Is(err, db.ErrTransferLimitExceeded) || errors.Is(err, db.ErrBalanceLimitExceeded) ||
+			errors.Is(err, db.ErrLimitExceeded) {
+			ctx.JSON(http.StatusForbidden, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	server.metrics.TransferCompleted(fmt.Sprint(fromAccount.Currency), before.Amount)
+
+	rsp := releaseHeldTransferResponse{
+		Alert: newMonitoringAlertResponse(result.Alert),
+		Transfer: transferResponse{
+			Transfer:    result.Transfer.Transfer,
+			FromAccount: result.Transfer.FromAccount,
+			ToAccount:   result.Transfer.ToAccount,
+		},
+	}
+	setAuditChange(ctx, auditTarget("alert", uri.ID), newMonitoringAlertResponse(before), rsp)
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// rejectHeldTransfer cancels a held transfer without executing it
+func (server *Server) rejectHeldTransfer(ctx *gin.Context) {
+	var uri getMonitoringAlertRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req decideHeldTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	before, ok := server.findMonitoringAlert(ctx, uri.ID)
+	if !ok {
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	alert, err := server.store.RejectHeldTransferTx(ctx, db.RejectHeldTransferTxParams{
+		AlertID:        uri.ID,
+		ResolutionNote: req.Note,
+		ResolvedBy:     payload.Username,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrAlertNotHeld) {
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("alert", alert.ID), newMonitoringAlertResponse(before), newMonitoringAlertResponse(alert))
+	ctx.JSON(http.StatusOK, newMonitoringAlertResponse(alert))
+}
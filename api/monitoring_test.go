@@ -0,0 +1,327 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/monitoring"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListMonitoringRulesAPI(t *testing.T) {
+	engine, err := monitoring.NewEngine([]monitoring.Rule{
+		{Name: "round", Type: monitoring.RuleRoundAmount, Severity: monitoring.SeverityLow, Multiple: 1000, MinAmount: 1000},
+	})
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	server := newTestServer(t, mockdb.NewMockStore(ctrl), WithMonitor(engine))
+
+	for _, role := range []string{util.AdminRole, util.DepositorRole} {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/api/v1/monitoring/rules", nil)
+		require.NoError(t, err)
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", role, time.Minute)
+		server.router.ServeHTTP(recorder, request)
+
+		if role == util.DepositorRole {
+			// customers must not learn the rules
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+			continue
+		}
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.JSONEq(t, `[{"name": "round", "type": "round_amount", "severity": "low", "min_amount": 1000, "multiple": 1000}]`,
+			recorder.Body.String())
+	}
+}
+
+func TestMonitoringAlertActionsAPI(t *testing.T) {
+	account := RandomAccount()
+	account.Balance = 10000
+	open := db.MonitoringAlert{
+		ID:            1,
+		Rule:          "round",
+		Severity:      monitoring.SeverityLow,
+		Reason:        "amount 1000 is a multiple of 1000",
+		TransferID:    sql.NullInt64{Int64: 5, Valid: true},
+		FromAccountID: account.ID,
+		ToAccountID:   account.ID + 1,
+		Amount:        1000,
+		Status:        db.AlertStatusOpen,
+		CreatedAt:     time.Now(),
+	}
+	held := open
+	held.ID = 2
+	held.TransferID = sql.NullInt64{}
+	held.Status = db.AlertStatusHeld
+
+	testCases := []struct {
+		name          string
+		url           string
+		role          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "AssignToSelf",
+			url:  "/api/v1/monitoring/alerts/1/assign",
+			role: util.AdminRole,
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(open.ID)).Times(1).Return(open, nil)
+				assigned := open
+				assigned.Status = db.AlertStatusInvestigating
+				assigned.AssignedTo = "admin"
+				store.EXPECT().
+					AssignMonitoringAlert(gomock.Any(), gomock.Eq(db.AssignMonitoringAlertParams{AssignedTo: "admin", ID: open.ID})).
+					Times(1).
+					Return(assigned, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "alert.assigned", arg.Action)
+						require.Equal(t, "alert:1", arg.Target)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp monitoringAlertResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.AlertStatusInvestigating, rsp.Status)
+				require.Equal(t, "admin", rsp.AssignedTo)
+			},
+		},
+		{
+			name: "Forbidden",
+			url:  "/api/v1/monitoring/alerts/1/assign",
+			role: util.DepositorRole,
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name: "Close",
+			url:  "/api/v1/monitoring/alerts/1/close",
+			role: util.AdminRole,
+			body: gin.H{"resolution": "reported", "note": "filed a report"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(open.ID)).Times(1).Return(open, nil)
+				closed := open
+				closed.Status = db.AlertStatusReported
+				store.EXPECT().
+					CloseMonitoringAlert(gomock.Any(), gomock.Eq(db.CloseMonitoringAlertParams{
+						Status:         db.AlertStatusReported,
+						ResolutionNote: "filed a report",
+						ResolvedBy:     "admin",
+						ID:             open.ID,
+					})).
+					Times(1).
+					Return(closed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "CloseHeld",
+			url:  "/api/v1/monitoring/alerts/2/close",
+			role: util.AdminRole,
+			body: gin.H{"resolution": "dismissed", "note": "fine"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(held.ID)).Times(1).Return(held, nil)
+				store.EXPECT().CloseMonitoringAlert(gomock.Any(), gomock.Any()).Times(1).Return(db.MonitoringAlert{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrAlertClosed.Code)
+			},
+		},
+		{
+			name: "InvalidResolution",
+			url:  "/api/v1/monitoring/alerts/1/close",
+			role: util.AdminRole,
+			body: gin.H{"resolution": "released", "note": "fine"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CloseMonitoringAlert(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Release",
+			url:  "/api/v1/monitoring/alerts/2/release",
+			role: util.AdminRole,
+			body: gin.H{"note": "known customer"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(held.ID)).Times(1).Return(held, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				released := held
+				released.Status = db.AlertStatusReleased
+				released.TransferID = sql.NullInt64{Int64: 9, Valid: true}
+				store.EXPECT().
+					ReleaseHeldTransferTx(gomock.Any(), gomock.Eq(db.ReleaseHeldTransferTxParams{
+						AlertID:        held.ID,
+						ResolutionNote: "known customer",
+						ResolvedBy:     "admin",
+					})).
+					Times(1).
+					Return(db.ReleaseHeldTransferTxResult{
+						Alert:    released,
+						Transfer: db.TransferTxResult{Transfer: db.Transfer{ID: 9, Amount: held.Amount}},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp releaseHeldTransferResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.AlertStatusReleased, rsp.Alert.Status)
+				require.Equal(t, int64(9), *rsp.Alert.TransferID)
+				require.Equal(t, int64(9), rsp.Transfer.Transfer.ID)
+			},
+		},
+		{
+			name: "ReleaseNotHeld",
+			url:  "/api/v1/monitoring/alerts/1/release",
+			role: util.AdminRole,
+			body: gin.H{"note": "known customer"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(open.ID)).Times(1).Return(open, nil)
+				store.EXPECT().ReleaseHeldTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrAlertNotHeld.Code)
+			},
+		},
+		{
+			name: "ReleaseLimitExceeded",
+			url:  "/api/v1/monitoring/alerts/2/release",
+			role: util.AdminRole,
+			body: gin.H{"note": "known customer"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(held.ID)).Times(1).Return(held, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					ReleaseHeldTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ReleaseHeldTransferTxResult{}, fmt.Errorf("%w: daily_amount of account 1 is 100, 0 remaining", db.ErrLimitExceeded))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrLimitExceeded.Code)
+			},
+		},
+		{
+			name: "ReleaseSpentMoney",
+			url:  "/api/v1/monitoring/alerts/2/release",
+			role: util.AdminRole,
+			body: gin.H{"note": "known customer"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(held.ID)).Times(1).Return(held, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				// a concurrent transfer spent the money after the check above
+				store.EXPECT().
+					ReleaseHeldTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ReleaseHeldTransferTxResult{}, fmt.Errorf("%w: account %d would have balance %d", db.ErrNegativeBalance, account.ID, -1))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrInsufficientBalance.Code)
+			},
+		},
+		{
+			name: "Reject",
+			url:  "/api/v1/monitoring/alerts/2/reject",
+			role: util.AdminRole,
+			body: gin.H{"note": "mule account"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(held.ID)).Times(1).Return(held, nil)
+				rejected := held
+				rejected.Status = db.AlertStatusRejected
+				store.EXPECT().
+					RejectHeldTransferTx(gomock.Any(), gomock.Eq(db.RejectHeldTransferTxParams{
+						AlertID:        held.ID,
+						ResolutionNote: "mule account",
+						ResolvedBy:     "admin",
+					})).
+					Times(1).
+					Return(rejected, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "RejectNotHeld",
+			url:  "/api/v1/monitoring/alerts/2/reject",
+			role: util.AdminRole,
+			body: gin.H{"note": "mule account"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(held.ID)).Times(1).Return(held, nil)
+				store.EXPECT().
+					RejectHeldTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.MonitoringAlert{}, fmt.Errorf("%w: alert 2 is released", db.ErrAlertNotHeld))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrAlertNotHeld.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			url:  "/api/v1/monitoring/alerts/3/reject",
+			role: util.AdminRole,
+			body: gin.H{"note": "mule account"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMonitoringAlert(gomock.Any(), gomock.Eq(int64(3))).Times(1).Return(db.MonitoringAlert{}, sql.ErrNoRows)
+				store.EXPECT().RejectHeldTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusNotFound, ErrNotFound.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, tc.url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,573 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/monitoring"
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+const (
+	openAPIVersion = "3.1.0"
+	apiBasePath    = "/api/v1"
+)
+
+// apiOperation documents a single route for the OpenAPI specification.
+// Every route registered under /api/v1 in setupRouter must have a matching
+// entry in apiOperations, which is enforced by a test.
+type apiOperation struct {
+	method      string
+	path        string // gin style path relative to apiBasePath, e.g. /accounts/:id
+	operationID string
+	summary     string
+	tag         string
+	uri         any // struct bound with ShouldBindUri
+	query       any // struct bound with ShouldBindQuery
+	body        any // struct bound with ShouldBindJSON
+	response    any
+	status      int // success status, 200 when zero
+}
+
+// messageResponse documents the body returned by delete handlers and by
+// handlers that send an email
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// errorBody documents the body produced by errorResponse
+type errorBody struct {
+	Error     string `json:"error"`
+	Code      string `json:"code,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// problemBody documents the body produced by problemResponse
+type problemBody struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Error     string `json:"error"`
+	Code      string `json:"code,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+var apiOperations = []apiOperation{
+	// Audit routes
+	{method: http.MethodGet, path: "/audit-events", operationID: "listAuditEvents", summary: "List audit events (admin)", tag: "audit",
+		query: listAuditEventsRequest{}, response: []db.AuditEvent{}},
+
+	// Account routes
+	{method: http.MethodPost, path: "/accounts", operationID: "createAccount", summary: "Create an account", tag: "accounts",
+		body: createAccountRequest{}, response: db.Account{}},
+	{method: http.MethodGet, path: "/accounts/:id", operationID: "getAccount", summary: "Get an account", tag: "accounts",
+		uri: getAccountRequest{}, response: db.Account{}},
+	{method: http.MethodGet, path: "/accounts", operationID: "listAccounts", summary: "List accounts", tag: "accounts",
+		query: listAccountsRequest{}, response: []db.Account{}},
+	{method: http.MethodPut, path: "/accounts/:id", operationID: "updateAccount", summary: "Set an account balance", tag: "accounts",
+		uri: getAccountRequest{}, body: updateAccountRequest{}, response: db.Account{}},
+	{method: http.MethodDelete, path: "/accounts/:id", operationID: "deleteAccount", summary: "Close an account (deprecated, use close)", tag: "accounts",
+		uri: getAccountRequest{}, response: accountStatusResponse{}},
+	{method: http.MethodPost, path: "/accounts/:id/freeze", operationID: "freezeAccount", summary: "Freeze an account (admin)", tag: "accounts",
+		uri: getAccountRequest{}, response: accountStatusResponse{}},
+	{method: http.MethodPost, path: "/accounts/:id/unfreeze", operationID: "unfreezeAccount", summary: "Unfreeze a frozen or dormant account (admin)", tag: "accounts",
+		uri: getAccountRequest{}, response: accountStatusResponse{}},
+	{method: http.MethodPost, path: "/accounts/:id/close", operationID: "closeAccount", summary: "Close an account, sweeping its balance", tag: "accounts",
+		uri: getAccountRequest{}, body: closeAccountRequest{}, response: accountStatusResponse{}},
+	{method: http.MethodPost, path: "/accounts/:id/reopen", operationID: "reopenAccount", summary: "Reopen a closed account (admin)", tag: "accounts",
+		uri: getAccountRequest{}, response: accountStatusResponse{}},
+	{method: http.MethodGet, path: "/accounts/:id/limits", operationID: "getTransferAllowance", summary: "Get the transfer limits of an account and what is left of them", tag: "accounts",
+		uri: getAccountRequest{}, response: db.TransferAllowance{}},
+	{method: http.MethodGet, path: "/accounts/:id/approval-policy", operationID: "getApprovalPolicy", summary: "Get the transfer approval policy of an account", tag: "accounts",
+		uri: getAccountRequest{}, response: approvalPolicyResponse{}},
+	{method: http.MethodPut, path: "/accounts/:id/approval-policy", operationID: "setApprovalPolicy", summary: "Require approvals for large transfers from an account (admin)", tag: "accounts",
+		uri: getAccountRequest{}, body: setApprovalPolicyRequest{}, response: approvalPolicyResponse{}},
+	{method: http.MethodDelete, path: "/accounts/:id/approval-policy", operationID: "deleteApprovalPolicy", summary: "Remove the transfer approval policy of an account (admin)", tag: "accounts",
+		uri: getAccountRequest{}, response: messageResponse{}},
+
+	// Entry routes
+	{method: http.MethodPost, path: "/entries", operationID: "createEntry", summary: "Create an entry", tag: "entries",
+		body: createEntryRequest{}, response: db.Entry{}},
+	{method: http.MethodGet, path: "/entries/:id", operationID: "getEntry", summary: "Get an entry", tag: "entries",
+		uri: getEntryRequest{}, response: db.Entry{}},
+	{method: http.MethodGet, path: "/entries", operationID: "listEntries", summary: "List entries", tag: "entries",
+		query: listEntriesRequest{}, response: []db.Entry{}},
+
+	// Transfer routes
+	{method: http.MethodPost, path: "/transfers", operationID: "createTransfer", summary: "Transfer money between accounts", tag: "transfers",
+		body: createTransferRequest{}, response: transferResponse{}},
+	{method: http.MethodGet, path: "/transfers/:id", operationID: "getTransfer", summary: "Get a transfer", tag: "transfers",
+		uri: getTransferRequest{}, response: db.Transfer{}},
+	{method: http.MethodGet, path: "/transfers/:id/timeline", operationID: "getTransferTimeline", summary: "Get a transfer with the history of its status", tag: "transfers",
+		uri: getTransferRequest{}, response: transferTimelineResponse{}},
+	{method: http.MethodGet, path: "/transfers", operationID: "listTransfers", summary: "List transfers", tag: "transfers",
+		query: listTransfersRequest{}, response: []db.Transfer{}},
+	{method: http.MethodPost, path: "/transfers/:id/reverse", operationID: "reverseTransfer", summary: "Reverse a completed transfer", tag: "transfers",
+		uri: getTransferRequest{}, response: transferResponse{}},
+
+	// User routes
+	{method: http.MethodPost, path: "/users", operationID: "createUser", summary: "Create a user", tag: "users",
+		body: createUserRequest{}, response: userResponse{}},
+	{method: http.MethodPost, path: "/users/login", operationID: "loginUser", summary: "Log in with a password", tag: "users",
+		body: loginUserRequest{}, response: loginUserResponse{}},
+	{method: http.MethodPost, path: "/users/totp", operationID: "enrollTOTP", summary: "Start two-factor enrolment", tag: "users",
+		response: enrollTOTPResponse{}},
+	{method: http.MethodPost, path: "/users/totp/activate", operationID: "activateTOTP", summary: "Confirm two-factor enrolment", tag: "users",
+		body: activateTOTPRequest{}, response: activateTOTPResponse{}},
+	{method: http.MethodPost, path: "/users/step-up", operationID: "stepUp", summary: "Verify a second factor for a step-up token", tag: "users",
+		body: stepUpRequest{}, response: stepUpResponse{}},
+	{method: http.MethodPost, path: "/users/verify-email", operationID: "resendVerificationEmail", summary: "Send a new verification email", tag: "users",
+		response: messageResponse{}, status: http.StatusAccepted},
+	{method: http.MethodPost, path: "/users/verify-email/confirm", operationID: "verifyEmail", summary: "Verify an email with an emailed token", tag: "users",
+		body: confirmEmailRequest{}, response: userResponse{}},
+	{method: http.MethodPost, path: "/users/password-reset", operationID: "requestPasswordReset", summary: "Email a password reset link", tag: "users",
+		body: passwordResetRequest{}, response: messageResponse{}, status: http.StatusAccepted},
+	{method: http.MethodPost, path: "/users/password-reset/confirm", operationID: "resetPassword", summary: "Set a new password with an emailed token", tag: "users",
+		body: confirmPasswordResetRequest{}, response: userResponse{}},
+	{method: http.MethodPost, path: "/users/:username/unlock", operationID: "unlockUser", summary: "Lift a login lockout (admin)", tag: "users",
+		uri: getUserRequest{}, response: userResponse{}},
+	{method: http.MethodPost, path: "/users/:username/erase", operationID: "eraseUser", summary: "Pseudonymise a deleted user (admin)", tag: "users",
+		uri: getUserRequest{}, response: userResponse{}},
+	{method: http.MethodGet, path: "/users/:username/export", operationID: "exportUser", summary: "Export all data stored about a user", tag: "users",
+		uri: getUserRequest{}, response: userExport{}},
+	{method: http.MethodGet, path: "/users/:username/kyc", operationID: "getKYCProfile", summary: "Get the KYC profile of a user", tag: "users",
+		uri: getUserRequest{}, response: kycProfileResponse{}},
+	{method: http.MethodPut, path: "/users/:username/kyc", operationID: "submitKYCProfile", summary: "Submit a KYC profile for review", tag: "users",
+		uri: getUserRequest{}, body: submitKYCProfileRequest{}, response: kycProfileResponse{}},
+	{method: http.MethodGet, path: "/users/:username", operationID: "getUser", summary: "Get a user", tag: "users",
+		uri: getUserRequest{}, response: userResponse{}},
+	{method: http.MethodGet, path: "/users", operationID: "listUsers", summary: "List users", tag: "users",
+		query: listUsersRequest{}, response: []userResponse{}},
+	{method: http.MethodPut, path: "/users/:username", operationID: "updateUser", summary: "Update a user", tag: "users",
+		uri: getUserRequest{}, body: updateUserRequest{}, response: userResponse{}},
+	{method: http.MethodDelete, path: "/users/:username", operationID: "deleteUser", summary: "Delete a user, keeping its ledger rows", tag: "users",
+		uri: getUserRequest{}, response: messageResponse{}},
+
+	// KYC review routes
+	{method: http.MethodGet, path: "/kyc/tiers", operationID: "listKYCTiers", summary: "List the KYC tiers and their limits", tag: "kyc",
+		response: []db.KycTier{}},
+	{method: http.MethodGet, path: "/kyc/profiles", operationID: "listKYCProfiles", summary: "List KYC profiles, e.g. the review queue (admin)", tag: "kyc",
+		query: listKYCProfilesRequest{}, response: []kycProfileResponse{}},
+	{method: http.MethodPost, path: "/kyc/profiles/:username/approve", operationID: "approveKYCProfile", summary: "Verify a KYC profile and grant a tier (admin)", tag: "kyc",
+		uri: getUserRequest{}, body: approveKYCProfileRequest{}, response: kycProfileResponse{}},
+	{method: http.MethodPost, path: "/kyc/profiles/:username/reject", operationID: "rejectKYCProfile", summary: "Reject a KYC profile (admin)", tag: "kyc",
+		uri: getUserRequest{}, body: rejectKYCProfileRequest{}, response: kycProfileResponse{}},
+
+	// Transfer limit routes
+	{method: http.MethodGet, path: "/limits", operationID: "listTransferLimits", summary: "List the default and overridden transfer limits (admin)", tag: "limits",
+		response: []transferLimitResponse{}},
+	{method: http.MethodPut, path: "/limits/defaults/:scope", operationID: "setDefaultTransferLimit", summary: "Set the default transfer limits of a scope (admin)", tag: "limits",
+		uri: limitScopeRequest{}, body: setTransferLimitRequest{}, response: transferLimitResponse{}},
+	{method: http.MethodPut, path: "/limits/accounts/:id", operationID: "setAccountTransferLimit", summary: "Override the transfer limits of an account (admin)", tag: "limits",
+		uri: getAccountRequest{}, body: setTransferLimitRequest{}, response: transferLimitResponse{}},
+	{method: http.MethodDelete, path: "/limits/accounts/:id", operationID: "deleteAccountTransferLimit", summary: "Remove the transfer limit override of an account (admin)", tag: "limits",
+		uri: getAccountRequest{}, response: messageResponse{}},
+	{method: http.MethodPut, path: "/limits/users/:username", operationID: "setUserTransferLimit", summary: "Override the transfer limits of a user (admin)", tag: "limits",
+		uri: getUserRequest{}, body: setTransferLimitRequest{}, response: transferLimitResponse{}},
+	{method: http.MethodDelete, path: "/limits/users/:username", operationID: "deleteUserTransferLimit", summary: "Remove the transfer limit override of a user (admin)", tag: "limits",
+		uri: getUserRequest{}, response: messageResponse{}},
+
+	// Transaction monitoring routes
+	{method: http.MethodGet, path: "/monitoring/rules", operationID: "listMonitoringRules", summary: "List the transaction monitoring rules (admin)", tag: "monitoring",
+		response: []monitoring.Rule{}},
+	{method: http.MethodGet, path: "/monitoring/alerts", operationID: "listMonitoringAlerts", summary: "List monitoring alerts, e.g. the case queue (admin)", tag: "monitoring",
+		query: listMonitoringAlertsRequest{}, response: []monitoringAlertResponse{}},
+	{method: http.MethodGet, path: "/monitoring/alerts/:id", operationID: "getMonitoringAlert", summary: "Get a monitoring alert (admin)", tag: "monitoring",
+		uri: getMonitoringAlertRequest{}, response: monitoringAlertResponse{}},
+	{method: http.MethodPost, path: "/monitoring/alerts/:id/assign", operationID: "assignMonitoringAlert", summary: "Assign a monitoring alert to an analyst (admin)", tag: "monitoring",
+		uri: getMonitoringAlertRequest{}, body: assignMonitoringAlertRequest{}, response: monitoringAlertResponse{}},
+	{method: http.MethodPost, path: "/monitoring/alerts/:id/close", operationID: "closeMonitoringAlert", summary: "Dismiss or report a monitoring alert (admin)", tag: "monitoring",
+		uri: getMonitoringAlertRequest{}, body: closeMonitoringAlertRequest{}, response: monitoringAlertResponse{}},
+	{method: http.MethodPost, path: "/monitoring/alerts/:id/release", operationID: "releaseHeldTransfer", summary: "Execute a transfer held by a monitoring rule (admin)", tag: "monitoring",
+		uri: getMonitoringAlertRequest{}, body: decideHeldTransferRequest{}, response: releaseHeldTransferResponse{}},
+	{method: http.MethodPost, path: "/monitoring/alerts/:id/reject", operationID: "rejectHeldTransfer", summary: "Reject a transfer held by a monitoring rule (admin)", tag: "monitoring",
+		uri: getMonitoringAlertRequest{}, body: decideHeldTransferRequest{}, response: monitoringAlertResponse{}},
+
+	// Sanctions screening routes
+	{method: http.MethodGet, path: "/screening/hits", operationID: "listScreeningHits", summary: "List sanctions screening hits, e.g. the review queue (admin)", tag: "screening",
+		query: listScreeningHitsRequest{}, response: []screeningHitResponse{}},
+	{method: http.MethodGet, path: "/screening/hits/:id", operationID: "getScreeningHit", summary: "Get a sanctions screening hit (admin)", tag: "screening",
+		uri: getScreeningHitRequest{}, response: screeningHitResponse{}},
+	{method: http.MethodPost, path: "/screening/hits/:id/clear", operationID: "clearScreeningHit", summary: "Clear a screening hit as a false positive (admin)", tag: "screening",
+		uri: getScreeningHitRequest{}, body: reviewScreeningHitRequest{}, response: screeningHitResponse{}},
+	{method: http.MethodPost, path: "/screening/hits/:id/confirm", operationID: "confirmScreeningHit", summary: "Confirm a screening hit as a true match (admin)", tag: "screening",
+		uri: getScreeningHitRequest{}, body: reviewScreeningHitRequest{}, response: screeningHitResponse{}},
+
+	// Transfer approval routes
+	{method: http.MethodGet, path: "/transfer-approvals", operationID: "listTransferApprovals", summary: "List the transfer approvals you initiated or may decide on", tag: "approvals",
+		query: listTransferApprovalsRequest{}, response: []transferApprovalResponse{}},
+	{method: http.MethodGet, path: "/transfer-approvals/:id", operationID: "getTransferApproval", summary: "Get a transfer approval with its decisions", tag: "approvals",
+		uri: getTransferApprovalRequest{}, response: transferApprovalResponse{}},
+	{method: http.MethodPost, path: "/transfer-approvals/:id/approve", operationID: "approveTransferApproval", summary: "Approve a transfer, executing it with the last approval", tag: "approvals",
+		uri: getTransferApprovalRequest{}, body: decideTransferApprovalRequest{}, response: transferApprovalResponse{}},
+	{method: http.MethodPost, path: "/transfer-approvals/:id/reject", operationID: "rejectTransferApproval", summary: "Reject a transfer that awaits approval", tag: "approvals",
+		uri: getTransferApprovalRequest{}, body: decideTransferApprovalRequest{}, response: transferApprovalResponse{}},
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref              string                    `json:"$ref,omitempty"`
+	Type             string                    `json:"type,omitempty"`
+	Format           string                    `json:"format,omitempty"`
+	Pattern          string                    `json:"pattern,omitempty"`
+	Enum             []string                  `json:"enum,omitempty"`
+	Minimum          *int64                    `json:"minimum,omitempty"`
+	Maximum          *int64                    `json:"maximum,omitempty"`
+	ExclusiveMinimum *int64                    `json:"exclusiveMinimum,omitempty"`
+	MinLength        *int64                    `json:"minLength,omitempty"`
+	MaxLength        *int64                    `json:"maxLength,omitempty"`
+	Items            *openAPISchema            `json:"items,omitempty"`
+	Properties       map[string]*openAPISchema `json:"properties,omitempty"`
+	Required         []string                  `json:"required,omitempty"`
+}
+
+// openAPISpec builds the specification once from apiOperations
+var openAPISpec = sync.OnceValue(func() *openAPIDocument {
+	return buildOpenAPIDocument(apiOperations)
+})
+
+func buildOpenAPIDocument(operations []apiOperation) *openAPIDocument {
+	gen := &schemaGenerator{schemas: map[string]*openAPISchema{}}
+	gen.schemas["errorBody"] = gen.objectSchema(reflect.TypeOf(errorBody{}))
+	gen.schemas["problemBody"] = gen.objectSchema(reflect.TypeOf(problemBody{}))
+
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   "Simple Bank API",
+			Version: "1.0.0",
+		},
+		Servers:    []openAPIServer{{URL: apiBasePath}},
+		Paths:      map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{Schemas: gen.schemas},
+	}
+
+	for _, op := range operations {
+		path := openAPIPath(op.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(op.method)] = gen.operation(op)
+	}
+
+	return doc
+}
+
+// openAPIPath converts gin path parameters (:id) into OpenAPI templates ({id})
+func openAPIPath(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+type schemaGenerator struct {
+	schemas map[string]*openAPISchema
+}
+
+func (gen *schemaGenerator) operation(op apiOperation) *openAPIOperation {
+	operation := &openAPIOperation{
+		OperationID: op.operationID,
+		Summary:     op.summary,
+		Tags:        []string{op.tag},
+		Responses: map[string]openAPIResponse{
+			"400": gen.errorResponse("Invalid request"),
+			"429": {
+				Description: "Rate limit exceeded",
+				Content: map[string]openAPIMediaType{
+					"application/problem+json": {Schema: &openAPISchema{Ref: "#/components/schemas/problemBody"}},
+				},
+			},
+			"500": gen.errorResponse("Internal server error"),
+		},
+	}
+
+	if op.uri != nil {
+		operation.Parameters = append(operation.Parameters, gen.parameters(reflect.TypeOf(op.uri), "path", "uri")...)
+		operation.Responses["404"] = gen.errorResponse("Not found")
+	}
+	if op.query != nil {
+		operation.Parameters = append(operation.Parameters, gen.parameters(reflect.TypeOf(op.query), "query", "form")...)
+	}
+	if op.body != nil {
+		operation.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content: map[string]openAPIMediaType{
+				"application/json": {Schema: gen.schemaFor(reflect.TypeOf(op.body))},
+			},
+		}
+	}
+	if op.response != nil {
+		status := http.StatusOK
+		if op.status != 0 {
+			status = op.status
+		}
+		operation.Responses[strconv.Itoa(status)] = openAPIResponse{
+			Description: http.StatusText(status),
+			Content: map[string]openAPIMediaType{
+				"application/json": {Schema: gen.schemaFor(reflect.TypeOf(op.response))},
+			},
+		}
+	}
+
+	return operation
+}
+
+func (gen *schemaGenerator) errorResponse(description string) openAPIResponse {
+	return openAPIResponse{
+		Description: description,
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: &openAPISchema{Ref: "#/components/schemas/errorBody"}},
+		},
+	}
+}
+
+// parameters describes the fields of a struct bound from the path or query string
+func (gen *schemaGenerator) parameters(t reflect.Type, in string, tagKey string) []openAPIParameter {
+	var params []openAPIParameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get(tagKey)
+		if name == "" || !field.IsExported() {
+			continue
+		}
+
+		schema := gen.schemaFor(field.Type)
+		required := applyBindingRules(schema, field.Tag.Get("binding"))
+		params = append(params, openAPIParameter{
+			Name:     name,
+			In:       in,
+			Required: required || in == "path",
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+// schemaFor returns the schema of a Go type, registering named structs as
+// reusable components and referring to them by name
+func (gen *schemaGenerator) schemaFor(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+	if t == reflect.TypeOf(monitoring.Duration(0)) {
+		// written as a Go duration such as "24h"
+		return &openAPISchema{Type: "string", Format: "duration"}
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		// embedded JSON documents can hold any value
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return gen.objectSchema(t)
+		}
+		if _, ok := gen.schemas[t.Name()]; !ok {
+			// reserve the name first so recursive types terminate
+			gen.schemas[t.Name()] = &openAPISchema{}
+			gen.schemas[t.Name()] = gen.objectSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes []byte as base64
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: gen.schemaFor(t.Elem())}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	default:
+		// interface{} and other dynamic values accept any JSON value
+		return &openAPISchema{}
+	}
+}
+
+func (gen *schemaGenerator) objectSchema(t reflect.Type) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	gen.addProperties(schema, t)
+	return schema
+}
+
+func (gen *schemaGenerator) addProperties(schema *openAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			gen.addProperties(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := gen.schemaFor(field.Type)
+		if applyBindingRules(property, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// applyBindingRules translates gin binding tags into schema constraints and
+// reports whether the field is required
+func applyBindingRules(schema *openAPISchema, binding string) (required bool) {
+	if binding == "" {
+		return false
+	}
+
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "alphanum":
+			schema.Pattern = "^[a-zA-Z0-9]+$"
+		case "oneof":
+			schema.Enum = strings.Fields(value)
+		case "min", "max", "gt":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			setBound(schema, key, n)
+		}
+	}
+
+	return required
+}
+
+func setBound(schema *openAPISchema, rule string, n int64) {
+	isString := schema.Type == "string"
+	switch {
+	case rule == "min" && isString:
+		schema.MinLength = &n
+	case rule == "max" && isString:
+		schema.MaxLength = &n
+	case rule == "min":
+		schema.Minimum = &n
+	case rule == "max":
+		schema.Maximum = &n
+	case rule == "gt":
+		schema.ExclusiveMinimum = &n
+	}
+}
+
+func (server *Server) getOpenAPISpec(ctx *gin.Context) {
+	if !server.featureEnabled(util.FeatureAPIDocs) {
+		ctx.JSON(http.StatusNotFound, errorResponse(ctx, ErrNotFound))
+		return
+	}
+	ctx.JSON(http.StatusOK, openAPISpec())
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8" />
+  <title>Simple Bank API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css" />
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "` + apiBasePath + `/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+func (server *Server) getSwaggerUI(ctx *gin.Context) {
+	if !server.featureEnabled(util.FeatureAPIDocs) {
+		ctx.JSON(http.StatusNotFound, errorResponse(ctx, ErrNotFound))
+		return
+	}
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// documentationRoutes serve the specification itself and are not part of it
+var documentationRoutes = map[string]bool{
+	"GET " + apiBasePath + "/openapi.json": true,
+	"GET " + apiBasePath + "/docs":         true,
+}
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+	var registered []string
+	for _, route := range server.router.Routes() {
+		key := route.Method + " " + route.Path
+		if !strings.HasPrefix(route.Path, apiBasePath+"/") || documentationRoutes[key] {
+			continue
+		}
+		registered = append(registered, key)
+	}
+
+	var documented []string
+	for _, op := range apiOperations {
+		documented = append(documented, op.method+" "+apiBasePath+op.path)
+	}
+
+	require.ElementsMatch(t, registered, documented, "routes in setupRouter and apiOperations have drifted")
+}
+
+func TestGetOpenAPISpec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, apiBasePath+"/openapi.json", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var doc openAPIDocument
+	err = json.Unmarshal(recorder.Body.Bytes(), &doc)
+	require.NoError(t, err)
+	require.Equal(t, openAPIVersion, doc.OpenAPI)
+
+	transfer := doc.Components.Schemas["createTransferRequest"]
+	require.NotNil(t, transfer)
+	require.ElementsMatch(t, []string{"from_account_id", "to_account_id", "amount"}, transfer.Required)
+	require.Equal(t, "integer", transfer.Properties["amount"].Type)
+	require.Equal(t, int64(0), *transfer.Properties["amount"].ExclusiveMinimum)
+
+	account := doc.Components.Schemas["createAccountRequest"]
+	require.NotNil(t, account)
+	require.Equal(t, []string{"USD", "EUR"}, account.Properties["currency"].Enum)
+
+	require.Contains(t, doc.Components.Schemas, "transferResponse")
+	require.Contains(t, doc.Paths, "/accounts/{id}")
+
+	getAccount := doc.Paths["/accounts/{id}"]["get"]
+	require.NotNil(t, getAccount)
+	require.Len(t, getAccount.Parameters, 1)
+	require.Equal(t, "id", getAccount.Parameters[0].Name)
+	require.Equal(t, "path", getAccount.Parameters[0].In)
+}
+
+func TestGetSwaggerUI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, apiBasePath+"/docs", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), apiBasePath+"/openapi.json")
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiiamanop/simple_bank/ratelimit"
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+// Rate limit headers, see draft-ietf-httpapi-ratelimit-headers
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+)
+
+// Route groups with their own rate limit, see util.Config
+const (
+	rateLimitDefault   = "default"
+	rateLimitTransfers = "transfers"
+	rateLimitAuth      = "auth"
+)
+
+// rateLimitGroups assigns routes, as "METHOD template", to a rate limit
+// group other than the default
+var rateLimitGroups = map[string]string{
+	http.MethodPost + " " + apiBasePath + "/transfers":                    rateLimitTransfers,
+	http.MethodPost + " " + apiBasePath + "/users":                        rateLimitAuth,
+	http.MethodPost + " " + apiBasePath + "/users/login":                  rateLimitAuth,
+	http.MethodPost + " " + apiBasePath + "/users/totp/activate":          rateLimitAuth,
+	http.MethodPost + " " + apiBasePath + "/users/step-up":                rateLimitAuth,
+	http.MethodPost + " " + apiBasePath + "/users/verify-email":           rateLimitAuth,
+	http.MethodPost + " " + apiBasePath + "/users/verify-email/confirm":   rateLimitAuth,
+	http.MethodPost + " " + apiBasePath + "/users/password-reset":         rateLimitAuth,
+	http.MethodPost + " " + apiBasePath + "/users/password-reset/confirm": rateLimitAuth,
+}
+
+// WithRateLimiter keeps the rate limit buckets in limiter. Without it the
+// server uses a ratelimit.Memory.
+func WithRateLimiter(limiter ratelimit.Limiter) Option {
+	return func(server *Server) {
+		server.limiter = limiter
+	}
+}
+
+// newRateLimits parses the rate limits of config by group
+func newRateLimits(config util.Config) map[string]ratelimit.Limit {
+	limits := make(map[string]ratelimit.Limit)
+	for group, limit := range map[string]string{
+		rateLimitDefault:   config.RateLimitDefault,
+		rateLimitTransfers: config.RateLimitTransfers,
+		rateLimitAuth:      config.RateLimitAuth,
+	} {
+		// validated by util.Config
+		requests, period, _ := util.ParseRateLimit(limit)
+		limits[group] = ratelimit.Limit{Requests: requests, Period: period}
+	}
+	return limits
+}
+
+// callerKey identifies the caller for rate limits and idempotency keys: the
+// authenticated user, the API key or else the client IP
+func callerKey(ctx *gin.Context) string {
+	if user := ctx.GetString(authUserKey); user != "" {
+		return "user:" + user
+	}
+	if apiKey := ctx.GetString(authAPIKeyKey); apiKey != "" {
+		return "key:" + apiKey
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+// rateLimitMiddleware takes a token from the bucket of the caller for the
+// group of the route and rejects the request with 429 when it is empty.
+// Each group has its own bucket, so listing accounts does not use up the
+// transfer allowance. If the limiter fails the request is let through,
+// since an outage of the limiter should not take the API down with it.
+func (server *Server) rateLimitMiddleware(ctx *gin.Context) {
+	group, ok := rateLimitGroups[ctx.Request.Method+" "+ctx.FullPath()]
+	if !ok {
+		group = rateLimitDefault
+	}
+	limit := (*server.rateLimits.Load())[group]
+	if limit.Unlimited() {
+		ctx.Next()
+		return
+	}
+
+	result, err := server.limiter.Take(ctx, group+":"+callerKey(ctx), limit)
+	if err != nil {
+		server.logger.ErrorContext(ctx, "rate limiter failed", slog.String("group", group), slog.Any("error", err))
+		ctx.Next()
+		return
+	}
+
+	header := ctx.Writer.Header()
+	header.Set(RateLimitLimitHeader, strconv.Itoa(limit.Requests))
+	header.Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+	header.Set(RateLimitResetHeader, strconv.Itoa(ceilSeconds(result.Reset)))
+	header.Set(RateLimitPolicyHeader, fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+
+	if !result.Allowed {
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		problemResponse(ctx, http.StatusTooManyRequests, ErrRateLimited,
+			fmt.Sprintf("limit of %d requests per %s exceeded", limit.Requests, limit.Period))
+		return
+	}
+	ctx.Next()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// problemResponse aborts the request with an RFC 9457 problem document. It
+// also carries the fields of errorResponse, so existing clients can decode
+// it as an Error.
+func problemResponse(ctx *gin.Context, status int, err *Error, detail string) {
+	body := errorResponse(ctx, err)
+	body["type"] = "about:blank"
+	body["title"] = http.StatusText(status)
+	body["status"] = status
+	body["detail"] = detail
+
+	// gin keeps a Content-Type that is already set
+	ctx.Header("Content-Type", "application/problem+json")
+	ctx.AbortWithStatusJSON(status, body)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	"github.com/hiiamanop/simple_bank/ratelimit"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).AnyTimes().Return(RandomAccount(), nil)
+
+	server := newTestServer(t, store)
+	config := *server.live.Load()
+	config.RateLimitDefault = "2/1m"
+	config.RateLimitTransfers = "1/1m"
+	server.Reload(config)
+
+	// send makes an anonymous request when username is empty
+	send := func(method, path, username string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(method, path, nil)
+		require.NoError(t, err)
+		if username != "" {
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, username, util.AdminRole, time.Minute)
+		}
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := send(http.MethodGet, "/api/v1/accounts/1", "alice")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "2", recorder.Header().Get(RateLimitLimitHeader))
+	require.Equal(t, "1", recorder.Header().Get(RateLimitRemainingHeader))
+	require.Equal(t, "30", recorder.Header().Get(RateLimitResetHeader))
+	require.Equal(t, "2;w=60", recorder.Header().Get(RateLimitPolicyHeader))
+
+	recorder = send(http.MethodGet, "/api/v1/accounts/1", "alice")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "0", recorder.Header().Get(RateLimitRemainingHeader))
+
+	recorder = send(http.MethodGet, "/api/v1/accounts/1", "alice")
+	requireErrorCode(t, recorder, http.StatusTooManyRequests, ErrRateLimited.Code)
+	require.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+	require.Equal(t, "30", recorder.Header().Get("Retry-After"))
+
+	var problem struct {
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+		Detail string `json:"detail"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	require.Equal(t, "about:blank", problem.Type)
+	require.Equal(t, http.StatusText(http.StatusTooManyRequests), problem.Title)
+	require.Equal(t, http.StatusTooManyRequests, problem.Status)
+	require.NotEmpty(t, problem.Detail)
+
+	// other clients and other groups have their own buckets
+	recorder = send(http.MethodGet, "/api/v1/accounts/1", "bob")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = send(http.MethodPost, "/api/v1/transfers", "")
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.Equal(t, "1", recorder.Header().Get(RateLimitLimitHeader))
+
+	recorder = send(http.MethodPost, "/api/v1/transfers", "")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+
+	// operational endpoints are never limited
+	recorder = send(http.MethodGet, "/healthz", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Empty(t, recorder.Header().Get(RateLimitLimitHeader))
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("database is down")
+}
+
+func (failingLimiter) Prune(ctx context.Context, idle time.Duration) error {
+	return nil
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(RandomAccount(), nil)
+
+	server := newTestServer(t, store, WithRateLimiter(failingLimiter{}))
+	config := *server.live.Load()
+	config.RateLimitDefault = "1/1m"
+	server.Reload(config)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/api/v1/accounts/1", nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "alice", util.AdminRole, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Empty(t, recorder.Header().Get(RateLimitLimitHeader))
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/screening"
+)
+
+// WithScreener screens new users and the parties of transfers against the
+// sanctions lists of screener. Without it nobody is screened.
+func WithScreener(screener *screening.Screener) Option {
+	return func(server *Server) {
+		server.screener = screener
+	}
+}
+
+// screeningHitResponse is a screening hit as shown to reviewers
+type screeningHitResponse struct {
+	ID         int64      `json:"id"`
+	Action     string     `json:"action"`
+	Username   string     `json:"username"`
+	Name       string     `json:"name"`
+	List       string     `json:"list"`
+	EntryID    string     `json:"entry_id"`
+	EntryName  string     `json:"entry_name"`
+	Program    string     `json:"program,omitempty"`
+	Score      float64    `json:"score"`
+	Status     string     `json:"status"`
+	ReviewNote string     `json:"review_note,omitempty"`
+	ReviewedBy string     `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newScreeningHitResponse(hit db.ScreeningHit) screeningHitResponse {
+	return screeningHitResponse{
+		ID:         hit.ID,
+		Action:     hit.Action,
+		Username:   hit.Username,
+		Name:       hit.Name,
+		List:       hit.List,
+		EntryID:    hit.EntryID,
+		EntryName:  hit.EntryName,
+		Program:    hit.Program,
+		Score:      hit.Score,
+		Status:     hit.Status,
+		ReviewNote: hit.ReviewNote,
+		ReviewedBy: hit.ReviewedBy,
+		ReviewedAt: nullTime(hit.ReviewedAt),
+		CreatedAt:  hit.CreatedAt,
+	}
+}
+
+// screeningAuditState is what the audit log keeps of a hit. The log cannot
+// be erased, so it leaves out the screened name.
+type screeningAuditState struct {
+	Username   string  `json:"username"`
+	List       string  `json:"list"`
+	EntryID    string  `json:"entry_id"`
+	Score      float64 `json:"score"`
+	Status     string  `json:"status"`
+	ReviewedBy string  `json:"reviewed_by,omitempty"`
+}
+
+func newScreeningAuditState(hit db.ScreeningHit) screeningAuditState {
+	return screeningAuditState{
+		Username:   hit.Username,
+		List:       hit.List,
+		EntryID:    hit.EntryID,
+		Score:      hit.Score,
+		Status:     hit.Status,
+		ReviewedBy: hit.ReviewedBy,
+	}
+}
+
+// screenedName is a name to screen and the user it belongs to
+type screenedName struct {
+	username string
+	name     string
+}
+
+// screenNames screens names before action and records their hits. Unless
+// every hit was cleared by a reviewer it responds that the request awaits
+// a compliance review and returns false. The response does not say which
+// name matched or on which list, so that nobody is tipped off.
+func (server *Server) screenNames(ctx *gin.Context, action string, names ...screenedName) bool {
+	if server.screener == nil {
+		return true
+	}
+
+	blocked := false
+	for _, name := range names {
+		for _, match := range server.screener.Screen(name.name) {
+			hit, err := server.store.RecordScreeningHit(ctx, db.RecordScreeningHitParams{
+				Action:    action,
+				Username:  name.username,
+				Name:      name.name,
+				List:      match.Entry.List,
+				EntryID:   match.Entry.ID,
+				EntryName: match.MatchedName,
+				Program:   match.Entry.Program,
+				Score:     match.Score,
+			})
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+				return false
+			}
+			blocked = blocked || hit.Status != db.ScreeningStatusCleared
+		}
+	}
+
+	if blocked {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrComplianceReview))
+		return false
+	}
+	return true
+}
+
+type listScreeningHitsRequest struct {
+	Status   string `form:"status" binding:"omitempty,oneof=pending cleared confirmed"`
+	PageID   int32  `form:"page_id" binding:"required,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=50"`
+}
+
+// listScreeningHits lists hits oldest first, which with status=pending is
+// the review queue
+func (server *Server) listScreeningHits(ctx *gin.Context) {
+	var req listScreeningHitsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	hits, err := server.store.ListScreeningHits(ctx, db.ListScreeningHitsParams{
+		Status: sql.NullString{String: req.Status, Valid: req.Status != ""},
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := make([]screeningHitResponse, len(hits))
+	for i, hit := range hits {
+		rsp[i] = newScreeningHitResponse(hit)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type getScreeningHitRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getScreeningHit(ctx *gin.Context) {
+	var req getScreeningHitRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	hit, err := server.store.GetScreeningHit(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newScreeningHitResponse(hit))
+}
+
+type reviewScreeningHitRequest struct {
+	Note string `json:"note" binding:"required,max=1000"`
+}
+
+// clearScreeningHit marks a hit as a false positive, which lets the name
+// pass the next screening
+func (server *Server) clearScreeningHit(ctx *gin.Context) {
+	server.reviewScreeningHit(ctx, db.ScreeningStatusCleared)
+}
+
+// confirmScreeningHit marks a hit as a true match, which keeps blocking
+// the name
+func (server *Server) confirmScreeningHit(ctx *gin.Context) {
+	server.reviewScreeningHit(ctx, db.ScreeningStatusConfirmed)
+}
+
+// reviewScreeningHit records the decision of the caller on a pending hit.
+// Nobody reviews a hit on their own name.
+func (server *Server) reviewScreeningHit(ctx *gin.Context, status string) {
+	var uri getScreeningHitRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req reviewScreeningHitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	before, err := server.store.GetScreeningHit(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	if payload.Username == before.Username {
+		err := fmt.Errorf("%w: cannot review a screening hit on your own name", ErrForbidden)
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, err))
+		return
+	}
+
+	hit, err := server.store.ReviewScreeningHit(ctx, db.ReviewScreeningHitParams{
+		Status:     status,
+		ReviewNote: req.Note,
+		ReviewedBy: payload.Username,
+		ID:         uri.ID,
+	})
+	if err != nil {
+		// the hit exists, so it was reviewed already
+		if errors.Is(err, sql.ErrNoRows) {
+			err := fmt.Errorf("%w: hit %d is %s", ErrScreeningReviewed, uri.ID, before.Status)
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("screening_hit", hit.ID), newScreeningAuditState(before), newScreeningAuditState(hit))
+	ctx.JSON(http.StatusOK, newScreeningHitResponse(hit))
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/screening"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScreener(t *testing.T) *screening.Screener {
+	screener, err := screening.NewScreener(screening.DefaultThreshold, []screening.Entry{
+		{List: screening.ListOFAC, ID: "2676", Name: "HUSSEIN, Saddam", Program: "IRAQ2"},
+	})
+	require.NoError(t, err)
+	return screener
+}
+
+func TestCreateUserScreeningAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		fullName      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Blocked",
+			fullName: "Sadam Husein",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RecordScreeningHit(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScreeningHitParams) (db.ScreeningHit, error) {
+						require.Equal(t, db.ScreeningActionOnboarding, arg.Action)
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, "Sadam Husein", arg.Name)
+						require.Equal(t, screening.ListOFAC, arg.List)
+						require.Equal(t, "2676", arg.EntryID)
+						return db.ScreeningHit{ID: 1, Status: db.ScreeningStatusPending}, nil
+					})
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrComplianceReview.Code)
+				// nothing tells the customer which entry matched
+				require.NotContains(t, recorder.Body.String(), "2676")
+				require.NotContains(t, recorder.Body.String(), "HUSSEIN")
+			},
+		},
+		{
+			name:     "Cleared",
+			fullName: "Saddam Hussein",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RecordScreeningHit(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ScreeningHit{ID: 1, Status: db.ScreeningStatusCleared}, nil)
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(1).Return(user, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).AnyTimes()
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "NoMatch",
+			fullName: "Jane Doe",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().RecordScreeningHit(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(1).Return(user, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).AnyTimes()
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			fullName: "Saddam Hussein",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RecordScreeningHit(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ScreeningHit{}, sql.ErrConnDone)
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store, WithScreener(newTestScreener(t)), WithMailSender(&recordingSender{}))
+			recorder := httptest.NewRecorder()
+
+			body := fmt.Sprintf(`{"username":%q,"password":%q,"full_name":%q,"email":%q}`,
+				user.Username, password, tc.fullName, user.Email)
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBufferString(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateTransferScreeningAPI(t *testing.T) {
+	fromAccount := db.Account{ID: 1, Owner: "alice", Balance: 1000, Currency: "USD", Status: db.AccountStatusActive}
+	toAccount := db.Account{ID: 2, Owner: "saddam", Balance: 0, Currency: "USD", Status: db.AccountStatusActive}
+	owner := db.User{Username: "alice", FullName: "Alice Smith", IsEmailVerified: true}
+	recipient := db.User{Username: "saddam", FullName: "Saddam Hussein"}
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(owner.Username)).Times(1).Return(owner, nil)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(recipient.Username)).Times(1).Return(recipient, nil)
+	store.EXPECT().
+		RecordScreeningHit(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.RecordScreeningHitParams) (db.ScreeningHit, error) {
+			require.Equal(t, db.ScreeningActionTransfer, arg.Action)
+			require.Equal(t, recipient.Username, arg.Username)
+			return db.ScreeningHit{ID: 1, Status: db.ScreeningStatusConfirmed}, nil
+		})
+	store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store, WithScreener(newTestScreener(t)))
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(gin.H{"from_account_id": fromAccount.ID, "to_account_id": toAccount.ID, "amount": 100})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/api/v1/transfers", bytes.NewReader(data))
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, fromAccount.Owner, util.DepositorRole, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	requireErrorCode(t, recorder, http.StatusForbidden, ErrComplianceReview.Code)
+}
+
+func TestReviewScreeningHitAPI(t *testing.T) {
+	pending := db.ScreeningHit{
+		ID:        1,
+		Action:    db.ScreeningActionOnboarding,
+		Username:  "saddam",
+		Name:      "Saddam Hussein",
+		List:      screening.ListOFAC,
+		EntryID:   "2676",
+		EntryName: "HUSSEIN, Saddam",
+		Program:   "IRAQ2",
+		Score:     1,
+		Status:    db.ScreeningStatusPending,
+		CreatedAt: time.Now(),
+	}
+	cleared := pending
+	cleared.Status = db.ScreeningStatusCleared
+	cleared.ReviewNote = "different date of birth"
+	cleared.ReviewedBy = "admin"
+	cleared.ReviewedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		url           string
+		username      string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Clear",
+			url:      "/api/v1/screening/hits/1/clear",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScreeningHit(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().
+					ReviewScreeningHit(gomock.Any(), gomock.Eq(db.ReviewScreeningHitParams{
+						Status:     db.ScreeningStatusCleared,
+						ReviewNote: "different date of birth",
+						ReviewedBy: "admin",
+						ID:         pending.ID,
+					})).
+					Times(1).
+					Return(cleared, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "screening.cleared", arg.Action)
+						require.Equal(t, "screening_hit:1", arg.Target)
+						// the audit log cannot be erased, so it keeps no name
+						require.NotContains(t, string(arg.After), pending.Name)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp screeningHitResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.ScreeningStatusCleared, rsp.Status)
+				require.Equal(t, "admin", rsp.ReviewedBy)
+				require.NotNil(t, rsp.ReviewedAt)
+			},
+		},
+		{
+			name:     "OwnName",
+			url:      "/api/v1/screening/hits/1/clear",
+			username: pending.Username,
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScreeningHit(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().ReviewScreeningHit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "Reviewed",
+			url:      "/api/v1/screening/hits/1/confirm",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScreeningHit(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(cleared, nil)
+				store.EXPECT().ReviewScreeningHit(gomock.Any(), gomock.Any()).Times(1).Return(db.ScreeningHit{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrScreeningReviewed.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			url:      "/api/v1/screening/hits/1/confirm",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScreeningHit(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(db.ScreeningHit{}, sql.ErrNoRows)
+				store.EXPECT().ReviewScreeningHit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "Forbidden",
+			url:      "/api/v1/screening/hits/1/clear",
+			username: "user",
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScreeningHit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"note": "different date of birth"})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, tc.url, bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
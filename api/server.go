@@ -1,38 +1,126 @@
 package api
 
 import (
-	"time"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync/atomic"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/health"
+	"github.com/hiiamanop/simple_bank/logging"
+	"github.com/hiiamanop/simple_bank/mail"
+	"github.com/hiiamanop/simple_bank/metrics"
+	"github.com/hiiamanop/simple_bank/monitoring"
+	"github.com/hiiamanop/simple_bank/ratelimit"
+	"github.com/hiiamanop/simple_bank/screening"
+	"github.com/hiiamanop/simple_bank/token"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Server serves HTTP requests for our banking service
 type Server struct {
-	store  db.Store
-	router *gin.Engine
+	config      util.Config
+	store       db.Store
+	router      *gin.Engine
+	httpServer  *http.Server
+	idempotency *idempotencyCache
+	metrics     *metrics.Metrics
+	logger      *slog.Logger
+	readiness   *health.Checker
+	limiter     ratelimit.Limiter
+	tokenMaker  token.Maker
+	mailer      mail.Sender
+	monitor     *monitoring.Engine
+	screener    *screening.Screener
+
+	// live holds the configuration that can change at runtime, see Reload
+	live       atomic.Pointer[util.Config]
+	cors       atomic.Pointer[gin.HandlerFunc]
+	rateLimits atomic.Pointer[map[string]ratelimit.Limit]
+}
+
+// Option configures optional dependencies of a Server
+type Option func(*Server)
+
+// WithMetrics records the server metrics in m. Without it the server uses
+// a private registry.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(server *Server) {
+		server.metrics = m
+	}
+}
+
+// WithLogger writes the request logs to logger instead of slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(server *Server) {
+		server.logger = logger
+	}
 }
 
 // NewServer creates a new HTTP server and setup routing
-func NewServer(store db.Store) *Server {
+func NewServer(config util.Config, store db.Store, opts ...Option) *Server {
 	server := &Server{
-		store:  store,
-		router: gin.Default(),
+		config:      config,
+		store:       store,
+		router:      gin.New(),
+		idempotency: newIdempotencyCache(idempotencyKeyTTL),
+		tokenMaker:  token.NewJWTMaker(config.TokenSymmetricKey),
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	if server.metrics == nil {
+		server.metrics = metrics.New(prometheus.NewRegistry())
+	}
+	if server.logger == nil {
+		server.logger = slog.Default()
+	}
+	if server.mailer == nil {
+		server.mailer = mail.NewLogSender(server.logger)
+	}
+	if server.limiter == nil {
+		server.limiter = ratelimit.NewMemory()
+	}
+	if server.readiness == nil {
+		server.readiness = health.NewChecker(health.DefaultTimeout)
+	}
+	// validated by util.Config, so this cannot fail
+	if err := server.router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		server.logger.Error("invalid trusted proxies", slog.Any("error", err))
 	}
 
-	// Add CORS middleware with more permissive settings
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
-	config.AllowCredentials = true
-	config.ExposeHeaders = []string{"Content-Length"}
-	// Important: Enable CORS preflight requests
-	config.AllowWildcard = true
-	config.MaxAge = 12 * time.Hour
+	server.httpServer = &http.Server{
+		Handler:           server.router,
+		ReadTimeout:       config.HTTPReadTimeout,
+		ReadHeaderTimeout: config.HTTPReadHeaderTimeout,
+		WriteTimeout:      config.HTTPWriteTimeout,
+		IdleTimeout:       config.HTTPIdleTimeout,
+		MaxHeaderBytes:    config.HTTPMaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(server.logger.Handler(), slog.LevelWarn),
+	}
 
-	server.router.Use(cors.New(config))
+	// let handlers pass *gin.Context to the store while still carrying the
+	// request context, and with it the trace span
+	server.router.ContextWithFallback = true
+	server.router.Use(requestIDMiddleware())
+	server.router.Use(loggingMiddleware(server.logger))
+	server.router.Use(tracingMiddleware())
+	server.router.Use(metricsMiddleware(server.metrics))
+	server.router.Use(recoveryMiddleware(server.logger))
+	server.Reload(config)
+	server.router.Use(server.corsMiddleware)
+	server.router.Use(bodyLimitMiddleware(config.HTTPMaxBodyBytes))
 
 	// setup routing
 	server.setupRouter()
@@ -44,57 +132,261 @@ func (server *Server) setupRouter() {
 	// Add routes to the router
 	router := server.router
 
+	// Operational endpoints live outside /api/v1 so that the middleware of
+	// the API, such as authentication and rate limiting, never applies
+	router.GET("/metrics", gin.WrapH(server.metrics.Handler()))
+	router.GET("/healthz", server.healthz)
+	router.GET("/readyz", server.readyz)
+	router.GET("/version", server.version)
+
 	// Group routes under /api/v1
-	v1 := router.Group("/api/v1")
+	v1 := router.Group(apiBasePath)
+	v1.Use(server.authMiddleware)
+	v1.Use(server.rateLimitMiddleware)
+	v1.Use(idempotencyMiddleware(server.idempotency))
+	v1.Use(server.auditMiddleware)
 	{
+		// API documentation
+		v1.GET("/openapi.json", server.getOpenAPISpec)
+		v1.GET("/docs", server.getSwaggerUI)
+
+		v1.GET("/audit-events", requireAuth(util.AdminRole), server.listAuditEvents)
+
 		// Account routes
 		accounts := v1.Group("/accounts")
 		{
-			accounts.POST("", server.createAccount)
-			accounts.GET("/:id", server.getAccount)
-			accounts.GET("", server.listAccounts)
-			accounts.PUT("/:id", server.updateAccount)
-			accounts.DELETE("/:id", server.deleteAccount)
+			accounts.POST("", requireAuth(), server.createAccount)
+			accounts.GET("/:id", requireAuth(), server.getAccount)
+			accounts.GET("", requireAuth(), server.listAccounts)
+			accounts.PUT("/:id", requireAuth(util.AdminRole), server.updateAccount)
+			// kept for old clients, closes the account like POST /:id/close
+			accounts.DELETE("/:id", requireAuth(), server.closeAccount)
+			accounts.POST("/:id/freeze", requireAuth(util.AdminRole), server.freezeAccount)
+			accounts.POST("/:id/unfreeze", requireAuth(util.AdminRole), server.unfreezeAccount)
+			accounts.POST("/:id/close", requireAuth(), server.closeAccount)
+			accounts.POST("/:id/reopen", requireAuth(util.AdminRole), server.reopenAccount)
+			accounts.GET("/:id/limits", requireAuth(), server.getTransferAllowance)
+			accounts.GET("/:id/approval-policy", requireAuth(), server.getApprovalPolicy)
+			accounts.PUT("/:id/approval-policy", requireAuth(util.AdminRole), server.setApprovalPolicy)
+			accounts.DELETE("/:id/approval-policy", requireAuth(util.AdminRole), server.deleteApprovalPolicy)
 		}
 
 		// Entry routes
 		entries := v1.Group("/entries")
 		{
-			entries.POST("", server.createEntry)
-			entries.GET("/:id", server.getEntry)
-			entries.GET("", server.listEntries)
-			entries.PUT("/:id", server.updateEntry)
-			entries.DELETE("/:id", server.deleteEntry)
+			entries.POST("", requireAuth(util.AdminRole), server.createEntry)
+			entries.GET("/:id", requireAuth(), server.getEntry)
+			entries.GET("", requireAuth(), server.listEntries)
 		}
 
 		// Transfer routes
 		transfers := v1.Group("/transfers")
 		{
-			transfers.POST("", server.createTransfer)
-			transfers.GET("/:id", server.getTransfer)
-			transfers.GET("", server.listTransfers)
-			transfers.PUT("/:id", server.updateTransfer)
-			transfers.DELETE("/:id", server.deleteTransfer)
+			transfers.POST("", requireAuth(), server.createTransfer)
+			transfers.GET("/:id", requireAuth(), server.getTransfer)
+			transfers.GET("/:id/timeline", requireAuth(), server.getTransferTimeline)
+			transfers.GET("", requireAuth(), server.listTransfers)
+			transfers.POST("/:id/reverse", requireAuth(util.AdminRole), server.reverseTransfer)
 		}
 
 		// User routes
 		users := v1.Group("/users")
 		{
 			users.POST("", server.createUser)
-			users.GET("/:username", server.getUser)
-			users.GET("", server.listUsers)
-			users.PUT("/:username", server.updateUser)
-			users.DELETE("/:username", server.deleteUser)
+			users.POST("/login", server.loginUser)
+			users.POST("/totp", requireAuth(), server.enrollTOTP)
+			users.POST("/totp/activate", requireAuth(), server.activateTOTP)
+			users.POST("/step-up", requireAuth(), server.stepUp)
+			users.POST("/verify-email", requireAuth(), server.resendVerificationEmail)
+			users.POST("/verify-email/confirm", server.verifyEmail)
+			users.POST("/password-reset", server.requestPasswordReset)
+			users.POST("/password-reset/confirm", server.resetPassword)
+			users.POST("/:username/unlock", requireAuth(util.AdminRole), server.unlockUser)
+			users.POST("/:username/erase", requireAuth(util.AdminRole), server.eraseUser)
+			users.GET("/:username/export", requireAuth(), server.exportUser)
+			users.GET("/:username/kyc", requireAuth(), server.getKYCProfile)
+			users.PUT("/:username/kyc", requireAuth(), server.submitKYCProfile)
+			users.GET("/:username", requireAuth(), server.getUser)
+			users.GET("", requireAuth(util.AdminRole), server.listUsers)
+			users.PUT("/:username", requireAuth(), server.updateUser)
+			users.DELETE("/:username", requireAuth(), server.deleteUser)
+		}
+
+		// KYC review routes, the profiles themselves live under /users
+		kyc := v1.Group("/kyc")
+		{
+			kyc.GET("/tiers", requireAuth(), server.listKYCTiers)
+			kyc.GET("/profiles", requireAuth(util.AdminRole), server.listKYCProfiles)
+			kyc.POST("/profiles/:username/approve", requireAuth(util.AdminRole), server.approveKYCProfile)
+			kyc.POST("/profiles/:username/reject", requireAuth(util.AdminRole), server.rejectKYCProfile)
+		}
+
+		// Transfer limit routes, the allowance of an account lives under /accounts
+		limits := v1.Group("/limits", requireAuth(util.AdminRole))
+		{
+			limits.GET("", server.listTransferLimits)
+			limits.PUT("/defaults/:scope", server.setDefaultTransferLimit)
+			limits.PUT("/accounts/:id", server.setAccountTransferLimit)
+			limits.DELETE("/accounts/:id", server.deleteAccountTransferLimit)
+			limits.PUT("/users/:username", server.setUserTransferLimit)
+			limits.DELETE("/users/:username", server.deleteUserTransferLimit)
+		}
+
+		// Transaction monitoring routes, the case management of analysts
+		monitor := v1.Group("/monitoring", requireAuth(util.AdminRole))
+		{
+			monitor.GET("/rules", server.listMonitoringRules)
+			monitor.GET("/alerts", server.listMonitoringAlerts)
+			monitor.GET("/alerts/:id", server.getMonitoringAlert)
+			monitor.POST("/alerts/:id/assign", server.assignMonitoringAlert)
+			monitor.POST("/alerts/:id/close", server.closeMonitoringAlert)
+			monitor.POST("/alerts/:id/release", server.releaseHeldTransfer)
+			monitor.POST("/alerts/:id/reject", server.rejectHeldTransfer)
+		}
+
+		// Sanctions screening routes, the review of hits
+		screeningHits := v1.Group("/screening", requireAuth(util.AdminRole))
+		{
+			screeningHits.GET("/hits", server.listScreeningHits)
+			screeningHits.GET("/hits/:id", server.getScreeningHit)
+			screeningHits.POST("/hits/:id/clear", server.clearScreeningHit)
+			screeningHits.POST("/hits/:id/confirm", server.confirmScreeningHit)
+		}
+
+		// Transfer approval routes, the policies live under /accounts
+		approvals := v1.Group("/transfer-approvals", requireAuth())
+		{
+			approvals.GET("", server.listTransferApprovals)
+			approvals.GET("/:id", server.getTransferApproval)
+			approvals.POST("/:id/approve", server.approveTransferApproval)
+			approvals.POST("/:id/reject", server.rejectTransferApproval)
 		}
 	}
 }
 
-// Start runs the HTTP server on a specific address
+// ServeHTTP lets the server be mounted as an http.Handler, e.g. in httptest
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.router.ServeHTTP(w, r)
+}
+
+// Reload applies the reloadable settings of config, such as the CORS
+// origins, the rate limits and the feature flags, to the running server
+func (server *Server) Reload(config util.Config) {
+	server.live.Store(&config)
+
+	var handler gin.HandlerFunc
+	if len(config.CORSAllowOrigins) > 0 {
+		handler = cors.New(newCORSConfig(config))
+	}
+	server.cors.Store(&handler)
+
+	rateLimits := newRateLimits(config)
+	server.rateLimits.Store(&rateLimits)
+}
+
+// corsMiddleware delegates to the CORS handler of the live configuration,
+// if CORS is enabled
+func (server *Server) corsMiddleware(ctx *gin.Context) {
+	if handler := *server.cors.Load(); handler != nil {
+		handler(ctx)
+	}
+}
+
+// featureEnabled reports whether the live configuration enables feature
+func (server *Server) featureEnabled(feature string) bool {
+	return server.live.Load().FeatureEnabled(feature)
+}
+
+// newCORSConfig translates the CORS settings of config
+func newCORSConfig(config util.Config) cors.Config {
+	corsConfig := cors.DefaultConfig()
+	if slices.Equal(config.CORSAllowOrigins, []string{"*"}) {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = config.CORSAllowOrigins
+	}
+	corsConfig.AllowMethods = config.CORSAllowMethods
+	corsConfig.AllowHeaders = config.CORSAllowHeaders
+	corsConfig.AllowCredentials = config.CORSAllowCredentials
+	corsConfig.ExposeHeaders = []string{
+		"Content-Length", RequestIDHeader, "Retry-After",
+		RateLimitLimitHeader, RateLimitRemainingHeader, RateLimitResetHeader, RateLimitPolicyHeader,
+	}
+	// allow origins such as https://*.example.com
+	corsConfig.AllowWildcard = true
+	corsConfig.MaxAge = config.CORSMaxAge
+	return corsConfig
+}
+
+// Start runs the HTTP server on a specific address. It returns nil once the
+// server has been shut down.
 func (server *Server) Start(address string) error {
-	return server.router.Run(address)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
 }
 
-// errorResponse is a helper function to return error responses
-func errorResponse(err error) gin.H {
-	return gin.H{"error": err.Error()}
+// Serve accepts connections on listener until the server is shut down. It
+// serves HTTPS when a TLS certificate is configured.
+func (server *Server) Serve(listener net.Listener) error {
+	var err error
+	if server.config.TLSEnabled() {
+		tlsConfig, tlsErr := newTLSConfig(server.config)
+		if tlsErr != nil {
+			return tlsErr
+		}
+		server.httpServer.TLSConfig = tlsConfig
+		err = server.httpServer.ServeTLS(listener, server.config.TLSCertFile, server.config.TLSKeyFile)
+	} else {
+		err = server.httpServer.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// newTLSConfig requires client certificates signed by the configured client
+// CA when mutual TLS is enabled
+func newTLSConfig(config util.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(config.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", config.TLSClientCAFile)
+	}
+
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// Shutdown stops accepting new connections and waits for in-flight requests,
+// such as transfers, to complete or for ctx to expire
+func (server *Server) Shutdown(ctx context.Context) error {
+	return server.httpServer.Shutdown(ctx)
+}
+
+// errorResponse is a helper function to return error responses. The error
+// is also attached to ctx so that the request log records it.
+func errorResponse(ctx *gin.Context, err error) gin.H {
+	_ = ctx.Error(err)
+
+	body := gin.H{"error": err.Error()}
+	if code := errorCode(err); code != "" {
+		body["code"] = code
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		body["request_id"] = id
+	}
+	return body
 }
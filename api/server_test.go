@@ -0,0 +1,408 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLimit(t *testing.T) {
+	account := RandomAccount()
+
+	testCases := []struct {
+		name          string
+		maxBodyBytes  int64
+		body          string
+		chunked       bool
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "WithinLimit",
+			maxBodyBytes: 1024,
+			body:         fmt.Sprintf(`{"currency":%q}`, account.Currency),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:         "ContentLengthTooLarge",
+			maxBodyBytes: 16,
+			body:         fmt.Sprintf(`{"currency":%q}`, account.Currency),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusRequestEntityTooLarge, ErrRequestTooLarge.Code)
+			},
+		},
+		{
+			name:         "ChunkedBodyTooLarge",
+			maxBodyBytes: 16,
+			body:         fmt.Sprintf(`{"currency":%q}`, account.Currency),
+			chunked:      true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusRequestEntityTooLarge, ErrRequestTooLarge.Code)
+			},
+		},
+		{
+			name:         "NoLimit",
+			maxBodyBytes: 0,
+			body:         fmt.Sprintf(`{"currency":%q,"padding":%q}`, account.Currency, strings.Repeat("x", 4096)),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			store.EXPECT().AppendAuditEventTx(gomock.Any(), gomock.Any()).AnyTimes()
+
+			server := NewServer(util.Config{HTTPMaxBodyBytes: tc.maxBodyBytes}, store)
+			recorder := httptest.NewRecorder()
+
+			var body io.Reader = strings.NewReader(tc.body)
+			if tc.chunked {
+				// hide the length so the limit is enforced while reading
+				body = io.MultiReader(body)
+			}
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/accounts", body)
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, util.DepositorRole, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	account := RandomAccount()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, id int64) (db.Account, error) {
+			close(started)
+			<-release
+			return account, nil
+		})
+
+	server := newTestServer(t, store)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	type result struct {
+		status int
+		err    error
+	}
+	url := fmt.Sprintf("http://%s/api/v1/accounts/%d", listener.Addr(), account.ID)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, util.DepositorRole, time.Minute)
+
+	responses := make(chan result, 1)
+	go func() {
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer response.Body.Close()
+		responses <- result{status: response.StatusCode}
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned before the request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	r := <-responses
+	require.NoError(t, r.err)
+	require.Equal(t, http.StatusOK, r.status)
+	require.NoError(t, <-shutdown)
+	require.NoError(t, <-served)
+}
+
+func requireErrorCode(t *testing.T, recorder *httptest.ResponseRecorder, status int, code string) {
+	require.Equal(t, status, recorder.Code)
+
+	var body Error
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	require.NoError(t, err)
+	require.Equal(t, code, body.Code)
+}
+
+func TestCORS(t *testing.T) {
+	testCases := []struct {
+		name          string
+		origins       []string
+		origin        string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "AllowedOrigin",
+			origins: []string{"https://app.example.com"},
+			origin:  "https://app.example.com",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+				require.Equal(t, "https://app.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+			},
+		},
+		{
+			name:    "WildcardSubdomain",
+			origins: []string{"https://*.example.com"},
+			origin:  "https://admin.example.com",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, "https://admin.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+			},
+		},
+		{
+			name:    "ForbiddenOrigin",
+			origins: []string{"https://app.example.com"},
+			origin:  "https://evil.example.org",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+			},
+		},
+		{
+			name:   "Disabled",
+			origin: "https://app.example.com",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			config := util.Config{
+				CORSAllowOrigins: tc.origins,
+				CORSAllowMethods: []string{"GET", "POST"},
+				CORSAllowHeaders: []string{"Content-Type"},
+			}
+			server := NewServer(config, mockdb.NewMockStore(ctrl))
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodOptions, "/api/v1/accounts", nil)
+			require.NoError(t, err)
+			request.Header.Set("Origin", tc.origin)
+			request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverCert := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+
+	config := util.Config{
+		TLSCertFile:     writePEM(t, dir, "server.pem", "CERTIFICATE", serverCert.Certificate[0]),
+		TLSKeyFile:      writeKey(t, dir, "server-key.pem", serverCert.PrivateKey),
+		TLSClientCAFile: writePEM(t, dir, "ca.pem", "CERTIFICATE", ca.cert.Raw),
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := NewServer(config, mockdb.NewMockStore(ctrl))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	url := fmt.Sprintf("https://%s/healthz", listener.Addr())
+
+	withClientCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+	response, err := withClientCert.Get(url)
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	withoutClientCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	_, err = withoutClientCert.Get(url)
+	require.Error(t, err)
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, data []byte) string {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600)
+	require.NoError(t, err)
+	return path
+}
+
+func writeKey(t *testing.T, dir, name string, key crypto.PrivateKey) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return writePEM(t, dir, name, "PRIVATE KEY", der)
+}
+
+func TestReload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	config := util.Config{
+		CORSAllowOrigins: []string{"https://old.example.com"},
+		CORSAllowMethods: []string{"GET"},
+	}
+	server := NewServer(config, mockdb.NewMockStore(ctrl))
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodOptions, "/api/v1/accounts", nil)
+		require.NoError(t, err)
+		request.Header.Set("Origin", origin)
+		request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	docs := func(path string) int {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		server.router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, preflight("https://old.example.com").Code)
+	require.Equal(t, http.StatusNotFound, docs("/api/v1/docs"))
+	require.Equal(t, http.StatusNotFound, docs("/api/v1/openapi.json"))
+
+	config.CORSAllowOrigins = []string{"https://new.example.com"}
+	config.FeatureFlags = []string{util.FeatureAPIDocs}
+	server.Reload(config)
+
+	require.Equal(t, http.StatusForbidden, preflight("https://old.example.com").Code)
+	require.Equal(t, http.StatusNoContent, preflight("https://new.example.com").Code)
+	require.Equal(t, http.StatusOK, docs("/api/v1/docs"))
+	require.Equal(t, http.StatusOK, docs("/api/v1/openapi.json"))
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/token"
+	"github.com/pquerna/otp/totp"
+)
+
+// StepUpTokenHeader carries the step-up token that authorizes sensitive
+// operations, such as large transfers
+const StepUpTokenHeader = "X-Step-Up-Token"
+
+const (
+	recoveryCodeCount = 10
+	// 32 characters, so that every random byte maps to one without bias,
+	// and without i, l and o, which are easily mistaken
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz123456789"
+	qrCodeSize           = 256
+)
+
+type enrollTOTPResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+	// QRCodePNG is the otpauth URL as a QR code image, base64 encoded in
+	// JSON
+	QRCodePNG []byte `json:"qr_code_png"`
+}
+
+// enrollTOTP generates a new TOTP secret for the caller. The secret is not
+// used until it is confirmed with activateTOTP.
+func (server *Server) enrollTOTP(ctx *gin.Context) {
+	payload, _ := authPayload(ctx)
+
+	user, err := server.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if user.TotpEnabled {
+		ctx.JSON(http.StatusConflict, errorResponse(ctx, ErrTOTPEnabled))
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      server.config.TOTPIssuer,
+		AccountName: user.Username,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	image, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	var qrCode bytes.Buffer
+	if err := png.Encode(&qrCode, image); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	_, err = server.store.SetTOTPSecret(ctx, db.SetTOTPSecretParams{
+		Username:   user.Username,
+		TotpSecret: key.Secret(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	// the secret itself stays out of the audit log
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, nil)
+	ctx.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret:     key.Secret(),
+		OTPAuthURL: key.URL(),
+		QRCodePNG:  qrCode.Bytes(),
+	})
+}
+
+type activateTOTPRequest struct {
+	TOTPCode string `json:"totp_code" binding:"required,numeric,len=6"`
+}
+
+type activateTOTPResponse struct {
+	// RecoveryCodes are shown only once; each can replace a TOTP code once
+	RecoveryCodes []string     `json:"recovery_codes"`
+	User          userResponse `json:"user"`
+}
+
+// activateTOTP turns on two-factor authentication once the caller proves
+// that their authenticator produces codes for the enrolled secret
+func (server *Server) activateTOTP(ctx *gin.Context) {
+	var req activateTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	payload, _ := authPayload(ctx)
+
+	user, err := server.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if user.TotpEnabled {
+		ctx.JSON(http.StatusConflict, errorResponse(ctx, ErrTOTPEnabled))
+		return
+	}
+	if user.TotpSecret == "" {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, ErrTOTPNotEnrolled))
+		return
+	}
+	if !totp.Validate(req.TOTPCode, user.TotpSecret) {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(ctx, ErrInvalidTOTP))
+		return
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	hashedCodes := make([]string, len(codes))
+	for i, code := range codes {
+		hashedCodes[i] = hashRecoveryCode(code)
+	}
+
+	before := user
+	user, err = server.store.EnableTOTPTx(ctx, db.EnableTOTPTxParams{
+		Username:            user.Username,
+		HashedRecoveryCodes: hashedCodes,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := activateTOTPResponse{
+		RecoveryCodes: codes,
+		User:          newUserResponse(user),
+	}
+	setAuditChange(ctx, auditTarget("user", user.Username), newUserAuditState(before), newUserAuditState(user))
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type stepUpRequest struct {
+	TOTPCode     string `json:"totp_code" binding:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code" binding:"required_without=TOTPCode"`
+}
+
+type stepUpResponse struct {
+	StepUpToken          string    `json:"step_up_token"`
+	StepUpTokenExpiresAt time.Time `json:"step_up_token_expires_at"`
+}
+
+// stepUp verifies a second factor of the logged in caller and issues a
+// fresh step-up token. Wrong codes count as failed logins, so guessing
+// them leads to a lockout like guessing passwords.
+func (server *Server) stepUp(ctx *gin.Context) {
+	var req stepUpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	payload, _ := authPayload(ctx)
+
+	user, err := server.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if lockedFor := time.Until(user.LockedUntil); lockedFor > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(lockedFor)))
+		ctx.JSON(http.StatusLocked, errorResponse(ctx, ErrUserLocked))
+		return
+	}
+	if !user.TotpEnabled {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, ErrTOTPNotEnrolled))
+		return
+	}
+
+	ok, err := server.verifySecondFactor(ctx, user, req.TOTPCode, req.RecoveryCode)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if !ok {
+		server.failLogin(ctx, user, ErrInvalidTOTP)
+		return
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if _, err := server.store.ResetFailedLogins(ctx, user.Username); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+			return
+		}
+	}
+
+	stepUpToken, stepUpPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, token.PurposeStepUp, server.config.StepUpTokenDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, nil)
+	ctx.JSON(http.StatusOK, stepUpResponse{
+		StepUpToken:          stepUpToken,
+		StepUpTokenExpiresAt: stepUpPayload.ExpiredAt,
+	})
+}
+
+// verifySecondFactor checks a TOTP code, or else consumes a recovery code
+func (server *Server) verifySecondFactor(ctx *gin.Context, user db.User, totpCode string, recoveryCode string) (bool, error) {
+	if recoveryCode == "" {
+		return totp.Validate(totpCode, user.TotpSecret), nil
+	}
+
+	_, err := server.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+		Username:   user.Username,
+		HashedCode: hashRecoveryCode(recoveryCode),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// verifyStepUp checks that the request carries a fresh step-up token of
+// username
+func (server *Server) verifyStepUp(ctx *gin.Context, username string) error {
+	payload, err := server.tokenMaker.VerifyToken(ctx.GetHeader(StepUpTokenHeader))
+	if err != nil || payload.Purpose != token.PurposeStepUp || payload.Username != username {
+		return ErrStepUpRequired
+	}
+	return nil
+}
+
+// newRecoveryCodes returns random codes formatted as xxxxx-xxxxx
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[b[j]%32]
+		}
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode hashes a recovery code regardless of case, dashes and
+// spaces. The codes are random enough that a fast hash is safe.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
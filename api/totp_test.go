@@ -0,0 +1,516 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/token"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+// randomTOTPUser returns a user with two-factor authentication enabled
+func randomTOTPUser(t *testing.T) (user db.User, password string) {
+	user, password = randomUser(t)
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "SimpleBank", AccountName: user.Username})
+	require.NoError(t, err)
+
+	user.TotpSecret = key.Secret()
+	user.TotpEnabled = true
+	return
+}
+
+func totpCode(t *testing.T, secret string) string {
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+func TestEnrollTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	enabled, _ := randomTOTPUser(t)
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore, user db.User)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: user,
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					SetTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.SetTOTPSecretParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.NotEmpty(t, arg.TotpSecret)
+						user.TotpSecret = arg.TotpSecret
+						return user, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp enrollTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp.Secret)
+				require.Contains(t, rsp.OTPAuthURL, "otpauth://totp/SimpleBank:"+user.Username)
+				require.Equal(t, []byte("\x89PNG"), rsp.QRCodePNG[:4])
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			user: enabled,
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().SetTOTPSecret(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrTOTPEnabled.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store, tc.user)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/totp", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, tc.user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestActivateTOTPAPI(t *testing.T) {
+	enrolled, _ := randomTOTPUser(t)
+	enrolled.TotpEnabled = false
+	notEnrolled, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		code          func(t *testing.T) string
+		buildStubs    func(store *mockdb.MockStore, user db.User)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: enrolled,
+			code: func(t *testing.T) string { return totpCode(t, enrolled.TotpSecret) },
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					EnableTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.EnableTOTPTxParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Len(t, arg.HashedRecoveryCodes, recoveryCodeCount)
+						user.TotpEnabled = true
+						return user, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp activateTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp.RecoveryCodes, recoveryCodeCount)
+				require.Regexp(t, `^[a-z1-9]{5}-[a-z1-9]{5}$`, rsp.RecoveryCodes[0])
+				require.True(t, rsp.User.TOTPEnabled)
+				require.NotContains(t, recorder.Body.String(), enrolled.TotpSecret)
+			},
+		},
+		{
+			name: "InvalidCode",
+			user: enrolled,
+			code: func(t *testing.T) string { return "000000" },
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().EnableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidTOTP.Code)
+			},
+		},
+		{
+			name: "NotEnrolled",
+			user: notEnrolled,
+			code: func(t *testing.T) string { return "123456" },
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().EnableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrTOTPNotEnrolled.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store, tc.user)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body := fmt.Sprintf(`{"totp_code":%q}`, tc.code(t))
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/totp/activate", bytes.NewBufferString(body))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, tc.user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestStepUpAPI(t *testing.T) {
+	user, _ := randomTOTPUser(t)
+	recoveryCode := "abcde-fghjk"
+
+	testCases := []struct {
+		name          string
+		body          func(t *testing.T) string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker)
+	}{
+		{
+			name: "TOTPCode",
+			body: func(t *testing.T) string {
+				return fmt.Sprintf(`{"totp_code":%q}`, totpCode(t, user.TotpSecret))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp stepUpResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				payload, err := tokenMaker.VerifyToken(rsp.StepUpToken)
+				require.NoError(t, err)
+				require.Equal(t, token.PurposeStepUp, payload.Purpose)
+				require.Equal(t, user.Username, payload.Username)
+			},
+		},
+		{
+			name: "RecoveryCode",
+			body: func(t *testing.T) string {
+				return `{"recovery_code":"ABCDE FGHJK"}`
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UseRecoveryCodeParams{
+					Username:   user.Username,
+					HashedCode: hashRecoveryCode(recoveryCode),
+				}
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.RecoveryCode{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: func(t *testing.T) string {
+				return `{"totp_code":"000000"}`
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				failed := user
+				failed.FailedLoginAttempts = 1
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(1).Return(failed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidTOTP.Code)
+			},
+		},
+		{
+			name: "Locked",
+			body: func(t *testing.T) string {
+				return fmt.Sprintf(`{"totp_code":%q}`, totpCode(t, user.TotpSecret))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				locked := user
+				locked.LockedUntil = time.Now().Add(time.Minute)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(locked, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				requireErrorCode(t, recorder, http.StatusLocked, ErrUserLocked.Code)
+			},
+		},
+		{
+			name: "NoCode",
+			body: func(t *testing.T) string {
+				return `{}`
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/step-up", bytes.NewBufferString(tc.body(t)))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server.tokenMaker)
+		})
+	}
+}
+
+func TestLoginWithTOTP(t *testing.T) {
+	user, password := randomTOTPUser(t)
+
+	testCases := []struct {
+		name          string
+		body          func(t *testing.T) string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: func(t *testing.T) string {
+				return fmt.Sprintf(`{"username":%q,"password":%q,"totp_code":%q}`, user.Username, password, totpCode(t, user.TotpSecret))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp.AccessToken)
+				require.NotEmpty(t, rsp.StepUpToken)
+				require.NotNil(t, rsp.StepUpTokenExpiresAt)
+			},
+		},
+		{
+			name: "CodeRequired",
+			body: func(t *testing.T) string {
+				return fmt.Sprintf(`{"username":%q,"password":%q}`, user.Username, password)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrTOTPRequired.Code)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: func(t *testing.T) string {
+				return fmt.Sprintf(`{"username":%q,"password":%q,"totp_code":"000000"}`, user.Username, password)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				failed := user
+				failed.FailedLoginAttempts = 1
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().RecordFailedLogin(gomock.Any(), gomock.Any()).Times(1).Return(failed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidTOTP.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/login", bytes.NewBufferString(tc.body(t)))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestTransferStepUp(t *testing.T) {
+	account := db.Account{
+		ID:       int64(util.RandomInt(1, 1000)),
+		Owner:    util.RandomOwner(),
+		Balance:  1000,
+		Currency: "USD",
+		Status:   db.AccountStatusActive,
+	}
+
+	testCases := []struct {
+		name          string
+		caller        string
+		callerRole    string
+		setupStepUp   func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:        "Missing",
+			setupStepUp: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrStepUpRequired.Code)
+			},
+		},
+		{
+			name: "AccessTokenInstead",
+			setupStepUp: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				stepUpToken, _, err := tokenMaker.CreateToken(account.Owner, util.DepositorRole, token.PurposeAccess, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(StepUpTokenHeader, stepUpToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrStepUpRequired.Code)
+			},
+		},
+		{
+			name: "OtherUser",
+			setupStepUp: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				stepUpToken, _, err := tokenMaker.CreateToken(util.RandomOwner(), util.DepositorRole, token.PurposeStepUp, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(StepUpTokenHeader, stepUpToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrStepUpRequired.Code)
+			},
+		},
+		{
+			name:       "AdminWithOwnerToken",
+			caller:     "admin",
+			callerRole: util.AdminRole,
+			setupStepUp: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				stepUpToken, _, err := tokenMaker.CreateToken(account.Owner, util.DepositorRole, token.PurposeStepUp, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(StepUpTokenHeader, stepUpToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrStepUpRequired.Code)
+			},
+		},
+		{
+			name:       "AdminVerified",
+			caller:     "admin",
+			callerRole: util.AdminRole,
+			setupStepUp: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				stepUpToken, _, err := tokenMaker.CreateToken("admin", util.AdminRole, token.PurposeStepUp, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(StepUpTokenHeader, stepUpToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInsufficientBalance.Code)
+			},
+		},
+		{
+			name: "Verified",
+			setupStepUp: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				stepUpToken, _, err := tokenMaker.CreateToken(account.Owner, util.DepositorRole, token.PurposeStepUp, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(StepUpTokenHeader, stepUpToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				// past the step-up check, the balance check rejects the transfer
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInsufficientBalance.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			store.EXPECT().
+				GetUser(gomock.Any(), gomock.Eq(account.Owner)).
+				Times(1).
+				Return(db.User{Username: account.Owner, IsEmailVerified: true}, nil)
+			store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+			server := newTestServer(t, store)
+			server.config.StepUpTransferAmount = 100
+
+			body, err := json.Marshal(gin.H{
+				"from_account_id": account.ID,
+				"to_account_id":   account.ID + 1,
+				"amount":          account.Balance + 1,
+			})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/transfers", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			caller, callerRole := account.Owner, util.DepositorRole
+			if tc.caller != "" {
+				caller, callerRole = tc.caller, tc.callerRole
+			}
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, caller, callerRole, time.Minute)
+			tc.setupStepUp(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
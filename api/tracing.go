@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiiamanop/simple_bank/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/hiiamanop/simple_bank/api"
+
+// tracingMiddleware starts a server span for every request, continuing the
+// trace of the caller when it sent a traceparent header. The span is stored
+// in the request context, which the handlers pass on to the store.
+func tracingMiddleware() gin.HandlerFunc {
+	tracer := otel.Tracer(tracerName)
+
+	return func(ctx *gin.Context) {
+		request := ctx.Request
+		parent := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		spanCtx, span := tracer.Start(parent, request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(request.URL.Path),
+				attribute.String("request.id", logging.RequestID(request.Context())),
+			),
+		)
+		defer span.End()
+
+		ctx.Request = request.WithContext(spanCtx)
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		if len(ctx.Errors) > 0 {
+			span.RecordError(ctx.Errors.Last())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return recorder
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	spans := recordSpans(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	account := RandomAccount()
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, id int64) (db.Account, error) {
+			// the store must see the server span through *gin.Context
+			spanContext := trace.SpanContextFromContext(ctx)
+			require.True(t, spanContext.IsValid())
+			require.Equal(t, traceID, spanContext.TraceID().String())
+			return account, nil
+		})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/accounts/%d", account.ID), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, util.DepositorRole, time.Minute)
+	request.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+
+	span := ended[0]
+	require.Equal(t, "GET /api/v1/accounts/:id", span.Name())
+	require.Equal(t, trace.SpanKindServer, span.SpanKind())
+	require.Equal(t, traceID, span.SpanContext().TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	require.Contains(t, span.Attributes(), attribute.Int64("account.id", account.ID))
+	require.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+}
@@ -0,0 +1,526 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+// approvalExpiryInterval is how often ExpireTransferApprovals looks for
+// approvals that were not decided in time
+const approvalExpiryInterval = time.Minute
+
+// approvalPolicyResponse is the dual control of an account: transfers above
+// the threshold need required_approvals of the approvers
+type approvalPolicyResponse struct {
+	AccountID         int64     `json:"account_id"`
+	Threshold         int64     `json:"threshold"`
+	RequiredApprovals int32     `json:"required_approvals"`
+	Approvers         []string  `json:"approvers"`
+	UpdatedBy         string    `json:"updated_by"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func newApprovalPolicyResponse(policy db.ApprovalPolicy, approvers []string) approvalPolicyResponse {
+	return approvalPolicyResponse{
+		AccountID:         policy.AccountID,
+		Threshold:         policy.Threshold,
+		RequiredApprovals: policy.RequiredApprovals,
+		Approvers:         approvers,
+		UpdatedBy:         policy.UpdatedBy,
+		UpdatedAt:         policy.UpdatedAt,
+	}
+}
+
+type approvalDecisionResponse struct {
+	Approver  string    `json:"approver"`
+	Decision  string    `json:"decision"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type transferApprovalResponse struct {
+	ID                int64                      `json:"id"`
+	FromAccountID     int64                      `json:"from_account_id"`
+	ToAccountID       int64                      `json:"to_account_id"`
+	Amount            int64                      `json:"amount"`
+	InitiatedBy       string                     `json:"initiated_by"`
+	RequiredApprovals int32                      `json:"required_approvals"`
+	Approvals         int32                      `json:"approvals"`
+	Status            string                     `json:"status"`
+	TransferID        *int64                     `json:"transfer_id,omitempty"`
+	FailureReason     string                     `json:"failure_reason,omitempty"`
+	ExpiresAt         time.Time                  `json:"expires_at"`
+	DecidedAt         *time.Time                 `json:"decided_at,omitempty"`
+	CreatedAt         time.Time                  `json:"created_at"`
+	Decisions         []approvalDecisionResponse `json:"decisions,omitempty"`
+}
+
+func newTransferApprovalResponse(approval db.TransferApproval) transferApprovalResponse {
+	rsp := transferApprovalResponse{
+		ID:                approval.ID,
+		FromAccountID:     approval.FromAccountID,
+		ToAccountID:       approval.ToAccountID,
+		Amount:            approval.Amount,
+		InitiatedBy:       approval.InitiatedBy,
+		RequiredApprovals: approval.RequiredApprovals,
+		Approvals:         approval.Approvals,
+		Status:            approval.Status,
+		FailureReason:     approval.FailureReason,
+		ExpiresAt:         approval.ExpiresAt,
+		DecidedAt:         nullTime(approval.DecidedAt),
+		CreatedAt:         approval.CreatedAt,
+	}
+	if approval.TransferID.Valid {
+		rsp.TransferID = &approval.TransferID.Int64
+	}
+	return rsp
+}
+
+// pendingApprovalResponse answers a transfer that waits for its approvers.
+// No money moved.
+type pendingApprovalResponse struct {
+	Status   string                   `json:"status"`
+	Approval transferApprovalResponse `json:"approval"`
+}
+
+// requestTransferApproval records a transfer above the threshold of policy
+// as pending for its approvers instead of executing it. The caller is the
+// initiator and cannot approve it.
+func (server *Server) requestTransferApproval(ctx *gin.Context, req createTransferRequest, policy db.ApprovalPolicy) {
+	payload, _ := authPayload(ctx)
+
+	result, err := server.store.CreateTransferApprovalTx(ctx, db.CreateTransferApprovalTxParams{
+		FromAccountID:     req.FromAccountID,
+		ToAccountID:       req.ToAccountID,
+		Amount:            req.Amount,
+		InitiatedBy:       payload.Username,
+		RequiredApprovals: policy.RequiredApprovals,
+		ExpiresAt:         time.Now().Add(server.config.TransferApprovalTTL),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := pendingApprovalResponse{
+		Status:   result.Approval.Status,
+		Approval: newTransferApprovalResponse(result.Approval),
+	}
+	setAuditChange(ctx, auditTarget("transfer_approval", result.Approval.ID), nil, rsp.Approval)
+	ctx.JSON(http.StatusAccepted, rsp)
+}
+
+// getApprovalPolicy shows the dual control of an account to its owner and
+// to admins
+func (server *Server) getApprovalPolicy(ctx *gin.Context) {
+	var req getAccountRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	if payload.Role != util.AdminRole && payload.Username != account.Owner {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	policy, ok := server.findApprovalPolicy(ctx, req.ID)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, policy)
+}
+
+// findApprovalPolicy loads the policy of an account with its approvers, or
+// responds with the error and returns false
+func (server *Server) findApprovalPolicy(ctx *gin.Context, accountID int64) (approvalPolicyResponse, bool) {
+	policy, err := server.store.GetApprovalPolicy(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err := fmt.Errorf("%w: account %d has no approval policy", ErrNotFound, accountID)
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return approvalPolicyResponse{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return approvalPolicyResponse{}, false
+	}
+
+	approvers, err := server.store.ListAccountApprovers(ctx, accountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return approvalPolicyResponse{}, false
+	}
+	return newApprovalPolicyResponse(policy, approvers), true
+}
+
+type setApprovalPolicyRequest struct {
+	Threshold         int64    `json:"threshold" binding:"required,min=1"`
+	RequiredApprovals int32    `json:"required_approvals" binding:"required,min=1"`
+	Approvers         []string `json:"approvers" binding:"required,min=1,dive,required"`
+}
+
+// setApprovalPolicy puts an account under dual control, or changes its
+// threshold and approvers
+func (server *Server) setApprovalPolicy(ctx *gin.Context) {
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req setApprovalPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if _, err := server.store.GetAccount(ctx, uri.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	var before any
+	if policy, err := server.store.GetApprovalPolicy(ctx, uri.ID); err == nil {
+		approvers, err := server.store.ListAccountApprovers(ctx, uri.ID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+			return
+		}
+		before = newApprovalPolicyResponse(policy, approvers)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	result, err := server.store.SetApprovalPolicyTx(ctx, db.SetApprovalPolicyTxParams{
+		AccountID:         uri.ID,
+		Threshold:         req.Threshold,
+		RequiredApprovals: req.RequiredApprovals,
+		Approvers:         req.Approvers,
+		UpdatedBy:         payload.Username,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrTooFewApprovers), errors.Is(err, db.ErrUserDeleted):
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		case errors.Is(err, sql.ErrNoRows):
+			// an approver does not exist
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		}
+		return
+	}
+
+	rsp := newApprovalPolicyResponse(result.Policy, result.Approvers)
+	setAuditChange(ctx, auditTarget("account", uri.ID), before, rsp)
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// deleteApprovalPolicy ends the dual control of an account. Transfers that
+// wait for approval still need it.
+func (server *Server) deleteApprovalPolicy(ctx *gin.Context) {
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	before, ok := server.findApprovalPolicy(ctx, uri.ID)
+	if !ok {
+		return
+	}
+
+	if _, err := server.store.DeleteApprovalPolicy(ctx, uri.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("account", uri.ID), before, nil)
+	ctx.JSON(http.StatusOK, messageResponse{Message: "approval policy deleted"})
+}
+
+type listTransferApprovalsRequest struct {
+	Status   string `form:"status" binding:"omitempty,oneof=pending_approval approved executed failed rejected expired"`
+	PageID   int32  `form:"page_id" binding:"required,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=50"`
+}
+
+// listTransferApprovals lists the approvals the caller initiated or may
+// decide on, newest first. Admins see all approvals.
+func (server *Server) listTransferApprovals(ctx *gin.Context) {
+	var req listTransferApprovalsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	arg := db.ListTransferApprovalsParams{
+		Status: sql.NullString{String: req.Status, Valid: req.Status != ""},
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	}
+	if payload.Role != util.AdminRole {
+		arg.Username = sql.NullString{String: payload.Username, Valid: true}
+	}
+
+	approvals, err := server.store.ListTransferApprovals(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := make([]transferApprovalResponse, len(approvals))
+	for i, approval := range approvals {
+		rsp[i] = newTransferApprovalResponse(approval)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type getTransferApprovalRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getTransferApproval shows an approval with the decisions taken so far to
+// its initiator, the approvers and the owner of the sending account, and to
+// admins
+func (server *Server) getTransferApproval(ctx *gin.Context) {
+	var req getTransferApprovalRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	approval, ok := server.findTransferApproval(ctx, req.ID)
+	if !ok {
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	allowed, err := server.canSeeTransferApproval(ctx, payload.Username, payload.Role, approval)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if !allowed {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	decisions, err := server.store.ListTransferApprovalDecisions(ctx, approval.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := newTransferApprovalResponse(approval)
+	for _, decision := range decisions {
+		rsp.Decisions = append(rsp.Decisions, approvalDecisionResponse{
+			Approver:  decision.Approver,
+			Decision:  decision.Decision,
+			Note:      decision.Note,
+			CreatedAt: decision.CreatedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+func (server *Server) canSeeTransferApproval(ctx context.Context, username, role string, approval db.TransferApproval) (bool, error) {
+	if role == util.AdminRole || username == approval.InitiatedBy {
+		return true, nil
+	}
+	approver, err := server.store.IsAccountApprover(ctx, db.IsAccountApproverParams{
+		AccountID: approval.FromAccountID,
+		Username:  username,
+	})
+	if err != nil || approver {
+		return approver, err
+	}
+	account, err := server.store.GetAccount(ctx, approval.FromAccountID)
+	if err != nil {
+		return false, err
+	}
+	return account.Owner == username, nil
+}
+
+// findTransferApproval loads an approval, or responds with the error and
+// returns false
+func (server *Server) findTransferApproval(ctx *gin.Context, id int64) (db.TransferApproval, bool) {
+	approval, err := server.store.GetTransferApproval(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return db.TransferApproval{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return db.TransferApproval{}, false
+	}
+	return approval, true
+}
+
+type decideTransferApprovalRequest struct {
+	Note string `json:"note" binding:"max=1000"`
+}
+
+// approveTransferApproval approves a transfer. The last required approval
+// executes it.
+func (server *Server) approveTransferApproval(ctx *gin.Context) {
+	server.decideTransferApproval(ctx, db.ApprovalDecisionApprove)
+}
+
+// rejectTransferApproval rejects a transfer, which then never executes
+func (server *Server) rejectTransferApproval(ctx *gin.Context) {
+	server.decideTransferApproval(ctx, db.ApprovalDecisionReject)
+}
+
+// decideTransferApproval records the decision of the caller, who must be a
+// designated approver of the sending account but not the initiator
+func (server *Server) decideTransferApproval(ctx *gin.Context, decision string) {
+	var uri getTransferApprovalRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	var req decideTransferApprovalRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	before, ok := server.findTransferApproval(ctx, uri.ID)
+	if !ok {
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	result, err := server.store.DecideTransferApprovalTx(ctx, db.DecideTransferApprovalTxParams{
+		ApprovalID: uri.ID,
+		Approver:   payload.Username,
+		Decision:   decision,
+		Note:       req.Note,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotApprover), errors.Is(err, db.ErrApproverIsInitiator):
+			ctx.JSON(http.StatusForbidden, errorResponse(ctx, fmt.Errorf("%w: %w", ErrForbidden, err)))
+		case errors.Is(err, db.ErrApprovalNotPending), errors.Is(err, db.ErrApprovalExpired),
+			errors.Is(err, db.ErrApprovalDecided):
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		}
+		return
+	}
+
+	approval := result.Approval
+	if approval.Status == db.ApprovalStatusApproved {
+		var execErr error
+		approval, execErr = server.executeTransferApproval(ctx, approval)
+		if execErr != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, execErr))
+			return
+		}
+	}
+
+	rsp := newTransferApprovalResponse(approval)
+	setAuditChange(ctx, auditTarget("transfer_approval", uri.ID), newTransferApprovalResponse(before), rsp)
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// executeTransferApproval executes an approved transfer with TransferTx. A
+// transfer that cannot be executed, e.g. for want of money, fails the
+// approval and the transfer with the error code as their reason. It returns
+// an error only when the outcome could not be recorded.
+func (server *Server) executeTransferApproval(ctx *gin.Context, approval db.TransferApproval) (db.TransferApproval, error) {
+	fromAccount, err := server.store.GetAccount(ctx, approval.FromAccountID)
+	if err != nil {
+		return approval, err
+	}
+
+	if fromAccount.Balance < approval.Amount {
+		err = fmt.Errorf("%w: account %d has %d, transfer needs %d",
+			ErrInsufficientBalance, fromAccount.ID, fromAccount.Balance, approval.Amount)
+	} else {
+		var result db.TransferTxResult
+		result, err = server.store.TransferTx(ctx, db.TransferTxParams{
+			FromAccountID: approval.FromAccountID,
+			ToAccountID:   approval.ToAccountID,
+			Amount:        approval.Amount,
+			ApprovalID:    approval.ID,
+		})
+		if err == nil {
+			server.metrics.TransferCompleted(fmt.Sprint(fromAccount.Currency), approval.Amount)
+			return *result.Approval, nil
+		}
+	}
+
+	reason := errorCode(err)
+	switch {
+	case errors.Is(err, db.ErrTransferHeld):
+		// the monitoring alert decides on the transfer from now on
+		reason = db.AlertStatusHeld
+	case reason == "":
+		reason = ErrInternal.Code
+		server.logger.ErrorContext(ctx, "cannot execute approved transfer",
+			slog.Int64("approval_id", approval.ID),
+			slog.Any("error", err),
+		)
+	}
+
+	return server.store.FailTransferApprovalTx(ctx, db.FailTransferApprovalTxParams{
+		ApprovalID:    approval.ID,
+		FailureReason: reason,
+	})
+}
+
+// ExpireTransferApprovals periodically expires the approvals that were not
+// decided in time, cancelling their transfers, until ctx is cancelled. It is
+// meant to run as a background worker.
+func (server *Server) ExpireTransferApprovals(ctx context.Context) error {
+	ticker := time.NewTicker(approvalExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			approvals, err := server.store.ExpireTransferApprovalsTx(ctx)
+			if err != nil {
+				server.logger.ErrorContext(ctx, "cannot expire transfer approvals", slog.Any("error", err))
+				continue
+			}
+			for _, approval := range approvals {
+				server.logger.InfoContext(ctx, "transfer approval expired",
+					slog.Int64("approval_id", approval.ID),
+					slog.Int64("from_account_id", approval.FromAccountID),
+				)
+			}
+		}
+	}
+}
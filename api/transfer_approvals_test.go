@@ -0,0 +1,454 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTransferApprovalAPI(t *testing.T) {
+	fromAccount := db.Account{ID: 1, Owner: "alice", Balance: 5000, Currency: "USD", Status: db.AccountStatusActive}
+	toAccount := db.Account{ID: 2, Owner: "bob", Balance: 0, Currency: "USD", Status: db.AccountStatusActive}
+	policy := db.ApprovalPolicy{AccountID: fromAccount.ID, Threshold: 500, RequiredApprovals: 2}
+
+	testCases := []struct {
+		name          string
+		amount        int64
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "AboveThreshold",
+			amount: 1000,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateTransferApprovalTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateTransferApprovalTxParams) (db.CreateTransferApprovalTxResult, error) {
+						require.Equal(t, int64(1000), arg.Amount)
+						require.Equal(t, "alice", arg.InitiatedBy)
+						require.Equal(t, int32(2), arg.RequiredApprovals)
+						require.WithinDuration(t, time.Now().Add(time.Hour), arg.ExpiresAt, time.Minute)
+						return db.CreateTransferApprovalTxResult{
+							Approval: db.TransferApproval{
+								ID:                7,
+								FromAccountID:     arg.FromAccountID,
+								ToAccountID:       arg.ToAccountID,
+								Amount:            arg.Amount,
+								InitiatedBy:       arg.InitiatedBy,
+								RequiredApprovals: arg.RequiredApprovals,
+								Status:            db.ApprovalStatusPending,
+								TransferID:        sql.NullInt64{Int64: 42, Valid: true},
+								ExpiresAt:         arg.ExpiresAt,
+							},
+							Transfer: db.Transfer{ID: 42, Status: db.TransferStatusPending},
+						}, nil
+					})
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+
+				var rsp pendingApprovalResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.ApprovalStatusPending, rsp.Status)
+				require.Equal(t, int64(7), rsp.Approval.ID)
+				require.NotNil(t, rsp.Approval.TransferID)
+				require.Equal(t, int64(42), *rsp.Approval.TransferID)
+			},
+		},
+		{
+			name:   "AtThreshold",
+			amount: 500,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateTransferApprovalTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).Times(1).Return(db.User{Username: "alice", IsEmailVerified: true}, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+			store.EXPECT().GetApprovalPolicy(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(policy, nil)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"from_account_id": fromAccount.ID, "to_account_id": toAccount.ID, "amount": tc.amount})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, fromAccount.Owner, util.DepositorRole, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestSetApprovalPolicyAPI(t *testing.T) {
+	account := db.Account{ID: 1, Owner: "alice", Currency: "USD", Status: db.AccountStatusActive}
+
+	testCases := []struct {
+		name          string
+		role          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			role: util.AdminRole,
+			body: gin.H{"threshold": 1000, "required_approvals": 2, "approvers": []string{"bob", "carol"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					SetApprovalPolicyTx(gomock.Any(), gomock.Eq(db.SetApprovalPolicyTxParams{
+						AccountID:         account.ID,
+						Threshold:         1000,
+						RequiredApprovals: 2,
+						Approvers:         []string{"bob", "carol"},
+						UpdatedBy:         "admin",
+					})).
+					Times(1).
+					Return(db.SetApprovalPolicyTxResult{
+						Policy:    db.ApprovalPolicy{AccountID: account.ID, Threshold: 1000, RequiredApprovals: 2, UpdatedBy: "admin"},
+						Approvers: []string{"bob", "carol"},
+					}, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "account.approval_policy_set", arg.Action)
+						require.Equal(t, "account:1", arg.Target)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp approvalPolicyResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int32(2), rsp.RequiredApprovals)
+				require.Equal(t, []string{"bob", "carol"}, rsp.Approvers)
+			},
+		},
+		{
+			name: "TooFewApprovers",
+			role: util.AdminRole,
+			body: gin.H{"threshold": 1000, "required_approvals": 3, "approvers": []string{"bob", "carol"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					SetApprovalPolicyTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.SetApprovalPolicyTxResult{}, fmt.Errorf("%w: 3 approvals, 2 approvers", db.ErrTooFewApprovers))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrTooFewApprovers.Code)
+			},
+		},
+		{
+			name: "NoApprovers",
+			role: util.AdminRole,
+			body: gin.H{"threshold": 1000, "required_approvals": 1, "approvers": []string{}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SetApprovalPolicyTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Forbidden",
+			role: util.DepositorRole,
+			body: gin.H{"threshold": 1000, "required_approvals": 1, "approvers": []string{"bob"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SetApprovalPolicyTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPut, "/api/v1/accounts/1/approval-policy", bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDecideTransferApprovalAPI(t *testing.T) {
+	fromAccount := db.Account{ID: 1, Owner: "alice", Balance: 5000, Currency: "USD", Status: db.AccountStatusActive}
+	pending := db.TransferApproval{
+		ID:                7,
+		FromAccountID:     fromAccount.ID,
+		ToAccountID:       2,
+		Amount:            1000,
+		InitiatedBy:       "alice",
+		RequiredApprovals: 1,
+		Status:            db.ApprovalStatusPending,
+		ExpiresAt:         time.Now().Add(time.Hour),
+		CreatedAt:         time.Now(),
+	}
+	approved := pending
+	approved.Approvals = 1
+	approved.Status = db.ApprovalStatusApproved
+	approved.DecidedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	executed := approved
+	executed.Status = db.ApprovalStatusExecuted
+	executed.TransferID = sql.NullInt64{Int64: 42, Valid: true}
+
+	testCases := []struct {
+		name          string
+		url           string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "FinalApproval",
+			url:      "/api/v1/transfer-approvals/7/approve",
+			username: "bob",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferApproval(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().
+					DecideTransferApprovalTx(gomock.Any(), gomock.Eq(db.DecideTransferApprovalTxParams{
+						ApprovalID: pending.ID,
+						Approver:   "bob",
+						Decision:   db.ApprovalDecisionApprove,
+						Note:       "checked the invoice",
+					})).
+					Times(1).
+					Return(db.DecideTransferApprovalTxResult{Approval: approved}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{
+						FromAccountID: pending.FromAccountID,
+						ToAccountID:   pending.ToAccountID,
+						Amount:        pending.Amount,
+						ApprovalID:    pending.ID,
+					})).
+					Times(1).
+					Return(db.TransferTxResult{Transfer: db.Transfer{ID: 42}, Approval: &executed}, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "transfer_approval.approved", arg.Action)
+						require.Equal(t, "transfer_approval:7", arg.Target)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp transferApprovalResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.ApprovalStatusExecuted, rsp.Status)
+				require.NotNil(t, rsp.TransferID)
+				require.Equal(t, int64(42), *rsp.TransferID)
+			},
+		},
+		{
+			name:     "InsufficientBalance",
+			url:      "/api/v1/transfer-approvals/7/approve",
+			username: "bob",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferApproval(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().
+					DecideTransferApprovalTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DecideTransferApprovalTxResult{Approval: approved}, nil)
+				poor := fromAccount
+				poor.Balance = 10
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(poor, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+				failed := approved
+				failed.Status = db.ApprovalStatusFailed
+				failed.FailureReason = ErrInsufficientBalance.Code
+				store.EXPECT().
+					FailTransferApprovalTx(gomock.Any(), gomock.Eq(db.FailTransferApprovalTxParams{
+						ApprovalID:    pending.ID,
+						FailureReason: ErrInsufficientBalance.Code,
+					})).
+					Times(1).
+					Return(failed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp transferApprovalResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.ApprovalStatusFailed, rsp.Status)
+				require.Equal(t, ErrInsufficientBalance.Code, rsp.FailureReason)
+			},
+		},
+		{
+			name:     "SpentDuringExecution",
+			url:      "/api/v1/transfer-approvals/7/approve",
+			username: "bob",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferApproval(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().
+					DecideTransferApprovalTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DecideTransferApprovalTxResult{Approval: approved}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: account %d would have balance %d", db.ErrNegativeBalance, fromAccount.ID, -1))
+				failed := approved
+				failed.Status = db.ApprovalStatusFailed
+				failed.FailureReason = ErrInsufficientBalance.Code
+				store.EXPECT().
+					FailTransferApprovalTx(gomock.Any(), gomock.Eq(db.FailTransferApprovalTxParams{
+						ApprovalID:    pending.ID,
+						FailureReason: ErrInsufficientBalance.Code,
+					})).
+					Times(1).
+					Return(failed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp transferApprovalResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.ApprovalStatusFailed, rsp.Status)
+				require.Equal(t, ErrInsufficientBalance.Code, rsp.FailureReason)
+			},
+		},
+		{
+			name:     "Reject",
+			url:      "/api/v1/transfer-approvals/7/reject",
+			username: "bob",
+			buildStubs: func(store *mockdb.MockStore) {
+				rejected := pending
+				rejected.Status = db.ApprovalStatusRejected
+				store.EXPECT().GetTransferApproval(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().
+					DecideTransferApprovalTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.DecideTransferApprovalTxParams) (db.DecideTransferApprovalTxResult, error) {
+						require.Equal(t, db.ApprovalDecisionReject, arg.Decision)
+						return db.DecideTransferApprovalTxResult{Approval: rejected}, nil
+					})
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp transferApprovalResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.ApprovalStatusRejected, rsp.Status)
+			},
+		},
+		{
+			name:     "OwnTransfer",
+			url:      "/api/v1/transfer-approvals/7/approve",
+			username: "alice",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferApproval(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().
+					DecideTransferApprovalTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DecideTransferApprovalTxResult{}, fmt.Errorf("%w: alice initiated approval 7", db.ErrApproverIsInitiator))
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "NotPending",
+			url:      "/api/v1/transfer-approvals/7/approve",
+			username: "carol",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferApproval(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(executed, nil)
+				store.EXPECT().
+					DecideTransferApprovalTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DecideTransferApprovalTxResult{}, fmt.Errorf("%w: approval 7 is executed", db.ErrApprovalNotPending))
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrApprovalNotPending.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			url:      "/api/v1/transfer-approvals/7/approve",
+			username: "bob",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferApproval(gomock.Any(), gomock.Eq(pending.ID)).Times(1).Return(db.TransferApproval{}, sql.ErrNoRows)
+				store.EXPECT().DecideTransferApprovalTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"note": "checked the invoice"})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, tc.url, bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, util.DepositorRole, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+// transferLimitResponse is a row of transfer_limits. A missing rule inherits
+// the default of the scope, or is not limited in the default itself.
+type transferLimitResponse struct {
+	Scope         string    `json:"scope"`
+	Subject       string    `json:"subject,omitempty"`
+	MaxAmount     *int64    `json:"max_amount,omitempty"`
+	DailyAmount   *int64    `json:"daily_amount,omitempty"`
+	MonthlyAmount *int64    `json:"monthly_amount,omitempty"`
+	HourlyCount   *int32    `json:"hourly_count,omitempty"`
+	Note          string    `json:"note,omitempty"`
+	UpdatedBy     string    `json:"updated_by"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func newTransferLimitResponse(limit db.TransferLimit) transferLimitResponse {
+	rsp := transferLimitResponse{
+		Scope:     limit.Scope,
+		Subject:   limit.Subject,
+		Note:      limit.Note,
+		UpdatedBy: limit.UpdatedBy,
+		UpdatedAt: limit.UpdatedAt,
+	}
+	if limit.MaxAmount.Valid {
+		rsp.MaxAmount = &limit.MaxAmount.Int64
+	}
+	if limit.DailyAmount.Valid {
+		rsp.DailyAmount = &limit.DailyAmount.Int64
+	}
+	if limit.MonthlyAmount.Valid {
+		rsp.MonthlyAmount = &limit.MonthlyAmount.Int64
+	}
+	if limit.HourlyCount.Valid {
+		rsp.HourlyCount = &limit.HourlyCount.Int32
+	}
+	return rsp
+}
+
+// getTransferAllowance shows how much an account may still send. Users see
+// their own accounts, admins any account.
+func (server *Server) getTransferAllowance(ctx *gin.Context) {
+	var req getAccountRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	if payload.Role != util.AdminRole && payload.Username != account.Owner {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	allowance, err := server.store.GetTransferAllowance(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	ctx.JSON(http.StatusOK, allowance)
+}
+
+// listTransferLimits lists the defaults and all overrides
+func (server *Server) listTransferLimits(ctx *gin.Context) {
+	limits, err := server.store.ListTransferLimits(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := make([]transferLimitResponse, len(limits))
+	for i, limit := range limits {
+		rsp[i] = newTransferLimitResponse(limit)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type limitScopeRequest struct {
+	Scope string `uri:"scope" binding:"required,oneof=account user"`
+}
+
+// setTransferLimitRequest replaces all rules of a default or an override.
+// A missing rule inherits the default, or is not limited in the default.
+type setTransferLimitRequest struct {
+	MaxAmount     *int64 `json:"max_amount" binding:"omitempty,gt=0"`
+	DailyAmount   *int64 `json:"daily_amount" binding:"omitempty,gt=0"`
+	MonthlyAmount *int64 `json:"monthly_amount" binding:"omitempty,gt=0"`
+	HourlyCount   *int32 `json:"hourly_count" binding:"omitempty,gt=0"`
+	Note          string `json:"note" binding:"max=500"`
+}
+
+func (server *Server) setDefaultTransferLimit(ctx *gin.Context) {
+	var uri limitScopeRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	server.setTransferLimit(ctx, uri.Scope, "")
+}
+
+func (server *Server) setAccountTransferLimit(ctx *gin.Context) {
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if _, err := server.store.GetAccount(ctx, uri.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	server.setTransferLimit(ctx, db.LimitScopeAccount, strconv.FormatInt(uri.ID, 10))
+}
+
+func (server *Server) setUserTransferLimit(ctx *gin.Context) {
+	var uri getUserRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if _, err := server.store.GetUser(ctx, uri.Username); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	server.setTransferLimit(ctx, db.LimitScopeUser, uri.Username)
+}
+
+// setTransferLimit creates or replaces the limits of scope and subject, the
+// default of the scope when subject is empty
+func (server *Server) setTransferLimit(ctx *gin.Context, scope, subject string) {
+	var req setTransferLimitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	var before any
+	if limit, err := server.store.GetTransferLimit(ctx, db.GetTransferLimitParams{Scope: scope, Subject: subject}); err == nil {
+		before = newTransferLimitResponse(limit)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	payload, _ := authPayload(ctx)
+	arg := db.UpsertTransferLimitParams{
+		Scope:     scope,
+		Subject:   subject,
+		Note:      req.Note,
+		UpdatedBy: payload.Username,
+	}
+	if req.MaxAmount != nil {
+		arg.MaxAmount = sql.NullInt64{Int64: *req.MaxAmount, Valid: true}
+	}
+	if req.DailyAmount != nil {
+		arg.DailyAmount = sql.NullInt64{Int64: *req.DailyAmount, Valid: true}
+	}
+	if req.MonthlyAmount != nil {
+		arg.MonthlyAmount = sql.NullInt64{Int64: *req.MonthlyAmount, Valid: true}
+	}
+	if req.HourlyCount != nil {
+		arg.HourlyCount = sql.NullInt32{Int32: *req.HourlyCount, Valid: true}
+	}
+
+	limit, err := server.store.UpsertTransferLimit(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	rsp := newTransferLimitResponse(limit)
+	setAuditChange(ctx, limitAuditTarget(scope, subject), before, rsp)
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+func (server *Server) deleteAccountTransferLimit(ctx *gin.Context) {
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	server.deleteTransferLimit(ctx, db.LimitScopeAccount, strconv.FormatInt(uri.ID, 10))
+}
+
+func (server *Server) deleteUserTransferLimit(ctx *gin.Context) {
+	var uri getUserRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+	server.deleteTransferLimit(ctx, db.LimitScopeUser, uri.Username)
+}
+
+// deleteTransferLimit removes an override, so that the default of its scope
+// applies again. The defaults themselves cannot be deleted.
+func (server *Server) deleteTransferLimit(ctx *gin.Context, scope, subject string) {
+	arg := db.GetTransferLimitParams{Scope: scope, Subject: subject}
+	before, err := server.store.GetTransferLimit(ctx, arg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	if _, err := server.store.DeleteTransferLimit(ctx, db.DeleteTransferLimitParams(arg)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	setAuditChange(ctx, limitAuditTarget(scope, subject), newTransferLimitResponse(before), nil)
+	ctx.JSON(http.StatusOK, messageResponse{Message: "transfer limit deleted"})
+}
+
+// limitAuditTarget names a limit in the audit log, such as "limit:user:alice"
+// or "limit:account:default"
+func limitAuditTarget(scope, subject string) string {
+	if subject == "" {
+		subject = "default"
+	}
+	return auditTarget("limit", scope+":"+subject)
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTransferAllowanceAPI(t *testing.T) {
+	account := RandomAccount()
+	allowance := db.TransferAllowance{
+		AccountID: account.ID,
+		Owner:     account.Owner,
+		Limits: []db.LimitUsage{
+			{Scope: db.LimitScopeUser, Rule: db.LimitRuleDailyAmount, Limit: 1000, Used: 400, Remaining: 600},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetTransferAllowance(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(allowance, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp db.TransferAllowance
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, allowance, rsp)
+			},
+		},
+		{
+			name:     "Admin",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetTransferAllowance(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(allowance, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: "mallory",
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetTransferAllowance(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().GetTransferAllowance(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/api/v1/accounts/%d/limits", account.ID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestSetTransferLimitAPI(t *testing.T) {
+	account := RandomAccount()
+	subject := strconv.FormatInt(account.ID, 10)
+	limit := db.TransferLimit{
+		Scope:       db.LimitScopeAccount,
+		Subject:     subject,
+		DailyAmount: sql.NullInt64{Int64: 500, Valid: true},
+		Note:        "customer request",
+		UpdatedBy:   "admin",
+		UpdatedAt:   time.Now(),
+	}
+
+	testCases := []struct {
+		name          string
+		method        string
+		url           string
+		role          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "SetAccount",
+			method: http.MethodPut,
+			url:    "/api/v1/limits/accounts/" + subject,
+			role:   util.AdminRole,
+			body:   gin.H{"daily_amount": 500, "note": "customer request"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					GetTransferLimit(gomock.Any(), gomock.Eq(db.GetTransferLimitParams{Scope: db.LimitScopeAccount, Subject: subject})).
+					Times(1).
+					Return(db.TransferLimit{}, sql.ErrNoRows)
+				store.EXPECT().
+					UpsertTransferLimit(gomock.Any(), gomock.Eq(db.UpsertTransferLimitParams{
+						Scope:       db.LimitScopeAccount,
+						Subject:     subject,
+						DailyAmount: sql.NullInt64{Int64: 500, Valid: true},
+						Note:        "customer request",
+						UpdatedBy:   "admin",
+					})).
+					Times(1).
+					Return(limit, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "limit.updated", arg.Action)
+						require.Equal(t, "limit:account:"+subject, arg.Target)
+						require.Nil(t, arg.Before)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp transferLimitResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(500), *rsp.DailyAmount)
+				require.Nil(t, rsp.MaxAmount)
+			},
+		},
+		{
+			name:   "SetDefault",
+			method: http.MethodPut,
+			url:    "/api/v1/limits/defaults/user",
+			role:   util.AdminRole,
+			body:   gin.H{"hourly_count": 10},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferLimit(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferLimit{Scope: db.LimitScopeUser}, nil)
+				store.EXPECT().
+					UpsertTransferLimit(gomock.Any(), gomock.Eq(db.UpsertTransferLimitParams{
+						Scope:       db.LimitScopeUser,
+						HourlyCount: sql.NullInt32{Int32: 10, Valid: true},
+						UpdatedBy:   "admin",
+					})).
+					Times(1).
+					Return(db.TransferLimit{Scope: db.LimitScopeUser, HourlyCount: sql.NullInt32{Int32: 10, Valid: true}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "InvalidScope",
+			method: http.MethodPut,
+			url:    "/api/v1/limits/defaults/bank",
+			role:   util.AdminRole,
+			body:   gin.H{"hourly_count": 10},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpsertTransferLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:   "NotPositive",
+			method: http.MethodPut,
+			url:    "/api/v1/limits/users/alice",
+			role:   util.AdminRole,
+			body:   gin.H{"max_amount": 0},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{Username: "alice"}, nil)
+				store.EXPECT().UpsertTransferLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:   "UserNotFound",
+			method: http.MethodPut,
+			url:    "/api/v1/limits/users/alice",
+			role:   util.AdminRole,
+			body:   gin.H{"max_amount": 100},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq("alice")).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().UpsertTransferLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:   "NotAdmin",
+			method: http.MethodPut,
+			url:    "/api/v1/limits/accounts/" + subject,
+			role:   util.DepositorRole,
+			body:   gin.H{"daily_amount": 500},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpsertTransferLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "Delete",
+			method: http.MethodDelete,
+			url:    "/api/v1/limits/accounts/" + subject,
+			role:   util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetTransferLimitParams{Scope: db.LimitScopeAccount, Subject: subject}
+				store.EXPECT().GetTransferLimit(gomock.Any(), gomock.Eq(arg)).Times(1).Return(limit, nil)
+				store.EXPECT().DeleteTransferLimit(gomock.Any(), gomock.Eq(db.DeleteTransferLimitParams(arg))).Times(1).Return(int64(1), nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "limit.deleted", arg.Action)
+						require.NotNil(t, arg.Before)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "DeleteNotFound",
+			method: http.MethodDelete,
+			url:    "/api/v1/limits/users/alice",
+			role:   util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferLimit(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferLimit{}, sql.ErrNoRows)
+				store.EXPECT().DeleteTransferLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -2,11 +2,15 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type createTransferRequest struct {
@@ -25,199 +29,341 @@ type transferResponse struct {
 func (server *Server) createTransfer(ctx *gin.Context) {
 	var req createTransferRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int64("transfer.from_account_id", req.FromAccountID),
+		attribute.Int64("transfer.to_account_id", req.ToAccountID),
+		attribute.Int64("transfer.amount", req.Amount),
+	)
+
 	fromAccount, err := server.store.GetAccount(ctx, req.FromAccountID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	// admins move money from any account, everyone else only from their own
+	payload, _ := authPayload(ctx)
+	if payload.Role != util.AdminRole && payload.Username != fromAccount.Owner {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	if err := accountStatusError(fromAccount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	owner, err := server.store.GetUser(ctx, fromAccount.Owner)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+	if !owner.IsEmailVerified {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrEmailNotVerified))
 		return
 	}
 
+	// large transfers need a recent second factor of the caller, who may be an
+	// admin sending on the owner's behalf
+	if limit := server.config.StepUpTransferAmount; limit > 0 && req.Amount > limit {
+		if err := server.verifyStepUp(ctx, payload.Username); err != nil {
+			ctx.JSON(http.StatusForbidden, errorResponse(ctx, err))
+			return
+		}
+	}
+
 	// Check if from_account has sufficient balance
 	if fromAccount.Balance < req.Amount {
-		err := fmt.Errorf("account %d has insufficient balance for transfer: %d < %d",
-			req.FromAccountID, fromAccount.Balance, req.Amount)
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		err := fmt.Errorf("%w: account %d has %d, transfer needs %d",
+			ErrInsufficientBalance, req.FromAccountID, fromAccount.Balance, req.Amount)
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
 	toAccount, err := server.store.GetAccount(ctx, req.ToAccountID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	if err := accountStatusError(toAccount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
 	if fromAccount.Currency != toAccount.Currency {
 		err := fmt.Errorf(
-			"currency mismatch: from account [%d] currency %s vs to account [%d] currency %s",
+			"%w: from account [%d] currency %s vs to account [%d] currency %s",
+			ErrCurrencyMismatch,
 			req.FromAccountID,
 			fromAccount.Currency,
 			req.ToAccountID,
 			toAccount.Currency,
 		)
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	// Create the transfer
-	arg := db.CreateTransfersParams{
-		FromAccountID: req.FromAccountID,
-		ToAccountID:   req.ToAccountID,
-		Amount:        req.Amount,
+	// the money must not reach or come from a sanctioned party
+	if server.screener != nil {
+		recipient, err := server.store.GetUser(ctx, toAccount.Owner)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+			return
+		}
+		if !server.screenNames(ctx, db.ScreeningActionTransfer,
+			screenedName{owner.Username, owner.FullName},
+			screenedName{recipient.Username, recipient.FullName},
+		) {
+			return
+		}
 	}
 
-	transfer, err := server.store.CreateTransfers(ctx, arg)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	// transfers above the threshold of an approval policy wait for approvers
+	policy, err := server.store.GetApprovalPolicy(ctx, req.FromAccountID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
-
-	// Update from_account balance
-	fromAccountArg := db.AddAccountBalanceParams{
-		ID:     req.FromAccountID,
-		Amount: -req.Amount, // Subtract the amount
-	}
-	fromAccount, err = server.store.AddAccountBalance(ctx, fromAccountArg)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	if err == nil && req.Amount > policy.Threshold {
+		server.requestTransferApproval(ctx, req, policy)
 		return
 	}
 
-	// Update to_account balance
-	toAccountArg := db.AddAccountBalanceParams{
-		ID:     req.ToAccountID,
-		Amount: req.Amount, // Add the amount
-	}
-	toAccount, err = server.store.AddAccountBalance(ctx, toAccountArg)
+	result, err := server.store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Amount:        req.Amount,
+	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		// a monitoring rule held the transfer for review; the customer is
+		// not told which, see heldTransferResponse
+		if errors.Is(err, db.ErrTransferHeld) && len(result.Alerts) > 0 {
+			response := heldTransferResponse{
+				Status:  db.AlertStatusHeld,
+				Message: "the transfer is held for review",
+			}
+			setAuditChange(ctx, auditTarget("alert", result.Alerts[0].ID), nil, newMonitoringAlertResponse(result.Alerts[0]))
+			ctx.JSON(http.StatusAccepted, response)
+			return
+		}
+		// an account was frozen or closed, or the balance was spent, since it
+		// was checked above
+		if errors.Is(err, db.ErrAccountNotActive) || errors.Is(err, db.ErrNegativeBalance) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+			return
+		}
+		if errors.Is(err, db.ErrTransferLimitExceeded) || errors.Is(err, db.ErrBalanceLimitExceeded) ||
+			errors.Is(err, db.ErrLimitExceeded) {
+			ctx.JSON(http.StatusForbidden, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
+	server.metrics.TransferCompleted(fmt.Sprint(fromAccount.Currency), req.Amount)
+
 	response := transferResponse{
-		Transfer:    transfer,
-		FromAccount: fromAccount,
-		ToAccount:   toAccount,
+		Transfer:    result.Transfer,
+		FromAccount: result.FromAccount,
+		ToAccount:   result.ToAccount,
 	}
 
+	setAuditChange(ctx, auditTarget("transfer", result.Transfer.ID), nil, response)
 	ctx.JSON(http.StatusOK, response)
 }
 
-func (server *Server) getTransfer(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
+// accountStatusError explains why an account cannot send or receive money,
+// or returns nil when it is active
+func accountStatusError(account db.Account) error {
+	switch account.Status {
+	case db.AccountStatusActive:
+		return nil
+	case db.AccountStatusFrozen:
+		return fmt.Errorf("%w: account %d", ErrAccountFrozen, account.ID)
+	default:
+		return fmt.Errorf("%w: account %d is %s", ErrAccountNotActive, account.ID, account.Status)
 	}
+}
 
+type getTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getTransfer(ctx *gin.Context) {
+	var req getTransferRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
 	transfer, err := server.store.GetTransfers(ctx, req.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	if !server.canAccessTransfer(ctx, transfer) {
 		return
 	}
 
 	ctx.JSON(http.StatusOK, transfer)
 }
 
-type listTransfersRequest struct {
-	PageID   int32 `form:"page_id" binding:"required,min=1"`
-	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+// canAccessTransfer reports whether the caller is an admin or owns one of
+// the accounts of the transfer. It writes the response when not.
+func (server *Server) canAccessTransfer(ctx *gin.Context, transfer db.Transfer) bool {
+	payload, _ := authPayload(ctx)
+	if payload.Role == util.AdminRole {
+		return true
+	}
+
+	for _, id := range []int64{transfer.FromAccountID, transfer.ToAccountID} {
+		account, err := server.store.GetAccount(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+			return false
+		}
+		if account.Owner == payload.Username {
+			return true
+		}
+	}
+
+	ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+	return false
 }
 
-func (server *Server) listTransfers(ctx *gin.Context) {
-	var req listTransfersRequest
-	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+// transferTimelineResponse is a transfer with every status it has been in,
+// oldest first
+type transferTimelineResponse struct {
+	Transfer db.Transfer        `json:"transfer"`
+	Events   []db.TransferEvent `json:"events"`
+}
+
+func (server *Server) getTransferTimeline(ctx *gin.Context) {
+	var req getTransferRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	transfer, err := server.store.GetTransfers(ctx, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	arg := db.ListTransfersParams{
-		Limit:  req.PageSize,
-		Offset: (req.PageID - 1) * req.PageSize,
+	if !server.canAccessTransfer(ctx, transfer) {
+		return
 	}
 
-	transfers, err := server.store.ListTransfers(ctx, arg)
+	events, err := server.store.ListTransferEvents(ctx, transfer.ID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, transfers)
+	ctx.JSON(http.StatusOK, transferTimelineResponse{Transfer: transfer, Events: events})
 }
 
-type updateTransferRequest struct {
-	Amount int64 `json:"amount" binding:"required,gt=0"`
+type listTransfersRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
 }
 
-func (server *Server) updateTransfer(ctx *gin.Context) {
-	var reqURI struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
-	if err := ctx.ShouldBindUri(&reqURI); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
-
-	var reqBody updateTransferRequest
-	if err := ctx.ShouldBindJSON(&reqBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+func (server *Server) listTransfers(ctx *gin.Context) {
+	var req listTransfersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	arg := db.UpdateTransferParams{
-		ID:     reqURI.ID,
-		Amount: reqBody.Amount,
+	// admins list every transfer, everyone else only those from or to their
+	// own accounts
+	var transfers []db.Transfer
+	var err error
+	payload, _ := authPayload(ctx)
+	if payload.Role == util.AdminRole {
+		transfers, err = server.store.ListTransfers(ctx, db.ListTransfersParams{
+			Limit:  req.PageSize,
+			Offset: (req.PageID - 1) * req.PageSize,
+		})
+	} else {
+		transfers, err = server.store.ListOwnerTransfers(ctx, db.ListOwnerTransfersParams{
+			Owner:  payload.Username,
+			Limit:  req.PageSize,
+			Offset: (req.PageID - 1) * req.PageSize,
+		})
 	}
-
-	transfer, err := server.store.UpdateTransfer(ctx, arg)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, transfer)
+	ctx.JSON(http.StatusOK, transfers)
 }
 
-func (server *Server) deleteTransfer(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
-
+// reverseTransfer returns the money of a completed transfer with a
+// compensating transfer. Transfers are never edited or deleted, so that
+// the ledger and their history stay intact.
+func (server *Server) reverseTransfer(ctx *gin.Context) {
+	var req getTransferRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	err := server.store.DeleteTransfers(ctx, req.ID)
+	before, err := server.store.GetTransfers(ctx, req.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Transfer deleted successfully"})
+	result, err := server.store.ReverseTransferTx(ctx, req.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrTransferAlreadyReversed), errors.Is(err, db.ErrTransferIsReversal),
+			errors.Is(err, db.ErrInvalidTransferTransition):
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+		// an account is no longer active or the receiver spent the money
+		case errors.Is(err, db.ErrAccountNotActive), errors.Is(err, db.ErrNegativeBalance):
+			ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		}
+		return
+	}
+
+	rsp := transferResponse{
+		Transfer:    result.Transfer,
+		FromAccount: result.FromAccount,
+		ToAccount:   result.ToAccount,
+	}
+	setAuditChange(ctx, auditTarget("transfer", before.ID), before, rsp)
+	ctx.JSON(http.StatusOK, rsp)
 }
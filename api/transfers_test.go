@@ -9,12 +9,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	mockdb "github.com/hiiamanop/simple_bank/db/mock"
 	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/metrics"
+	"github.com/hiiamanop/simple_bank/token"
 	"github.com/hiiamanop/simple_bank/util"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 )
 
@@ -37,24 +41,32 @@ func TestCreateTransfer(t *testing.T) {
 	fromAccount := db.Account{
 		ID:       int64(util.RandomInt(1, 1000)),
 		Owner:    util.RandomOwner(),
-		Balance:  int64(util.RandomMoney()),
+		Balance:  amount + int64(util.RandomMoney()),
 		Currency: "USD",
+		Status:   db.AccountStatusActive,
 	}
 
 	toAccount := db.Account{
-		ID:       int64(util.RandomInt(1, 1000)),
+		ID:       fromAccount.ID + 1000,
 		Owner:    util.RandomOwner(),
 		Balance:  int64(util.RandomMoney()),
 		Currency: "USD",
+		Status:   db.AccountStatusActive,
 	}
 
 	wrongCurrencyAccount := db.Account{
-		ID:       int64(util.RandomInt(1, 1000)),
+		ID:       fromAccount.ID + 2000,
 		Owner:    util.RandomOwner(),
 		Balance:  int64(util.RandomMoney()),
 		Currency: "EUR",
+		Status:   db.AccountStatusActive,
 	}
 
+	closedAccount := toAccount
+	closedAccount.Status = db.AccountStatusClosed
+
+	owner := db.User{Username: fromAccount.Owner, IsEmailVerified: true}
+
 	transfer := db.Transfer{
 		ID:            int64(util.RandomInt(1, 1000)),
 		FromAccountID: fromAccount.ID,
@@ -63,8 +75,10 @@ func TestCreateTransfer(t *testing.T) {
 	}
 
 	testCases := []struct {
-		name          string
-		body          gin.H
+		name string
+		body gin.H
+		// setupAuth defaults to a token of the owner of fromAccount
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
@@ -82,76 +96,338 @@ func TestCreateTransfer(t *testing.T) {
 					Times(1).
 					Return(fromAccount, nil)
 
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
+
 				// Second GetAccount call for to_account
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
 					Times(1).
 					Return(toAccount, nil)
 
-				// Create transfer
-				arg := db.CreateTransfersParams{
+				updatedFromAccount := fromAccount
+				updatedFromAccount.Balance -= amount
+				updatedToAccount := toAccount
+				updatedToAccount.Balance += amount
+
+				arg := db.TransferTxParams{
 					FromAccountID: fromAccount.ID,
 					ToAccountID:   toAccount.ID,
 					Amount:        amount,
 				}
 				store.EXPECT().
-					CreateTransfers(gomock.Any(), gomock.Eq(arg)).
+					TransferTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(transfer, nil)
+					Return(db.TransferTxResult{
+						Transfer:    transfer,
+						FromAccount: updatedFromAccount,
+						ToAccount:   updatedToAccount,
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
 
-				// Mock AddAccountBalance for from_account (subtract amount)
-				addFromAccountArg := db.AddAccountBalanceParams{
-					ID:     fromAccount.ID,
-					Amount: -amount,
-				}
+				// Create expected accounts with updated balances
+				expectedFromAccount := fromAccount
+				expectedFromAccount.Balance -= amount
+				expectedToAccount := toAccount
+				expectedToAccount.Balance += amount
+
+				requireBodyMatchTransferResponse(t, recorder.Body, transfer, expectedFromAccount, expectedToAccount)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrUnauthorized.Code)
+			},
+		},
+		{
+			name: "NotOwner",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, toAccount.Owner, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name: "Admin",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "admin", util.AdminRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).Times(1).Return(owner, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{Transfer: transfer}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "EmailNotVerified",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					AddAccountBalance(gomock.Any(), gomock.Eq(addFromAccountArg)).
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
 					Times(1).
 					Return(fromAccount, nil)
 
-				// Mock AddAccountBalance for to_account (add amount)
-				addToAccountArg := db.AddAccountBalanceParams{
-					ID:     toAccount.ID,
-					Amount: amount,
-				}
+				unverified := owner
+				unverified.IsEmailVerified = false
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(unverified, nil)
+
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrEmailNotVerified.Code)
+			},
+		},
+		{
+			name: "FromAccountNotFound",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(db.Account{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "CurrencyMismatch",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   wrongCurrencyAccount.ID,
+				"amount":          amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
+
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(wrongCurrencyAccount.ID)).
+					Times(1).
+					Return(wrongCurrencyAccount, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ToAccountClosed",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   closedAccount.ID,
+				"amount":          amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
+
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(closedAccount.ID)).
+					Times(1).
+					Return(closedAccount, nil)
+
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrAccountNotActive.Code)
+			},
+		},
+		{
+			name: "ClosedDuringTransfer",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					AddAccountBalance(gomock.Any(), gomock.Eq(addToAccountArg)).
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
+
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
 					Times(1).
 					Return(toAccount, nil)
 
-				// Update fromAccount balance for the response
-				updatedFromAccount := fromAccount
-				updatedFromAccount.Balance -= amount
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: account %d is closed", db.ErrAccountNotActive, toAccount.ID))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrAccountNotActive.Code)
+			},
+		},
+		{
+			name: "SpentDuringTransfer",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
 
-				// Update toAccount balance for the response
-				updatedToAccount := toAccount
-				updatedToAccount.Balance += amount
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
 
-				// Final GetAccount calls for response
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
+					Times(1).
+					Return(toAccount, nil)
+
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: account %d would have balance %d", db.ErrNegativeBalance, fromAccount.ID, -amount))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInsufficientBalance.Code)
+			},
+		},
+		{
+			name: "TransferLimitExceeded",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
 					Times(1).
-					Return(updatedFromAccount, nil)
+					Return(fromAccount, nil)
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
 
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
 					Times(1).
-					Return(updatedToAccount, nil)
+					Return(toAccount, nil)
+
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: tier 0 (unverified)", db.ErrTransferLimitExceeded))
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusOK, recorder.Code)
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrTransferLimit.Code)
+			},
+		},
+		{
+			name: "BalanceLimitExceeded",
+			body: gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
 
-				// Create expected accounts with updated balances
-				expectedFromAccount := fromAccount
-				expectedFromAccount.Balance -= amount
-				expectedToAccount := toAccount
-				expectedToAccount.Balance += amount
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
 
-				requireBodyMatchTransferResponse(t, recorder.Body, transfer, expectedFromAccount, expectedToAccount)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
+					Times(1).
+					Return(toAccount, nil)
+
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: tier 0 (unverified)", db.ErrBalanceLimitExceeded))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrBalanceLimit.Code)
 			},
 		},
 		{
-			name: "FromAccountNotFound",
+			name: "LimitExceeded",
 			body: gin.H{
 				"from_account_id": fromAccount.ID,
 				"to_account_id":   toAccount.ID,
@@ -161,32 +437,60 @@ func TestCreateTransfer(t *testing.T) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
 					Times(1).
-					Return(db.Account{}, sql.ErrNoRows)
+					Return(fromAccount, nil)
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
+
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
+					Times(1).
+					Return(toAccount, nil)
+
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: daily_amount of account 1 is 100, 40 remaining", db.ErrLimitExceeded))
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrLimitExceeded.Code)
+				require.Contains(t, recorder.Body.String(), "40 remaining")
 			},
 		},
 		{
-			name: "CurrencyMismatch",
+			name: "Held",
 			body: gin.H{
 				"from_account_id": fromAccount.ID,
-				"to_account_id":   wrongCurrencyAccount.ID,
+				"to_account_id":   toAccount.ID,
 				"amount":          amount,
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
+
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
 					Times(1).
-					Return(fromAccount, nil)
+					Return(toAccount, nil)
 
+				alert := db.MonitoringAlert{ID: 7, Rule: "new_account_receipt", Reason: "account 2 is new", Status: db.AlertStatusHeld}
 				store.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(wrongCurrencyAccount.ID)).
+					TransferTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(wrongCurrencyAccount, nil)
+					Return(db.TransferTxResult{Alerts: []db.MonitoringAlert{alert}}, fmt.Errorf("%w: alert 7", db.ErrTransferHeld))
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.JSONEq(t, `{"status": "held", "message": "the transfer is held for review"}`, recorder.Body.String())
 			},
 		},
 		{
@@ -202,7 +506,7 @@ func TestCreateTransfer(t *testing.T) {
 					Times(0)
 
 				store.EXPECT().
-					CreateTransfers(gomock.Any(), gomock.Any()).
+					TransferTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -222,15 +526,20 @@ func TestCreateTransfer(t *testing.T) {
 					Times(1).
 					Return(fromAccount, nil)
 
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+					Times(1).
+					Return(owner, nil)
+
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
 					Times(1).
 					Return(toAccount, nil)
 
 				store.EXPECT().
-					CreateTransfers(gomock.Any(), gomock.Any()).
+					TransferTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.Transfer{}, sql.ErrConnDone)
+					Return(db.TransferTxResult{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -248,7 +557,7 @@ func TestCreateTransfer(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			data, err := json.Marshal(tc.body)
@@ -258,6 +567,11 @@ func TestCreateTransfer(t *testing.T) {
 			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
 			require.NoError(t, err)
 
+			if tc.setupAuth != nil {
+				tc.setupAuth(t, request, server.tokenMaker)
+			} else {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, fromAccount.Owner, util.DepositorRole, time.Minute)
+			}
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
 		})
@@ -265,31 +579,124 @@ func TestCreateTransfer(t *testing.T) {
 }
 
 func TestGetTransfer(t *testing.T) {
+	fromAccount := RandomAccount()
+	toAccount := RandomAccount()
+	toAccount.ID = fromAccount.ID + 1
 	transfer := randomTransfer()
+	transfer.FromAccountID = fromAccount.ID
+	transfer.ToAccountID = toAccount.ID
 
 	testCases := []struct {
 		name          string
 		transferID    int64
+		username      string
+		role          string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name:       "OK",
 			transferID: transfer.ID,
+			username:   fromAccount.Owner,
+			role:       util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).
+					Times(1).
+					Return(transfer, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTransfer(t, recorder.Body, transfer)
+			},
+		},
+		{
+			name:       "Receiver",
+			transferID: transfer.ID,
+			username:   toAccount.Owner,
+			role:       util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).
+					Times(1).
+					Return(transfer, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
+					Times(1).
+					Return(toAccount, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTransfer(t, recorder.Body, transfer)
+			},
+		},
+		{
+			name:       "Admin",
+			transferID: transfer.ID,
+			username:   "admin",
+			role:       util.AdminRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).
 					Times(1).
 					Return(transfer, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
 				requireBodyMatchTransfer(t, recorder.Body, transfer)
 			},
 		},
+		{
+			name:       "OtherOwner",
+			transferID: transfer.ID,
+			username:   "mallory",
+			role:       util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).
+					Times(1).
+					Return(transfer, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).
+					Times(1).
+					Return(fromAccount, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).
+					Times(1).
+					Return(toAccount, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:       "NoAuthorization",
+			transferID: transfer.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name:       "NotFound",
 			transferID: transfer.ID,
+			username:   fromAccount.Owner,
+			role:       util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).
@@ -303,6 +710,8 @@ func TestGetTransfer(t *testing.T) {
 		{
 			name:       "InternalError",
 			transferID: transfer.ID,
+			username:   fromAccount.Owner,
+			role:       util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).
@@ -325,12 +734,96 @@ func TestGetTransfer(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			url := fmt.Sprintf("/api/v1/transfers/%d", tc.transferID)
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			if tc.username != "" {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			}
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetTransferTimeline(t *testing.T) {
+	fromAccount := RandomAccount()
+	transfer := randomTransfer()
+	transfer.FromAccountID = fromAccount.ID
+	transfer.Status = db.TransferStatusReversed
+	events := []db.TransferEvent{
+		{ID: 1, TransferID: transfer.ID, Status: db.TransferStatusCompleted},
+		{ID: 2, TransferID: transfer.ID, Status: db.TransferStatusReversed},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: fromAccount.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				store.EXPECT().ListTransferEvents(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(events, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp transferTimelineResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, db.TransferStatusReversed, rsp.Transfer.Status)
+				require.Len(t, rsp.Events, 2)
+				require.Equal(t, db.TransferStatusCompleted, rsp.Events[0].Status)
+			},
+		},
+		{
+			name:     "OtherOwner",
+			username: "mallory",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(2).Return(fromAccount, nil)
+				store.EXPECT().ListTransferEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: fromAccount.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(db.Transfer{}, sql.ErrNoRows)
+				store.EXPECT().ListTransferEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/api/v1/transfers/%d/timeline", transfer.ID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, util.DepositorRole, time.Minute)
 
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
@@ -339,6 +832,7 @@ func TestGetTransfer(t *testing.T) {
 }
 
 func TestListTransfers(t *testing.T) {
+	owner := util.RandomOwner()
 	n := 5
 	transfers := make([]db.Transfer, n)
 	for i := 0; i < n; i++ {
@@ -353,6 +847,8 @@ func TestListTransfers(t *testing.T) {
 	testCases := []struct {
 		name          string
 		query         Query
+		username      string
+		role          string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
@@ -362,6 +858,36 @@ func TestListTransfers(t *testing.T) {
 				pageID:   1,
 				pageSize: n,
 			},
+			username: owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListOwnerTransfersParams{
+					Owner:  owner,
+					Limit:  int32(n),
+					Offset: 0,
+				}
+
+				store.EXPECT().
+					ListOwnerTransfers(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(transfers, nil)
+				store.EXPECT().
+					ListTransfers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTransfers(t, recorder.Body, transfers)
+			},
+		},
+		{
+			name: "Admin",
+			query: Query{
+				pageID:   1,
+				pageSize: n,
+			},
+			username: "admin",
+			role:     util.AdminRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.ListTransfersParams{
 					Limit:  int32(n),
@@ -378,15 +904,32 @@ func TestListTransfers(t *testing.T) {
 				requireBodyMatchTransfers(t, recorder.Body, transfers)
 			},
 		},
+		{
+			name: "NoAuthorization",
+			query: Query{
+				pageID:   1,
+				pageSize: n,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListOwnerTransfers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name: "InternalError",
 			query: Query{
 				pageID:   1,
 				pageSize: n,
 			},
+			username: owner,
+			role:     util.DepositorRole,
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					ListTransfers(gomock.Any(), gomock.Any()).
+					ListOwnerTransfers(gomock.Any(), gomock.Any()).
 					Times(1).
 					Return([]db.Transfer{}, sql.ErrConnDone)
 			},
@@ -406,12 +949,15 @@ func TestListTransfers(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			url := "/api/v1/transfers"
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			if tc.username != "" {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			}
 
 			// Add query parameters to request URL
 			q := request.URL.Query()
@@ -425,51 +971,51 @@ func TestListTransfers(t *testing.T) {
 	}
 }
 
-func TestUpdateTransfer(t *testing.T) {
-	transfer := randomTransfer()
+// TestCreateTransferTransferTx checks that createTransfer books a transfer
+// only through TransferTx, which moves the money in one transaction, and
+// counts its volume only once TransferTx succeeded
+func TestCreateTransferTransferTx(t *testing.T) {
+	amount := int64(util.RandomMoney())
+	fromAccount := db.Account{
+		ID:       int64(util.RandomInt(1, 1000)),
+		Owner:    util.RandomOwner(),
+		Balance:  amount + int64(util.RandomMoney()),
+		Currency: "USD",
+		Status:   db.AccountStatusActive,
+	}
+	toAccount := db.Account{
+		ID:       fromAccount.ID + 1000,
+		Owner:    util.RandomOwner(),
+		Balance:  int64(util.RandomMoney()),
+		Currency: "USD",
+		Status:   db.AccountStatusActive,
+	}
+	volume := fmt.Sprintf(`simplebank_transfer_volume_total{currency="USD"} %d`, amount)
 
 	testCases := []struct {
 		name          string
-		transferID    int64
-		body          gin.H
-		buildStubs    func(store *mockdb.MockStore)
-		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+		result        db.TransferTxResult
+		err           error
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, exposition string)
 	}{
 		{
-			name:       "OK",
-			transferID: transfer.ID,
-			body: gin.H{
-				"amount": transfer.Amount + 100,
+			name: "Completed",
+			result: db.TransferTxResult{
+				Transfer:    db.Transfer{ID: 1, FromAccountID: fromAccount.ID, ToAccountID: toAccount.ID, Amount: amount},
+				FromAccount: fromAccount,
+				ToAccount:   toAccount,
 			},
-			buildStubs: func(store *mockdb.MockStore) {
-				arg := db.UpdateTransferParams{
-					ID:     transfer.ID,
-					Amount: transfer.Amount + 100,
-				}
-
-				store.EXPECT().
-					UpdateTransfer(gomock.Any(), gomock.Eq(arg)).
-					Times(1).
-					Return(transfer, nil)
-			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, exposition string) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, exposition, volume)
 			},
 		},
 		{
-			name:       "NotFound",
-			transferID: transfer.ID,
-			body: gin.H{
-				"amount": transfer.Amount + 100,
-			},
-			buildStubs: func(store *mockdb.MockStore) {
-				store.EXPECT().
-					UpdateTransfer(gomock.Any(), gomock.Any()).
-					Times(1).
-					Return(db.Transfer{}, sql.ErrNoRows)
-			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+			name: "Failed",
+			err:  fmt.Errorf("%w: daily_amount of account 1 is 100, 0 remaining", db.ErrLimitExceeded),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, exposition string) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrLimitExceeded.Code)
+				require.NotContains(t, exposition, "simplebank_transfer_volume_total{")
 			},
 		},
 	}
@@ -482,57 +1028,142 @@ func TestUpdateTransfer(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
-			tc.buildStubs(store)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+			store.EXPECT().
+				GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+				Times(1).
+				Return(db.User{Username: fromAccount.Owner, IsEmailVerified: true}, nil)
+			store.EXPECT().
+				TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{
+					FromAccountID: fromAccount.ID,
+					ToAccountID:   toAccount.ID,
+					Amount:        amount,
+				})).
+				Times(1).
+				Return(tc.result, tc.err)
+			// the ledger is never written outside of TransferTx
+			store.EXPECT().CreateTransfers(gomock.Any(), gomock.Any()).Times(0)
+			store.EXPECT().CreateEntries(gomock.Any(), gomock.Any()).Times(0)
+			store.EXPECT().AddAccountBalance(gomock.Any(), gomock.Any()).Times(0)
 
-			server := NewServer(store)
-			recorder := httptest.NewRecorder()
+			server := newTestServer(t, store, WithMetrics(metrics.New(prometheus.NewRegistry())))
 
-			data, err := json.Marshal(tc.body)
+			body, err := json.Marshal(gin.H{
+				"from_account_id": fromAccount.ID,
+				"to_account_id":   toAccount.ID,
+				"amount":          amount,
+			})
 			require.NoError(t, err)
 
-			url := fmt.Sprintf("/api/v1/transfers/%d", tc.transferID)
-			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/transfers", bytes.NewReader(body))
 			require.NoError(t, err)
-
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, fromAccount.Owner, util.DepositorRole, time.Minute)
 			server.router.ServeHTTP(recorder, request)
-			tc.checkResponse(t, recorder)
+
+			metricsRecorder := httptest.NewRecorder()
+			request, err = http.NewRequest(http.MethodGet, "/metrics", nil)
+			require.NoError(t, err)
+			server.router.ServeHTTP(metricsRecorder, request)
+
+			tc.checkResponse(t, recorder, metricsRecorder.Body.String())
 		})
 	}
 }
 
-func TestDeleteTransfer(t *testing.T) {
+func TestReverseTransfer(t *testing.T) {
 	transfer := randomTransfer()
+	reversal := db.Transfer{
+		ID:            transfer.ID + 1,
+		FromAccountID: transfer.ToAccountID,
+		ToAccountID:   transfer.FromAccountID,
+		Amount:        transfer.Amount,
+		Status:        db.TransferStatusCompleted,
+	}
 
 	testCases := []struct {
 		name          string
-		transferID    int64
+		role          string
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
-			name:       "OK",
-			transferID: transfer.ID,
+			name: "OK",
+			role: util.AdminRole,
 			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
 				store.EXPECT().
-					DeleteTransfers(gomock.Any(), gomock.Eq(transfer.ID)).
+					ReverseTransferTx(gomock.Any(), gomock.Eq(transfer.ID)).
 					Times(1).
-					Return(nil)
+					Return(db.TransferTxResult{Transfer: reversal}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTransferResponse(t, recorder.Body, reversal, db.Account{}, db.Account{})
 			},
 		},
 		{
-			name:       "NotFound",
-			transferID: transfer.ID,
+			name: "NotAdmin",
+			role: util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			role: util.AdminRole,
 			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(db.Transfer{}, sql.ErrNoRows)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusNotFound, ErrNotFound.Code)
+			},
+		},
+		{
+			name: "AlreadyReversed",
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
 				store.EXPECT().
-					DeleteTransfers(gomock.Any(), gomock.Eq(transfer.ID)).
+					ReverseTransferTx(gomock.Any(), gomock.Eq(transfer.ID)).
 					Times(1).
-					Return(sql.ErrNoRows)
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: transfer %d", db.ErrTransferAlreadyReversed, transfer.ID))
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder, http.StatusConflict, ErrTransferReversed.Code)
+			},
+		},
+		{
+			name: "Reversal",
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().
+					ReverseTransferTx(gomock.Any(), gomock.Eq(transfer.ID)).
+					Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: transfer %d", db.ErrTransferIsReversal, transfer.ID))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrTransferIsReversal.Code)
+			},
+		},
+		{
+			name: "MoneySpent",
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfers(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().
+					ReverseTransferTx(gomock.Any(), gomock.Eq(transfer.ID)).
+					Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: account %d would have balance -1", db.ErrNegativeBalance, transfer.ToAccountID))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusBadRequest, ErrInsufficientBalance.Code)
 			},
 		},
 	}
@@ -547,13 +1178,14 @@ func TestDeleteTransfer(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
-			url := fmt.Sprintf("/api/v1/transfers/%d", tc.transferID)
-			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			url := fmt.Sprintf("/api/v1/transfers/%d/reverse", transfer.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
 			require.NoError(t, err)
 
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
 		})
@@ -566,6 +1198,7 @@ func randomTransfer() db.Transfer {
 		FromAccountID: int64(util.RandomInt(1, 1000)),
 		ToAccountID:   int64(util.RandomInt(1, 1000)),
 		Amount:        int64(util.RandomMoney()),
+		Status:        db.TransferStatusCompleted,
 	}
 }
 
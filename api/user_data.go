@@ -0,0 +1,123 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// userExport is the archive returned by exportUser. It holds everything
+// stored about a user apart from credentials: the password hash, the TOTP
+// secret, recovery codes and email tokens are left out.
+type userExport struct {
+	ExportedAt          time.Time       `json:"exported_at"`
+	User                userResponse    `json:"user"`
+	FailedLoginAttempts int32           `json:"failed_login_attempts"`
+	LockedUntil         time.Time       `json:"locked_until"`
+	Accounts            []db.Account    `json:"accounts"`
+	Entries             []db.Entry      `json:"entries"`
+	Transfers           []db.Transfer   `json:"transfers"`
+	EmailsSent          []exportedEmail `json:"emails_sent"`
+	AuditEvents         []db.AuditEvent `json:"audit_events"`
+	// KYCProfile is left out when the user never submitted one
+	KYCProfile *kycProfileResponse `json:"kyc_profile,omitempty"`
+}
+
+// exportedEmail is a verification or password reset email sent to the user
+type exportedEmail struct {
+	Email     string     `json:"email"`
+	Purpose   string     `json:"purpose"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiredAt time.Time  `json:"expired_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+func newUserExport(data db.UserData) userExport {
+	export := userExport{
+		ExportedAt:          time.Now().UTC(),
+		User:                newUserResponse(data.User),
+		FailedLoginAttempts: data.User.FailedLoginAttempts,
+		LockedUntil:         data.User.LockedUntil,
+		Accounts:            data.Accounts,
+		Entries:             data.Entries,
+		Transfers:           data.Transfers,
+		EmailsSent:          make([]exportedEmail, len(data.VerifyEmails)),
+		AuditEvents:         data.AuditEvents,
+	}
+	for i, email := range data.VerifyEmails {
+		export.EmailsSent[i] = exportedEmail{
+			Email:     email.Email,
+			Purpose:   email.Purpose,
+			CreatedAt: email.CreatedAt,
+			ExpiredAt: email.ExpiredAt,
+			UsedAt:    nullTime(email.UsedAt),
+		}
+	}
+	if data.KYCProfile != nil {
+		profile := newKYCProfileResponse(*data.KYCProfile)
+		export.KYCProfile = &profile
+	}
+	return export
+}
+
+// exportUser returns everything stored about a user as a JSON download,
+// for data subject access requests. Users can export their own data and
+// admins anyone's. Every export is recorded in the audit log.
+func (server *Server) exportUser(ctx *gin.Context) {
+	var req getUserRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if !canAccessUser(ctx, req.Username) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	data, err := server.store.ExportUserData(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	server.audit(ctx, auditUserExported, auditTarget("user", req.Username), nil, nil)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-export.json"`, req.Username))
+	ctx.JSON(http.StatusOK, newUserExport(data))
+}
+
+// eraseUser pseudonymises a deleted user, keeping the ledger rows that the
+// law requires us to retain. The user is renamed to a random pseudonym that
+// the response returns.
+func (server *Server) eraseUser(ctx *gin.Context) {
+	var req getUserRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	user, err := server.store.EraseUserTx(ctx, req.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+		case errors.Is(err, db.ErrUserNotDeleted), errors.Is(err, db.ErrUserErased):
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		}
+		return
+	}
+
+	setAuditChange(ctx, auditTarget("user", req.Username), nil, newUserAuditState(user))
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	deleted := user
+	deleted.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		username      string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteUserTx(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(deleted, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "Admin",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteUserTx(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(deleted, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: "mallory",
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "OpenAccounts",
+			username: user.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, fmt.Errorf("%w: account 1 is active", db.ErrUserHasOpenAccounts))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrUserHasOpenAccounts.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteUserTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodDelete, "/api/v1/users/"+user.Username, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestExportUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.TotpSecret = "JBSWY3DPEHPK3PXP"
+	account := RandomAccount()
+	account.Owner = user.Username
+	data := db.UserData{
+		User:     user,
+		Accounts: []db.Account{account},
+		Entries:  []db.Entry{{ID: 1, AccountID: account.ID, Amount: 10}},
+		VerifyEmails: []db.VerifyEmail{{
+			ID:          1,
+			Username:    user.Username,
+			Email:       user.Email,
+			Purpose:     db.VerifyEmailPurposeEmailVerification,
+			HashedToken: "secret-token-hash",
+		}},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ExportUserData(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(data, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, auditUserExported, arg.Action)
+						require.Equal(t, "user:"+user.Username, arg.Target)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Header().Get("Content-Disposition"), "attachment")
+
+				var export userExport
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &export))
+				require.Equal(t, user.Email, export.User.Email)
+				require.Equal(t, data.Accounts[0].ID, export.Accounts[0].ID)
+				require.Len(t, export.Entries, 1)
+				require.Len(t, export.EmailsSent, 1)
+
+				// credentials are never exported
+				body := recorder.Body.String()
+				require.NotContains(t, body, user.HashedPassword)
+				require.NotContains(t, body, user.TotpSecret)
+				require.NotContains(t, body, "secret-token-hash")
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: "mallory",
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ExportUserData(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: "admin",
+			role:     util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ExportUserData(gomock.Any(), gomock.Any()).Times(1).Return(db.UserData{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/api/v1/users/"+user.Username+"/export", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestEraseUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	erased := db.User{
+		Username:  "erased0011223344556677",
+		Email:     "erased0011223344556677@" + db.ErasedEmailDomain,
+		Role:      util.DepositorRole,
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ErasedAt:  sql.NullTime{Time: time.Now(), Valid: true},
+	}
+
+	testCases := []struct {
+		name          string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().EraseUserTx(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(erased, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "user.erased", arg.Action)
+						require.Nil(t, arg.Before)
+						require.NotContains(t, string(arg.After), user.Email)
+						require.NotContains(t, string(arg.After), user.FullName)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, erased.Username, rsp.Username)
+				require.NotNil(t, rsp.ErasedAt)
+			},
+		},
+		{
+			name: "NotDeleted",
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					EraseUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, fmt.Errorf("%w: %s has to be deleted first", db.ErrUserNotDeleted, user.Username))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusConflict, ErrUserNotDeleted.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			role: util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().EraseUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/users/"+user.Username+"/erase", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
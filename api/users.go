@@ -2,12 +2,80 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
 )
 
+// userResponse is a user without its password hash and login state
+type userResponse struct {
+	Username          string    `json:"username"`
+	FullName          string    `json:"full_name"`
+	Email             string    `json:"email"`
+	EmailVerified     bool      `json:"email_verified"`
+	Role              string    `json:"role"`
+	TOTPEnabled       bool      `json:"totp_enabled"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	// DeletedAt and ErasedAt are only set for deleted users
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ErasedAt  *time.Time `json:"erased_at,omitempty"`
+}
+
+func newUserResponse(user db.User) userResponse {
+	return userResponse{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		EmailVerified:     user.IsEmailVerified,
+		Role:              user.Role,
+		TOTPEnabled:       user.TotpEnabled,
+		PasswordChangedAt: user.PasswordChangedAt,
+		CreatedAt:         user.CreatedAt,
+		DeletedAt:         nullTime(user.DeletedAt),
+		ErasedAt:          nullTime(user.ErasedAt),
+	}
+}
+
+// userAuditState is what the audit log keeps of a user. The log cannot be
+// erased, so it leaves out the name and email and only flags changes to
+// them.
+type userAuditState struct {
+	Username          string     `json:"username"`
+	EmailVerified     bool       `json:"email_verified"`
+	Role              string     `json:"role"`
+	TOTPEnabled       bool       `json:"totp_enabled"`
+	PasswordChangedAt time.Time  `json:"password_changed_at"`
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
+	ErasedAt          *time.Time `json:"erased_at,omitempty"`
+	FullNameChanged   bool       `json:"full_name_changed,omitempty"`
+	EmailChanged      bool       `json:"email_changed,omitempty"`
+}
+
+func newUserAuditState(user db.User) userAuditState {
+	return userAuditState{
+		Username:          user.Username,
+		EmailVerified:     user.IsEmailVerified,
+		Role:              user.Role,
+		TOTPEnabled:       user.TotpEnabled,
+		PasswordChangedAt: user.PasswordChangedAt,
+		DeletedAt:         nullTime(user.DeletedAt),
+		ErasedAt:          nullTime(user.ErasedAt),
+	}
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
 type createUserRequest struct {
 	Username string `json:"username" binding:"required,alphanum"`
 	Password string `json:"password" binding:"required,min=6"`
@@ -18,48 +86,66 @@ type createUserRequest struct {
 func (server *Server) createUser(ctx *gin.Context) {
 	var req createUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if !server.screenNames(ctx, db.ScreeningActionOnboarding, screenedName{req.Username, req.FullName}) {
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.Password)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
 	arg := db.CreateUserParams{
 		Username:       req.Username,
-		HashedPassword: req.Password, // Note: You should hash this password
+		HashedPassword: hashedPassword,
 		FullName:       req.FullName,
 		Email:          req.Email,
 	}
 
 	user, err := server.store.CreateUser(ctx, arg)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	server.sendVerificationEmail(ctx, user)
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, newUserAuditState(user))
+	ctx.JSON(http.StatusOK, newUserResponse(user))
 }
 
 type getUserRequest struct {
 	Username string `uri:"username" binding:"required,alphanum"`
 }
 
+// getUser shows a user to themselves and to admins
 func (server *Server) getUser(ctx *gin.Context) {
 	var req getUserRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if !canAccessUser(ctx, req.Username) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
 		return
 	}
 
 	user, err := server.store.GetUser(ctx, req.Username)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	ctx.JSON(http.StatusOK, newUserResponse(user))
 }
 
 type listUsersRequest struct {
@@ -70,7 +156,7 @@ type listUsersRequest struct {
 func (server *Server) listUsers(ctx *gin.Context) {
 	var req listUsersRequest
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
@@ -81,41 +167,87 @@ func (server *Server) listUsers(ctx *gin.Context) {
 
 	users, err := server.store.ListUsers(ctx, arg)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, users)
+	rsp := make([]userResponse, len(users))
+	for i, user := range users {
+		rsp[i] = newUserResponse(user)
+	}
+	ctx.JSON(http.StatusOK, rsp)
 }
 
 type updateUserRequest struct {
 	Password string `json:"password" binding:"omitempty,min=6"`
-	FullName string `json:"full_name" binding:"omitempty"`
-	Email    string `json:"email" binding:"omitempty,email"`
+	// CurrentPassword is required to change the password; without it a
+	// reset link is the way to a new one
+	CurrentPassword string `json:"current_password" binding:"required_with=Password"`
+	FullName        string `json:"full_name" binding:"omitempty"`
+	Email           string `json:"email" binding:"omitempty,email"`
 }
 
+// updateUser changes the profile of a user, for the user or an admin. A new
+// password needs the current one, which is checked like a login and so
+// counts towards a lockout.
 func (server *Server) updateUser(ctx *gin.Context) {
 	var reqURI getUserRequest
 	if err := ctx.ShouldBindUri(&reqURI); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
 	var reqBody updateUserRequest
 	if err := ctx.ShouldBindJSON(&reqBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
+		return
+	}
+
+	if !canAccessUser(ctx, reqURI.Username) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
 		return
 	}
 
+	before, err := server.store.GetUser(ctx, reqURI.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+		return
+	}
+
+	if reqBody.Password != "" {
+		if lockedFor := time.Until(before.LockedUntil); lockedFor > 0 {
+			ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(lockedFor)))
+			ctx.JSON(http.StatusLocked, errorResponse(ctx, ErrUserLocked))
+			return
+		}
+		if err := util.CheckPassword(reqBody.CurrentPassword, before.HashedPassword); err != nil {
+			server.failLogin(ctx, before, ErrInvalidCredentials)
+			return
+		}
+	}
+
 	arg := db.UpdateUserParams{
 		Username: reqURI.Username,
 	}
 
 	if reqBody.Password != "" {
+		hashedPassword, err := util.HashPassword(reqBody.Password)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
+			return
+		}
 		arg.HashedPassword = sql.NullString{
-			String: reqBody.Password, // Note: Should hash this password
+			String: hashedPassword,
 			Valid:  true,
 		}
+		arg.PasswordChangedAt = sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		}
 	}
 
 	if reqBody.FullName != "" {
@@ -135,32 +267,57 @@ func (server *Server) updateUser(ctx *gin.Context) {
 	user, err := server.store.UpdateUser(ctx, arg)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	// UpdateUser clears the verification of a changed email
+	if reqBody.Email != "" && !user.IsEmailVerified {
+		server.sendVerificationEmail(ctx, user)
+	}
+	after := newUserAuditState(user)
+	after.FullNameChanged = user.FullName != before.FullName
+	after.EmailChanged = user.Email != before.Email
+	setAuditChange(ctx, auditTarget("user", user.Username), newUserAuditState(before), after)
+	ctx.JSON(http.StatusOK, newUserResponse(user))
 }
 
+// deleteUser soft-deletes a user. Users can delete themselves once all
+// their accounts are closed; admins can delete anyone.
 func (server *Server) deleteUser(ctx *gin.Context) {
 	var req getUserRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(ctx, err))
 		return
 	}
 
-	err := server.store.DeleteUser(ctx, req.Username)
+	if !canAccessUser(ctx, req.Username) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ctx, ErrForbidden))
+		return
+	}
+
+	user, err := server.store.DeleteUserTx(ctx, req.Username)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			ctx.JSON(http.StatusNotFound, errorResponse(ctx, err))
+		case errors.Is(err, db.ErrUserDeleted), errors.Is(err, db.ErrUserHasOpenAccounts):
+			ctx.JSON(http.StatusConflict, errorResponse(ctx, err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ctx, err))
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
+	setAuditChange(ctx, auditTarget("user", user.Username), nil, gin.H{"deleted_at": user.DeletedAt.Time})
 	ctx.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
+
+// canAccessUser reports whether the caller is the user or an admin
+func canAccessUser(ctx *gin.Context, username string) bool {
+	payload, ok := authPayload(ctx)
+	return ok && (payload.Role == util.AdminRole || payload.Username == username)
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/token"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// eqCreateUserParamsMatcher matches CreateUserParams whose password hash
+// belongs to password, since the salt makes every hash different
+type eqCreateUserParamsMatcher struct {
+	arg      db.CreateUserParams
+	password string
+}
+
+func (e eqCreateUserParamsMatcher) Matches(x any) bool {
+	arg, ok := x.(db.CreateUserParams)
+	if !ok {
+		return false
+	}
+	if err := util.CheckPassword(e.password, arg.HashedPassword); err != nil {
+		return false
+	}
+
+	e.arg.HashedPassword = arg.HashedPassword
+	return e.arg == arg
+}
+
+func (e eqCreateUserParamsMatcher) String() string {
+	return fmt.Sprintf("matches arg %v and password %v", e.arg, e.password)
+}
+
+func EqCreateUserParams(arg db.CreateUserParams, password string) gomock.Matcher {
+	return eqCreateUserParamsMatcher{arg, password}
+}
+
+func TestCreateUserAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	arg := db.CreateUserParams{
+		Username: user.Username,
+		FullName: user.FullName,
+		Email:    user.Email,
+	}
+	store.EXPECT().
+		CreateUser(gomock.Any(), EqCreateUserParams(arg, password)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		CreateVerifyEmail(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+			require.Equal(t, user.Username, arg.Username)
+			require.Equal(t, db.VerifyEmailPurposeEmailVerification, arg.Purpose)
+			return db.VerifyEmail{}, nil
+		})
+
+	// the audit log cannot be erased, so it must not hold personal data
+	store.EXPECT().
+		AppendAuditEventTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+			require.Equal(t, "user.created", arg.Action)
+			require.Contains(t, string(arg.After), user.Username)
+			require.NotContains(t, string(arg.After), user.Email)
+			require.NotContains(t, string(arg.After), user.FullName)
+			return db.AuditEvent{}, nil
+		})
+
+	mailer := &recordingSender{}
+	server := newTestServer(t, store, WithMailSender(mailer))
+	recorder := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"username":%q,"password":%q,"full_name":%q,"email":%q}`,
+		user.Username, password, user.FullName, user.Email)
+	request, err := http.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBufferString(body))
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, user.Username, rsp["username"])
+	require.NotContains(t, rsp, "hashed_password")
+	require.Equal(t, false, rsp["email_verified"])
+
+	// signing up sends the verification link
+	require.Len(t, mailer.messages, 1)
+	require.Equal(t, user.Email, mailer.messages[0].To)
+	require.Contains(t, mailer.messages[0].Body, "http://localhost:3000/verify-email?token=")
+}
+
+func TestGetUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Self",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Admin",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "admin", util.AdminRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "OtherUser",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "mallory", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrUnauthorized.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/api/v1/users/"+user.Username, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListUsersAPIRequiresAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/api/v1/users?page_id=1&page_size=5", nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "alice", util.DepositorRole, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+}
+
+func TestUpdateUserAPI(t *testing.T) {
+	user, password := randomUser(t)
+	user.IsEmailVerified = true
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "FullName",
+			username: user.Username,
+			body:     `{"full_name":"Alice Smith"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.FullName = "Alice Smith"
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.UpdateUserParams) (db.User, error) {
+						require.False(t, arg.HashedPassword.Valid)
+						require.Equal(t, "Alice Smith", arg.FullName.String)
+						return updated, nil
+					})
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "user.updated", arg.Action)
+						var after userAuditState
+						require.NoError(t, json.Unmarshal(arg.After, &after))
+						require.True(t, after.FullNameChanged)
+						require.False(t, after.EmailChanged)
+						require.NotContains(t, string(arg.After), "Alice Smith")
+						require.NotContains(t, string(arg.Before), user.FullName)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "Password",
+			username: user.Username,
+			body:     fmt.Sprintf(`{"password":"new-secret","current_password":%q}`, password),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.UpdateUserParams) (db.User, error) {
+						require.NoError(t, util.CheckPassword("new-secret", arg.HashedPassword.String))
+						return user, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "PasswordWithoutCurrent",
+			username: user.Username,
+			body:     `{"password":"new-secret"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "WrongCurrentPassword",
+			username: user.Username,
+			body:     `{"password":"new-secret","current_password":"wrong-password"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					RecordFailedLogin(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{Username: user.Username, FailedLoginAttempts: 1}, nil)
+				store.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusUnauthorized, ErrInvalidCredentials.Code)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: "mallory",
+			body:     `{"email":"mallory@example.com"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireErrorCode(t, recorder, http.StatusForbidden, ErrForbidden.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPut, "/api/v1/users/"+tc.username, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
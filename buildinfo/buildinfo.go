@@ -0,0 +1,56 @@
+// Package buildinfo describes the running binary.
+package buildinfo
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Commit and BuildTime can be set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/hiiamanop/simple_bank/buildinfo.BuildTime=$(date -u +%FT%TZ)"
+//
+// Otherwise they are taken from the VCS information that the go command
+// stamps into the binary.
+var (
+	Commit    string
+	BuildTime string
+)
+
+// Info is the build information reported by the version endpoint
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary
+var Get = sync.OnceValue(func() Info {
+	info := Info{
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = build.GoVersion
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+})
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"strconv"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// CreateAccountParams contains the input of CreateAccount
+type CreateAccountParams struct {
+	Currency string `json:"currency"`
+}
+
+// CreateAccount opens a new account with a zero balance, owned by the user
+// of the token
+func (c *Client) CreateAccount(ctx context.Context, arg CreateAccountParams) (db.Account, error) {
+	var account db.Account
+	err := c.do(ctx, http.MethodPost, "/accounts", nil, arg, &account)
+	return account, err
+}
+
+// GetAccount fetches an account by id
+func (c *Client) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+	var account db.Account
+	err := c.do(ctx, http.MethodGet, "/accounts/"+strconv.FormatInt(id, 10), nil, nil, &account)
+	return account, err
+}
+
+// ListAccounts iterates over the accounts of the user, or over all accounts
+// with an admin token, fetching pageSize at a time
+func (c *Client) ListAccounts(ctx context.Context, pageSize int32) iter.Seq2[db.Account, error] {
+	return paginate[db.Account](ctx, c, "/accounts", nil, pageSize)
+}
+
+// AccountStatusResult is an account after a lifecycle change
+type AccountStatusResult struct {
+	Account db.Account `json:"account"`
+	// Sweep is the transfer of the balance of a closed account, if any
+	Sweep *TransferResult `json:"sweep,omitempty"`
+}
+
+// FreezeAccount stops an account from sending or receiving money. It needs
+// an admin token.
+func (c *Client) FreezeAccount(ctx context.Context, id int64) (AccountStatusResult, error) {
+	return c.changeAccountStatus(ctx, id, "freeze", nil)
+}
+
+// UnfreezeAccount makes a frozen or dormant account active again. It needs
+// an admin token.
+func (c *Client) UnfreezeAccount(ctx context.Context, id int64) (AccountStatusResult, error) {
+	return c.changeAccountStatus(ctx, id, "unfreeze", nil)
+}
+
+// CloseAccountParams contains the input of CloseAccount
+type CloseAccountParams struct {
+	// SweepAccountID receives the remaining balance. Without it only an
+	// account with a zero balance can be closed.
+	SweepAccountID int64 `json:"sweep_account_id,omitempty"`
+}
+
+// CloseAccount closes an account of the caller
+func (c *Client) CloseAccount(ctx context.Context, id int64, arg CloseAccountParams) (AccountStatusResult, error) {
+	return c.changeAccountStatus(ctx, id, "close", arg)
+}
+
+// ReopenAccount makes a closed account active again. It needs an admin
+// token.
+func (c *Client) ReopenAccount(ctx context.Context, id int64) (AccountStatusResult, error) {
+	return c.changeAccountStatus(ctx, id, "reopen", nil)
+}
+
+func (c *Client) changeAccountStatus(ctx context.Context, id int64, action string, body any) (AccountStatusResult, error) {
+	var result AccountStatusResult
+	err := c.do(ctx, http.MethodPost, "/accounts/"+strconv.FormatInt(id, 10)+"/"+action, nil, body, &result)
+	return result, err
+}
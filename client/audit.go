@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"time"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// AuditEventFilter narrows ListAuditEvents. Zero fields match every event.
+type AuditEventFilter struct {
+	Actor  string
+	Target string
+	// Since and Until bound the creation time to [Since, Until)
+	Since time.Time
+	Until time.Time
+}
+
+// ListAuditEvents iterates over the audit events matching filter, newest
+// first, fetching pageSize at a time. It needs an admin token.
+func (c *Client) ListAuditEvents(ctx context.Context, filter AuditEventFilter, pageSize int32) iter.Seq2[db.AuditEvent, error] {
+	query := url.Values{}
+	if filter.Actor != "" {
+		query.Set("actor", filter.Actor)
+	}
+	if filter.Target != "" {
+		query.Set("target", filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	return paginate[db.AuditEvent](ctx, c, "/audit-events", query, pageSize)
+}
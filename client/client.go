@@ -0,0 +1,271 @@
+// Package client is a typed Go client for the simple_bank HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hiiamanop/simple_bank/api"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = 200 * time.Millisecond
+	maxBackoff        = 5 * time.Second
+)
+
+// Client calls the simple_bank API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+
+	mu          sync.RWMutex
+	token       string
+	stepUpToken string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the default http.Client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken sets the bearer token sent with every request
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and the
+// initial backoff between attempts, which doubles after every attempt
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// New creates a client for the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q: scheme and host are required", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1"
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// SetToken replaces the bearer token used for subsequent requests
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// SetStepUpToken replaces the step-up token sent with subsequent requests,
+// which large transfers require
+func (c *Client) SetStepUpToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stepUpToken = token
+}
+
+func (c *Client) currentToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+func (c *Client) currentStepUpToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stepUpToken
+}
+
+// do sends a request and decodes a successful JSON response into out.
+// Failed requests are retried on network errors and on 502, 503 and 504
+// responses, which a proxy or an unavailable server sends without executing
+// the request. POST requests carry an idempotency key that is
+// reused across retries so the server executes them at most once.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("cannot encode request: %w", err)
+		}
+	}
+
+	var idempotencyKey string
+	if method == http.MethodPost {
+		idempotencyKey = newIdempotencyKey()
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if idempotencyKey != "" {
+			req.Header.Set(api.IdempotencyKeyHeader, idempotencyKey)
+		}
+		if token := c.currentToken(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if token := c.currentStepUpToken(); token != "" {
+			req.Header.Set(api.StepUpTokenHeader, token)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			defer resp.Body.Close()
+			if out == nil {
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("cannot decode response: %w", err)
+			}
+			return nil
+		}
+
+		var wait time.Duration
+		if err == nil {
+			apiErr := decodeError(resp)
+			if !retryable(resp.StatusCode) || attempt >= c.maxRetries {
+				return apiErr
+			}
+			wait = retryAfter(resp)
+			err = apiErr
+		} else if ctx.Err() != nil || attempt >= c.maxRetries {
+			return err
+		}
+
+		if wait == 0 {
+			wait = backoff
+			backoff = min(backoff*2, maxBackoff)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxBackoff)
+}
+
+// decodeError reads an error response into the same *api.Error the server
+// produced, falling back to the code implied by the status
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	apiErr := &api.Error{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(data, apiErr); err != nil || apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = api.CodeForStatus(resp.StatusCode)
+	}
+	return apiErr
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// paginate walks a page_id/page_size listing, narrowed by the filter query
+// parameters, until a short page is returned
+func paginate[T any](ctx context.Context, c *Client, path string, filter url.Values, pageSize int32) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for pageID := 1; ; pageID++ {
+			query := url.Values{}
+			for key, values := range filter {
+				query[key] = values
+			}
+			query.Set("page_id", strconv.Itoa(pageID))
+			query.Set("page_size", strconv.Itoa(int(pageSize)))
+
+			var page []T
+			if err := c.do(ctx, http.MethodGet, path, query, nil, &page); err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range page {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if len(page) < int(pageSize) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/hiiamanop/simple_bank/api"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/token"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+const testTokenKey = "12345678901234567890123456789012"
+
+// newTestClient starts an httptest.Server around api.NewServer. wrap, when
+// set, can intercept requests before they reach the API.
+func newTestClient(t *testing.T, store db.Store, wrap func(http.Handler) http.Handler, opts ...Option) *Client {
+	if mock, ok := store.(*mockdb.MockStore); ok {
+		mock.EXPECT().AppendAuditEventTx(gomock.Any(), gomock.Any()).AnyTimes().Return(db.AuditEvent{}, nil)
+		mock.EXPECT().GetApprovalPolicy(gomock.Any(), gomock.Any()).AnyTimes().Return(db.ApprovalPolicy{}, sql.ErrNoRows)
+	}
+	var handler http.Handler = api.NewServer(util.Config{TokenSymmetricKey: testTokenKey, AccessTokenDuration: time.Minute, StepUpTokenDuration: time.Minute}, store)
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	opts = append([]Option{WithRetries(2, time.Millisecond)}, opts...)
+	c, err := New(ts.URL, opts...)
+	require.NoError(t, err)
+	return c
+}
+
+func randomAccount(currency string) db.Account {
+	return db.Account{
+		ID:       int64(util.RandomInt(1, 1000)),
+		Owner:    util.RandomOwner(),
+		Balance:  int64(util.RandomInt(1000, 2000)),
+		Currency: currency,
+		Status:   db.AccountStatusActive,
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("localhost:8080")
+	require.Error(t, err)
+
+	c, err := New("http://localhost:8080/")
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:8080/api/v1", c.baseURL.String())
+}
+
+func TestCreateAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	account := randomAccount("USD")
+	account.Balance = 0
+
+	store.EXPECT().
+		CreateAccount(gomock.Any(), gomock.Eq(db.CreateAccountParams{
+			Owner:    account.Owner,
+			Currency: "USD",
+		})).
+		Times(1).
+		Return(account, nil)
+
+	owner, _, err := token.NewJWTMaker(testTokenKey).CreateToken(account.Owner, util.DepositorRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	c := newTestClient(t, store, nil, WithToken(owner))
+	got, err := c.CreateAccount(context.Background(), CreateAccountParams{
+		Currency: "USD",
+	})
+	require.NoError(t, err)
+	require.Equal(t, account, got)
+}
+
+func TestGetAccountNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(int64(42))).
+		Times(1).
+		Return(db.Account{}, sql.ErrNoRows)
+
+	admin, _, err := token.NewJWTMaker(testTokenKey).CreateToken("admin", util.AdminRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	c := newTestClient(t, store, nil, WithToken(admin))
+	_, err = c.GetAccount(context.Background(), 42)
+	require.ErrorIs(t, err, api.ErrNotFound)
+
+	var apiErr *api.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestCloseAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	account := randomAccount("USD")
+	receiver := randomAccount("USD")
+	closed := account
+	closed.Status = db.AccountStatusClosed
+	closed.Balance = 0
+
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(2).Return(account, nil)
+	store.EXPECT().
+		ChangeAccountStatusTx(gomock.Any(), gomock.Eq(db.ChangeAccountStatusTxParams{
+			AccountID:  account.ID,
+			Transition: db.AccountClose,
+		})).
+		Times(1).
+		Return(db.ChangeAccountStatusTxResult{}, db.ErrAccountNotEmpty)
+	store.EXPECT().
+		ChangeAccountStatusTx(gomock.Any(), gomock.Eq(db.ChangeAccountStatusTxParams{
+			AccountID:      account.ID,
+			Transition:     db.AccountClose,
+			SweepAccountID: receiver.ID,
+		})).
+		Times(1).
+		Return(db.ChangeAccountStatusTxResult{
+			Account: closed,
+			Sweep: &db.TransferTxResult{
+				Transfer:    db.Transfer{ID: 1, FromAccountID: account.ID, ToAccountID: receiver.ID, Amount: account.Balance},
+				FromAccount: closed,
+				ToAccount:   receiver,
+			},
+		}, nil)
+
+	owner, _, err := token.NewJWTMaker(testTokenKey).CreateToken(account.Owner, util.DepositorRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	c := newTestClient(t, store, nil, WithToken(owner))
+
+	_, err = c.CloseAccount(context.Background(), account.ID, CloseAccountParams{})
+	require.ErrorIs(t, err, api.ErrAccountNotEmpty)
+
+	result, err := c.CloseAccount(context.Background(), account.ID, CloseAccountParams{SweepAccountID: receiver.ID})
+	require.NoError(t, err)
+	require.Equal(t, closed, result.Account)
+	require.NotNil(t, result.Sweep)
+	require.Equal(t, account.Balance, result.Sweep.Transfer.Amount)
+}
+
+func TestTransfer(t *testing.T) {
+	fromAccount := randomAccount("USD")
+	toAccount := randomAccount("USD")
+	toAccount.ID = fromAccount.ID + 1
+	amount := int64(10)
+
+	transfer := db.Transfer{
+		ID:            1,
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        amount,
+	}
+	ownerToken, _, err := token.NewJWTMaker(testTokenKey).CreateToken(fromAccount.Owner, util.DepositorRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+
+	t.Run("OK", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+
+		updatedFrom := fromAccount
+		updatedFrom.Balance -= amount
+		updatedTo := toAccount
+		updatedTo.Balance += amount
+
+		store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+		store.EXPECT().
+			GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+			Times(1).
+			Return(db.User{Username: fromAccount.Owner, IsEmailVerified: true}, nil)
+		store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+		store.EXPECT().
+			TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{
+				FromAccountID: fromAccount.ID,
+				ToAccountID:   toAccount.ID,
+				Amount:        amount,
+			})).
+			Times(1).
+			Return(db.TransferTxResult{Transfer: transfer, FromAccount: updatedFrom, ToAccount: updatedTo}, nil)
+
+		c := newTestClient(t, store, nil, WithToken(ownerToken))
+		result, err := c.Transfer(context.Background(), TransferParams{
+			FromAccountID: fromAccount.ID,
+			ToAccountID:   toAccount.ID,
+			Amount:        amount,
+		})
+		require.NoError(t, err)
+		require.Equal(t, transfer, result.Transfer)
+		require.Equal(t, updatedFrom, result.FromAccount)
+		require.Equal(t, updatedTo, result.ToAccount)
+	})
+
+	t.Run("InsufficientBalance", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+
+		store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+		store.EXPECT().
+			GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).
+			Times(1).
+			Return(db.User{Username: fromAccount.Owner, IsEmailVerified: true}, nil)
+		store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+		c := newTestClient(t, store, nil, WithToken(ownerToken))
+		_, err := c.Transfer(context.Background(), TransferParams{
+			FromAccountID: fromAccount.ID,
+			ToAccountID:   toAccount.ID,
+			Amount:        fromAccount.Balance + 1,
+		})
+		require.ErrorIs(t, err, api.ErrInsufficientBalance)
+	})
+}
+
+func TestListEntries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	entries := make([]db.Entry, 7)
+	for i := range entries {
+		entries[i] = db.Entry{
+			ID:        int64(i + 1),
+			AccountID: 1,
+			Amount:    int64(util.RandomMoney()),
+		}
+	}
+
+	gomock.InOrder(
+		store.EXPECT().
+			ListEntries(gomock.Any(), gomock.Eq(db.ListEntriesParams{Limit: 5, Offset: 0})).
+			Times(1).
+			Return(entries[:5], nil),
+		store.EXPECT().
+			ListEntries(gomock.Any(), gomock.Eq(db.ListEntriesParams{Limit: 5, Offset: 5})).
+			Times(1).
+			Return(entries[5:], nil),
+	)
+
+	admin, _, err := token.NewJWTMaker(testTokenKey).CreateToken("admin", util.AdminRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	c := newTestClient(t, store, nil, WithToken(admin))
+
+	var got []db.Entry
+	for entry, err := range c.ListEntries(context.Background(), 5) {
+		require.NoError(t, err)
+		got = append(got, entry)
+	}
+	require.Equal(t, entries, got)
+}
+
+func TestListEntriesError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	store.EXPECT().
+		ListEntries(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil, sql.ErrConnDone)
+
+	admin, _, err := token.NewJWTMaker(testTokenKey).CreateToken("admin", util.AdminRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	c := newTestClient(t, store, nil, WithToken(admin), WithRetries(0, 0))
+
+	var errs []error
+	for _, err := range c.ListEntries(context.Background(), 5) {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], api.ErrInternal)
+}
+
+func TestListAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := db.AuditEvent{ID: 1, Actor: "alice", Action: "account.created", Target: "account:1", After: []byte(`{"id":1}`)}
+	store.EXPECT().
+		ListAuditEvents(gomock.Any(), gomock.Eq(db.ListAuditEventsParams{
+			Actor:  sql.NullString{String: "alice", Valid: true},
+			Since:  sql.NullTime{Time: since, Valid: true},
+			Limit:  5,
+			Offset: 0,
+		})).
+		Times(1).
+		Return([]db.AuditEvent{event}, nil)
+
+	admin, _, err := token.NewJWTMaker(testTokenKey).CreateToken("admin", util.AdminRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	c := newTestClient(t, store, nil, WithToken(admin))
+
+	var got []db.AuditEvent
+	for event, err := range c.ListAuditEvents(context.Background(), AuditEventFilter{Actor: "alice", Since: since}, 5) {
+		require.NoError(t, err)
+		got = append(got, event)
+	}
+	require.Len(t, got, 1)
+	require.Equal(t, event.Target, got[0].Target)
+	require.JSONEq(t, `{"id":1}`, string(got[0].After))
+}
+
+func TestRetryReusesIdempotencyKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	account := randomAccount("EUR")
+	store.EXPECT().
+		CreateAccount(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(account, nil)
+
+	var mu sync.Mutex
+	var keys []string
+	flaky := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			keys = append(keys, r.Header.Get(api.IdempotencyKeyHeader))
+			attempt := len(keys)
+			mu.Unlock()
+
+			if attempt == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	owner, _, err := token.NewJWTMaker(testTokenKey).CreateToken(account.Owner, util.DepositorRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	c := newTestClient(t, store, flaky, WithToken(owner))
+	got, err := c.CreateAccount(context.Background(), CreateAccountParams{Currency: "EUR"})
+	require.NoError(t, err)
+	require.Equal(t, account, got)
+
+	require.Len(t, keys, 2)
+	require.NotEmpty(t, keys[0])
+	require.Equal(t, keys[0], keys[1])
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	attempts := 0
+	down := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(http.StatusBadGateway)
+		})
+	}
+
+	c := newTestClient(t, store, down)
+	_, err := c.GetAccount(context.Background(), 1)
+	require.ErrorIs(t, err, api.ErrInternal)
+	require.Equal(t, 3, attempts)
+}
+
+func TestNoRetryOnServerError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	for _, status := range []int{http.StatusInternalServerError, http.StatusTooManyRequests} {
+		attempts := 0
+		failing := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.WriteHeader(status)
+			})
+		}
+
+		c := newTestClient(t, store, failing)
+		_, err := c.GetAccount(context.Background(), 1)
+		require.Error(t, err)
+		require.Equal(t, 1, attempts, "status %d", status)
+	}
+}
+
+func TestToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	account := randomAccount("USD")
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(2).Return(account, nil)
+
+	var authorization []string
+	capture := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = append(authorization, r.Header.Get("Authorization"))
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	maker := token.NewJWTMaker(testTokenKey)
+	first, _, err := maker.CreateToken("alice", util.AdminRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	second, _, err := maker.CreateToken("bob", util.AdminRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+
+	c := newTestClient(t, store, capture, WithToken(first))
+	_, err = c.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+
+	c.SetToken(second)
+	_, err = c.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"Bearer " + first, "Bearer " + second}, authorization)
+}
+
+func TestLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	hashedPassword, err := util.HashPassword("secret")
+	require.NoError(t, err)
+	user := db.User{Username: "alice", Role: util.AdminRole, HashedPassword: hashedPassword}
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq("alice")).Times(1).Return(user, nil)
+	store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Eq("bob")).Times(1).Return(db.User{Username: "bob"}, nil)
+
+	c := newTestClient(t, store, nil)
+	rsp, err := c.Login(context.Background(), "alice", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "alice", rsp.User.Username)
+	require.Empty(t, rsp.User.HashedPassword)
+
+	// the token authorizes the admin endpoints
+	unlocked, err := c.UnlockUser(context.Background(), "bob")
+	require.NoError(t, err)
+	require.Equal(t, "bob", unlocked.Username)
+}
+
+func TestStepUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "SimpleBank", AccountName: "alice"})
+	require.NoError(t, err)
+	user := db.User{Username: "alice", Role: util.DepositorRole, TotpSecret: key.Secret(), TotpEnabled: true}
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq("alice")).Times(1).Return(user, nil)
+
+	account := randomAccount("USD")
+	account.Owner = "alice"
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+
+	var stepUpToken string
+	capture := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			stepUpToken = r.Header.Get(api.StepUpTokenHeader)
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	maker := token.NewJWTMaker(testTokenKey)
+	accessToken, _, err := maker.CreateToken("alice", util.DepositorRole, token.PurposeAccess, time.Minute)
+	require.NoError(t, err)
+
+	c := newTestClient(t, store, capture, WithToken(accessToken))
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	require.NoError(t, err)
+	rsp, err := c.StepUp(context.Background(), code)
+	require.NoError(t, err)
+	require.NotEmpty(t, rsp.StepUpToken)
+
+	// the step-up token is sent along with later requests
+	_, err = c.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, rsp.StepUpToken, stepUpToken)
+}
@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"strconv"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// GetEntry fetches a ledger entry by id
+func (c *Client) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+	var entry db.Entry
+	err := c.do(ctx, http.MethodGet, "/entries/"+strconv.FormatInt(id, 10), nil, nil, &entry)
+	return entry, err
+}
+
+// ListEntries iterates over the ledger entries of the accounts of the user,
+// or over all entries with an admin token, fetching pageSize at a time.
+// Iteration stops at the first error, which is yielded with a zero entry.
+func (c *Client) ListEntries(ctx context.Context, pageSize int32) iter.Seq2[db.Entry, error] {
+	return paginate[db.Entry](ctx, c, "/entries", nil, pageSize)
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"strconv"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// TransferParams contains the input of Transfer
+type TransferParams struct {
+	FromAccountID int64 `json:"from_account_id"`
+	ToAccountID   int64 `json:"to_account_id"`
+	Amount        int64 `json:"amount"`
+}
+
+// TransferResult is the transfer and both accounts after the money moved.
+// Status is "held" instead when the transfer waits for a compliance review,
+// or "pending_approval" when it waits for the approvers of the account; no
+// money moved then and the other fields are empty.
+type TransferResult struct {
+	Status      string      `json:"status,omitempty"`
+	Transfer    db.Transfer `json:"transfer"`
+	FromAccount db.Account  `json:"from_account"`
+	ToAccount   db.Account  `json:"to_account"`
+}
+
+// Transfer moves money between two accounts. Retries reuse the same
+// idempotency key, so the transfer is executed at most once.
+func (c *Client) Transfer(ctx context.Context, arg TransferParams) (TransferResult, error) {
+	var result TransferResult
+	err := c.do(ctx, http.MethodPost, "/transfers", nil, arg, &result)
+	return result, err
+}
+
+// GetTransfer fetches a transfer by id
+func (c *Client) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+	var transfer db.Transfer
+	err := c.do(ctx, http.MethodGet, "/transfers/"+strconv.FormatInt(id, 10), nil, nil, &transfer)
+	return transfer, err
+}
+
+// TransferTimeline is a transfer with every status it has been in, oldest
+// first
+type TransferTimeline struct {
+	Transfer db.Transfer        `json:"transfer"`
+	Events   []db.TransferEvent `json:"events"`
+}
+
+// GetTransferTimeline fetches a transfer with the history of its status
+func (c *Client) GetTransferTimeline(ctx context.Context, id int64) (TransferTimeline, error) {
+	var timeline TransferTimeline
+	err := c.do(ctx, http.MethodGet, "/transfers/"+strconv.FormatInt(id, 10)+"/timeline", nil, nil, &timeline)
+	return timeline, err
+}
+
+// ListTransfers iterates over the transfers from or to the accounts of the
+// user, or over all transfers with an admin token, fetching pageSize at a
+// time
+func (c *Client) ListTransfers(ctx context.Context, pageSize int32) iter.Seq2[db.Transfer, error] {
+	return paginate[db.Transfer](ctx, c, "/transfers", nil, pageSize)
+}
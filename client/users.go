@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// CreateUserParams contains the input of CreateUser
+type CreateUserParams struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+}
+
+// CreateUser registers a new user
+func (c *Client) CreateUser(ctx context.Context, arg CreateUserParams) (db.User, error) {
+	var user db.User
+	err := c.do(ctx, http.MethodPost, "/users", nil, arg, &user)
+	return user, err
+}
+
+// GetUser fetches a user by username. It requires a token of the user or
+// of an admin.
+func (c *Client) GetUser(ctx context.Context, username string) (db.User, error) {
+	var user db.User
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(username), nil, nil, &user)
+	return user, err
+}
+
+// LoginResponse contains the access token issued by Login, and the
+// step-up token when a second factor was given
+type LoginResponse struct {
+	AccessToken          string     `json:"access_token"`
+	AccessTokenExpiresAt time.Time  `json:"access_token_expires_at"`
+	StepUpToken          string     `json:"step_up_token"`
+	StepUpTokenExpiresAt *time.Time `json:"step_up_token_expires_at"`
+	User                 db.User    `json:"user"`
+}
+
+// Login exchanges a username and password for an access token, which the
+// client sends with every subsequent request
+func (c *Client) Login(ctx context.Context, username, password string) (LoginResponse, error) {
+	return c.login(ctx, map[string]string{"username": username, "password": password})
+}
+
+// LoginWithTOTP logs in a user with two-factor authentication enabled. The
+// client also keeps the step-up token issued with the access token.
+func (c *Client) LoginWithTOTP(ctx context.Context, username, password, totpCode string) (LoginResponse, error) {
+	return c.login(ctx, map[string]string{"username": username, "password": password, "totp_code": totpCode})
+}
+
+func (c *Client) login(ctx context.Context, arg map[string]string) (LoginResponse, error) {
+	var rsp LoginResponse
+	if err := c.do(ctx, http.MethodPost, "/users/login", nil, arg, &rsp); err != nil {
+		return rsp, err
+	}
+
+	c.SetToken(rsp.AccessToken)
+	if rsp.StepUpToken != "" {
+		c.SetStepUpToken(rsp.StepUpToken)
+	}
+	return rsp, nil
+}
+
+// StepUpResponse contains the step-up token issued by StepUp
+type StepUpResponse struct {
+	StepUpToken          string    `json:"step_up_token"`
+	StepUpTokenExpiresAt time.Time `json:"step_up_token_expires_at"`
+}
+
+// StepUp verifies a TOTP code of the logged in user for a fresh step-up
+// token, which the client sends with every subsequent request
+func (c *Client) StepUp(ctx context.Context, totpCode string) (StepUpResponse, error) {
+	var rsp StepUpResponse
+	arg := map[string]string{"totp_code": totpCode}
+	if err := c.do(ctx, http.MethodPost, "/users/step-up", nil, arg, &rsp); err != nil {
+		return rsp, err
+	}
+
+	c.SetStepUpToken(rsp.StepUpToken)
+	return rsp, nil
+}
+
+// UnlockUser lifts a login lockout. It requires an admin token.
+func (c *Client) UnlockUser(ctx context.Context, username string) (db.User, error) {
+	var user db.User
+	err := c.do(ctx, http.MethodPost, "/users/"+url.PathEscape(username)+"/unlock", nil, nil, &user)
+	return user, err
+}
+
+// ResendVerificationEmail sends the logged in user a new verification link
+func (c *Client) ResendVerificationEmail(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/users/verify-email", nil, nil, nil)
+}
+
+// VerifyEmail verifies an email with the token from a verification link
+func (c *Client) VerifyEmail(ctx context.Context, token string) (db.User, error) {
+	var user db.User
+	arg := map[string]string{"token": token}
+	err := c.do(ctx, http.MethodPost, "/users/verify-email/confirm", nil, arg, &user)
+	return user, err
+}
+
+// RequestPasswordReset emails a password reset link if a user has the
+// email. It succeeds either way.
+func (c *Client) RequestPasswordReset(ctx context.Context, email string) error {
+	arg := map[string]string{"email": email}
+	return c.do(ctx, http.MethodPost, "/users/password-reset", nil, arg, nil)
+}
+
+// ResetPassword sets a new password with the token from a reset link
+func (c *Client) ResetPassword(ctx context.Context, token, password string) (db.User, error) {
+	var user db.User
+	arg := map[string]string{"token": token, "password": password}
+	err := c.do(ctx, http.MethodPost, "/users/password-reset/confirm", nil, arg, &user)
+	return user, err
+}
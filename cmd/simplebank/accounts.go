@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+func createAccount(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("account create")
+	owner := flags.String("owner", "", "username of the owner")
+	currency := flags.String("currency", "", "currency, USD or EUR")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "owner", "currency"); err != nil {
+		return err
+	}
+
+	var account db.Account
+	err := a.store.ExecTx(ctx, func(tx db.Store) error {
+		var err error
+		account, err = tx.CreateAccount(ctx, db.CreateAccountParams{
+			Owner:    *owner,
+			Balance:  0,
+			Currency: *currency,
+		})
+		if err != nil {
+			return err
+		}
+		return a.audit(ctx, tx, "account.created", fmt.Sprintf("account:%d", account.ID), nil, account)
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.print(account, accountsTable(account))
+}
+
+// adjustAccount posts a manual credit (positive amount) or debit (negative
+// amount) together with a ledger entry
+func adjustAccount(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("account adjust")
+	id := flags.Int64("id", 0, "account id")
+	amount := flags.Int64("amount", 0, "amount to credit, negative to debit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "id", "amount"); err != nil {
+		return err
+	}
+
+	var result db.AdjustBalanceTxResult
+	err := a.store.ExecTx(ctx, func(tx db.Store) error {
+		var err error
+		result, err = tx.AdjustBalanceTx(ctx, db.AdjustBalanceTxParams{
+			AccountID: *id,
+			Amount:    *amount,
+		})
+		if err != nil {
+			return err
+		}
+		return a.audit(ctx, tx, "account.adjusted", fmt.Sprintf("account:%d", *id), nil, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.print(result, accountsTable(result.Account))
+}
+
+func freezeAccount(ctx context.Context, a *app, args []string) error {
+	return changeAccountStatus(ctx, a, "account freeze", "account.frozen", db.AccountFreeze, args)
+}
+
+func unfreezeAccount(ctx context.Context, a *app, args []string) error {
+	return changeAccountStatus(ctx, a, "account unfreeze", "account.unfrozen", db.AccountUnfreeze, args)
+}
+
+func markAccountDormant(ctx context.Context, a *app, args []string) error {
+	return changeAccountStatus(ctx, a, "account dormant", "account.marked_dormant", db.AccountMarkDormant, args)
+}
+
+func closeAccount(ctx context.Context, a *app, args []string) error {
+	return changeAccountStatus(ctx, a, "account close", "account.closed", db.AccountClose, args)
+}
+
+func reopenAccount(ctx context.Context, a *app, args []string) error {
+	return changeAccountStatus(ctx, a, "account reopen", "account.reopened", db.AccountReopen, args)
+}
+
+func changeAccountStatus(ctx context.Context, a *app, name string, action string, transition db.AccountTransition, args []string) error {
+	flags := newFlagSet(name)
+	id := flags.Int64("id", 0, "account id")
+	var sweepTo *int64
+	if transition.To == db.AccountStatusClosed {
+		sweepTo = flags.Int64("sweep-to", 0, "account id that receives the remaining balance")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "id"); err != nil {
+		return err
+	}
+
+	arg := db.ChangeAccountStatusTxParams{
+		AccountID:  *id,
+		Transition: transition,
+	}
+	if sweepTo != nil {
+		arg.SweepAccountID = *sweepTo
+	}
+	var result db.ChangeAccountStatusTxResult
+	err := a.store.ExecTx(ctx, func(tx db.Store) error {
+		var err error
+		result, err = tx.ChangeAccountStatusTx(ctx, arg)
+		if err != nil {
+			return fmt.Errorf("cannot %s account %d: %w", transition.Name, *id, err)
+		}
+		return a.audit(ctx, tx, action, fmt.Sprintf("account:%d", *id), nil, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	accounts := []db.Account{result.Account}
+	if result.Sweep != nil {
+		accounts = append(accounts, result.Sweep.ToAccount)
+	}
+	return a.print(result, accountsTable(accounts...))
+}
+
+type accountHistoryResult struct {
+	Account db.Account `json:"account"`
+	Entries []db.Entry `json:"entries"`
+}
+
+func accountHistory(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("account history")
+	id := flags.Int64("id", 0, "account id")
+	limit := flags.Int("limit", 50, "maximum number of entries")
+	offset := flags.Int("offset", 0, "number of entries to skip")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "id"); err != nil {
+		return err
+	}
+
+	account, err := a.store.GetAccount(ctx, *id)
+	if err != nil {
+		return fmt.Errorf("cannot get account %d: %w", *id, err)
+	}
+
+	entries, err := a.store.ListEntriesByAccount(ctx, db.ListEntriesByAccountParams{
+		AccountID: *id,
+		Limit:     int32(*limit),
+		Offset:    int32(*offset),
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.print(accountHistoryResult{Account: account, Entries: entries}, entriesTable(entries...))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+var errAuditTampered = errors.New("audit log was tampered with")
+
+// cliActor names the operator in audit events, e.g. "cli:alice"
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return "cli:" + name
+	}
+	return "cli"
+}
+
+// audit records a change made by a command in tx, the transaction of the
+// change, so that neither is kept without the other
+func (a *app) audit(ctx context.Context, tx db.Store, action string, target string, before, after any) error {
+	arg := db.AppendAuditEventTxParams{
+		Actor:  a.actor,
+		Action: action,
+		Target: target,
+	}
+	var err error
+	if before != nil {
+		if arg.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if arg.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.AppendAuditEventTx(ctx, arg); err != nil {
+		return fmt.Errorf("cannot record audit event of %s: %w", action, err)
+	}
+	return nil
+}
+
+// verifyAudit checks the hash chain of the audit log and fails when an
+// event was changed, removed or inserted
+func verifyAudit(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("audit verify")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	status, err := a.store.VerifyAuditChain(ctx)
+	if err != nil && !errors.Is(err, db.ErrAuditChainBroken) {
+		return err
+	}
+
+	result := "ok"
+	if err != nil {
+		result = err.Error()
+	}
+	t := table{
+		headers: []string{"EVENTS", "LAST ID", "LAST HASH", "RESULT"},
+		rows:    [][]string{{fmt.Sprint(status.Events), fmt.Sprint(status.LastID), status.LastHash, result}},
+	}
+	if printErr := a.print(status, t); printErr != nil {
+		return printErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", errAuditTampered, err)
+	}
+	return nil
+}
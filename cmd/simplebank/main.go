@@ -0,0 +1,172 @@
+// Command simplebank is the admin CLI for operating the bank.
+//
+// Usage:
+//
+//	simplebank [-config dir] [-output table|json] <command> <subcommand> [flags]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/pii"
+	"github.com/hiiamanop/simple_bank/util"
+	_ "github.com/lib/pq"
+)
+
+// app holds the dependencies shared by every subcommand
+type app struct {
+	config util.Config
+	store  db.Store
+	out    io.Writer
+	format string
+	// actor is recorded in the audit events of the changes made
+	actor string
+}
+
+type subcommand func(ctx context.Context, a *app, args []string) error
+
+// commands maps "command subcommand" to its implementation
+var commands = map[string]subcommand{
+	"user create":      createUser,
+	"user role":        setUserRole,
+	"user unlock":      unlockUser,
+	"user verify":      verifyUserEmail,
+	"account create":   createAccount,
+	"account adjust":   adjustAccount,
+	"account freeze":   freezeAccount,
+	"account unfreeze": unfreezeAccount,
+	"account dormant":  markAccountDormant,
+	"account close":    closeAccount,
+	"account reopen":   reopenAccount,
+	"account history":  accountHistory,
+	"transfer reverse": reverseTransfer,
+	"reconcile run":    reconcile,
+	"audit verify":     verifyAudit,
+	"pii keygen":       piiKeygen,
+	"pii rotate":       piiRotate,
+	"pii reencrypt":    piiReencrypt,
+	"migrate up":       migrateUp,
+	"migrate down":     migrateDown,
+	"migrate status":   migrateStatus,
+	"migrate version":  migrateVersion,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("simplebank", flag.ContinueOnError)
+	configPath := flags.String("config", ".", "directory containing app.env")
+	format := flags.String("output", "table", "output format: table or json")
+	flags.Usage = func() { printUsage(flags.Output()) }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := util.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("cannot load config: %w", err)
+	}
+
+	dbConn, err := db.Open(config)
+	if err != nil {
+		return fmt.Errorf("cannot connect to db: %w", err)
+	}
+	defer dbConn.Close()
+
+	// without a key file only "pii keygen" and the commands that do not
+	// touch personal data work
+	var opts []db.StoreOption
+	keys, err := pii.LoadKeyFile(config.PIIKeyFile)
+	switch {
+	case err == nil:
+		opts = append(opts, db.WithCipher(pii.NewCipher(keys)))
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("cannot load PII keys: %w", err)
+	}
+
+	a := &app{
+		config: config,
+		store:  db.NewStore(dbConn, opts...),
+		out:    out,
+		format: *format,
+		actor:  cliActor(),
+	}
+	return a.run(ctx, flags.Args())
+}
+
+// run dispatches args to a subcommand. A command without a subcommand, such
+// as "reconcile", runs its "run" subcommand.
+func (a *app) run(ctx context.Context, args []string) error {
+	if a.format != "table" && a.format != "json" {
+		return fmt.Errorf("unknown output format %q", a.format)
+	}
+	if len(args) == 0 {
+		printUsage(a.out)
+		return errors.New("missing command")
+	}
+
+	if len(args) >= 2 {
+		if cmd, ok := commands[args[0]+" "+args[1]]; ok {
+			return cmd(ctx, a, args[2:])
+		}
+	}
+	if cmd, ok := commands[args[0]+" run"]; ok {
+		return cmd(ctx, a, args[1:])
+	}
+
+	printUsage(a.out)
+	return fmt.Errorf("unknown command %q", strings.Join(args, " "))
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, strings.TrimSuffix(name, " run"))
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: simplebank [-config dir] [-output table|json] <command> [flags]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, name := range names {
+		fmt.Fprintln(w, "  "+name)
+	}
+}
+
+// newFlagSet creates the flag set of a subcommand
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// requireFlags fails when any of the named flags was left at its zero value
+func requireFlags(flags *flag.FlagSet, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		f := flags.Lookup(name)
+		if f != nil && (f.Value.String() == "" || f.Value.String() == "0") {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: missing required flags %s", flags.Name(), strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/hiiamanop/simple_bank/db/mock"
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/pii"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomAccount() db.Account {
+	return db.Account{
+		ID:       int64(util.RandomInt(1, 1000)),
+		Owner:    util.RandomOwner(),
+		Balance:  int64(util.RandomMoney()),
+		Currency: util.RandomCurrency(),
+		Status:   db.AccountStatusActive,
+	}
+}
+
+func TestRun(t *testing.T) {
+	account := randomAccount()
+
+	testCases := []struct {
+		name       string
+		args       []string
+		format     string
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, output string, err error)
+	}{
+		{
+			name:       "MissingCommand",
+			args:       nil,
+			buildStubs: func(store *mockdb.MockStore) {},
+			check: func(t *testing.T, output string, err error) {
+				require.Error(t, err)
+				require.Contains(t, output, "account history")
+			},
+		},
+		{
+			name:       "UnknownCommand",
+			args:       []string{"account", "explode"},
+			buildStubs: func(store *mockdb.MockStore) {},
+			check: func(t *testing.T, output string, err error) {
+				require.ErrorContains(t, err, "unknown command")
+			},
+		},
+		{
+			name:       "MissingFlag",
+			args:       []string{"account", "create", "-owner", account.Owner},
+			buildStubs: func(store *mockdb.MockStore) {},
+			check: func(t *testing.T, output string, err error) {
+				require.ErrorContains(t, err, "-currency")
+			},
+		},
+		{
+			name: "CreateAccount",
+			args: []string{"account", "create", "-owner", account.Owner, "-currency", "USD"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Eq(db.CreateAccountParams{
+						Owner:    account.Owner,
+						Balance:  0,
+						Currency: "USD",
+					})).
+					Times(1).
+					Return(account, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, "OWNER")
+				require.Contains(t, output, account.Owner)
+			},
+		},
+		{
+			name: "AdjustAccount",
+			args: []string{"account", "adjust", "-id", "7", "-amount", "-25"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AdjustBalanceTx(gomock.Any(), gomock.Eq(db.AdjustBalanceTxParams{AccountID: 7, Amount: -25})).
+					Times(1).
+					Return(db.AdjustBalanceTxResult{Account: account}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, account.Owner)
+			},
+		},
+		{
+			name: "FreezeAccount",
+			args: []string{"account", "freeze", "-id", "7"},
+			buildStubs: func(store *mockdb.MockStore) {
+				frozen := account
+				frozen.Status = db.AccountStatusFrozen
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Eq(db.ChangeAccountStatusTxParams{AccountID: 7, Transition: db.AccountFreeze})).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{Account: frozen}, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+						require.Equal(t, "cli:operator", arg.Actor)
+						require.Equal(t, "account.frozen", arg.Action)
+						require.Equal(t, "account:7", arg.Target)
+						require.Contains(t, string(arg.After), `"status":"frozen"`)
+						return db.AuditEvent{}, nil
+					})
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, db.AccountStatusFrozen)
+			},
+		},
+		{
+			name: "CloseAccount",
+			args: []string{"account", "close", "-id", "7", "-sweep-to", "8"},
+			buildStubs: func(store *mockdb.MockStore) {
+				closed := account
+				closed.Status = db.AccountStatusClosed
+				receiver := randomAccount()
+				arg := db.ChangeAccountStatusTxParams{AccountID: 7, Transition: db.AccountClose, SweepAccountID: 8}
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{
+						Account: closed,
+						Sweep:   &db.TransferTxResult{ToAccount: receiver},
+					}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, db.AccountStatusClosed)
+			},
+		},
+		{
+			name: "ReopenActiveAccount",
+			args: []string{"account", "reopen", "-id", "7"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{}, db.ErrInvalidAccountTransition)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.ErrorIs(t, err, db.ErrInvalidAccountTransition)
+			},
+		},
+		{
+			name: "SetUserRole",
+			args: []string{"user", "role", "-username", "alice", "-role", util.AdminRole},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserRole(gomock.Any(), gomock.Eq(db.UpdateUserRoleParams{Username: "alice", Role: util.AdminRole})).
+					Times(1).
+					Return(db.User{Username: "alice", Role: util.AdminRole, HashedPassword: "secret-hash"}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, util.AdminRole)
+				require.NotContains(t, output, "secret-hash")
+			},
+		},
+		{
+			name: "AuditError",
+			args: []string{"user", "unlock", "-username", "alice"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetFailedLogins(gomock.Any(), gomock.Any()).Times(1).Return(db.User{Username: "alice"}, nil)
+				store.EXPECT().
+					AppendAuditEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AuditEvent{}, errors.New("connection refused"))
+			},
+			check: func(t *testing.T, output string, err error) {
+				// the unlock is rolled back with the audit event
+				require.ErrorContains(t, err, "cannot record audit event of user.unlocked")
+			},
+		},
+		{
+			name:       "UnknownRole",
+			args:       []string{"user", "role", "-username", "alice", "-role", "root"},
+			buildStubs: func(store *mockdb.MockStore) {},
+			check: func(t *testing.T, output string, err error) {
+				require.ErrorContains(t, err, "unknown role")
+			},
+		},
+		{
+			name: "UnlockUser",
+			args: []string{"user", "unlock", "-username", "alice"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetFailedLogins(gomock.Any(), gomock.Eq("alice")).
+					Times(1).
+					Return(db.User{Username: "alice", Role: util.DepositorRole}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, "alice")
+			},
+		},
+		{
+			name: "VerifyUserEmail",
+			args: []string{"user", "verify", "-username", "alice"},
+			buildStubs: func(store *mockdb.MockStore) {
+				alice := db.User{Username: "alice", Email: "alice@example.com", EmailIndex: "1f2e"}
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq("alice")).Times(1).Return(alice, nil)
+
+				verified := alice
+				verified.IsEmailVerified = true
+				store.EXPECT().
+					VerifyUserEmail(gomock.Any(), gomock.Eq(db.VerifyUserEmailParams{Username: "alice", EmailIndex: "1f2e"})).
+					Times(1).
+					Return(verified, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, "alice")
+			},
+		},
+		{
+			name:   "AccountHistoryJSON",
+			args:   []string{"account", "history", "-id", "7", "-limit", "10"},
+			format: "json",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(int64(7))).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListEntriesByAccount(gomock.Any(), gomock.Eq(db.ListEntriesByAccountParams{AccountID: 7, Limit: 10, Offset: 0})).
+					Times(1).
+					Return([]db.Entry{{ID: 1, AccountID: 7, Amount: 100}}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+
+				var got accountHistoryResult
+				require.NoError(t, json.Unmarshal([]byte(output), &got))
+				require.Equal(t, account.Owner, got.Account.Owner)
+				require.Len(t, got.Entries, 1)
+				require.Equal(t, int64(100), got.Entries[0].Amount)
+			},
+		},
+		{
+			name: "ReverseTransfer",
+			args: []string{"transfer", "reverse", "-id", "3"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReverseTransferTx(gomock.Any(), gomock.Eq(int64(3))).
+					Times(1).
+					Return(db.TransferTxResult{Transfer: db.Transfer{ID: 4, FromAccountID: 2, ToAccountID: 1, Amount: 50}}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, "50")
+			},
+		},
+		{
+			name: "AuditVerify",
+			args: []string{"audit", "verify"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyAuditChain(gomock.Any()).
+					Times(1).
+					Return(db.AuditChainStatus{Events: 42, LastID: 42, LastHash: "c0ffee"}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, "c0ffee")
+				require.Contains(t, output, "ok")
+			},
+		},
+		{
+			name: "AuditVerifyTampered",
+			args: []string{"audit", "verify"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyAuditChain(gomock.Any()).
+					Times(1).
+					Return(db.AuditChainStatus{Events: 6, LastID: 6, LastHash: "c0ffee"},
+						fmt.Errorf("%w: event 7 was modified", db.ErrAuditChainBroken))
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.ErrorIs(t, err, errAuditTampered)
+				require.ErrorIs(t, err, db.ErrAuditChainBroken)
+				require.Contains(t, output, "event 7 was modified")
+			},
+		},
+		{
+			name: "PIIReencrypt",
+			args: []string{"pii", "reencrypt"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReencryptPII(gomock.Any()).
+					Times(1).
+					Return(db.ReencryptPIIResult{Users: 3, VerifyEmails: 2, Rewritten: 4}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, output, "REWRITTEN")
+			},
+		},
+		{
+			name: "PIIReencryptSkipped",
+			args: []string{"pii", "reencrypt"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReencryptPII(gomock.Any()).
+					Times(1).
+					Return(db.ReencryptPIIResult{Users: 3, Rewritten: 2, Skipped: 1}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.ErrorContains(t, err, "run it again")
+			},
+		},
+		{
+			name: "ReconcileClean",
+			args: []string{"reconcile"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUnbalancedAccounts(gomock.Any()).
+					Times(1).
+					Return([]db.ListUnbalancedAccountsRow{}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "ReconcileMismatch",
+			args: []string{"reconcile"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUnbalancedAccounts(gomock.Any()).
+					Times(1).
+					Return([]db.ListUnbalancedAccountsRow{{ID: 1, Owner: "alice", Currency: "USD", Balance: 100, EntriesTotal: 80}}, nil)
+			},
+			check: func(t *testing.T, output string, err error) {
+				require.ErrorIs(t, err, errUnbalanced)
+				require.Contains(t, output, "alice")
+				require.Contains(t, output, "20")
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			// the mock has no transactions, the calls of a command go to it directly
+			store.EXPECT().
+				ExecTx(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				DoAndReturn(func(_ context.Context, fn func(db.Store) error) error {
+					return fn(store)
+				})
+			// cases that check the audit event expect it first
+			store.EXPECT().AppendAuditEventTx(gomock.Any(), gomock.Any()).AnyTimes().Return(db.AuditEvent{}, nil)
+
+			format := tc.format
+			if format == "" {
+				format = "table"
+			}
+
+			var out bytes.Buffer
+			a := &app{store: store, out: &out, format: format, actor: "cli:operator"}
+			err := a.run(context.Background(), tc.args)
+			tc.check(t, out.String(), err)
+		})
+	}
+}
+
+func TestPIIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pii_keys.json")
+	a := &app{config: util.Config{PIIKeyFile: path}, out: io.Discard, format: "table"}
+
+	require.NoError(t, a.run(context.Background(), []string{"pii", "keygen"}))
+	keys, err := pii.LoadKeyFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "k1", keys.Current)
+
+	// the keys in use are never replaced
+	require.ErrorContains(t, a.run(context.Background(), []string{"pii", "keygen"}), "already exists")
+
+	var out bytes.Buffer
+	a.out = &out
+	require.NoError(t, a.run(context.Background(), []string{"pii", "rotate", "-file", path}))
+	require.Contains(t, out.String(), "k1,k2")
+
+	rotated, err := pii.LoadKeyFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "k2", rotated.Current)
+	require.Equal(t, keys.Keys["k1"], rotated.Keys["k1"])
+	require.Equal(t, keys.Index, rotated.Index)
+}
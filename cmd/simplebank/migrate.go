@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/hiiamanop/simple_bank/db/migration"
+)
+
+// withMigrate runs fn against the embedded migrations and the configured
+// database
+func withMigrate(a *app, fn func(m *migrate.Migrate) error) error {
+	m, err := migration.New(a.config.DBSource)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return fn(m)
+}
+
+// migrateUp applies all pending migrations, or only -steps of them
+func migrateUp(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("migrate up")
+	steps := flags.Int("steps", 0, "number of migrations to apply, 0 for all")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return withMigrate(a, func(m *migrate.Migrate) error {
+		var err error
+		if *steps > 0 {
+			err = m.Steps(*steps)
+		} else {
+			err = m.Up()
+		}
+		return reportMigration(a, m, err)
+	})
+}
+
+// migrateDown reverts one migration by default. Reverting everything
+// requires -all so that the schema is not dropped by accident.
+func migrateDown(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("migrate down")
+	steps := flags.Int("steps", 1, "number of migrations to revert")
+	all := flags.Bool("all", false, "revert all migrations")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return withMigrate(a, func(m *migrate.Migrate) error {
+		var err error
+		if *all {
+			err = m.Down()
+		} else {
+			err = m.Steps(-*steps)
+		}
+		return reportMigration(a, m, err)
+	})
+}
+
+// migrateStatus shows the applied version and the pending migrations
+func migrateStatus(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("migrate status")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return withMigrate(a, func(m *migrate.Migrate) error {
+		status, err := migration.GetStatus(m)
+		if err != nil {
+			return err
+		}
+
+		return a.print(status, table{
+			headers: []string{"VERSION", "DIRTY", "LATEST", "PENDING"},
+			rows: [][]string{{
+				fmt.Sprint(status.Version),
+				fmt.Sprint(status.Dirty),
+				fmt.Sprint(status.Latest),
+				fmt.Sprint(len(status.Pending)),
+			}},
+		})
+	})
+}
+
+// migrateVersion prints the applied version only
+func migrateVersion(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("migrate version")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return withMigrate(a, func(m *migrate.Migrate) error {
+		return reportMigration(a, m, nil)
+	})
+}
+
+func reportMigration(a *app, m *migrate.Migrate, err error) error {
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Fprintln(a.out, "no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "database at version %d (dirty: %t)\n", version, dirty)
+	return nil
+}
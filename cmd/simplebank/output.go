@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// table is the tabular rendering of a command result
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+// print writes v as indented JSON or t as an aligned table, depending on
+// the -output flag
+func (a *app) print(v any, t table) error {
+	if a.format == "json" {
+		encoder := json.NewEncoder(a.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func accountsTable(accounts ...db.Account) table {
+	t := table{headers: []string{"ID", "OWNER", "BALANCE", "CURRENCY", "STATUS", "CREATED AT"}}
+	for _, account := range accounts {
+		t.rows = append(t.rows, []string{
+			fmt.Sprint(account.ID),
+			account.Owner,
+			fmt.Sprint(account.Balance),
+			fmt.Sprint(account.Currency),
+			account.Status,
+			formatTime(account.CreatedAt),
+		})
+	}
+	return t
+}
+
+func entriesTable(entries ...db.Entry) table {
+	t := table{headers: []string{"ID", "ACCOUNT", "AMOUNT", "CREATED AT"}}
+	for _, entry := range entries {
+		t.rows = append(t.rows, []string{
+			fmt.Sprint(entry.ID),
+			fmt.Sprint(entry.AccountID),
+			fmt.Sprint(entry.Amount),
+			formatTime(entry.CreatedAt),
+		})
+	}
+	return t
+}
+
+func transfersTable(transfers ...db.Transfer) table {
+	t := table{headers: []string{"ID", "FROM", "TO", "AMOUNT", "STATUS", "CREATED AT"}}
+	for _, transfer := range transfers {
+		t.rows = append(t.rows, []string{
+			fmt.Sprint(transfer.ID),
+			fmt.Sprint(transfer.FromAccountID),
+			fmt.Sprint(transfer.ToAccountID),
+			fmt.Sprint(transfer.Amount),
+			transfer.Status,
+			formatTime(transfer.CreatedAt),
+		})
+	}
+	return t
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/pii"
+)
+
+type keyFileOutput struct {
+	File    string   `json:"file"`
+	Current string   `json:"current"`
+	Keys    []string `json:"keys"`
+}
+
+func (a *app) printKeyFile(path string, file *pii.KeyFile) error {
+	output := keyFileOutput{File: path, Current: file.Current}
+	for id := range file.Keys {
+		output.Keys = append(output.Keys, id)
+	}
+	sort.Strings(output.Keys)
+
+	return a.print(output, table{
+		headers: []string{"FILE", "CURRENT KEY", "KEYS"},
+		rows:    [][]string{{output.File, output.Current, strings.Join(output.Keys, ",")}},
+	})
+}
+
+// piiKeygen creates the key file that encrypts personal data. It never
+// overwrites an existing one, whose keys may still be in use.
+func piiKeygen(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("pii keygen")
+	path := flags.String("file", a.config.PIIKeyFile, "key file to create")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(*path); !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s already exists, use \"pii rotate\" to add a key", *path)
+	}
+	file, err := pii.NewKeyFile()
+	if err != nil {
+		return err
+	}
+	if err := file.Save(*path); err != nil {
+		return fmt.Errorf("cannot save %s: %w", *path, err)
+	}
+	return a.printKeyFile(*path, file)
+}
+
+// piiRotate adds a new key to the key file and makes it the current one.
+// The servers have to be restarted to use it, and "pii reencrypt" moves
+// the existing data to it.
+func piiRotate(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("pii rotate")
+	path := flags.String("file", a.config.PIIKeyFile, "key file to rotate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	file, err := pii.LoadKeyFile(*path)
+	if err != nil {
+		return fmt.Errorf("cannot load %s: %w", *path, err)
+	}
+	if _, err := file.Rotate(); err != nil {
+		return err
+	}
+	if err := file.Save(*path); err != nil {
+		return fmt.Errorf("cannot save %s: %w", *path, err)
+	}
+	return a.printKeyFile(*path, file)
+}
+
+// piiReencrypt moves all personal data to the current key and encrypts
+// what was stored before encryption was introduced
+func piiReencrypt(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("pii reencrypt")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var result db.ReencryptPIIResult
+	err := a.store.ExecTx(ctx, func(tx db.Store) error {
+		var err error
+		result, err = tx.ReencryptPII(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot re-encrypt: %w", err)
+		}
+		return a.audit(ctx, tx, "pii.reencrypted", "pii", nil, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = a.print(result, table{
+		headers: []string{"USERS", "VERIFY EMAILS", "KYC PROFILES", "SCREENING HITS", "REWRITTEN", "SKIPPED"},
+		rows: [][]string{{
+			fmt.Sprint(result.Users), fmt.Sprint(result.VerifyEmails), fmt.Sprint(result.KYCProfiles),
+			fmt.Sprint(result.ScreeningHits), fmt.Sprint(result.Rewritten), fmt.Sprint(result.Skipped),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.Skipped > 0 {
+		return fmt.Errorf("%d rows changed meanwhile, run it again", result.Skipped)
+	}
+	return nil
+}
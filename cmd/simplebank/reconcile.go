@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var errUnbalanced = errors.New("ledger does not reconcile")
+
+// reconcile compares every account balance with the sum of its entries and
+// fails when any of them differ
+func reconcile(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("reconcile")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	accounts, err := a.store.ListUnbalancedAccounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"ID", "OWNER", "CURRENCY", "BALANCE", "ENTRIES TOTAL", "DIFFERENCE"}}
+	for _, account := range accounts {
+		t.rows = append(t.rows, []string{
+			fmt.Sprint(account.ID),
+			account.Owner,
+			fmt.Sprint(account.Currency),
+			fmt.Sprint(account.Balance),
+			fmt.Sprint(account.EntriesTotal),
+			fmt.Sprint(account.Balance - account.EntriesTotal),
+		})
+	}
+	if err := a.print(accounts, t); err != nil {
+		return err
+	}
+
+	if len(accounts) > 0 {
+		return fmt.Errorf("%w: %d accounts differ from their entries", errUnbalanced, len(accounts))
+	}
+	return nil
+}
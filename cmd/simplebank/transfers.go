@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+func reverseTransfer(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("transfer reverse")
+	id := flags.Int64("id", 0, "id of the transfer to reverse")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "id"); err != nil {
+		return err
+	}
+
+	var result db.TransferTxResult
+	err := a.store.ExecTx(ctx, func(tx db.Store) error {
+		var err error
+		result, err = tx.ReverseTransferTx(ctx, *id)
+		if err != nil {
+			return err
+		}
+		return a.audit(ctx, tx, "transfer.reversed", fmt.Sprintf("transfer:%d", *id), nil, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.print(result, transfersTable(result.Transfer))
+}
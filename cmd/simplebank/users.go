@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+// userOutput is a user without its password hash
+type userOutput struct {
+	Username            string    `json:"username"`
+	FullName            string    `json:"full_name"`
+	Email               string    `json:"email"`
+	Role                string    `json:"role"`
+	EmailVerified       bool      `json:"email_verified"`
+	FailedLoginAttempts int32     `json:"failed_login_attempts"`
+	LockedUntil         time.Time `json:"locked_until"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func createUser(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("user create")
+	username := flags.String("username", "", "username (alphanumeric)")
+	password := flags.String("password", "", "initial password")
+	fullName := flags.String("full-name", "", "full name")
+	email := flags.String("email", "", "email address")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "username", "password", "full-name", "email"); err != nil {
+		return err
+	}
+
+	hashedPassword, err := util.HashPassword(*password)
+	if err != nil {
+		return err
+	}
+
+	var user db.User
+	err = a.store.ExecTx(ctx, func(tx db.Store) error {
+		var err error
+		user, err = tx.CreateUser(ctx, db.CreateUserParams{
+			Username:       *username,
+			HashedPassword: hashedPassword,
+			FullName:       *fullName,
+			Email:          *email,
+		})
+		if err != nil {
+			return err
+		}
+		return a.auditUser(ctx, tx, "user.created", user)
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.printUser(user)
+}
+
+func setUserRole(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("user role")
+	username := flags.String("username", "", "username")
+	role := flags.String("role", "", "depositor or admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "username", "role"); err != nil {
+		return err
+	}
+	if !util.IsSupportedRole(*role) {
+		return fmt.Errorf("unknown role %q", *role)
+	}
+
+	var user db.User
+	err := a.store.ExecTx(ctx, func(tx db.Store) error {
+		var err error
+		user, err = tx.UpdateUserRole(ctx, db.UpdateUserRoleParams{
+			Username: *username,
+			Role:     *role,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot update user %s: %w", *username, err)
+		}
+		return a.auditUser(ctx, tx, "user.role_changed", user)
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.printUser(user)
+}
+
+func unlockUser(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("user unlock")
+	username := flags.String("username", "", "username")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "username"); err != nil {
+		return err
+	}
+
+	var user db.User
+	err := a.store.ExecTx(ctx, func(tx db.Store) error {
+		var err error
+		user, err = tx.ResetFailedLogins(ctx, *username)
+		if err != nil {
+			return fmt.Errorf("cannot unlock user %s: %w", *username, err)
+		}
+		return a.auditUser(ctx, tx, "user.unlocked", user)
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.printUser(user)
+}
+
+// verifyUserEmail marks the current email of a user as verified, e.g. for
+// users created with "user create"
+func verifyUserEmail(ctx context.Context, a *app, args []string) error {
+	flags := newFlagSet("user verify")
+	username := flags.String("username", "", "username")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(flags, "username"); err != nil {
+		return err
+	}
+
+	var verified db.User
+	err := a.store.ExecTx(ctx, func(tx db.Store) error {
+		user, err := tx.GetUser(ctx, *username)
+		if err != nil {
+			return fmt.Errorf("cannot get user %s: %w", *username, err)
+		}
+		verified, err = tx.VerifyUserEmail(ctx, db.VerifyUserEmailParams{
+			Username:   user.Username,
+			EmailIndex: user.EmailIndex,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot verify user %s: %w", *username, err)
+		}
+		return a.audit(ctx, tx, "user.email_verified", "user:"+user.Username, newUserAuditState(user), newUserAuditState(verified))
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.printUser(verified)
+}
+
+func newUserOutput(user db.User) userOutput {
+	return userOutput{
+		Username:            user.Username,
+		FullName:            user.FullName,
+		Email:               user.Email,
+		Role:                user.Role,
+		EmailVerified:       user.IsEmailVerified,
+		FailedLoginAttempts: user.FailedLoginAttempts,
+		LockedUntil:         user.LockedUntil,
+		CreatedAt:           user.CreatedAt,
+	}
+}
+
+// userAuditState is what the audit log keeps of a user. The log cannot be
+// erased, so it leaves out the name and email.
+type userAuditState struct {
+	Username            string    `json:"username"`
+	Role                string    `json:"role"`
+	EmailVerified       bool      `json:"email_verified"`
+	FailedLoginAttempts int32     `json:"failed_login_attempts"`
+	LockedUntil         time.Time `json:"locked_until"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func newUserAuditState(user db.User) userAuditState {
+	return userAuditState{
+		Username:            user.Username,
+		Role:                user.Role,
+		EmailVerified:       user.IsEmailVerified,
+		FailedLoginAttempts: user.FailedLoginAttempts,
+		LockedUntil:         user.LockedUntil,
+		CreatedAt:           user.CreatedAt,
+	}
+}
+
+// auditUser records a change of user, without its password hash or
+// personal data
+func (a *app) auditUser(ctx context.Context, tx db.Store, action string, user db.User) error {
+	return a.audit(ctx, tx, action, "user:"+user.Username, nil, newUserAuditState(user))
+}
+
+func (a *app) printUser(user db.User) error {
+	return a.print(newUserOutput(user), table{
+		headers: []string{"USERNAME", "FULL NAME", "EMAIL", "ROLE", "FAILED LOGINS", "CREATED AT"},
+		rows: [][]string{{
+			user.Username, user.FullName, user.Email, user.Role,
+			strconv.Itoa(int(user.FailedLoginAttempts)), formatTime(user.CreatedAt),
+		}},
+	})
+}
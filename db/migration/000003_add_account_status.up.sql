@@ -0,0 +1,3 @@
+ALTER TABLE "account" ADD COLUMN "status" varchar NOT NULL DEFAULT 'active';
+
+COMMENT ON COLUMN "account"."status" IS 'active or frozen';
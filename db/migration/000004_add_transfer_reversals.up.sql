@@ -0,0 +1,8 @@
+CREATE TABLE "transfer_reversals" (
+    "transfer_id" bigint PRIMARY KEY,
+    "reversal_id" bigint UNIQUE NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "transfer_reversals" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+ALTER TABLE "transfer_reversals" ADD FOREIGN KEY ("reversal_id") REFERENCES "transfers" ("id");
@@ -0,0 +1,11 @@
+-- Token buckets of the Postgres rate limiter, shared by all instances
+CREATE TABLE "rate_limit_buckets" (
+    "key" varchar PRIMARY KEY,
+    "tokens" double precision NOT NULL,
+    "allowed" boolean NOT NULL,
+    "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "rate_limit_buckets" ("updated_at");
+
+COMMENT ON COLUMN "rate_limit_buckets"."allowed" IS 'outcome of the last request, returned by the upsert';
@@ -0,0 +1,6 @@
+ALTER TABLE "users" ADD COLUMN "role" varchar NOT NULL DEFAULT 'depositor';
+ALTER TABLE "users" ADD COLUMN "failed_login_attempts" integer NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN "locked_until" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z';
+
+COMMENT ON COLUMN "users"."role" IS 'depositor or admin';
+COMMENT ON COLUMN "users"."failed_login_attempts" IS 'consecutive failed logins since the last successful one';
@@ -0,0 +1,18 @@
+ALTER TABLE "users" ADD COLUMN "totp_secret" varchar NOT NULL DEFAULT '';
+ALTER TABLE "users" ADD COLUMN "totp_enabled" boolean NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN "users"."totp_secret" IS 'base32 TOTP secret, set on enrolment and in use once totp_enabled';
+
+CREATE TABLE "recovery_codes" (
+    "id" bigserial PRIMARY KEY,
+    "username" varchar NOT NULL,
+    "hashed_code" varchar NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE UNIQUE INDEX ON "recovery_codes" ("username", "hashed_code");
+
+COMMENT ON COLUMN "recovery_codes"."hashed_code" IS 'SHA-256 of the code, hex encoded';
@@ -0,0 +1,21 @@
+ALTER TABLE "users" ADD COLUMN "is_email_verified" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE "verify_emails" (
+    "id" bigserial PRIMARY KEY,
+    "username" varchar NOT NULL,
+    "email" varchar NOT NULL,
+    "purpose" varchar NOT NULL,
+    "hashed_token" varchar NOT NULL,
+    "used_at" timestamptz,
+    "expired_at" timestamptz NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "verify_emails" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE UNIQUE INDEX ON "verify_emails" ("hashed_token");
+CREATE INDEX ON "verify_emails" ("username");
+
+COMMENT ON COLUMN "verify_emails"."email" IS 'address the token was sent to';
+COMMENT ON COLUMN "verify_emails"."purpose" IS 'email_verification or password_reset';
+COMMENT ON COLUMN "verify_emails"."hashed_token" IS 'SHA-256 of the token, hex encoded';
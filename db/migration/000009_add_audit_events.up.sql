@@ -0,0 +1,35 @@
+CREATE TABLE "audit_events" (
+    "id" bigserial PRIMARY KEY,
+    "actor" varchar NOT NULL,
+    "action" varchar NOT NULL,
+    "target" varchar NOT NULL,
+    "before" json NOT NULL DEFAULT 'null',
+    "after" json NOT NULL DEFAULT 'null',
+    "request_id" varchar NOT NULL DEFAULT '',
+    "ip" varchar NOT NULL DEFAULT '',
+    "created_at" timestamptz NOT NULL,
+    "prev_hash" varchar NOT NULL,
+    "hash" varchar NOT NULL
+);
+
+CREATE INDEX ON "audit_events" ("actor");
+CREATE INDEX ON "audit_events" ("target");
+CREATE INDEX ON "audit_events" ("created_at");
+
+COMMENT ON COLUMN "audit_events"."actor" IS 'username of the caller, anonymous or cli:<os user>';
+COMMENT ON COLUMN "audit_events"."before" IS 'state of the target before the change, json rather than jsonb to keep the hashed text';
+COMMENT ON COLUMN "audit_events"."after" IS 'state of the target after the change';
+COMMENT ON COLUMN "audit_events"."prev_hash" IS 'hash of the previous event, empty for the first one';
+COMMENT ON COLUMN "audit_events"."hash" IS 'SHA-256 over prev_hash and the other columns, hex encoded';
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON "audit_events"
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON "audit_events"
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,3 @@
+COMMENT ON COLUMN "account"."status" IS 'active or frozen';
+
+ALTER TABLE "account" DROP CONSTRAINT IF EXISTS "account_status_check";
@@ -0,0 +1,4 @@
+ALTER TABLE "account" ADD CONSTRAINT "account_status_check"
+  CHECK ("status" IN ('active', 'frozen', 'dormant', 'closed'));
+
+COMMENT ON COLUMN "account"."status" IS 'active, frozen, dormant or closed; only active accounts send or receive money';
@@ -0,0 +1,14 @@
+ALTER TABLE "verify_emails" DROP CONSTRAINT "verify_emails_username_fkey";
+ALTER TABLE "verify_emails" ADD CONSTRAINT "verify_emails_username_fkey"
+  FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "recovery_codes" DROP CONSTRAINT "recovery_codes_username_fkey";
+ALTER TABLE "recovery_codes" ADD CONSTRAINT "recovery_codes_username_fkey"
+  FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "account" DROP CONSTRAINT "account_owner_fkey";
+ALTER TABLE "account" ADD CONSTRAINT "account_owner_fkey"
+  FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "erased_at";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "deleted_at";
@@ -0,0 +1,18 @@
+ALTER TABLE "users" ADD COLUMN "deleted_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "erased_at" timestamptz;
+
+COMMENT ON COLUMN "users"."deleted_at" IS 'set when the user is deleted; the row stays for the ledger';
+COMMENT ON COLUMN "users"."erased_at" IS 'set when the personal data of a deleted user was pseudonymised';
+
+-- erasure renames the user, and the rows that refer to it follow
+ALTER TABLE "account" DROP CONSTRAINT "account_owner_fkey";
+ALTER TABLE "account" ADD CONSTRAINT "account_owner_fkey"
+  FOREIGN KEY ("owner") REFERENCES "users" ("username") ON UPDATE CASCADE;
+
+ALTER TABLE "recovery_codes" DROP CONSTRAINT "recovery_codes_username_fkey";
+ALTER TABLE "recovery_codes" ADD CONSTRAINT "recovery_codes_username_fkey"
+  FOREIGN KEY ("username") REFERENCES "users" ("username") ON UPDATE CASCADE;
+
+ALTER TABLE "verify_emails" DROP CONSTRAINT "verify_emails_username_fkey";
+ALTER TABLE "verify_emails" ADD CONSTRAINT "verify_emails_username_fkey"
+  FOREIGN KEY ("username") REFERENCES "users" ("username") ON UPDATE CASCADE;
@@ -0,0 +1,7 @@
+-- the values stay encrypted; decrypting them needs the keys
+COMMENT ON COLUMN "verify_emails"."email" IS 'address the token was sent to';
+COMMENT ON COLUMN "users"."email" IS NULL;
+COMMENT ON COLUMN "users"."full_name" IS NULL;
+
+ALTER TABLE "users" ADD CONSTRAINT "users_email_key" UNIQUE ("email");
+ALTER TABLE "users" DROP COLUMN IF EXISTS "email_index";
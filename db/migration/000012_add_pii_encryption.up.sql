@@ -0,0 +1,13 @@
+-- full_name and email are encrypted, so the email is looked up and kept
+-- unique by its blind index. Existing rows get a placeholder until
+-- "simplebank pii reencrypt" encrypts them.
+ALTER TABLE "users" ADD COLUMN "email_index" varchar;
+UPDATE "users" SET "email_index" = 'unindexed:' || "username";
+ALTER TABLE "users" ALTER COLUMN "email_index" SET NOT NULL;
+ALTER TABLE "users" ADD CONSTRAINT "users_email_index_key" UNIQUE ("email_index");
+ALTER TABLE "users" DROP CONSTRAINT "users_email_key";
+
+COMMENT ON COLUMN "users"."full_name" IS 'encrypted, see package pii';
+COMMENT ON COLUMN "users"."email" IS 'encrypted, see package pii';
+COMMENT ON COLUMN "users"."email_index" IS 'blind index of the email, HMAC-SHA256 hex encoded';
+COMMENT ON COLUMN "verify_emails"."email" IS 'encrypted address the token was sent to';
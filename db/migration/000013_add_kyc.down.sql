@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "kyc_profiles";
+DROP TABLE IF EXISTS "kyc_tiers";
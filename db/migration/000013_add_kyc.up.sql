@@ -0,0 +1,64 @@
+CREATE TABLE "kyc_tiers" (
+    "tier" int PRIMARY KEY,
+    "name" varchar NOT NULL,
+    "max_balance" bigint NOT NULL,
+    "max_transfer_amount" bigint NOT NULL
+);
+
+COMMENT ON COLUMN "kyc_tiers"."max_balance" IS 'highest balance a transfer may leave in a receiving account';
+COMMENT ON COLUMN "kyc_tiers"."max_transfer_amount" IS 'largest amount a single transfer may send';
+
+-- customers without a verified profile are in tier 0 and cannot move money
+INSERT INTO "kyc_tiers" ("tier", "name", "max_balance", "max_transfer_amount") VALUES
+    (0, 'unverified', 0, 0),
+    (1, 'basic', 500000, 100000),
+    (2, 'full', 100000000, 10000000);
+
+CREATE TABLE "kyc_profiles" (
+    "username" varchar PRIMARY KEY,
+    "legal_name" varchar NOT NULL,
+    "date_of_birth" varchar NOT NULL,
+    "address" varchar NOT NULL,
+    "document_type" varchar NOT NULL,
+    "document_number" varchar NOT NULL,
+    "document_country" varchar NOT NULL,
+    "status" varchar NOT NULL DEFAULT 'pending',
+    "tier" int NOT NULL DEFAULT 0,
+    "review_note" varchar NOT NULL DEFAULT '',
+    "reviewed_by" varchar NOT NULL DEFAULT '',
+    "reviewed_at" timestamptz,
+    "submitted_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- erasure renames the user, and the profile is kept for the retention period
+ALTER TABLE "kyc_profiles" ADD FOREIGN KEY ("username") REFERENCES "users" ("username") ON UPDATE CASCADE;
+ALTER TABLE "kyc_profiles" ADD FOREIGN KEY ("tier") REFERENCES "kyc_tiers" ("tier");
+ALTER TABLE "kyc_profiles" ADD CONSTRAINT "kyc_profiles_status_check"
+  CHECK ("status" IN ('pending', 'verified', 'rejected'));
+ALTER TABLE "kyc_profiles" ADD CONSTRAINT "kyc_profiles_document_type_check"
+  CHECK ("document_type" IN ('passport', 'national_id', 'driving_licence', 'legacy'));
+
+CREATE INDEX ON "kyc_profiles" ("status", "submitted_at");
+
+COMMENT ON COLUMN "kyc_profiles"."legal_name" IS 'encrypted, see package pii';
+COMMENT ON COLUMN "kyc_profiles"."date_of_birth" IS 'encrypted, YYYY-MM-DD';
+COMMENT ON COLUMN "kyc_profiles"."address" IS 'encrypted, see package pii';
+COMMENT ON COLUMN "kyc_profiles"."document_type" IS 'passport, national_id or driving_licence; legacy for customers verified before KYC';
+COMMENT ON COLUMN "kyc_profiles"."document_number" IS 'encrypted, see package pii';
+COMMENT ON COLUMN "kyc_profiles"."document_country" IS 'ISO 3166-1 alpha-2 code of the issuing country';
+COMMENT ON COLUMN "kyc_profiles"."status" IS 'pending, verified or rejected';
+COMMENT ON COLUMN "kyc_profiles"."tier" IS 'tier granted by the reviewer, 0 unless verified';
+COMMENT ON COLUMN "kyc_profiles"."reviewed_by" IS 'username of the reviewer, empty while pending';
+
+-- customers who verified their email before KYC existed keep moving small
+-- amounts: they get a verified profile in the basic tier without identity
+-- data, and need a reviewed profile for anything more
+INSERT INTO "kyc_profiles" (
+    "username", "legal_name", "date_of_birth", "address", "document_type",
+    "document_number", "document_country", "status", "tier", "review_note",
+    "reviewed_by", "reviewed_at"
+)
+SELECT "username", '', '', '', 'legacy', '', '', 'verified', 1, 'verified before KYC',
+    'migration', now()
+FROM "users"
+WHERE "is_email_verified" AND "deleted_at" IS NULL;
@@ -0,0 +1,30 @@
+CREATE TABLE "transfer_limits" (
+    "scope" varchar NOT NULL,
+    "subject" varchar NOT NULL,
+    "max_amount" bigint,
+    "daily_amount" bigint,
+    "monthly_amount" bigint,
+    "hourly_count" int,
+    "note" varchar NOT NULL DEFAULT '',
+    "updated_by" varchar NOT NULL DEFAULT '',
+    "updated_at" timestamptz NOT NULL DEFAULT (now()),
+    PRIMARY KEY ("scope", "subject")
+);
+
+ALTER TABLE "transfer_limits" ADD CONSTRAINT "transfer_limits_scope_check"
+  CHECK ("scope" IN ('account', 'user'));
+ALTER TABLE "transfer_limits" ADD CONSTRAINT "transfer_limits_positive_check"
+  CHECK ("max_amount" > 0 AND "daily_amount" > 0 AND "monthly_amount" > 0 AND "hourly_count" > 0);
+
+COMMENT ON COLUMN "transfer_limits"."scope" IS 'account or user; user limits count the transfers from all accounts of the user';
+COMMENT ON COLUMN "transfer_limits"."subject" IS 'account id or username, empty for the default of the scope';
+COMMENT ON COLUMN "transfer_limits"."max_amount" IS 'largest single transfer; NULL inherits the default, or means no limit in the default';
+COMMENT ON COLUMN "transfer_limits"."daily_amount" IS 'total sent in the last 24 hours';
+COMMENT ON COLUMN "transfer_limits"."monthly_amount" IS 'total sent in the last 30 days';
+COMMENT ON COLUMN "transfer_limits"."hourly_count" IS 'number of transfers sent in the last hour';
+
+INSERT INTO "transfer_limits" ("scope", "subject", "daily_amount", "monthly_amount", "hourly_count", "note")
+VALUES ('user', '', 1000000, 5000000, 20, 'default limits');
+
+-- the limits sum up the recent transfers of an account
+CREATE INDEX ON "transfers" ("from_account_id", "created_at");
@@ -0,0 +1,36 @@
+CREATE TABLE "monitoring_alerts" (
+    "id" bigserial PRIMARY KEY,
+    "rule" varchar NOT NULL,
+    "severity" varchar NOT NULL,
+    "reason" varchar NOT NULL,
+    "transfer_id" bigint,
+    "from_account_id" bigint NOT NULL,
+    "to_account_id" bigint NOT NULL,
+    "amount" bigint NOT NULL,
+    "status" varchar NOT NULL DEFAULT 'open',
+    "assigned_to" varchar NOT NULL DEFAULT '',
+    "resolution_note" varchar NOT NULL DEFAULT '',
+    "resolved_by" varchar NOT NULL DEFAULT '',
+    "resolved_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "monitoring_alerts" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+ALTER TABLE "monitoring_alerts" ADD FOREIGN KEY ("from_account_id") REFERENCES "account" ("id");
+ALTER TABLE "monitoring_alerts" ADD FOREIGN KEY ("to_account_id") REFERENCES "account" ("id");
+ALTER TABLE "monitoring_alerts" ADD CONSTRAINT "monitoring_alerts_status_check"
+  CHECK ("status" IN ('open', 'investigating', 'dismissed', 'reported', 'held', 'released', 'rejected'));
+
+CREATE INDEX ON "monitoring_alerts" ("status", "created_at");
+CREATE INDEX ON "monitoring_alerts" ("transfer_id");
+
+COMMENT ON COLUMN "monitoring_alerts"."rule" IS 'name of the rule in the monitoring rules file';
+COMMENT ON COLUMN "monitoring_alerts"."reason" IS 'why the rule matched';
+COMMENT ON COLUMN "monitoring_alerts"."transfer_id" IS 'flagged transfer; NULL while a held transfer is not released';
+COMMENT ON COLUMN "monitoring_alerts"."amount" IS 'amount of the transfer, kept for held transfers that were not executed';
+COMMENT ON COLUMN "monitoring_alerts"."status" IS 'open, investigating, dismissed or reported; held, released or rejected for blocked transfers';
+COMMENT ON COLUMN "monitoring_alerts"."assigned_to" IS 'username of the analyst working on the alert';
+COMMENT ON COLUMN "monitoring_alerts"."resolved_by" IS 'username of the analyst who closed the alert';
+
+-- the rules look at the recent transfers received by an account
+CREATE INDEX ON "transfers" ("to_account_id", "created_at");
@@ -0,0 +1,37 @@
+CREATE TABLE "screening_hits" (
+    "id" bigserial PRIMARY KEY,
+    "action" varchar NOT NULL,
+    "username" varchar NOT NULL,
+    "name" varchar NOT NULL,
+    "name_index" varchar NOT NULL,
+    "list" varchar NOT NULL,
+    "entry_id" varchar NOT NULL,
+    "entry_name" varchar NOT NULL,
+    "program" varchar NOT NULL DEFAULT '',
+    "score" double precision NOT NULL,
+    "status" varchar NOT NULL DEFAULT 'pending',
+    "review_note" varchar NOT NULL DEFAULT '',
+    "reviewed_by" varchar NOT NULL DEFAULT '',
+    "reviewed_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "screening_hits" ADD CONSTRAINT "screening_hits_action_check"
+  CHECK ("action" IN ('onboarding', 'transfer'));
+ALTER TABLE "screening_hits" ADD CONSTRAINT "screening_hits_status_check"
+  CHECK ("status" IN ('pending', 'cleared', 'confirmed'));
+
+-- a decision holds for the name and the list entry, wherever the name
+-- shows up again
+CREATE UNIQUE INDEX ON "screening_hits" ("name_index", "list", "entry_id");
+CREATE INDEX ON "screening_hits" ("status", "created_at");
+
+COMMENT ON COLUMN "screening_hits"."action" IS 'what the screening blocked: onboarding or transfer';
+COMMENT ON COLUMN "screening_hits"."username" IS 'user whose name was screened; at onboarding the requested username, which may not exist';
+COMMENT ON COLUMN "screening_hits"."name" IS 'screened name, encrypted';
+COMMENT ON COLUMN "screening_hits"."name_index" IS 'blind index of the normalised name';
+COMMENT ON COLUMN "screening_hits"."list" IS 'ofac_sdn or eu_consolidated';
+COMMENT ON COLUMN "screening_hits"."entry_id" IS 'identifier of the entry within the list';
+COMMENT ON COLUMN "screening_hits"."entry_name" IS 'name or alias of the entry that matched';
+COMMENT ON COLUMN "screening_hits"."score" IS 'similarity of the names in [0, 1]';
+COMMENT ON COLUMN "screening_hits"."status" IS 'pending until a reviewer clears the hit as a false positive or confirms it';
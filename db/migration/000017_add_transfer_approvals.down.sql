@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "transfer_approval_decisions";
+DROP TABLE IF EXISTS "transfer_approvals";
+DROP TABLE IF EXISTS "account_approvers";
+DROP TABLE IF EXISTS "approval_policies";
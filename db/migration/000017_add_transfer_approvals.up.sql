@@ -0,0 +1,76 @@
+CREATE TABLE "approval_policies" (
+    "account_id" bigint PRIMARY KEY,
+    "threshold" bigint NOT NULL,
+    "required_approvals" int NOT NULL DEFAULT 1,
+    "updated_by" varchar NOT NULL DEFAULT '',
+    "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "approval_policies" ADD FOREIGN KEY ("account_id") REFERENCES "account" ("id");
+ALTER TABLE "approval_policies" ADD CONSTRAINT "approval_policies_positive_check"
+  CHECK ("threshold" > 0 AND "required_approvals" > 0);
+
+COMMENT ON COLUMN "approval_policies"."threshold" IS 'transfers of a larger amount from the account need approval';
+COMMENT ON COLUMN "approval_policies"."required_approvals" IS 'number of approvers who must approve a transfer';
+
+CREATE TABLE "account_approvers" (
+    "account_id" bigint NOT NULL,
+    "username" varchar NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    PRIMARY KEY ("account_id", "username")
+);
+
+ALTER TABLE "account_approvers" ADD FOREIGN KEY ("account_id") REFERENCES "approval_policies" ("account_id") ON DELETE CASCADE;
+-- erasure renames the user
+ALTER TABLE "account_approvers" ADD FOREIGN KEY ("username") REFERENCES "users" ("username") ON UPDATE CASCADE;
+
+CREATE INDEX ON "account_approvers" ("username");
+
+CREATE TABLE "transfer_approvals" (
+    "id" bigserial PRIMARY KEY,
+    "from_account_id" bigint NOT NULL,
+    "to_account_id" bigint NOT NULL,
+    "amount" bigint NOT NULL,
+    "initiated_by" varchar NOT NULL,
+    "required_approvals" int NOT NULL,
+    "approvals" int NOT NULL DEFAULT 0,
+    "status" varchar NOT NULL DEFAULT 'pending_approval',
+    "transfer_id" bigint,
+    "failure_reason" varchar NOT NULL DEFAULT '',
+    "expires_at" timestamptz NOT NULL,
+    "decided_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "transfer_approvals" ADD FOREIGN KEY ("from_account_id") REFERENCES "account" ("id");
+ALTER TABLE "transfer_approvals" ADD FOREIGN KEY ("to_account_id") REFERENCES "account" ("id");
+ALTER TABLE "transfer_approvals" ADD FOREIGN KEY ("initiated_by") REFERENCES "users" ("username") ON UPDATE CASCADE;
+ALTER TABLE "transfer_approvals" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+ALTER TABLE "transfer_approvals" ADD CONSTRAINT "transfer_approvals_status_check"
+  CHECK ("status" IN ('pending_approval', 'approved', 'executed', 'failed', 'rejected', 'expired'));
+ALTER TABLE "transfer_approvals" ADD CONSTRAINT "transfer_approvals_amount_check"
+  CHECK ("amount" > 0);
+
+CREATE INDEX ON "transfer_approvals" ("status", "expires_at");
+CREATE INDEX ON "transfer_approvals" ("from_account_id", "created_at");
+
+COMMENT ON COLUMN "transfer_approvals"."initiated_by" IS 'username of the maker, who cannot approve the transfer';
+COMMENT ON COLUMN "transfer_approvals"."required_approvals" IS 'approvals the policy required when the transfer was requested';
+COMMENT ON COLUMN "transfer_approvals"."status" IS 'pending_approval, then approved and executed or failed; rejected or expired without moving money';
+COMMENT ON COLUMN "transfer_approvals"."transfer_id" IS 'executed transfer, NULL until the final approval';
+COMMENT ON COLUMN "transfer_approvals"."failure_reason" IS 'error code of the transfer that failed after the final approval';
+COMMENT ON COLUMN "transfer_approvals"."decided_at" IS 'time of the final approval, the rejection or the expiry';
+
+CREATE TABLE "transfer_approval_decisions" (
+    "approval_id" bigint NOT NULL,
+    "approver" varchar NOT NULL,
+    "decision" varchar NOT NULL,
+    "note" varchar NOT NULL DEFAULT '',
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    PRIMARY KEY ("approval_id", "approver")
+);
+
+ALTER TABLE "transfer_approval_decisions" ADD FOREIGN KEY ("approval_id") REFERENCES "transfer_approvals" ("id");
+ALTER TABLE "transfer_approval_decisions" ADD FOREIGN KEY ("approver") REFERENCES "users" ("username") ON UPDATE CASCADE;
+ALTER TABLE "transfer_approval_decisions" ADD CONSTRAINT "transfer_approval_decisions_decision_check"
+  CHECK ("decision" IN ('approve', 'reject'));
@@ -0,0 +1,12 @@
+COMMENT ON COLUMN "transfer_approvals"."transfer_id" IS 'executed transfer, NULL until the final approval';
+
+-- without a status a transfer row means the money moved
+UPDATE "transfer_approvals" SET "transfer_id" = NULL WHERE "status" <> 'executed';
+DROP TABLE IF EXISTS "transfer_events";
+DELETE FROM "transfers" WHERE "status" IN ('pending', 'processing', 'failed', 'cancelled');
+
+ALTER TABLE "transfers" DROP CONSTRAINT IF EXISTS "transfers_status_check";
+ALTER TABLE "transfers"
+  DROP COLUMN IF EXISTS "updated_at",
+  DROP COLUMN IF EXISTS "failure_reason",
+  DROP COLUMN IF EXISTS "status";
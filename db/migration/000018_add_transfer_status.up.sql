@@ -0,0 +1,66 @@
+ALTER TABLE "transfers"
+  ADD COLUMN "status" varchar NOT NULL DEFAULT 'completed',
+  ADD COLUMN "failure_reason" varchar NOT NULL DEFAULT '',
+  ADD COLUMN "updated_at" timestamptz NOT NULL DEFAULT (now());
+
+-- every transfer so far moved the money when it was created
+UPDATE "transfers" SET "updated_at" = "created_at";
+UPDATE "transfers" SET "status" = 'reversed', "updated_at" = r."created_at"
+FROM "transfer_reversals" r
+WHERE r."transfer_id" = "transfers"."id";
+
+ALTER TABLE "transfers" ALTER COLUMN "status" SET DEFAULT 'pending';
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_status_check"
+  CHECK ("status" IN ('pending', 'processing', 'completed', 'failed', 'reversed', 'cancelled'));
+
+COMMENT ON COLUMN "transfers"."status" IS 'pending, processing, then completed or failed; completed transfers can be reversed, pending ones cancelled';
+COMMENT ON COLUMN "transfers"."failure_reason" IS 'error code of a failed transfer';
+COMMENT ON COLUMN "transfers"."updated_at" IS 'time of the last status change';
+
+CREATE TABLE "transfer_events" (
+    "id" bigserial PRIMARY KEY,
+    "transfer_id" bigint NOT NULL,
+    "status" varchar NOT NULL,
+    "reason" varchar NOT NULL DEFAULT '',
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "transfer_events" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id") ON DELETE CASCADE;
+
+CREATE INDEX ON "transfer_events" ("transfer_id", "id");
+
+COMMENT ON COLUMN "transfer_events"."status" IS 'status the transfer entered';
+COMMENT ON COLUMN "transfer_events"."reason" IS 'error code of a failure, or why the transfer was cancelled';
+
+INSERT INTO "transfer_events" ("transfer_id", "status", "created_at")
+SELECT "id", 'completed', "created_at" FROM "transfers"
+ORDER BY "id";
+
+INSERT INTO "transfer_events" ("transfer_id", "status", "created_at")
+SELECT "transfer_id", 'reversed', "created_at" FROM "transfer_reversals"
+ORDER BY "transfer_id";
+
+-- transfers waiting for approval get their pending transfer, which the
+-- final approval executes
+DO $$
+DECLARE
+  approval record;
+  pending_id bigint;
+BEGIN
+  FOR approval IN
+    SELECT * FROM "transfer_approvals"
+    WHERE "transfer_id" IS NULL AND "status" IN ('pending_approval', 'approved')
+    ORDER BY "id"
+  LOOP
+    INSERT INTO "transfers" ("from_account_id", "to_account_id", "amount", "created_at", "updated_at")
+    VALUES (approval."from_account_id", approval."to_account_id", approval."amount", approval."created_at", approval."created_at")
+    RETURNING "id" INTO pending_id;
+
+    INSERT INTO "transfer_events" ("transfer_id", "status", "created_at")
+    VALUES (pending_id, 'pending', approval."created_at");
+
+    UPDATE "transfer_approvals" SET "transfer_id" = pending_id WHERE "id" = approval."id";
+  END LOOP;
+END $$;
+
+COMMENT ON COLUMN "transfer_approvals"."transfer_id" IS 'the transfer, pending until the final approval executes it';
@@ -0,0 +1,12 @@
+-- a held alert describes its transfer by itself again, and held transfers
+-- end failed like those of held approvals did
+UPDATE "monitoring_alerts" SET "transfer_id" = NULL WHERE "status" = 'held';
+UPDATE "transfers" SET "status" = 'failed', "failure_reason" = 'held' WHERE "status" = 'held';
+
+COMMENT ON COLUMN "monitoring_alerts"."transfer_id" IS 'flagged transfer; NULL while a held transfer is not released';
+COMMENT ON COLUMN "transfer_events"."reason" IS 'error code of a failure, or why the transfer was cancelled';
+COMMENT ON COLUMN "transfers"."status" IS 'pending, processing, then completed or failed; completed transfers can be reversed, pending ones cancelled';
+
+ALTER TABLE "transfers" DROP CONSTRAINT "transfers_status_check";
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_status_check"
+  CHECK ("status" IN ('pending', 'processing', 'completed', 'failed', 'reversed', 'cancelled'));
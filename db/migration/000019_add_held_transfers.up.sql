@@ -0,0 +1,30 @@
+ALTER TABLE "transfers" DROP CONSTRAINT "transfers_status_check";
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_status_check"
+  CHECK ("status" IN ('pending', 'held', 'processing', 'completed', 'failed', 'reversed', 'cancelled'));
+
+COMMENT ON COLUMN "transfers"."status" IS 'pending or held, processing, then completed or failed; completed transfers can be reversed, pending ones cancelled and held ones rejected';
+COMMENT ON COLUMN "transfer_events"."reason" IS 'error code of a failure, rule that held the transfer, or why it was cancelled';
+COMMENT ON COLUMN "monitoring_alerts"."transfer_id" IS 'flagged transfer; a held transfer stays held until the alert is released or rejected';
+
+-- alerts held so far only describe their transfer, book it as held
+DO $$
+DECLARE
+  alert record;
+  held_id bigint;
+BEGIN
+  FOR alert IN
+    SELECT * FROM "monitoring_alerts"
+    WHERE "status" = 'held' AND "transfer_id" IS NULL
+    ORDER BY "id"
+  LOOP
+    INSERT INTO "transfers" ("from_account_id", "to_account_id", "amount", "status", "created_at", "updated_at")
+    VALUES (alert."from_account_id", alert."to_account_id", alert."amount", 'held', alert."created_at", alert."created_at")
+    RETURNING "id" INTO held_id;
+
+    INSERT INTO "transfer_events" ("transfer_id", "status", "reason", "created_at")
+    VALUES (held_id, 'pending', '', alert."created_at"),
+           (held_id, 'held', alert."rule", alert."created_at");
+
+    UPDATE "monitoring_alerts" SET "transfer_id" = held_id WHERE "id" = alert."id";
+  END LOOP;
+END $$;
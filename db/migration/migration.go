@@ -0,0 +1,129 @@
+// Package migration embeds the SQL migrations of the bank and applies them
+// with golang-migrate, so no external migrate binary is needed.
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Status describes how far a database is behind the embedded migrations
+type Status struct {
+	Version uint   `json:"version"`
+	Dirty   bool   `json:"dirty"`
+	Latest  uint   `json:"latest"`
+	Pending []uint `json:"pending"`
+}
+
+// New returns a migrate instance that applies the embedded migrations to
+// the database at databaseURL. Callers must Close it.
+func New(databaseURL string) (*migrate.Migrate, error) {
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create migrate instance: %w", err)
+	}
+	return m, nil
+}
+
+// Up applies all pending migrations. It is a no-op when the database is
+// already up to date.
+func Up(databaseURL string) error {
+	m, err := New(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Versions lists the versions of all embedded migrations in order
+func Versions() ([]uint, error) {
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []uint{version}
+	for {
+		version, err = src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+}
+
+// LatestVersion returns the version of the newest embedded migration
+func LatestVersion() (uint, error) {
+	versions, err := Versions()
+	if err != nil {
+		return 0, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// CurrentVersion reads the applied version straight from the
+// schema_migrations table, reusing an existing connection pool.
+// A database without migrations reports version 0.
+func CurrentVersion(ctx context.Context, db *sql.DB) (version uint, dirty bool, err error) {
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// GetStatus compares the version applied by m with the embedded migrations
+func GetStatus(m *migrate.Migrate) (Status, error) {
+	var status Status
+
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, err
+	}
+	status.Version = version
+	status.Dirty = dirty
+
+	versions, err := Versions()
+	if err != nil {
+		return status, err
+	}
+	status.Latest = versions[len(versions)-1]
+	status.Pending = []uint{}
+	for _, v := range versions {
+		if v > version {
+			status.Pending = append(status.Pending, v)
+		}
+	}
+
+	return status, nil
+}
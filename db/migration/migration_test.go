@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/hiiamanop/simple_bank/util"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// createFreshDatabase creates an empty database next to the configured one
+// and returns its URL. The database is dropped when the test finishes.
+func createFreshDatabase(t *testing.T) string {
+	config, err := util.LoadConfig("../..")
+	require.NoError(t, err)
+
+	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	name := fmt.Sprintf("simple_bank_migration_%d", time.Now().UnixNano())
+	_, err = conn.Exec("CREATE DATABASE " + name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := conn.Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)")
+		require.NoError(t, err)
+	})
+
+	u, err := url.Parse(config.DBSource)
+	require.NoError(t, err)
+	u.Path = "/" + name
+	return u.String()
+}
+
+func TestVersions(t *testing.T) {
+	versions, err := Versions()
+	require.NoError(t, err)
+	require.NotEmpty(t, versions)
+
+	for i := 1; i < len(versions); i++ {
+		require.Greater(t, versions[i], versions[i-1])
+	}
+
+	latest, err := LatestVersion()
+	require.NoError(t, err)
+	require.Equal(t, versions[len(versions)-1], latest)
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	databaseURL := createFreshDatabase(t)
+
+	m, err := New(databaseURL)
+	require.NoError(t, err)
+	defer m.Close()
+
+	status, err := GetStatus(m)
+	require.NoError(t, err)
+	require.Zero(t, status.Version)
+	require.NotEmpty(t, status.Pending)
+
+	require.NoError(t, m.Up())
+
+	status, err = GetStatus(m)
+	require.NoError(t, err)
+	require.Equal(t, status.Latest, status.Version)
+	require.False(t, status.Dirty)
+	require.Empty(t, status.Pending)
+
+	conn, err := sql.Open("postgres", databaseURL)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	version, dirty, err := CurrentVersion(context.Background(), conn)
+	require.NoError(t, err)
+	require.Equal(t, status.Latest, version)
+	require.False(t, dirty)
+
+	require.NoError(t, m.Down())
+
+	_, _, err = m.Version()
+	require.ErrorIs(t, err, migrate.ErrNilVersion)
+
+	// the schema must be re-creatable after a full rollback
+	require.NoError(t, m.Up())
+	require.ErrorIs(t, m.Up(), migrate.ErrNoChange)
+}
+
+func TestKYCBackfill(t *testing.T) {
+	databaseURL := createFreshDatabase(t)
+
+	m, err := New(databaseURL)
+	require.NoError(t, err)
+	defer m.Close()
+
+	// the last version before KYC
+	require.NoError(t, m.Migrate(12))
+
+	conn, err := sql.Open("postgres", databaseURL)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Exec(`INSERT INTO users (username, hashed_password, full_name, email, email_index, is_email_verified, deleted_at) VALUES
+		('verified', 'x', 'x', 'verified@example.com', 'i1', true, NULL),
+		('unverified', 'x', 'x', 'unverified@example.com', 'i2', false, NULL),
+		('deleted', 'x', 'x', 'deleted@example.com', 'i3', true, now())`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Migrate(13))
+
+	rows, err := conn.Query(`SELECT username, status, tier, document_type FROM kyc_profiles ORDER BY username`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var profiles []string
+	for rows.Next() {
+		var username, status, documentType string
+		var tier int
+		require.NoError(t, rows.Scan(&username, &status, &tier, &documentType))
+		profiles = append(profiles, fmt.Sprintf("%s %s %d %s", username, status, tier, documentType))
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []string{"verified verified 1 legacy"}, profiles)
+}
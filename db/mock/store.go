@@ -6,7 +6,9 @@ package mockdb
 
 import (
 	context "context"
+	sql "database/sql"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	db "github.com/hiiamanop/simple_bank/db/sqlc"
@@ -18,31 +20,6 @@ type MockStore struct {
 	recorder *MockStoreMockRecorder
 }
 
-// CreateUser implements db.Store.
-func (m *MockStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
-	panic("unimplemented")
-}
-
-// DeleteUser implements db.Store.
-func (m *MockStore) DeleteUser(ctx context.Context, username string) error {
-	panic("unimplemented")
-}
-
-// GetUser implements db.Store.
-func (m *MockStore) GetUser(ctx context.Context, username string) (db.User, error) {
-	panic("unimplemented")
-}
-
-// ListUsers implements db.Store.
-func (m *MockStore) ListUsers(ctx context.Context, arg db.ListUsersParams) ([]db.User, error) {
-	panic("unimplemented")
-}
-
-// UpdateUser implements db.Store.
-func (m *MockStore) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
-	panic("unimplemented")
-}
-
 // MockStoreMockRecorder is the mock recorder for MockStore.
 type MockStoreMockRecorder struct {
 	mock *MockStore
@@ -60,6 +37,20 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// AddAccountApprover mocks base method.
+func (m *MockStore) AddAccountApprover(arg0 context.Context, arg1 db.AddAccountApproverParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAccountApprover", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAccountApprover indicates an expected call of AddAccountApprover.
+func (mr *MockStoreMockRecorder) AddAccountApprover(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountApprover", reflect.TypeOf((*MockStore)(nil).AddAccountApprover), arg0, arg1)
+}
+
 // AddAccountBalance mocks base method.
 func (m *MockStore) AddAccountBalance(arg0 context.Context, arg1 db.AddAccountBalanceParams) (db.Account, error) {
 	m.ctrl.T.Helper()
@@ -75,6 +66,111 @@ func (mr *MockStoreMockRecorder) AddAccountBalance(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountBalance", reflect.TypeOf((*MockStore)(nil).AddAccountBalance), arg0, arg1)
 }
 
+// AdjustBalanceTx mocks base method.
+func (m *MockStore) AdjustBalanceTx(arg0 context.Context, arg1 db.AdjustBalanceTxParams) (db.AdjustBalanceTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalanceTx", arg0, arg1)
+	ret0, _ := ret[0].(db.AdjustBalanceTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalanceTx indicates an expected call of AdjustBalanceTx.
+func (mr *MockStoreMockRecorder) AdjustBalanceTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalanceTx", reflect.TypeOf((*MockStore)(nil).AdjustBalanceTx), arg0, arg1)
+}
+
+// AppendAuditEventTx mocks base method.
+func (m *MockStore) AppendAuditEventTx(arg0 context.Context, arg1 db.AppendAuditEventTxParams) (db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendAuditEventTx", arg0, arg1)
+	ret0, _ := ret[0].(db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppendAuditEventTx indicates an expected call of AppendAuditEventTx.
+func (mr *MockStoreMockRecorder) AppendAuditEventTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendAuditEventTx", reflect.TypeOf((*MockStore)(nil).AppendAuditEventTx), arg0, arg1)
+}
+
+// ApproveTransferApproval mocks base method.
+func (m *MockStore) ApproveTransferApproval(arg0 context.Context, arg1 int64) (db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveTransferApproval", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveTransferApproval indicates an expected call of ApproveTransferApproval.
+func (mr *MockStoreMockRecorder) ApproveTransferApproval(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveTransferApproval", reflect.TypeOf((*MockStore)(nil).ApproveTransferApproval), arg0, arg1)
+}
+
+// AssignMonitoringAlert mocks base method.
+func (m *MockStore) AssignMonitoringAlert(arg0 context.Context, arg1 db.AssignMonitoringAlertParams) (db.MonitoringAlert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssignMonitoringAlert", arg0, arg1)
+	ret0, _ := ret[0].(db.MonitoringAlert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AssignMonitoringAlert indicates an expected call of AssignMonitoringAlert.
+func (mr *MockStoreMockRecorder) AssignMonitoringAlert(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssignMonitoringAlert", reflect.TypeOf((*MockStore)(nil).AssignMonitoringAlert), arg0, arg1)
+}
+
+// ChangeAccountStatusTx mocks base method.
+func (m *MockStore) ChangeAccountStatusTx(arg0 context.Context, arg1 db.ChangeAccountStatusTxParams) (db.ChangeAccountStatusTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeAccountStatusTx", arg0, arg1)
+	ret0, _ := ret[0].(db.ChangeAccountStatusTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeAccountStatusTx indicates an expected call of ChangeAccountStatusTx.
+func (mr *MockStoreMockRecorder) ChangeAccountStatusTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeAccountStatusTx", reflect.TypeOf((*MockStore)(nil).ChangeAccountStatusTx), arg0, arg1)
+}
+
+// CloseMonitoringAlert mocks base method.
+func (m *MockStore) CloseMonitoringAlert(arg0 context.Context, arg1 db.CloseMonitoringAlertParams) (db.MonitoringAlert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseMonitoringAlert", arg0, arg1)
+	ret0, _ := ret[0].(db.MonitoringAlert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CloseMonitoringAlert indicates an expected call of CloseMonitoringAlert.
+func (mr *MockStoreMockRecorder) CloseMonitoringAlert(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseMonitoringAlert", reflect.TypeOf((*MockStore)(nil).CloseMonitoringAlert), arg0, arg1)
+}
+
+// CountTransfersSentInRange mocks base method.
+func (m *MockStore) CountTransfersSentInRange(arg0 context.Context, arg1 db.CountTransfersSentInRangeParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTransfersSentInRange", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTransfersSentInRange indicates an expected call of CountTransfersSentInRange.
+func (mr *MockStoreMockRecorder) CountTransfersSentInRange(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTransfersSentInRange", reflect.TypeOf((*MockStore)(nil).CountTransfersSentInRange), arg0, arg1)
+}
+
 // CreateAccount mocks base method.
 func (m *MockStore) CreateAccount(arg0 context.Context, arg1 db.CreateAccountParams) (db.Account, error) {
 	m.ctrl.T.Helper()
@@ -90,6 +186,21 @@ func (mr *MockStoreMockRecorder) CreateAccount(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockStore)(nil).CreateAccount), arg0, arg1)
 }
 
+// CreateAuditEvent mocks base method.
+func (m *MockStore) CreateAuditEvent(arg0 context.Context, arg1 db.CreateAuditEventParams) (db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAuditEvent", arg0, arg1)
+	ret0, _ := ret[0].(db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAuditEvent indicates an expected call of CreateAuditEvent.
+func (mr *MockStoreMockRecorder) CreateAuditEvent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuditEvent", reflect.TypeOf((*MockStore)(nil).CreateAuditEvent), arg0, arg1)
+}
+
 // CreateEntries mocks base method.
 func (m *MockStore) CreateEntries(arg0 context.Context, arg1 db.CreateEntriesParams) (db.Entry, error) {
 	m.ctrl.T.Helper()
@@ -105,194 +216,1854 @@ func (mr *MockStoreMockRecorder) CreateEntries(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntries", reflect.TypeOf((*MockStore)(nil).CreateEntries), arg0, arg1)
 }
 
-// CreateTransfers mocks base method.
-func (m *MockStore) CreateTransfers(arg0 context.Context, arg1 db.CreateTransfersParams) (db.Transfer, error) {
+// CreateMonitoringAlert mocks base method.
+func (m *MockStore) CreateMonitoringAlert(arg0 context.Context, arg1 db.CreateMonitoringAlertParams) (db.MonitoringAlert, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTransfers", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateMonitoringAlert", arg0, arg1)
+	ret0, _ := ret[0].(db.MonitoringAlert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMonitoringAlert indicates an expected call of CreateMonitoringAlert.
+func (mr *MockStoreMockRecorder) CreateMonitoringAlert(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMonitoringAlert", reflect.TypeOf((*MockStore)(nil).CreateMonitoringAlert), arg0, arg1)
+}
+
+// CreatePendingTransfer mocks base method.
+func (m *MockStore) CreatePendingTransfer(arg0 context.Context, arg1 db.CreatePendingTransferParams) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePendingTransfer", arg0, arg1)
 	ret0, _ := ret[0].(db.Transfer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateTransfers indicates an expected call of CreateTransfers.
-func (mr *MockStoreMockRecorder) CreateTransfers(arg0, arg1 interface{}) *gomock.Call {
+// CreatePendingTransfer indicates an expected call of CreatePendingTransfer.
+func (mr *MockStoreMockRecorder) CreatePendingTransfer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfers", reflect.TypeOf((*MockStore)(nil).CreateTransfers), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePendingTransfer", reflect.TypeOf((*MockStore)(nil).CreatePendingTransfer), arg0, arg1)
 }
 
-// DeleteAccount mocks base method.
-func (m *MockStore) DeleteAccount(arg0 context.Context, arg1 int64) error {
+// CreateRecoveryCode mocks base method.
+func (m *MockStore) CreateRecoveryCode(arg0 context.Context, arg1 db.CreateRecoveryCodeParams) (db.RecoveryCode, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAccount", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateRecoveryCode", arg0, arg1)
+	ret0, _ := ret[0].(db.RecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteAccount indicates an expected call of DeleteAccount.
-func (mr *MockStoreMockRecorder) DeleteAccount(arg0, arg1 interface{}) *gomock.Call {
+// CreateRecoveryCode indicates an expected call of CreateRecoveryCode.
+func (mr *MockStoreMockRecorder) CreateRecoveryCode(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockStore)(nil).DeleteAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRecoveryCode", reflect.TypeOf((*MockStore)(nil).CreateRecoveryCode), arg0, arg1)
 }
 
-// DeleteEntries mocks base method.
-func (m *MockStore) DeleteEntries(arg0 context.Context, arg1 int64) error {
+// CreateTransferApproval mocks base method.
+func (m *MockStore) CreateTransferApproval(arg0 context.Context, arg1 db.CreateTransferApprovalParams) (db.TransferApproval, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteEntries", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateTransferApproval", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteEntries indicates an expected call of DeleteEntries.
-func (mr *MockStoreMockRecorder) DeleteEntries(arg0, arg1 interface{}) *gomock.Call {
+// CreateTransferApproval indicates an expected call of CreateTransferApproval.
+func (mr *MockStoreMockRecorder) CreateTransferApproval(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEntries", reflect.TypeOf((*MockStore)(nil).DeleteEntries), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferApproval", reflect.TypeOf((*MockStore)(nil).CreateTransferApproval), arg0, arg1)
 }
 
-// DeleteTransfers mocks base method.
-func (m *MockStore) DeleteTransfers(arg0 context.Context, arg1 int64) error {
+// CreateTransferApprovalDecision mocks base method.
+func (m *MockStore) CreateTransferApprovalDecision(arg0 context.Context, arg1 db.CreateTransferApprovalDecisionParams) (db.TransferApprovalDecision, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteTransfers", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateTransferApprovalDecision", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApprovalDecision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteTransfers indicates an expected call of DeleteTransfers.
-func (mr *MockStoreMockRecorder) DeleteTransfers(arg0, arg1 interface{}) *gomock.Call {
+// CreateTransferApprovalDecision indicates an expected call of CreateTransferApprovalDecision.
+func (mr *MockStoreMockRecorder) CreateTransferApprovalDecision(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTransfers", reflect.TypeOf((*MockStore)(nil).DeleteTransfers), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferApprovalDecision", reflect.TypeOf((*MockStore)(nil).CreateTransferApprovalDecision), arg0, arg1)
 }
 
-// GetAccount mocks base method.
-func (m *MockStore) GetAccount(arg0 context.Context, arg1 int64) (db.Account, error) {
+// CreateTransferApprovalTx mocks base method.
+func (m *MockStore) CreateTransferApprovalTx(arg0 context.Context, arg1 db.CreateTransferApprovalTxParams) (db.CreateTransferApprovalTxResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccount", arg0, arg1)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "CreateTransferApprovalTx", arg0, arg1)
+	ret0, _ := ret[0].(db.CreateTransferApprovalTxResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccount indicates an expected call of GetAccount.
-func (mr *MockStoreMockRecorder) GetAccount(arg0, arg1 interface{}) *gomock.Call {
+// CreateTransferApprovalTx indicates an expected call of CreateTransferApprovalTx.
+func (mr *MockStoreMockRecorder) CreateTransferApprovalTx(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockStore)(nil).GetAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferApprovalTx", reflect.TypeOf((*MockStore)(nil).CreateTransferApprovalTx), arg0, arg1)
 }
 
-// GetEntries mocks base method.
-func (m *MockStore) GetEntries(arg0 context.Context, arg1 int64) (db.Entry, error) {
+// CreateTransferEvent mocks base method.
+func (m *MockStore) CreateTransferEvent(arg0 context.Context, arg1 db.CreateTransferEventParams) (db.TransferEvent, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEntries", arg0, arg1)
-	ret0, _ := ret[0].(db.Entry)
+	ret := m.ctrl.Call(m, "CreateTransferEvent", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferEvent)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetEntries indicates an expected call of GetEntries.
-func (mr *MockStoreMockRecorder) GetEntries(arg0, arg1 interface{}) *gomock.Call {
+// CreateTransferEvent indicates an expected call of CreateTransferEvent.
+func (mr *MockStoreMockRecorder) CreateTransferEvent(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntries", reflect.TypeOf((*MockStore)(nil).GetEntries), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferEvent", reflect.TypeOf((*MockStore)(nil).CreateTransferEvent), arg0, arg1)
 }
 
-// GetTransfers mocks base method.
-func (m *MockStore) GetTransfers(arg0 context.Context, arg1 int64) (db.Transfer, error) {
+// CreateTransferReversal mocks base method.
+func (m *MockStore) CreateTransferReversal(arg0 context.Context, arg1 db.CreateTransferReversalParams) (db.TransferReversal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransfers", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateTransferReversal", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTransferReversal indicates an expected call of CreateTransferReversal.
+func (mr *MockStoreMockRecorder) CreateTransferReversal(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferReversal", reflect.TypeOf((*MockStore)(nil).CreateTransferReversal), arg0, arg1)
+}
+
+// CreateTransfers mocks base method.
+func (m *MockStore) CreateTransfers(arg0 context.Context, arg1 db.CreateTransfersParams) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransfers", arg0, arg1)
 	ret0, _ := ret[0].(db.Transfer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTransfers indicates an expected call of GetTransfers.
-func (mr *MockStoreMockRecorder) GetTransfers(arg0, arg1 interface{}) *gomock.Call {
+// CreateTransfers indicates an expected call of CreateTransfers.
+func (mr *MockStoreMockRecorder) CreateTransfers(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockStore)(nil).GetTransfers), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfers", reflect.TypeOf((*MockStore)(nil).CreateTransfers), arg0, arg1)
 }
 
-// ListAccounts mocks base method.
-func (m *MockStore) ListAccounts(arg0 context.Context, arg1 db.ListAccountsParams) ([]db.Account, error) {
+// CreateUser mocks base method.
+func (m *MockStore) CreateUser(arg0 context.Context, arg1 db.CreateUserParams) (db.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListAccounts", arg0, arg1)
-	ret0, _ := ret[0].([]db.Account)
+	ret := m.ctrl.Call(m, "CreateUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListAccounts indicates an expected call of ListAccounts.
-func (mr *MockStoreMockRecorder) ListAccounts(arg0, arg1 interface{}) *gomock.Call {
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockStoreMockRecorder) CreateUser(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockStore)(nil).ListAccounts), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), arg0, arg1)
 }
 
-// ListEntries mocks base method.
-func (m *MockStore) ListEntries(arg0 context.Context, arg1 db.ListEntriesParams) ([]db.Entry, error) {
+// CreateVerifyEmail mocks base method.
+func (m *MockStore) CreateVerifyEmail(arg0 context.Context, arg1 db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListEntries", arg0, arg1)
-	ret0, _ := ret[0].([]db.Entry)
+	ret := m.ctrl.Call(m, "CreateVerifyEmail", arg0, arg1)
+	ret0, _ := ret[0].(db.VerifyEmail)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListEntries indicates an expected call of ListEntries.
-func (mr *MockStoreMockRecorder) ListEntries(arg0, arg1 interface{}) *gomock.Call {
+// CreateVerifyEmail indicates an expected call of CreateVerifyEmail.
+func (mr *MockStoreMockRecorder) CreateVerifyEmail(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockStore)(nil).ListEntries), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVerifyEmail", reflect.TypeOf((*MockStore)(nil).CreateVerifyEmail), arg0, arg1)
 }
 
-// ListTransfers mocks base method.
-func (m *MockStore) ListTransfers(arg0 context.Context, arg1 db.ListTransfersParams) ([]db.Transfer, error) {
+// DecideHeldMonitoringAlert mocks base method.
+func (m *MockStore) DecideHeldMonitoringAlert(arg0 context.Context, arg1 db.DecideHeldMonitoringAlertParams) (db.MonitoringAlert, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTransfers", arg0, arg1)
-	ret0, _ := ret[0].([]db.Transfer)
+	ret := m.ctrl.Call(m, "DecideHeldMonitoringAlert", arg0, arg1)
+	ret0, _ := ret[0].(db.MonitoringAlert)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListTransfers indicates an expected call of ListTransfers.
-func (mr *MockStoreMockRecorder) ListTransfers(arg0, arg1 interface{}) *gomock.Call {
+// DecideHeldMonitoringAlert indicates an expected call of DecideHeldMonitoringAlert.
+func (mr *MockStoreMockRecorder) DecideHeldMonitoringAlert(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfers", reflect.TypeOf((*MockStore)(nil).ListTransfers), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecideHeldMonitoringAlert", reflect.TypeOf((*MockStore)(nil).DecideHeldMonitoringAlert), arg0, arg1)
 }
 
-// TransferTx mocks base method.
-func (m *MockStore) TransferTx(arg0 context.Context, arg1 db.TransferTxParams) (db.TransferTxResult, error) {
+// DecideTransferApprovalTx mocks base method.
+func (m *MockStore) DecideTransferApprovalTx(arg0 context.Context, arg1 db.DecideTransferApprovalTxParams) (db.DecideTransferApprovalTxResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TransferTx", arg0, arg1)
-	ret0, _ := ret[0].(db.TransferTxResult)
+	ret := m.ctrl.Call(m, "DecideTransferApprovalTx", arg0, arg1)
+	ret0, _ := ret[0].(db.DecideTransferApprovalTxResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// TransferTx indicates an expected call of TransferTx.
-func (mr *MockStoreMockRecorder) TransferTx(arg0, arg1 interface{}) *gomock.Call {
+// DecideTransferApprovalTx indicates an expected call of DecideTransferApprovalTx.
+func (mr *MockStoreMockRecorder) DecideTransferApprovalTx(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferTx", reflect.TypeOf((*MockStore)(nil).TransferTx), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecideTransferApprovalTx", reflect.TypeOf((*MockStore)(nil).DecideTransferApprovalTx), arg0, arg1)
 }
 
-// UpdateEntries mocks base method.
-func (m *MockStore) UpdateEntries(arg0 context.Context, arg1 db.UpdateEntriesParams) (db.Entry, error) {
+// DeleteAccountApprovers mocks base method.
+func (m *MockStore) DeleteAccountApprovers(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateEntries", arg0, arg1)
-	ret0, _ := ret[0].(db.Entry)
+	ret := m.ctrl.Call(m, "DeleteAccountApprovers", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccountApprovers indicates an expected call of DeleteAccountApprovers.
+func (mr *MockStoreMockRecorder) DeleteAccountApprovers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccountApprovers", reflect.TypeOf((*MockStore)(nil).DeleteAccountApprovers), arg0, arg1)
+}
+
+// DeleteApprovalPolicy mocks base method.
+func (m *MockStore) DeleteApprovalPolicy(arg0 context.Context, arg1 int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteApprovalPolicy", arg0, arg1)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdateEntries indicates an expected call of UpdateEntries.
-func (mr *MockStoreMockRecorder) UpdateEntries(arg0, arg1 interface{}) *gomock.Call {
+// DeleteApprovalPolicy indicates an expected call of DeleteApprovalPolicy.
+func (mr *MockStoreMockRecorder) DeleteApprovalPolicy(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEntries", reflect.TypeOf((*MockStore)(nil).UpdateEntries), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteApprovalPolicy", reflect.TypeOf((*MockStore)(nil).DeleteApprovalPolicy), arg0, arg1)
 }
 
-// UpdateTransfer mocks base method.
-func (m *MockStore) UpdateTransfer(arg0 context.Context, arg1 db.UpdateTransferParams) (db.Transfer, error) {
+// DeleteEntries mocks base method.
+func (m *MockStore) DeleteEntries(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTransfer", arg0, arg1)
-	ret0, _ := ret[0].(db.Transfer)
+	ret := m.ctrl.Call(m, "DeleteEntries", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteEntries indicates an expected call of DeleteEntries.
+func (mr *MockStoreMockRecorder) DeleteEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEntries", reflect.TypeOf((*MockStore)(nil).DeleteEntries), arg0, arg1)
+}
+
+// DeleteIdleRateLimitBuckets mocks base method.
+func (m *MockStore) DeleteIdleRateLimitBuckets(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdleRateLimitBuckets", arg0, arg1)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdateTransfer indicates an expected call of UpdateTransfer.
-func (mr *MockStoreMockRecorder) UpdateTransfer(arg0, arg1 interface{}) *gomock.Call {
+// DeleteIdleRateLimitBuckets indicates an expected call of DeleteIdleRateLimitBuckets.
+func (mr *MockStoreMockRecorder) DeleteIdleRateLimitBuckets(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransfer", reflect.TypeOf((*MockStore)(nil).UpdateTransfer), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdleRateLimitBuckets", reflect.TypeOf((*MockStore)(nil).DeleteIdleRateLimitBuckets), arg0, arg1)
+}
+
+// DeleteRecoveryCodes mocks base method.
+func (m *MockStore) DeleteRecoveryCodes(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRecoveryCodes", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRecoveryCodes indicates an expected call of DeleteRecoveryCodes.
+func (mr *MockStoreMockRecorder) DeleteRecoveryCodes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecoveryCodes", reflect.TypeOf((*MockStore)(nil).DeleteRecoveryCodes), arg0, arg1)
+}
+
+// DeleteTransferLimit mocks base method.
+func (m *MockStore) DeleteTransferLimit(arg0 context.Context, arg1 db.DeleteTransferLimitParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTransferLimit", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteTransferLimit indicates an expected call of DeleteTransferLimit.
+func (mr *MockStoreMockRecorder) DeleteTransferLimit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTransferLimit", reflect.TypeOf((*MockStore)(nil).DeleteTransferLimit), arg0, arg1)
+}
+
+// DeleteTransfers mocks base method.
+func (m *MockStore) DeleteTransfers(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTransfers", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTransfers indicates an expected call of DeleteTransfers.
+func (mr *MockStoreMockRecorder) DeleteTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTransfers", reflect.TypeOf((*MockStore)(nil).DeleteTransfers), arg0, arg1)
+}
+
+// DeleteUserTx mocks base method.
+func (m *MockStore) DeleteUserTx(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUserTx indicates an expected call of DeleteUserTx.
+func (mr *MockStoreMockRecorder) DeleteUserTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserTx", reflect.TypeOf((*MockStore)(nil).DeleteUserTx), arg0, arg1)
+}
+
+// DeleteVerifyEmails mocks base method.
+func (m *MockStore) DeleteVerifyEmails(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteVerifyEmails", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteVerifyEmails indicates an expected call of DeleteVerifyEmails.
+func (mr *MockStoreMockRecorder) DeleteVerifyEmails(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteVerifyEmails", reflect.TypeOf((*MockStore)(nil).DeleteVerifyEmails), arg0, arg1)
+}
+
+// EnableTOTP mocks base method.
+func (m *MockStore) EnableTOTP(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTP", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockStoreMockRecorder) EnableTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockStore)(nil).EnableTOTP), arg0, arg1)
+}
+
+// EnableTOTPTx mocks base method.
+func (m *MockStore) EnableTOTPTx(arg0 context.Context, arg1 db.EnableTOTPTxParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTPTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnableTOTPTx indicates an expected call of EnableTOTPTx.
+func (mr *MockStoreMockRecorder) EnableTOTPTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTPTx", reflect.TypeOf((*MockStore)(nil).EnableTOTPTx), arg0, arg1)
+}
+
+// EraseUser mocks base method.
+func (m *MockStore) EraseUser(arg0 context.Context, arg1 db.EraseUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EraseUser indicates an expected call of EraseUser.
+func (mr *MockStoreMockRecorder) EraseUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseUser", reflect.TypeOf((*MockStore)(nil).EraseUser), arg0, arg1)
+}
+
+// EraseUserTx mocks base method.
+func (m *MockStore) EraseUserTx(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseUserTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EraseUserTx indicates an expected call of EraseUserTx.
+func (mr *MockStoreMockRecorder) EraseUserTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseUserTx", reflect.TypeOf((*MockStore)(nil).EraseUserTx), arg0, arg1)
+}
+
+// ExecTx mocks base method.
+func (m *MockStore) ExecTx(arg0 context.Context, arg1 func(db.Store) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExecTx indicates an expected call of ExecTx.
+func (mr *MockStoreMockRecorder) ExecTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecTx", reflect.TypeOf((*MockStore)(nil).ExecTx), arg0, arg1)
+}
+
+// ExecuteTransferApproval mocks base method.
+func (m *MockStore) ExecuteTransferApproval(arg0 context.Context, arg1 int64) (db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecuteTransferApproval", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecuteTransferApproval indicates an expected call of ExecuteTransferApproval.
+func (mr *MockStoreMockRecorder) ExecuteTransferApproval(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteTransferApproval", reflect.TypeOf((*MockStore)(nil).ExecuteTransferApproval), arg0, arg1)
+}
+
+// ExpireTransferApprovals mocks base method.
+func (m *MockStore) ExpireTransferApprovals(arg0 context.Context) ([]db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireTransferApprovals", arg0)
+	ret0, _ := ret[0].([]db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireTransferApprovals indicates an expected call of ExpireTransferApprovals.
+func (mr *MockStoreMockRecorder) ExpireTransferApprovals(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireTransferApprovals", reflect.TypeOf((*MockStore)(nil).ExpireTransferApprovals), arg0)
+}
+
+// ExpireTransferApprovalsTx mocks base method.
+func (m *MockStore) ExpireTransferApprovalsTx(arg0 context.Context) ([]db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireTransferApprovalsTx", arg0)
+	ret0, _ := ret[0].([]db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireTransferApprovalsTx indicates an expected call of ExpireTransferApprovalsTx.
+func (mr *MockStoreMockRecorder) ExpireTransferApprovalsTx(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireTransferApprovalsTx", reflect.TypeOf((*MockStore)(nil).ExpireTransferApprovalsTx), arg0)
+}
+
+// ExportUserData mocks base method.
+func (m *MockStore) ExportUserData(arg0 context.Context, arg1 string) (db.UserData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUserData", arg0, arg1)
+	ret0, _ := ret[0].(db.UserData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportUserData indicates an expected call of ExportUserData.
+func (mr *MockStoreMockRecorder) ExportUserData(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUserData", reflect.TypeOf((*MockStore)(nil).ExportUserData), arg0, arg1)
+}
+
+// FailTransferApproval mocks base method.
+func (m *MockStore) FailTransferApproval(arg0 context.Context, arg1 db.FailTransferApprovalParams) (db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailTransferApproval", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FailTransferApproval indicates an expected call of FailTransferApproval.
+func (mr *MockStoreMockRecorder) FailTransferApproval(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailTransferApproval", reflect.TypeOf((*MockStore)(nil).FailTransferApproval), arg0, arg1)
+}
+
+// FailTransferApprovalTx mocks base method.
+func (m *MockStore) FailTransferApprovalTx(arg0 context.Context, arg1 db.FailTransferApprovalTxParams) (db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailTransferApprovalTx", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FailTransferApprovalTx indicates an expected call of FailTransferApprovalTx.
+func (mr *MockStoreMockRecorder) FailTransferApprovalTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailTransferApprovalTx", reflect.TypeOf((*MockStore)(nil).FailTransferApprovalTx), arg0, arg1)
+}
+
+// GetAccount mocks base method.
+func (m *MockStore) GetAccount(arg0 context.Context, arg1 int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccount", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccount indicates an expected call of GetAccount.
+func (mr *MockStoreMockRecorder) GetAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockStore)(nil).GetAccount), arg0, arg1)
+}
+
+// GetAccountForUpdate mocks base method.
+func (m *MockStore) GetAccountForUpdate(arg0 context.Context, arg1 int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
+func (mr *MockStoreMockRecorder) GetAccountForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockStore)(nil).GetAccountForUpdate), arg0, arg1)
+}
+
+// GetAccountTransferTotals mocks base method.
+func (m *MockStore) GetAccountTransferTotals(arg0 context.Context, arg1 db.GetAccountTransferTotalsParams) (db.GetAccountTransferTotalsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountTransferTotals", arg0, arg1)
+	ret0, _ := ret[0].(db.GetAccountTransferTotalsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountTransferTotals indicates an expected call of GetAccountTransferTotals.
+func (mr *MockStoreMockRecorder) GetAccountTransferTotals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountTransferTotals", reflect.TypeOf((*MockStore)(nil).GetAccountTransferTotals), arg0, arg1)
+}
+
+// GetApprovalPolicy mocks base method.
+func (m *MockStore) GetApprovalPolicy(arg0 context.Context, arg1 int64) (db.ApprovalPolicy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApprovalPolicy", arg0, arg1)
+	ret0, _ := ret[0].(db.ApprovalPolicy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApprovalPolicy indicates an expected call of GetApprovalPolicy.
+func (mr *MockStoreMockRecorder) GetApprovalPolicy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApprovalPolicy", reflect.TypeOf((*MockStore)(nil).GetApprovalPolicy), arg0, arg1)
+}
+
+// GetEntries mocks base method.
+func (m *MockStore) GetEntries(arg0 context.Context, arg1 int64) (db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEntries", arg0, arg1)
+	ret0, _ := ret[0].(db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEntries indicates an expected call of GetEntries.
+func (mr *MockStoreMockRecorder) GetEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntries", reflect.TypeOf((*MockStore)(nil).GetEntries), arg0, arg1)
+}
+
+// GetKYCProfile mocks base method.
+func (m *MockStore) GetKYCProfile(arg0 context.Context, arg1 string) (db.KycProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKYCProfile", arg0, arg1)
+	ret0, _ := ret[0].(db.KycProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKYCProfile indicates an expected call of GetKYCProfile.
+func (mr *MockStoreMockRecorder) GetKYCProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKYCProfile", reflect.TypeOf((*MockStore)(nil).GetKYCProfile), arg0, arg1)
+}
+
+// GetKYCTier mocks base method.
+func (m *MockStore) GetKYCTier(arg0 context.Context, arg1 int32) (db.KycTier, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKYCTier", arg0, arg1)
+	ret0, _ := ret[0].(db.KycTier)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKYCTier indicates an expected call of GetKYCTier.
+func (mr *MockStoreMockRecorder) GetKYCTier(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKYCTier", reflect.TypeOf((*MockStore)(nil).GetKYCTier), arg0, arg1)
+}
+
+// GetKYCTierByAccount mocks base method.
+func (m *MockStore) GetKYCTierByAccount(arg0 context.Context, arg1 int64) (db.KycTier, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKYCTierByAccount", arg0, arg1)
+	ret0, _ := ret[0].(db.KycTier)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKYCTierByAccount indicates an expected call of GetKYCTierByAccount.
+func (mr *MockStoreMockRecorder) GetKYCTierByAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKYCTierByAccount", reflect.TypeOf((*MockStore)(nil).GetKYCTierByAccount), arg0, arg1)
+}
+
+// GetLastAuditEvent mocks base method.
+func (m *MockStore) GetLastAuditEvent(arg0 context.Context) (db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastAuditEvent", arg0)
+	ret0, _ := ret[0].(db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastAuditEvent indicates an expected call of GetLastAuditEvent.
+func (mr *MockStoreMockRecorder) GetLastAuditEvent(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastAuditEvent", reflect.TypeOf((*MockStore)(nil).GetLastAuditEvent), arg0)
+}
+
+// GetMonitoringAlert mocks base method.
+func (m *MockStore) GetMonitoringAlert(arg0 context.Context, arg1 int64) (db.MonitoringAlert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMonitoringAlert", arg0, arg1)
+	ret0, _ := ret[0].(db.MonitoringAlert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMonitoringAlert indicates an expected call of GetMonitoringAlert.
+func (mr *MockStoreMockRecorder) GetMonitoringAlert(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMonitoringAlert", reflect.TypeOf((*MockStore)(nil).GetMonitoringAlert), arg0, arg1)
+}
+
+// GetMonitoringAlertForUpdate mocks base method.
+func (m *MockStore) GetMonitoringAlertForUpdate(arg0 context.Context, arg1 int64) (db.MonitoringAlert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMonitoringAlertForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(db.MonitoringAlert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMonitoringAlertForUpdate indicates an expected call of GetMonitoringAlertForUpdate.
+func (mr *MockStoreMockRecorder) GetMonitoringAlertForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMonitoringAlertForUpdate", reflect.TypeOf((*MockStore)(nil).GetMonitoringAlertForUpdate), arg0, arg1)
+}
+
+// GetScreeningHit mocks base method.
+func (m *MockStore) GetScreeningHit(arg0 context.Context, arg1 int64) (db.ScreeningHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScreeningHit", arg0, arg1)
+	ret0, _ := ret[0].(db.ScreeningHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScreeningHit indicates an expected call of GetScreeningHit.
+func (mr *MockStoreMockRecorder) GetScreeningHit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScreeningHit", reflect.TypeOf((*MockStore)(nil).GetScreeningHit), arg0, arg1)
+}
+
+// GetTransferAllowance mocks base method.
+func (m *MockStore) GetTransferAllowance(arg0 context.Context, arg1 int64) (db.TransferAllowance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferAllowance", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferAllowance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferAllowance indicates an expected call of GetTransferAllowance.
+func (mr *MockStoreMockRecorder) GetTransferAllowance(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferAllowance", reflect.TypeOf((*MockStore)(nil).GetTransferAllowance), arg0, arg1)
+}
+
+// GetTransferApproval mocks base method.
+func (m *MockStore) GetTransferApproval(arg0 context.Context, arg1 int64) (db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferApproval", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferApproval indicates an expected call of GetTransferApproval.
+func (mr *MockStoreMockRecorder) GetTransferApproval(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferApproval", reflect.TypeOf((*MockStore)(nil).GetTransferApproval), arg0, arg1)
+}
+
+// GetTransferApprovalForUpdate mocks base method.
+func (m *MockStore) GetTransferApprovalForUpdate(arg0 context.Context, arg1 int64) (db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferApprovalForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferApprovalForUpdate indicates an expected call of GetTransferApprovalForUpdate.
+func (mr *MockStoreMockRecorder) GetTransferApprovalForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferApprovalForUpdate", reflect.TypeOf((*MockStore)(nil).GetTransferApprovalForUpdate), arg0, arg1)
+}
+
+// GetTransferLimit mocks base method.
+func (m *MockStore) GetTransferLimit(arg0 context.Context, arg1 db.GetTransferLimitParams) (db.TransferLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferLimit", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferLimit indicates an expected call of GetTransferLimit.
+func (mr *MockStoreMockRecorder) GetTransferLimit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferLimit", reflect.TypeOf((*MockStore)(nil).GetTransferLimit), arg0, arg1)
+}
+
+// GetTransferReversal mocks base method.
+func (m *MockStore) GetTransferReversal(arg0 context.Context, arg1 int64) (db.TransferReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferReversal", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferReversal indicates an expected call of GetTransferReversal.
+func (mr *MockStoreMockRecorder) GetTransferReversal(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferReversal", reflect.TypeOf((*MockStore)(nil).GetTransferReversal), arg0, arg1)
+}
+
+// GetTransferReversalByReversalID mocks base method.
+func (m *MockStore) GetTransferReversalByReversalID(arg0 context.Context, arg1 int64) (db.TransferReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferReversalByReversalID", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferReversalByReversalID indicates an expected call of GetTransferReversalByReversalID.
+func (mr *MockStoreMockRecorder) GetTransferReversalByReversalID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferReversalByReversalID", reflect.TypeOf((*MockStore)(nil).GetTransferReversalByReversalID), arg0, arg1)
+}
+
+// GetTransfers mocks base method.
+func (m *MockStore) GetTransfers(arg0 context.Context, arg1 int64) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfers", arg0, arg1)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfers indicates an expected call of GetTransfers.
+func (mr *MockStoreMockRecorder) GetTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockStore)(nil).GetTransfers), arg0, arg1)
+}
+
+// GetUser mocks base method.
+func (m *MockStore) GetUser(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockStoreMockRecorder) GetUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), arg0, arg1)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockStore) GetUserByEmail(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockStoreMockRecorder) GetUserByEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockStore)(nil).GetUserByEmail), arg0, arg1)
+}
+
+// GetUserTransferTotals mocks base method.
+func (m *MockStore) GetUserTransferTotals(arg0 context.Context, arg1 db.GetUserTransferTotalsParams) (db.GetUserTransferTotalsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTransferTotals", arg0, arg1)
+	ret0, _ := ret[0].(db.GetUserTransferTotalsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTransferTotals indicates an expected call of GetUserTransferTotals.
+func (mr *MockStoreMockRecorder) GetUserTransferTotals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTransferTotals", reflect.TypeOf((*MockStore)(nil).GetUserTransferTotals), arg0, arg1)
+}
+
+// IsAccountApprover mocks base method.
+func (m *MockStore) IsAccountApprover(arg0 context.Context, arg1 db.IsAccountApproverParams) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsAccountApprover", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsAccountApprover indicates an expected call of IsAccountApprover.
+func (mr *MockStoreMockRecorder) IsAccountApprover(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAccountApprover", reflect.TypeOf((*MockStore)(nil).IsAccountApprover), arg0, arg1)
+}
+
+// ListAccountApprovers mocks base method.
+func (m *MockStore) ListAccountApprovers(arg0 context.Context, arg1 int64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountApprovers", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountApprovers indicates an expected call of ListAccountApprovers.
+func (mr *MockStoreMockRecorder) ListAccountApprovers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountApprovers", reflect.TypeOf((*MockStore)(nil).ListAccountApprovers), arg0, arg1)
+}
+
+// ListAccounts mocks base method.
+func (m *MockStore) ListAccounts(arg0 context.Context, arg1 db.ListAccountsParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccounts", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccounts indicates an expected call of ListAccounts.
+func (mr *MockStoreMockRecorder) ListAccounts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockStore)(nil).ListAccounts), arg0, arg1)
+}
+
+// ListAccountsByOwner mocks base method.
+func (m *MockStore) ListAccountsByOwner(arg0 context.Context, arg1 string) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountsByOwner", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountsByOwner indicates an expected call of ListAccountsByOwner.
+func (mr *MockStoreMockRecorder) ListAccountsByOwner(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountsByOwner", reflect.TypeOf((*MockStore)(nil).ListAccountsByOwner), arg0, arg1)
+}
+
+// ListAuditEvents mocks base method.
+func (m *MockStore) ListAuditEvents(arg0 context.Context, arg1 db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEvents", arg0, arg1)
+	ret0, _ := ret[0].([]db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEvents indicates an expected call of ListAuditEvents.
+func (mr *MockStoreMockRecorder) ListAuditEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEvents", reflect.TypeOf((*MockStore)(nil).ListAuditEvents), arg0, arg1)
+}
+
+// ListAuditEventsAfter mocks base method.
+func (m *MockStore) ListAuditEventsAfter(arg0 context.Context, arg1 db.ListAuditEventsAfterParams) ([]db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEventsAfter", arg0, arg1)
+	ret0, _ := ret[0].([]db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEventsAfter indicates an expected call of ListAuditEventsAfter.
+func (mr *MockStoreMockRecorder) ListAuditEventsAfter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEventsAfter", reflect.TypeOf((*MockStore)(nil).ListAuditEventsAfter), arg0, arg1)
+}
+
+// ListAuditEventsByUser mocks base method.
+func (m *MockStore) ListAuditEventsByUser(arg0 context.Context, arg1 string) ([]db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEventsByUser", arg0, arg1)
+	ret0, _ := ret[0].([]db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEventsByUser indicates an expected call of ListAuditEventsByUser.
+func (mr *MockStoreMockRecorder) ListAuditEventsByUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEventsByUser", reflect.TypeOf((*MockStore)(nil).ListAuditEventsByUser), arg0, arg1)
+}
+
+// ListEntries mocks base method.
+func (m *MockStore) ListEntries(arg0 context.Context, arg1 db.ListEntriesParams) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntries", arg0, arg1)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntries indicates an expected call of ListEntries.
+func (mr *MockStoreMockRecorder) ListEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockStore)(nil).ListEntries), arg0, arg1)
+}
+
+// ListEntriesByAccount mocks base method.
+func (m *MockStore) ListEntriesByAccount(arg0 context.Context, arg1 db.ListEntriesByAccountParams) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntriesByAccount", arg0, arg1)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntriesByAccount indicates an expected call of ListEntriesByAccount.
+func (mr *MockStoreMockRecorder) ListEntriesByAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntriesByAccount", reflect.TypeOf((*MockStore)(nil).ListEntriesByAccount), arg0, arg1)
+}
+
+// ListEntriesByOwner mocks base method.
+func (m *MockStore) ListEntriesByOwner(arg0 context.Context, arg1 string) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntriesByOwner", arg0, arg1)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntriesByOwner indicates an expected call of ListEntriesByOwner.
+func (mr *MockStoreMockRecorder) ListEntriesByOwner(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntriesByOwner", reflect.TypeOf((*MockStore)(nil).ListEntriesByOwner), arg0, arg1)
+}
+
+// ListKYCProfiles mocks base method.
+func (m *MockStore) ListKYCProfiles(arg0 context.Context, arg1 db.ListKYCProfilesParams) ([]db.KycProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListKYCProfiles", arg0, arg1)
+	ret0, _ := ret[0].([]db.KycProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListKYCProfiles indicates an expected call of ListKYCProfiles.
+func (mr *MockStoreMockRecorder) ListKYCProfiles(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListKYCProfiles", reflect.TypeOf((*MockStore)(nil).ListKYCProfiles), arg0, arg1)
+}
+
+// ListKYCProfilesAfter mocks base method.
+func (m *MockStore) ListKYCProfilesAfter(arg0 context.Context, arg1 db.ListKYCProfilesAfterParams) ([]db.KycProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListKYCProfilesAfter", arg0, arg1)
+	ret0, _ := ret[0].([]db.KycProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListKYCProfilesAfter indicates an expected call of ListKYCProfilesAfter.
+func (mr *MockStoreMockRecorder) ListKYCProfilesAfter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListKYCProfilesAfter", reflect.TypeOf((*MockStore)(nil).ListKYCProfilesAfter), arg0, arg1)
+}
+
+// ListKYCTiers mocks base method.
+func (m *MockStore) ListKYCTiers(arg0 context.Context) ([]db.KycTier, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListKYCTiers", arg0)
+	ret0, _ := ret[0].([]db.KycTier)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListKYCTiers indicates an expected call of ListKYCTiers.
+func (mr *MockStoreMockRecorder) ListKYCTiers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListKYCTiers", reflect.TypeOf((*MockStore)(nil).ListKYCTiers), arg0)
+}
+
+// ListMonitoringAlerts mocks base method.
+func (m *MockStore) ListMonitoringAlerts(arg0 context.Context, arg1 db.ListMonitoringAlertsParams) ([]db.MonitoringAlert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMonitoringAlerts", arg0, arg1)
+	ret0, _ := ret[0].([]db.MonitoringAlert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMonitoringAlerts indicates an expected call of ListMonitoringAlerts.
+func (mr *MockStoreMockRecorder) ListMonitoringAlerts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMonitoringAlerts", reflect.TypeOf((*MockStore)(nil).ListMonitoringAlerts), arg0, arg1)
+}
+
+// ListOwnerAccounts mocks base method.
+func (m *MockStore) ListOwnerAccounts(arg0 context.Context, arg1 db.ListOwnerAccountsParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOwnerAccounts", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOwnerAccounts indicates an expected call of ListOwnerAccounts.
+func (mr *MockStoreMockRecorder) ListOwnerAccounts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOwnerAccounts", reflect.TypeOf((*MockStore)(nil).ListOwnerAccounts), arg0, arg1)
+}
+
+// ListOwnerEntries mocks base method.
+func (m *MockStore) ListOwnerEntries(arg0 context.Context, arg1 db.ListOwnerEntriesParams) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOwnerEntries", arg0, arg1)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOwnerEntries indicates an expected call of ListOwnerEntries.
+func (mr *MockStoreMockRecorder) ListOwnerEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOwnerEntries", reflect.TypeOf((*MockStore)(nil).ListOwnerEntries), arg0, arg1)
+}
+
+// ListOwnerTransfers mocks base method.
+func (m *MockStore) ListOwnerTransfers(arg0 context.Context, arg1 db.ListOwnerTransfersParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOwnerTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOwnerTransfers indicates an expected call of ListOwnerTransfers.
+func (mr *MockStoreMockRecorder) ListOwnerTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOwnerTransfers", reflect.TypeOf((*MockStore)(nil).ListOwnerTransfers), arg0, arg1)
+}
+
+// ListScreeningHits mocks base method.
+func (m *MockStore) ListScreeningHits(arg0 context.Context, arg1 db.ListScreeningHitsParams) ([]db.ScreeningHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScreeningHits", arg0, arg1)
+	ret0, _ := ret[0].([]db.ScreeningHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScreeningHits indicates an expected call of ListScreeningHits.
+func (mr *MockStoreMockRecorder) ListScreeningHits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScreeningHits", reflect.TypeOf((*MockStore)(nil).ListScreeningHits), arg0, arg1)
+}
+
+// ListScreeningHitsAfter mocks base method.
+func (m *MockStore) ListScreeningHitsAfter(arg0 context.Context, arg1 db.ListScreeningHitsAfterParams) ([]db.ScreeningHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScreeningHitsAfter", arg0, arg1)
+	ret0, _ := ret[0].([]db.ScreeningHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScreeningHitsAfter indicates an expected call of ListScreeningHitsAfter.
+func (mr *MockStoreMockRecorder) ListScreeningHitsAfter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScreeningHitsAfter", reflect.TypeOf((*MockStore)(nil).ListScreeningHitsAfter), arg0, arg1)
+}
+
+// ListTransferApprovalDecisions mocks base method.
+func (m *MockStore) ListTransferApprovalDecisions(arg0 context.Context, arg1 int64) ([]db.TransferApprovalDecision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferApprovalDecisions", arg0, arg1)
+	ret0, _ := ret[0].([]db.TransferApprovalDecision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferApprovalDecisions indicates an expected call of ListTransferApprovalDecisions.
+func (mr *MockStoreMockRecorder) ListTransferApprovalDecisions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferApprovalDecisions", reflect.TypeOf((*MockStore)(nil).ListTransferApprovalDecisions), arg0, arg1)
+}
+
+// ListTransferApprovals mocks base method.
+func (m *MockStore) ListTransferApprovals(arg0 context.Context, arg1 db.ListTransferApprovalsParams) ([]db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferApprovals", arg0, arg1)
+	ret0, _ := ret[0].([]db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferApprovals indicates an expected call of ListTransferApprovals.
+func (mr *MockStoreMockRecorder) ListTransferApprovals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferApprovals", reflect.TypeOf((*MockStore)(nil).ListTransferApprovals), arg0, arg1)
+}
+
+// ListTransferEvents mocks base method.
+func (m *MockStore) ListTransferEvents(arg0 context.Context, arg1 int64) ([]db.TransferEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferEvents", arg0, arg1)
+	ret0, _ := ret[0].([]db.TransferEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferEvents indicates an expected call of ListTransferEvents.
+func (mr *MockStoreMockRecorder) ListTransferEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferEvents", reflect.TypeOf((*MockStore)(nil).ListTransferEvents), arg0, arg1)
+}
+
+// ListTransferLimits mocks base method.
+func (m *MockStore) ListTransferLimits(arg0 context.Context) ([]db.TransferLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferLimits", arg0)
+	ret0, _ := ret[0].([]db.TransferLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferLimits indicates an expected call of ListTransferLimits.
+func (mr *MockStoreMockRecorder) ListTransferLimits(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferLimits", reflect.TypeOf((*MockStore)(nil).ListTransferLimits), arg0)
+}
+
+// ListTransferLimitsFor mocks base method.
+func (m *MockStore) ListTransferLimitsFor(arg0 context.Context, arg1 db.ListTransferLimitsForParams) ([]db.TransferLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferLimitsFor", arg0, arg1)
+	ret0, _ := ret[0].([]db.TransferLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferLimitsFor indicates an expected call of ListTransferLimitsFor.
+func (mr *MockStoreMockRecorder) ListTransferLimitsFor(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferLimitsFor", reflect.TypeOf((*MockStore)(nil).ListTransferLimitsFor), arg0, arg1)
+}
+
+// ListTransferMonitoringAlerts mocks base method.
+func (m *MockStore) ListTransferMonitoringAlerts(arg0 context.Context, arg1 sql.NullInt64) ([]db.MonitoringAlert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferMonitoringAlerts", arg0, arg1)
+	ret0, _ := ret[0].([]db.MonitoringAlert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferMonitoringAlerts indicates an expected call of ListTransferMonitoringAlerts.
+func (mr *MockStoreMockRecorder) ListTransferMonitoringAlerts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferMonitoringAlerts", reflect.TypeOf((*MockStore)(nil).ListTransferMonitoringAlerts), arg0, arg1)
+}
+
+// ListTransfers mocks base method.
+func (m *MockStore) ListTransfers(arg0 context.Context, arg1 db.ListTransfersParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransfers indicates an expected call of ListTransfers.
+func (mr *MockStoreMockRecorder) ListTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfers", reflect.TypeOf((*MockStore)(nil).ListTransfers), arg0, arg1)
+}
+
+// ListTransfersByOwner mocks base method.
+func (m *MockStore) ListTransfersByOwner(arg0 context.Context, arg1 string) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransfersByOwner", arg0, arg1)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransfersByOwner indicates an expected call of ListTransfersByOwner.
+func (mr *MockStoreMockRecorder) ListTransfersByOwner(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfersByOwner", reflect.TypeOf((*MockStore)(nil).ListTransfersByOwner), arg0, arg1)
+}
+
+// ListUnbalancedAccounts mocks base method.
+func (m *MockStore) ListUnbalancedAccounts(arg0 context.Context) ([]db.ListUnbalancedAccountsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnbalancedAccounts", arg0)
+	ret0, _ := ret[0].([]db.ListUnbalancedAccountsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnbalancedAccounts indicates an expected call of ListUnbalancedAccounts.
+func (mr *MockStoreMockRecorder) ListUnbalancedAccounts(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnbalancedAccounts", reflect.TypeOf((*MockStore)(nil).ListUnbalancedAccounts), arg0)
+}
+
+// ListUsers mocks base method.
+func (m *MockStore) ListUsers(arg0 context.Context, arg1 db.ListUsersParams) ([]db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", arg0, arg1)
+	ret0, _ := ret[0].([]db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockStoreMockRecorder) ListUsers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockStore)(nil).ListUsers), arg0, arg1)
+}
+
+// ListVerifyEmails mocks base method.
+func (m *MockStore) ListVerifyEmails(arg0 context.Context, arg1 string) ([]db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVerifyEmails", arg0, arg1)
+	ret0, _ := ret[0].([]db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVerifyEmails indicates an expected call of ListVerifyEmails.
+func (mr *MockStoreMockRecorder) ListVerifyEmails(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVerifyEmails", reflect.TypeOf((*MockStore)(nil).ListVerifyEmails), arg0, arg1)
+}
+
+// ListVerifyEmailsAfter mocks base method.
+func (m *MockStore) ListVerifyEmailsAfter(arg0 context.Context, arg1 db.ListVerifyEmailsAfterParams) ([]db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVerifyEmailsAfter", arg0, arg1)
+	ret0, _ := ret[0].([]db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVerifyEmailsAfter indicates an expected call of ListVerifyEmailsAfter.
+func (mr *MockStoreMockRecorder) ListVerifyEmailsAfter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVerifyEmailsAfter", reflect.TypeOf((*MockStore)(nil).ListVerifyEmailsAfter), arg0, arg1)
+}
+
+// LockAuditEvents mocks base method.
+func (m *MockStore) LockAuditEvents(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockAuditEvents", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockAuditEvents indicates an expected call of LockAuditEvents.
+func (mr *MockStoreMockRecorder) LockAuditEvents(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockAuditEvents", reflect.TypeOf((*MockStore)(nil).LockAuditEvents), arg0)
+}
+
+// LockUserTransfers mocks base method.
+func (m *MockStore) LockUserTransfers(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockUserTransfers", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockUserTransfers indicates an expected call of LockUserTransfers.
+func (mr *MockStoreMockRecorder) LockUserTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockUserTransfers", reflect.TypeOf((*MockStore)(nil).LockUserTransfers), arg0, arg1)
+}
+
+// RecordFailedLogin mocks base method.
+func (m *MockStore) RecordFailedLogin(arg0 context.Context, arg1 db.RecordFailedLoginParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordFailedLogin", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordFailedLogin indicates an expected call of RecordFailedLogin.
+func (mr *MockStoreMockRecorder) RecordFailedLogin(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordFailedLogin", reflect.TypeOf((*MockStore)(nil).RecordFailedLogin), arg0, arg1)
+}
+
+// RecordScreeningHit mocks base method.
+func (m *MockStore) RecordScreeningHit(arg0 context.Context, arg1 db.RecordScreeningHitParams) (db.ScreeningHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordScreeningHit", arg0, arg1)
+	ret0, _ := ret[0].(db.ScreeningHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordScreeningHit indicates an expected call of RecordScreeningHit.
+func (mr *MockStoreMockRecorder) RecordScreeningHit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordScreeningHit", reflect.TypeOf((*MockStore)(nil).RecordScreeningHit), arg0, arg1)
+}
+
+// ReencryptPII mocks base method.
+func (m *MockStore) ReencryptPII(arg0 context.Context) (db.ReencryptPIIResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReencryptPII", arg0)
+	ret0, _ := ret[0].(db.ReencryptPIIResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReencryptPII indicates an expected call of ReencryptPII.
+func (mr *MockStoreMockRecorder) ReencryptPII(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReencryptPII", reflect.TypeOf((*MockStore)(nil).ReencryptPII), arg0)
+}
+
+// RejectHeldTransferTx mocks base method.
+func (m *MockStore) RejectHeldTransferTx(arg0 context.Context, arg1 db.RejectHeldTransferTxParams) (db.MonitoringAlert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectHeldTransferTx", arg0, arg1)
+	ret0, _ := ret[0].(db.MonitoringAlert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RejectHeldTransferTx indicates an expected call of RejectHeldTransferTx.
+func (mr *MockStoreMockRecorder) RejectHeldTransferTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectHeldTransferTx", reflect.TypeOf((*MockStore)(nil).RejectHeldTransferTx), arg0, arg1)
+}
+
+// RejectTransferApproval mocks base method.
+func (m *MockStore) RejectTransferApproval(arg0 context.Context, arg1 int64) (db.TransferApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectTransferApproval", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RejectTransferApproval indicates an expected call of RejectTransferApproval.
+func (mr *MockStoreMockRecorder) RejectTransferApproval(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectTransferApproval", reflect.TypeOf((*MockStore)(nil).RejectTransferApproval), arg0, arg1)
+}
+
+// ReleaseHeldTransferTx mocks base method.
+func (m *MockStore) ReleaseHeldTransferTx(arg0 context.Context, arg1 db.ReleaseHeldTransferTxParams) (db.ReleaseHeldTransferTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseHeldTransferTx", arg0, arg1)
+	ret0, _ := ret[0].(db.ReleaseHeldTransferTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReleaseHeldTransferTx indicates an expected call of ReleaseHeldTransferTx.
+func (mr *MockStoreMockRecorder) ReleaseHeldTransferTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseHeldTransferTx", reflect.TypeOf((*MockStore)(nil).ReleaseHeldTransferTx), arg0, arg1)
+}
+
+// ResetFailedLogins mocks base method.
+func (m *MockStore) ResetFailedLogins(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetFailedLogins", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetFailedLogins indicates an expected call of ResetFailedLogins.
+func (mr *MockStoreMockRecorder) ResetFailedLogins(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetFailedLogins", reflect.TypeOf((*MockStore)(nil).ResetFailedLogins), arg0, arg1)
+}
+
+// ResetPasswordTx mocks base method.
+func (m *MockStore) ResetPasswordTx(arg0 context.Context, arg1 db.ResetPasswordTxParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPasswordTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPasswordTx indicates an expected call of ResetPasswordTx.
+func (mr *MockStoreMockRecorder) ResetPasswordTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPasswordTx", reflect.TypeOf((*MockStore)(nil).ResetPasswordTx), arg0, arg1)
+}
+
+// ReverseTransferTx mocks base method.
+func (m *MockStore) ReverseTransferTx(arg0 context.Context, arg1 int64) (db.TransferTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransferTx", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseTransferTx indicates an expected call of ReverseTransferTx.
+func (mr *MockStoreMockRecorder) ReverseTransferTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransferTx", reflect.TypeOf((*MockStore)(nil).ReverseTransferTx), arg0, arg1)
+}
+
+// ReviewKYCProfile mocks base method.
+func (m *MockStore) ReviewKYCProfile(arg0 context.Context, arg1 db.ReviewKYCProfileParams) (db.KycProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewKYCProfile", arg0, arg1)
+	ret0, _ := ret[0].(db.KycProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviewKYCProfile indicates an expected call of ReviewKYCProfile.
+func (mr *MockStoreMockRecorder) ReviewKYCProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewKYCProfile", reflect.TypeOf((*MockStore)(nil).ReviewKYCProfile), arg0, arg1)
+}
+
+// ReviewScreeningHit mocks base method.
+func (m *MockStore) ReviewScreeningHit(arg0 context.Context, arg1 db.ReviewScreeningHitParams) (db.ScreeningHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewScreeningHit", arg0, arg1)
+	ret0, _ := ret[0].(db.ScreeningHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviewScreeningHit indicates an expected call of ReviewScreeningHit.
+func (mr *MockStoreMockRecorder) ReviewScreeningHit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewScreeningHit", reflect.TypeOf((*MockStore)(nil).ReviewScreeningHit), arg0, arg1)
+}
+
+// SetApprovalPolicyTx mocks base method.
+func (m *MockStore) SetApprovalPolicyTx(arg0 context.Context, arg1 db.SetApprovalPolicyTxParams) (db.SetApprovalPolicyTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetApprovalPolicyTx", arg0, arg1)
+	ret0, _ := ret[0].(db.SetApprovalPolicyTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetApprovalPolicyTx indicates an expected call of SetApprovalPolicyTx.
+func (mr *MockStoreMockRecorder) SetApprovalPolicyTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetApprovalPolicyTx", reflect.TypeOf((*MockStore)(nil).SetApprovalPolicyTx), arg0, arg1)
+}
+
+// SetTOTPSecret mocks base method.
+func (m *MockStore) SetTOTPSecret(arg0 context.Context, arg1 db.SetTOTPSecretParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTOTPSecret", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetTOTPSecret indicates an expected call of SetTOTPSecret.
+func (mr *MockStoreMockRecorder) SetTOTPSecret(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTOTPSecret", reflect.TypeOf((*MockStore)(nil).SetTOTPSecret), arg0, arg1)
+}
+
+// SoftDeleteUser mocks base method.
+func (m *MockStore) SoftDeleteUser(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeleteUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SoftDeleteUser indicates an expected call of SoftDeleteUser.
+func (mr *MockStoreMockRecorder) SoftDeleteUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeleteUser", reflect.TypeOf((*MockStore)(nil).SoftDeleteUser), arg0, arg1)
+}
+
+// SubmitKYCProfile mocks base method.
+func (m *MockStore) SubmitKYCProfile(arg0 context.Context, arg1 db.SubmitKYCProfileParams) (db.KycProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubmitKYCProfile", arg0, arg1)
+	ret0, _ := ret[0].(db.KycProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubmitKYCProfile indicates an expected call of SubmitKYCProfile.
+func (mr *MockStoreMockRecorder) SubmitKYCProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitKYCProfile", reflect.TypeOf((*MockStore)(nil).SubmitKYCProfile), arg0, arg1)
+}
+
+// SumBalancesByCurrency mocks base method.
+func (m *MockStore) SumBalancesByCurrency(arg0 context.Context) ([]db.SumBalancesByCurrencyRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SumBalancesByCurrency", arg0)
+	ret0, _ := ret[0].([]db.SumBalancesByCurrencyRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SumBalancesByCurrency indicates an expected call of SumBalancesByCurrency.
+func (mr *MockStoreMockRecorder) SumBalancesByCurrency(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SumBalancesByCurrency", reflect.TypeOf((*MockStore)(nil).SumBalancesByCurrency), arg0)
+}
+
+// SumTransfersReceivedSince mocks base method.
+func (m *MockStore) SumTransfersReceivedSince(arg0 context.Context, arg1 db.SumTransfersReceivedSinceParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SumTransfersReceivedSince", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SumTransfersReceivedSince indicates an expected call of SumTransfersReceivedSince.
+func (mr *MockStoreMockRecorder) SumTransfersReceivedSince(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SumTransfersReceivedSince", reflect.TypeOf((*MockStore)(nil).SumTransfersReceivedSince), arg0, arg1)
+}
+
+// TakeRateLimitToken mocks base method.
+func (m *MockStore) TakeRateLimitToken(arg0 context.Context, arg1 db.TakeRateLimitTokenParams) (db.TakeRateLimitTokenRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeRateLimitToken", arg0, arg1)
+	ret0, _ := ret[0].(db.TakeRateLimitTokenRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeRateLimitToken indicates an expected call of TakeRateLimitToken.
+func (mr *MockStoreMockRecorder) TakeRateLimitToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeRateLimitToken", reflect.TypeOf((*MockStore)(nil).TakeRateLimitToken), arg0, arg1)
+}
+
+// TransferTx mocks base method.
+func (m *MockStore) TransferTx(arg0 context.Context, arg1 db.TransferTxParams) (db.TransferTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferTx", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TransferTx indicates an expected call of TransferTx.
+func (mr *MockStoreMockRecorder) TransferTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferTx", reflect.TypeOf((*MockStore)(nil).TransferTx), arg0, arg1)
+}
+
+// UpdateAccountStatus mocks base method.
+func (m *MockStore) UpdateAccountStatus(arg0 context.Context, arg1 db.UpdateAccountStatusParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountStatus", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountStatus indicates an expected call of UpdateAccountStatus.
+func (mr *MockStoreMockRecorder) UpdateAccountStatus(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountStatus", reflect.TypeOf((*MockStore)(nil).UpdateAccountStatus), arg0, arg1)
+}
+
+// UpdateEntries mocks base method.
+func (m *MockStore) UpdateEntries(arg0 context.Context, arg1 db.UpdateEntriesParams) (db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEntries", arg0, arg1)
+	ret0, _ := ret[0].(db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateEntries indicates an expected call of UpdateEntries.
+func (mr *MockStoreMockRecorder) UpdateEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEntries", reflect.TypeOf((*MockStore)(nil).UpdateEntries), arg0, arg1)
+}
+
+// UpdateKYCProfilePII mocks base method.
+func (m *MockStore) UpdateKYCProfilePII(arg0 context.Context, arg1 db.UpdateKYCProfilePIIParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateKYCProfilePII", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateKYCProfilePII indicates an expected call of UpdateKYCProfilePII.
+func (mr *MockStoreMockRecorder) UpdateKYCProfilePII(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKYCProfilePII", reflect.TypeOf((*MockStore)(nil).UpdateKYCProfilePII), arg0, arg1)
+}
+
+// UpdateScreeningHitPII mocks base method.
+func (m *MockStore) UpdateScreeningHitPII(arg0 context.Context, arg1 db.UpdateScreeningHitPIIParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateScreeningHitPII", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateScreeningHitPII indicates an expected call of UpdateScreeningHitPII.
+func (mr *MockStoreMockRecorder) UpdateScreeningHitPII(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateScreeningHitPII", reflect.TypeOf((*MockStore)(nil).UpdateScreeningHitPII), arg0, arg1)
+}
+
+// UpdateTransfer mocks base method.
+func (m *MockStore) UpdateTransfer(arg0 context.Context, arg1 db.UpdateTransferParams) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTransfer", arg0, arg1)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTransfer indicates an expected call of UpdateTransfer.
+func (mr *MockStoreMockRecorder) UpdateTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransfer", reflect.TypeOf((*MockStore)(nil).UpdateTransfer), arg0, arg1)
+}
+
+// UpdateTransferStatus mocks base method.
+func (m *MockStore) UpdateTransferStatus(arg0 context.Context, arg1 db.UpdateTransferStatusParams) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTransferStatus", arg0, arg1)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTransferStatus indicates an expected call of UpdateTransferStatus.
+func (mr *MockStoreMockRecorder) UpdateTransferStatus(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransferStatus", reflect.TypeOf((*MockStore)(nil).UpdateTransferStatus), arg0, arg1)
+}
+
+// UpdateUser mocks base method.
+func (m *MockStore) UpdateUser(arg0 context.Context, arg1 db.UpdateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockStoreMockRecorder) UpdateUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStore)(nil).UpdateUser), arg0, arg1)
+}
+
+// UpdateUserPII mocks base method.
+func (m *MockStore) UpdateUserPII(arg0 context.Context, arg1 db.UpdateUserPIIParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPII", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserPII indicates an expected call of UpdateUserPII.
+func (mr *MockStoreMockRecorder) UpdateUserPII(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPII", reflect.TypeOf((*MockStore)(nil).UpdateUserPII), arg0, arg1)
+}
+
+// UpdateUserRole mocks base method.
+func (m *MockStore) UpdateUserRole(arg0 context.Context, arg1 db.UpdateUserRoleParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRole", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserRole indicates an expected call of UpdateUserRole.
+func (mr *MockStoreMockRecorder) UpdateUserRole(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRole", reflect.TypeOf((*MockStore)(nil).UpdateUserRole), arg0, arg1)
+}
+
+// UpdateVerifyEmailAddress mocks base method.
+func (m *MockStore) UpdateVerifyEmailAddress(arg0 context.Context, arg1 db.UpdateVerifyEmailAddressParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVerifyEmailAddress", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateVerifyEmailAddress indicates an expected call of UpdateVerifyEmailAddress.
+func (mr *MockStoreMockRecorder) UpdateVerifyEmailAddress(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVerifyEmailAddress", reflect.TypeOf((*MockStore)(nil).UpdateVerifyEmailAddress), arg0, arg1)
+}
+
+// UpsertApprovalPolicy mocks base method.
+func (m *MockStore) UpsertApprovalPolicy(arg0 context.Context, arg1 db.UpsertApprovalPolicyParams) (db.ApprovalPolicy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertApprovalPolicy", arg0, arg1)
+	ret0, _ := ret[0].(db.ApprovalPolicy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertApprovalPolicy indicates an expected call of UpsertApprovalPolicy.
+func (mr *MockStoreMockRecorder) UpsertApprovalPolicy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertApprovalPolicy", reflect.TypeOf((*MockStore)(nil).UpsertApprovalPolicy), arg0, arg1)
+}
+
+// UpsertTransferLimit mocks base method.
+func (m *MockStore) UpsertTransferLimit(arg0 context.Context, arg1 db.UpsertTransferLimitParams) (db.TransferLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertTransferLimit", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertTransferLimit indicates an expected call of UpsertTransferLimit.
+func (mr *MockStoreMockRecorder) UpsertTransferLimit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertTransferLimit", reflect.TypeOf((*MockStore)(nil).UpsertTransferLimit), arg0, arg1)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockStore) UseRecoveryCode(arg0 context.Context, arg1 db.UseRecoveryCodeParams) (db.RecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", arg0, arg1)
+	ret0, _ := ret[0].(db.RecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockStoreMockRecorder) UseRecoveryCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockStore)(nil).UseRecoveryCode), arg0, arg1)
+}
+
+// UseVerifyEmail mocks base method.
+func (m *MockStore) UseVerifyEmail(arg0 context.Context, arg1 db.UseVerifyEmailParams) (db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseVerifyEmail", arg0, arg1)
+	ret0, _ := ret[0].(db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseVerifyEmail indicates an expected call of UseVerifyEmail.
+func (mr *MockStoreMockRecorder) UseVerifyEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseVerifyEmail", reflect.TypeOf((*MockStore)(nil).UseVerifyEmail), arg0, arg1)
+}
+
+// VerifyAuditChain mocks base method.
+func (m *MockStore) VerifyAuditChain(arg0 context.Context) (db.AuditChainStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAuditChain", arg0)
+	ret0, _ := ret[0].(db.AuditChainStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAuditChain indicates an expected call of VerifyAuditChain.
+func (mr *MockStoreMockRecorder) VerifyAuditChain(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAuditChain", reflect.TypeOf((*MockStore)(nil).VerifyAuditChain), arg0)
+}
+
+// VerifyEmailTx mocks base method.
+func (m *MockStore) VerifyEmailTx(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmailTx indicates an expected call of VerifyEmailTx.
+func (mr *MockStoreMockRecorder) VerifyEmailTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailTx", reflect.TypeOf((*MockStore)(nil).VerifyEmailTx), arg0, arg1)
+}
+
+// VerifyUserEmail mocks base method.
+func (m *MockStore) VerifyUserEmail(arg0 context.Context, arg1 db.VerifyUserEmailParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyUserEmail", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyUserEmail indicates an expected call of VerifyUserEmail.
+func (mr *MockStoreMockRecorder) VerifyUserEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyUserEmail", reflect.TypeOf((*MockStore)(nil).VerifyUserEmail), arg0, arg1)
 }
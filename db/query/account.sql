@@ -6,6 +6,25 @@ INSERT INTO account (owner, balance, currency) VALUES ($1, $2, $3) RETURNING *;
 -- Get an account by id
 SELECT * FROM account WHERE id = $1;
 
+-- name: GetAccountForUpdate :one
+-- Get an account by id and lock it until the transaction ends
+SELECT * FROM account WHERE id = $1
+FOR NO KEY UPDATE;
+
+-- name: ListAccountsByOwner :many
+-- List all accounts of a user
+SELECT * FROM account
+WHERE owner = $1
+ORDER BY id;
+
+-- name: ListOwnerAccounts :many
+-- List a page of the accounts of a user
+SELECT * FROM account
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
 -- name: ListAccounts :many
 -- List all accounts
 SELECT * FROM account
@@ -19,7 +38,24 @@ SET balance = balance + sqlc.arg(amount)
 WHERE id = sqlc.arg(id)
 RETURNING *;
 
--- name: DeleteAccount :exec
--- Delete an account
-DELETE FROM account WHERE id = $1;
+-- name: UpdateAccountStatus :one
+UPDATE account
+SET status = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: ListUnbalancedAccounts :many
+-- List accounts whose balance differs from the sum of their entries
+SELECT a.id, a.owner, a.currency, a.balance, COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM account a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id;
 
+-- name: SumBalancesByCurrency :many
+-- Total balance held in each currency
+SELECT currency::text AS currency, COALESCE(SUM(balance), 0)::bigint AS total_balance
+FROM account
+GROUP BY currency
+ORDER BY currency;
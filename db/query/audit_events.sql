@@ -0,0 +1,52 @@
+-- name: LockAuditEvents :exec
+-- Serialize appends, so that every event chains to the one before it
+LOCK TABLE audit_events IN SHARE ROW EXCLUSIVE MODE;
+
+-- name: GetLastAuditEvent :one
+SELECT * FROM audit_events
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+  actor,
+  action,
+  target,
+  before,
+  after,
+  request_id,
+  ip,
+  created_at,
+  prev_hash,
+  hash
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING *;
+
+-- name: ListAuditEvents :many
+-- List events newest first, optionally filtered by actor, target and a
+-- time range
+SELECT * FROM audit_events
+WHERE (sqlc.narg(actor)::varchar IS NULL OR actor = sqlc.narg(actor))
+  AND (sqlc.narg(target)::varchar IS NULL OR target = sqlc.narg(target))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamptz IS NULL OR created_at < sqlc.narg(until))
+ORDER BY id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: ListAuditEventsAfter :many
+-- List events in chain order, for verification
+SELECT * FROM audit_events
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: ListAuditEventsByUser :many
+-- List the events by a user, about the user or about any of its accounts,
+-- in chain order
+SELECT * FROM audit_events
+WHERE actor = sqlc.arg(username)
+   OR target = 'user:' || sqlc.arg(username)
+   OR target IN (SELECT 'account:' || id FROM account WHERE owner = sqlc.arg(username))
+ORDER BY id;
@@ -21,4 +21,28 @@ RETURNING *;
 
 -- name: DeleteEntries :exec
 -- Delete an entries
-DELETE FROM entries WHERE id = $1;
\ No newline at end of file
+DELETE FROM entries WHERE id = $1;
+
+-- name: ListEntriesByOwner :many
+-- List the entries of all accounts of a user
+SELECT e.* FROM entries e
+JOIN account a ON a.id = e.account_id
+WHERE a.owner = $1
+ORDER BY e.id;
+
+-- name: ListOwnerEntries :many
+-- List a page of the entries of all accounts of a user
+SELECT e.* FROM entries e
+JOIN account a ON a.id = e.account_id
+WHERE a.owner = $1
+ORDER BY e.id
+LIMIT $2
+OFFSET $3;
+
+-- name: ListEntriesByAccount :many
+-- List the entries of one account
+SELECT * FROM entries
+WHERE account_id = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
@@ -0,0 +1,94 @@
+-- name: SubmitKYCProfile :one
+-- Create or replace the KYC profile of a user. A changed profile goes back
+-- to review, and the user is in tier 0 until it is verified again.
+INSERT INTO kyc_profiles (
+  username,
+  legal_name,
+  date_of_birth,
+  address,
+  document_type,
+  document_number,
+  document_country
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (username) DO UPDATE
+SET
+  legal_name = EXCLUDED.legal_name,
+  date_of_birth = EXCLUDED.date_of_birth,
+  address = EXCLUDED.address,
+  document_type = EXCLUDED.document_type,
+  document_number = EXCLUDED.document_number,
+  document_country = EXCLUDED.document_country,
+  status = 'pending',
+  tier = 0,
+  review_note = '',
+  reviewed_by = '',
+  reviewed_at = NULL,
+  submitted_at = now()
+RETURNING *;
+
+-- name: GetKYCProfile :one
+SELECT * FROM kyc_profiles
+WHERE username = $1 LIMIT 1;
+
+-- name: ListKYCProfiles :many
+-- List profiles oldest submission first, optionally filtered by status,
+-- e.g. the pending ones for the review queue
+SELECT * FROM kyc_profiles
+WHERE sqlc.narg(status)::varchar IS NULL OR status = sqlc.narg(status)
+ORDER BY submitted_at, username
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: ListKYCProfilesAfter :many
+-- Page through all profiles in username order
+SELECT * FROM kyc_profiles
+WHERE username > $1
+ORDER BY username
+LIMIT $2;
+
+-- name: ReviewKYCProfile :one
+-- Record the decision on a pending profile. No row means the profile does
+-- not exist or is not pending anymore.
+UPDATE kyc_profiles
+SET
+  status = sqlc.arg(status),
+  tier = sqlc.arg(tier),
+  review_note = sqlc.arg(review_note),
+  reviewed_by = sqlc.arg(reviewed_by),
+  reviewed_at = now()
+WHERE username = sqlc.arg(username) AND status = 'pending'
+RETURNING *;
+
+-- name: UpdateKYCProfilePII :execrows
+-- Replace the personal data of a profile with a re-encrypted copy, unless
+-- it changed since it was read
+UPDATE kyc_profiles
+SET
+  legal_name = sqlc.arg(legal_name),
+  date_of_birth = sqlc.arg(date_of_birth),
+  address = sqlc.arg(address),
+  document_number = sqlc.arg(document_number)
+WHERE
+  username = sqlc.arg(username)
+  AND legal_name = sqlc.arg(old_legal_name)
+  AND date_of_birth = sqlc.arg(old_date_of_birth)
+  AND address = sqlc.arg(old_address)
+  AND document_number = sqlc.arg(old_document_number);
+
+-- name: GetKYCTier :one
+SELECT * FROM kyc_tiers
+WHERE tier = $1 LIMIT 1;
+
+-- name: GetKYCTierByAccount :one
+-- Get the tier of the owner of an account. Owners without a verified
+-- profile are in tier 0.
+SELECT kyc_tiers.* FROM account
+LEFT JOIN kyc_profiles ON kyc_profiles.username = account.owner AND kyc_profiles.status = 'verified'
+JOIN kyc_tiers ON kyc_tiers.tier = COALESCE(kyc_profiles.tier, 0)
+WHERE account.id = $1;
+
+-- name: ListKYCTiers :many
+SELECT * FROM kyc_tiers
+ORDER BY tier;
@@ -0,0 +1,86 @@
+-- name: CreateMonitoringAlert :one
+INSERT INTO monitoring_alerts (
+  rule,
+  severity,
+  reason,
+  transfer_id,
+  from_account_id,
+  to_account_id,
+  amount,
+  status
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: GetMonitoringAlert :one
+SELECT * FROM monitoring_alerts
+WHERE id = $1 LIMIT 1;
+
+-- name: GetMonitoringAlertForUpdate :one
+SELECT * FROM monitoring_alerts
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListMonitoringAlerts :many
+-- List alerts oldest first, optionally filtered by status, e.g. the open
+-- ones for the case queue
+SELECT * FROM monitoring_alerts
+WHERE sqlc.narg(status)::varchar IS NULL OR status = sqlc.narg(status)
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: ListTransferMonitoringAlerts :many
+SELECT * FROM monitoring_alerts
+WHERE transfer_id = $1
+ORDER BY id;
+
+-- name: AssignMonitoringAlert :one
+-- Assign an alert that is not closed to an analyst. An open alert is then
+-- under investigation. No row means the alert does not exist or is closed.
+UPDATE monitoring_alerts
+SET
+  assigned_to = sqlc.arg(assigned_to),
+  status = CASE WHEN status = 'open' THEN 'investigating' ELSE status END
+WHERE id = sqlc.arg(id) AND status IN ('open', 'investigating', 'held')
+RETURNING *;
+
+-- name: CloseMonitoringAlert :one
+-- Close an alert of an executed transfer as dismissed or reported. No row
+-- means the alert does not exist or is not open.
+UPDATE monitoring_alerts
+SET
+  status = sqlc.arg(status),
+  resolution_note = sqlc.arg(resolution_note),
+  resolved_by = sqlc.arg(resolved_by),
+  resolved_at = now()
+WHERE id = sqlc.arg(id) AND status IN ('open', 'investigating')
+RETURNING *;
+
+-- name: DecideHeldMonitoringAlert :one
+-- Release or reject a held transfer, which the alert links since it was
+-- held. No row means the alert does not exist or is not held.
+UPDATE monitoring_alerts
+SET
+  status = sqlc.arg(status),
+  resolution_note = sqlc.arg(resolution_note),
+  resolved_by = sqlc.arg(resolved_by),
+  resolved_at = now()
+WHERE id = sqlc.arg(id) AND status = 'held'
+RETURNING *;
+
+-- name: CountTransfersSentInRange :one
+-- Count the transfers an account sent since a time with an amount in
+-- [min_amount, max_amount)
+SELECT COUNT(*) FROM transfers
+WHERE from_account_id = sqlc.arg(account_id)
+  AND created_at >= sqlc.arg(since)
+  AND amount >= sqlc.arg(min_amount)
+  AND amount < sqlc.arg(max_amount)
+  AND status NOT IN ('pending', 'held', 'failed', 'cancelled');
+
+-- name: SumTransfersReceivedSince :one
+SELECT COALESCE(SUM(amount), 0)::bigint FROM transfers
+WHERE to_account_id = sqlc.arg(account_id)
+  AND created_at >= sqlc.arg(since)
+  AND status NOT IN ('pending', 'held', 'failed', 'cancelled');
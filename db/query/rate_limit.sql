@@ -0,0 +1,17 @@
+-- name: TakeRateLimitToken :one
+-- Refill the bucket for the time since its last update and take one token
+-- if there is one, in a single statement so concurrent instances agree
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES (sqlc.arg(key), sqlc.arg(capacity)::float8 - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+    tokens = CASE
+        WHEN LEAST(sqlc.arg(capacity)::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * sqlc.arg(refill_rate)::float8) >= 1
+        THEN LEAST(sqlc.arg(capacity)::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * sqlc.arg(refill_rate)::float8) - 1
+        ELSE LEAST(sqlc.arg(capacity)::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * sqlc.arg(refill_rate)::float8)
+    END,
+    allowed = LEAST(sqlc.arg(capacity)::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * sqlc.arg(refill_rate)::float8) >= 1,
+    updated_at = now()
+RETURNING tokens, allowed;
+
+-- name: DeleteIdleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets WHERE updated_at < sqlc.arg(idle_since);
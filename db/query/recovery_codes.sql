@@ -0,0 +1,13 @@
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (username, hashed_code) VALUES ($1, $2) RETURNING *;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE username = $1;
+
+-- name: UseRecoveryCode :one
+-- Mark an unused recovery code as used. No row means the code is wrong or
+-- was used before.
+UPDATE recovery_codes
+SET used_at = now()
+WHERE username = $1 AND hashed_code = $2 AND used_at IS NULL
+RETURNING *;
@@ -0,0 +1,60 @@
+-- name: RecordScreeningHit :one
+-- Record that a name matched a list entry. A name that matched the entry
+-- before keeps its hit, and with it the decision of the reviewer.
+INSERT INTO screening_hits (
+  action,
+  username,
+  name,
+  name_index,
+  list,
+  entry_id,
+  entry_name,
+  program,
+  score
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+ON CONFLICT (name_index, list, entry_id) DO UPDATE
+SET score = EXCLUDED.score
+RETURNING *;
+
+-- name: GetScreeningHit :one
+SELECT * FROM screening_hits
+WHERE id = $1 LIMIT 1;
+
+-- name: ListScreeningHits :many
+-- List hits oldest first, optionally filtered by status, e.g. the pending
+-- ones for the review queue
+SELECT * FROM screening_hits
+WHERE sqlc.narg(status)::varchar IS NULL OR status = sqlc.narg(status)
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: ListScreeningHitsAfter :many
+-- Walk all hits in id order, for re-encryption
+SELECT * FROM screening_hits
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: ReviewScreeningHit :one
+-- Clear or confirm a pending hit. No row means the hit does not exist or
+-- was reviewed already.
+UPDATE screening_hits
+SET
+  status = sqlc.arg(status),
+  review_note = sqlc.arg(review_note),
+  reviewed_by = sqlc.arg(reviewed_by),
+  reviewed_at = now()
+WHERE id = sqlc.arg(id) AND status = 'pending'
+RETURNING *;
+
+-- name: UpdateScreeningHitPII :execrows
+-- Store the re-encrypted name and recomputed index of a hit, unless the
+-- name changed since it was read
+UPDATE screening_hits
+SET
+  name = sqlc.arg(name),
+  name_index = sqlc.arg(name_index)
+WHERE id = sqlc.arg(id) AND name = sqlc.arg(old_name);
@@ -0,0 +1,146 @@
+-- name: UpsertApprovalPolicy :one
+INSERT INTO approval_policies (
+  account_id,
+  threshold,
+  required_approvals,
+  updated_by
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (account_id) DO UPDATE
+SET
+  threshold = EXCLUDED.threshold,
+  required_approvals = EXCLUDED.required_approvals,
+  updated_by = EXCLUDED.updated_by,
+  updated_at = now()
+RETURNING *;
+
+-- name: GetApprovalPolicy :one
+SELECT * FROM approval_policies
+WHERE account_id = $1 LIMIT 1;
+
+-- name: DeleteApprovalPolicy :execrows
+DELETE FROM approval_policies
+WHERE account_id = $1;
+
+-- name: AddAccountApprover :exec
+INSERT INTO account_approvers (
+  account_id,
+  username
+) VALUES (
+  $1, $2
+);
+
+-- name: DeleteAccountApprovers :exec
+DELETE FROM account_approvers
+WHERE account_id = $1;
+
+-- name: ListAccountApprovers :many
+SELECT username FROM account_approvers
+WHERE account_id = $1
+ORDER BY username;
+
+-- name: IsAccountApprover :one
+SELECT EXISTS (
+  SELECT 1 FROM account_approvers
+  WHERE account_id = $1 AND username = $2
+);
+
+-- name: CreateTransferApproval :one
+INSERT INTO transfer_approvals (
+  from_account_id,
+  to_account_id,
+  amount,
+  initiated_by,
+  required_approvals,
+  expires_at,
+  transfer_id
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: GetTransferApproval :one
+SELECT * FROM transfer_approvals
+WHERE id = $1 LIMIT 1;
+
+-- name: GetTransferApprovalForUpdate :one
+SELECT * FROM transfer_approvals
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListTransferApprovals :many
+-- List approvals newest first, optionally filtered by status and by a user,
+-- who sees the approvals they initiated or may decide on
+SELECT * FROM transfer_approvals
+WHERE (sqlc.narg(status)::varchar IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(username)::varchar IS NULL
+    OR initiated_by = sqlc.narg(username)
+    OR from_account_id IN (
+      SELECT account_id FROM account_approvers
+      WHERE username = sqlc.narg(username)
+    ))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: ApproveTransferApproval :one
+-- Count an approval. The last required one approves the transfer. No row
+-- means the approval does not exist or is not pending.
+UPDATE transfer_approvals
+SET
+  approvals = approvals + 1,
+  status = CASE WHEN approvals + 1 >= required_approvals THEN 'approved' ELSE status END,
+  decided_at = CASE WHEN approvals + 1 >= required_approvals THEN now() ELSE decided_at END
+WHERE id = $1 AND status = 'pending_approval'
+RETURNING *;
+
+-- name: RejectTransferApproval :one
+-- No row means the approval does not exist or is not pending
+UPDATE transfer_approvals
+SET
+  status = 'rejected',
+  decided_at = now()
+WHERE id = $1 AND status = 'pending_approval'
+RETURNING *;
+
+-- name: ExecuteTransferApproval :one
+-- Mark an approved transfer as executed. No row means the approval does
+-- not exist or is not approved, e.g. it was executed already.
+UPDATE transfer_approvals
+SET status = 'executed'
+WHERE id = $1 AND status = 'approved'
+RETURNING *;
+
+-- name: FailTransferApproval :one
+-- Record why the transfer of an approved transfer failed. No row means the
+-- approval does not exist or is not approved.
+UPDATE transfer_approvals
+SET
+  status = 'failed',
+  failure_reason = sqlc.arg(failure_reason)
+WHERE id = sqlc.arg(id) AND status = 'approved'
+RETURNING *;
+
+-- name: ExpireTransferApprovals :many
+-- Expire the pending approvals that were not decided in time
+UPDATE transfer_approvals
+SET
+  status = 'expired',
+  decided_at = now()
+WHERE status = 'pending_approval' AND expires_at <= now()
+RETURNING *;
+
+-- name: CreateTransferApprovalDecision :one
+INSERT INTO transfer_approval_decisions (
+  approval_id,
+  approver,
+  decision,
+  note
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ListTransferApprovalDecisions :many
+SELECT * FROM transfer_approval_decisions
+WHERE approval_id = $1
+ORDER BY created_at, approver;
@@ -0,0 +1,14 @@
+-- name: CreateTransferEvent :one
+INSERT INTO transfer_events (
+  transfer_id,
+  status,
+  reason
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: ListTransferEvents :many
+-- List the status changes of a transfer, oldest first
+SELECT * FROM transfer_events
+WHERE transfer_id = $1
+ORDER BY id;
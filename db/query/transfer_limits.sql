@@ -0,0 +1,81 @@
+-- name: UpsertTransferLimit :one
+INSERT INTO transfer_limits (
+  scope,
+  subject,
+  max_amount,
+  daily_amount,
+  monthly_amount,
+  hourly_count,
+  note,
+  updated_by
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+ON CONFLICT (scope, subject) DO UPDATE
+SET
+  max_amount = EXCLUDED.max_amount,
+  daily_amount = EXCLUDED.daily_amount,
+  monthly_amount = EXCLUDED.monthly_amount,
+  hourly_count = EXCLUDED.hourly_count,
+  note = EXCLUDED.note,
+  updated_by = EXCLUDED.updated_by,
+  updated_at = now()
+RETURNING *;
+
+-- name: GetTransferLimit :one
+SELECT * FROM transfer_limits
+WHERE scope = $1 AND subject = $2 LIMIT 1;
+
+-- name: ListTransferLimits :many
+SELECT * FROM transfer_limits
+ORDER BY scope, subject;
+
+-- name: ListTransferLimitsFor :many
+-- List the defaults and the overrides that apply to an account and its owner
+SELECT * FROM transfer_limits
+WHERE (scope = 'account' AND subject IN ('', sqlc.arg(account_id)::varchar))
+   OR (scope = 'user' AND subject IN ('', sqlc.arg(owner)::varchar))
+ORDER BY scope, subject;
+
+-- name: DeleteTransferLimit :execrows
+DELETE FROM transfer_limits
+WHERE scope = $1 AND subject = $2;
+
+-- name: LockUserTransfers :exec
+-- Lock the user until the transaction ends, so that concurrent transfers
+-- from different accounts of the user are checked against its limits one
+-- at a time
+SELECT username FROM users
+WHERE username = $1
+FOR NO KEY UPDATE;
+
+-- name: GetAccountTransferTotals :one
+-- Sum up what an account sent in the last hour, day and 30 days. The
+-- transfer being checked, reversed transfers and their reversals, and
+-- transfers that moved no money are left out.
+SELECT
+  COUNT(*) FILTER (WHERE created_at > now() - interval '1 hour') AS hourly_count,
+  COALESCE(SUM(amount) FILTER (WHERE created_at > now() - interval '1 day'), 0)::bigint AS daily_amount,
+  COALESCE(SUM(amount), 0)::bigint AS monthly_amount
+FROM transfers
+WHERE from_account_id = sqlc.arg(account_id)
+  AND id <> sqlc.arg(exclude_id)
+  AND created_at > now() - interval '30 days'
+  AND id NOT IN (SELECT reversal_id FROM transfer_reversals)
+  AND status NOT IN ('pending', 'held', 'failed', 'cancelled', 'reversed');
+
+-- name: GetUserTransferTotals :one
+-- Sum up what all accounts of a user sent in the last hour, day and 30
+-- days. The transfer being checked, reversed transfers and their reversals,
+-- and transfers that moved no money are left out.
+SELECT
+  COUNT(*) FILTER (WHERE transfers.created_at > now() - interval '1 hour') AS hourly_count,
+  COALESCE(SUM(transfers.amount) FILTER (WHERE transfers.created_at > now() - interval '1 day'), 0)::bigint AS daily_amount,
+  COALESCE(SUM(transfers.amount), 0)::bigint AS monthly_amount
+FROM transfers
+JOIN account ON account.id = transfers.from_account_id
+WHERE account.owner = sqlc.arg(owner)
+  AND transfers.id <> sqlc.arg(exclude_id)
+  AND transfers.created_at > now() - interval '30 days'
+  AND transfers.id NOT IN (SELECT reversal_id FROM transfer_reversals)
+  AND transfers.status NOT IN ('pending', 'held', 'failed', 'cancelled', 'reversed');
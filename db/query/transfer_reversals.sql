@@ -0,0 +1,8 @@
+-- name: CreateTransferReversal :one
+INSERT INTO transfer_reversals (transfer_id, reversal_id) VALUES ($1, $2) RETURNING *;
+
+-- name: GetTransferReversal :one
+SELECT * FROM transfer_reversals WHERE transfer_id = $1;
+
+-- name: GetTransferReversalByReversalID :one
+SELECT * FROM transfer_reversals WHERE reversal_id = $1;
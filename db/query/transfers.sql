@@ -1,5 +1,9 @@
 -- name: CreateTransfers :one
--- Create a new transfers
+-- Create a new transfers, completed as its money moves in the same transaction
+INSERT INTO transfers (from_account_id, to_account_id, amount, status) VALUES ($1, $2, $3, 'completed') RETURNING *;
+
+-- name: CreatePendingTransfer :one
+-- Create a transfer that moves its money later, e.g. after approval
 INSERT INTO transfers (from_account_id, to_account_id, amount) VALUES ($1, $2, $3) RETURNING *;
 
 -- name: GetTransfers :one
@@ -13,12 +17,39 @@ ORDER BY id
 LIMIT $1
 OFFSET $2;
 
+-- name: ListTransfersByOwner :many
+-- List the transfers from or to any account of a user
+SELECT * FROM transfers
+WHERE from_account_id IN (SELECT id FROM account WHERE owner = $1)
+   OR to_account_id IN (SELECT id FROM account WHERE owner = $1)
+ORDER BY id;
+
+-- name: ListOwnerTransfers :many
+-- List a page of the transfers from or to any account of a user
+SELECT * FROM transfers
+WHERE from_account_id IN (SELECT id FROM account WHERE owner = $1)
+   OR to_account_id IN (SELECT id FROM account WHERE owner = $1)
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
 -- name: UpdateTransfer :one
 UPDATE transfers
 SET amount = $2
 WHERE id = $1
 RETURNING *;
 
+-- name: UpdateTransferStatus :one
+-- Move a transfer from one status to another. No row means the transfer
+-- does not exist or is no longer in from_status.
+UPDATE transfers
+SET
+  status = sqlc.arg(status),
+  failure_reason = sqlc.arg(failure_reason),
+  updated_at = now()
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(from_status)
+RETURNING *;
+
 -- name: DeleteTransfers :exec
 -- Delete a transfers
 DELETE FROM transfers WHERE id = $1;
\ No newline at end of file
@@ -3,15 +3,21 @@ INSERT INTO users (
   username,
   hashed_password,
   full_name,
-  email
+  email,
+  email_index
 ) VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5
 ) RETURNING *;
 
 -- name: GetUser :one
 SELECT * FROM users
 WHERE username = $1 LIMIT 1;
 
+-- name: GetUserByEmail :one
+-- Find a user by the blind index of the email
+SELECT * FROM users
+WHERE email_index = $1 LIMIT 1;
+
 -- name: ListUsers :many
 SELECT * FROM users
 ORDER BY username
@@ -19,16 +25,110 @@ LIMIT $1
 OFFSET $2;
 
 -- name: UpdateUser :one
+-- A changed email has to be verified again
 UPDATE users
 SET
   hashed_password = COALESCE(sqlc.narg(hashed_password), hashed_password),
   full_name = COALESCE(sqlc.narg(full_name), full_name),
   email = COALESCE(sqlc.narg(email), email),
-  password_changed_at = COALESCE(sqlc.narg(password_changed_at), password_changed_at)
+  email_index = COALESCE(sqlc.narg(email_index), email_index),
+  password_changed_at = COALESCE(sqlc.narg(password_changed_at), password_changed_at),
+  is_email_verified = is_email_verified AND COALESCE(sqlc.narg(email_index) = email_index, true)
 WHERE
   username = sqlc.arg(username)
 RETURNING *;
 
--- name: DeleteUser :exec
-DELETE FROM users
-WHERE username = $1;
\ No newline at end of file
+-- name: SoftDeleteUser :one
+-- Mark a user as deleted. The row stays, since the ledger refers to it.
+UPDATE users
+SET deleted_at = now()
+WHERE username = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: EraseUser :one
+-- Replace the personal data of a deleted user with a pseudonym and remove
+-- its credentials. Rows that refer to the username follow the rename.
+UPDATE users
+SET
+  username = sqlc.arg(pseudonym),
+  email = sqlc.arg(email),
+  email_index = sqlc.arg(email_index),
+  full_name = '',
+  hashed_password = '',
+  totp_secret = '',
+  totp_enabled = false,
+  is_email_verified = false,
+  erased_at = now()
+WHERE username = sqlc.arg(username) AND deleted_at IS NOT NULL AND erased_at IS NULL
+RETURNING *;
+
+-- name: UpdateUserPII :execrows
+-- Replace the personal data of a user with a re-encrypted copy, unless it
+-- changed since it was read
+UPDATE users
+SET
+  full_name = sqlc.arg(full_name),
+  email = sqlc.arg(email),
+  email_index = sqlc.arg(email_index)
+WHERE
+  username = sqlc.arg(username)
+  AND full_name = sqlc.arg(old_full_name)
+  AND email = sqlc.arg(old_email);
+
+-- name: RecordFailedLogin :one
+-- Count a failed login and lock the user out until lock_until once the
+-- count reaches max_attempts. A lockout that has expired starts a new
+-- count, so the next failure does not lock the user out again. The
+-- increment is atomic, so concurrent guesses cannot slip past the limit.
+UPDATE users
+SET
+  failed_login_attempts = CASE
+    WHEN locked_until > '0001-01-01 00:00:00Z' AND locked_until <= now() THEN 1
+    ELSE failed_login_attempts + 1
+  END,
+  locked_until = CASE
+    WHEN locked_until > '0001-01-01 00:00:00Z' AND locked_until <= now() THEN
+      CASE WHEN 1 >= sqlc.arg(max_attempts)::integer THEN sqlc.arg(lock_until)::timestamptz ELSE '0001-01-01 00:00:00Z' END
+    WHEN failed_login_attempts + 1 >= sqlc.arg(max_attempts)::integer THEN sqlc.arg(lock_until)::timestamptz
+    ELSE locked_until
+  END
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: ResetFailedLogins :one
+-- Clear the failed login count and lift a lockout
+UPDATE users
+SET
+  failed_login_attempts = 0,
+  locked_until = '0001-01-01 00:00:00Z'
+WHERE username = $1
+RETURNING *;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2
+WHERE username = $1
+RETURNING *;
+
+-- name: SetTOTPSecret :one
+-- Store a new secret for enrolment. It is not used until EnableTOTP.
+UPDATE users
+SET
+  totp_secret = $2,
+  totp_enabled = false
+WHERE username = $1
+RETURNING *;
+
+-- name: EnableTOTP :one
+UPDATE users
+SET totp_enabled = true
+WHERE username = $1 AND totp_secret <> ''
+RETURNING *;
+
+-- name: VerifyUserEmail :one
+-- Mark the email of a user as verified, unless it changed since the token
+-- was sent
+UPDATE users
+SET is_email_verified = true
+WHERE username = $1 AND email_index = $2
+RETURNING *;
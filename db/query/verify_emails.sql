@@ -0,0 +1,42 @@
+-- name: CreateVerifyEmail :one
+INSERT INTO verify_emails (
+  username,
+  email,
+  purpose,
+  hashed_token,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: UseVerifyEmail :one
+-- Mark an unused and unexpired token as used. No row means the token is
+-- wrong, expired or was used before.
+UPDATE verify_emails
+SET used_at = now()
+WHERE hashed_token = $1 AND purpose = $2 AND used_at IS NULL AND expired_at > now()
+RETURNING *;
+
+-- name: ListVerifyEmails :many
+-- List the tokens ever sent to a user
+SELECT * FROM verify_emails
+WHERE username = $1
+ORDER BY id;
+
+-- name: ListVerifyEmailsAfter :many
+-- Page through all tokens in id order
+SELECT * FROM verify_emails
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: UpdateVerifyEmailAddress :execrows
+-- Replace the address with a re-encrypted copy, unless it changed since it
+-- was read
+UPDATE verify_emails
+SET email = sqlc.arg(email)
+WHERE id = sqlc.arg(id) AND email = sqlc.arg(old_email);
+
+-- name: DeleteVerifyEmails :exec
+DELETE FROM verify_emails
+WHERE username = $1;
@@ -13,7 +13,7 @@ const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE account
 SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, status
 `
 
 type AddAccountBalanceParams struct {
@@ -30,12 +30,13 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
 	)
 	return i, err
 }
 
 const createAccount = `-- name: CreateAccount :one
-INSERT INTO account (owner, balance, currency) VALUES ($1, $2, $3) RETURNING id, owner, balance, currency, created_at
+INSERT INTO account (owner, balance, currency) VALUES ($1, $2, $3) RETURNING id, owner, balance, currency, created_at, status
 `
 
 type CreateAccountParams struct {
@@ -54,22 +55,13 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
 	)
 	return i, err
 }
 
-const deleteAccount = `-- name: DeleteAccount :exec
-DELETE FROM account WHERE id = $1
-`
-
-// Delete an account
-func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteAccount, id)
-	return err
-}
-
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM account WHERE id = $1
+SELECT id, owner, balance, currency, created_at, status FROM account WHERE id = $1
 `
 
 // Get an account by id
@@ -82,12 +74,33 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+	)
+	return i, err
+}
+
+const getAccountForUpdate = `-- name: GetAccountForUpdate :one
+SELECT id, owner, balance, currency, created_at, status FROM account WHERE id = $1
+FOR NO KEY UPDATE
+`
+
+// Get an account by id and lock it until the transaction ends
+func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getAccountForUpdate, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
 	)
 	return i, err
 }
 
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM account
+SELECT id, owner, balance, currency, created_at, status FROM account
 ORDER BY id
 LIMIT $1
 OFFSET $2
@@ -114,6 +127,7 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.Status,
 		); err != nil {
 			return nil, err
 		}
@@ -127,3 +141,194 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 	}
 	return items, nil
 }
+
+const listAccountsByOwner = `-- name: ListAccountsByOwner :many
+SELECT id, owner, balance, currency, created_at, status FROM account
+WHERE owner = $1
+ORDER BY id
+`
+
+// List all accounts of a user
+func (q *Queries) ListAccountsByOwner(ctx context.Context, owner string) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountsByOwner, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOwnerAccounts = `-- name: ListOwnerAccounts :many
+SELECT id, owner, balance, currency, created_at, status FROM account
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3
+`
+
+type ListOwnerAccountsParams struct {
+	Owner  string `json:"owner"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+// List a page of the accounts of a user
+func (q *Queries) ListOwnerAccounts(ctx context.Context, arg ListOwnerAccountsParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listOwnerAccounts, arg.Owner, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnbalancedAccounts = `-- name: ListUnbalancedAccounts :many
+SELECT a.id, a.owner, a.currency, a.balance, COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM account a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id
+`
+
+type ListUnbalancedAccountsRow struct {
+	ID           int64       `json:"id"`
+	Owner        string      `json:"owner"`
+	Currency     interface{} `json:"currency"`
+	Balance      int64       `json:"balance"`
+	EntriesTotal int64       `json:"entries_total"`
+}
+
+// List accounts whose balance differs from the sum of their entries
+func (q *Queries) ListUnbalancedAccounts(ctx context.Context) ([]ListUnbalancedAccountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUnbalancedAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUnbalancedAccountsRow{}
+	for rows.Next() {
+		var i ListUnbalancedAccountsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Currency,
+			&i.Balance,
+			&i.EntriesTotal,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const sumBalancesByCurrency = `-- name: SumBalancesByCurrency :many
+SELECT currency::text AS currency, COALESCE(SUM(balance), 0)::bigint AS total_balance
+FROM account
+GROUP BY currency
+ORDER BY currency
+`
+
+type SumBalancesByCurrencyRow struct {
+	Currency     string `json:"currency"`
+	TotalBalance int64  `json:"total_balance"`
+}
+
+// Total balance held in each currency
+func (q *Queries) SumBalancesByCurrency(ctx context.Context) ([]SumBalancesByCurrencyRow, error) {
+	rows, err := q.db.QueryContext(ctx, sumBalancesByCurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SumBalancesByCurrencyRow{}
+	for rows.Next() {
+		var i SumBalancesByCurrencyRow
+		if err := rows.Scan(&i.Currency, &i.TotalBalance); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateAccountStatus = `-- name: UpdateAccountStatus :one
+UPDATE account
+SET status = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, status
+`
+
+type UpdateAccountStatusParams struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+func (q *Queries) UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountStatus, arg.ID, arg.Status)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+	)
+	return i, err
+}
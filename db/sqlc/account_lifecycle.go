@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidAccountTransition = errors.New("account status does not allow this")
+	ErrAccountNotEmpty          = errors.New("account balance is not zero")
+	ErrInvalidSweepAccount      = errors.New("invalid sweep account")
+)
+
+// AccountTransition is a step in the lifecycle of an account. It moves an
+// account in any of the From statuses to the To status.
+type AccountTransition struct {
+	Name string   `json:"name"`
+	From []string `json:"from"`
+	To   string   `json:"to"`
+}
+
+// The transitions of the account lifecycle. A frozen account has to be
+// unfrozen before it can be closed, and a closed account keeps its rows so
+// that the ledger still reconciles.
+var (
+	AccountFreeze = AccountTransition{
+		Name: "freeze",
+		From: []string{AccountStatusActive, AccountStatusDormant},
+		To:   AccountStatusFrozen,
+	}
+	// AccountUnfreeze also wakes up a dormant account
+	AccountUnfreeze = AccountTransition{
+		Name: "unfreeze",
+		From: []string{AccountStatusFrozen, AccountStatusDormant},
+		To:   AccountStatusActive,
+	}
+	AccountMarkDormant = AccountTransition{
+		Name: "mark dormant",
+		From: []string{AccountStatusActive},
+		To:   AccountStatusDormant,
+	}
+	AccountClose = AccountTransition{
+		Name: "close",
+		From: []string{AccountStatusActive, AccountStatusDormant},
+		To:   AccountStatusClosed,
+	}
+	AccountReopen = AccountTransition{
+		Name: "reopen",
+		From: []string{AccountStatusClosed},
+		To:   AccountStatusActive,
+	}
+)
+
+// Allows reports whether the transition applies to an account in status
+func (t AccountTransition) Allows(status string) bool {
+	return slices.Contains(t.From, status)
+}
+
+type ChangeAccountStatusTxParams struct {
+	AccountID  int64             `json:"account_id"`
+	Transition AccountTransition `json:"transition"`
+	// SweepAccountID receives the balance of an account that is closed.
+	// Without it only an account with a zero balance can be closed.
+	SweepAccountID int64 `json:"sweep_account_id"`
+}
+
+type ChangeAccountStatusTxResult struct {
+	Account Account `json:"account"`
+	// Sweep is the transfer of the balance of a closed account, if any
+	Sweep *TransferTxResult `json:"sweep,omitempty"`
+}
+
+// ChangeAccountStatusTx applies a lifecycle transition to an account. It
+// fails with ErrInvalidAccountTransition when the account is not in one of
+// the statuses the transition starts from. Closing an account with money
+// left sweeps it to SweepAccountID, which must be an active account in the
+// same currency.
+func (store *SQLStore) ChangeAccountStatusTx(ctx context.Context, arg ChangeAccountStatusTxParams) (ChangeAccountStatusTxResult, error) {
+	ctx, span := startTxSpan(ctx, "ChangeAccountStatusTx",
+		attribute.Int64("account.id", arg.AccountID),
+		attribute.String("account.transition", arg.Transition.Name),
+	)
+	defer span.End()
+
+	var result ChangeAccountStatusTxResult
+
+	err := store.execTx(ctx, "ChangeAccountStatusTx", func(q *Queries) error {
+		account, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if !arg.Transition.Allows(account.Status) {
+			return fmt.Errorf("%w: cannot %s account %d, it is %s",
+				ErrInvalidAccountTransition, arg.Transition.Name, account.ID, account.Status)
+		}
+
+		if arg.Transition.To == AccountStatusClosed && account.Balance != 0 {
+			result.Sweep, err = sweepAccount(ctx, q, account, arg.SweepAccountID)
+			if err != nil {
+				return err
+			}
+		}
+
+		result.Account, err = q.UpdateAccountStatus(ctx, UpdateAccountStatusParams{
+			ID:     account.ID,
+			Status: arg.Transition.To,
+		})
+		return err
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+// sweepAccount transfers the whole balance of account, which is being
+// closed, to the account with id sweepAccountID
+func sweepAccount(ctx context.Context, q *Queries, account Account, sweepAccountID int64) (*TransferTxResult, error) {
+	if account.Balance < 0 || sweepAccountID == 0 {
+		return nil, fmt.Errorf("%w: account %d has balance %d", ErrAccountNotEmpty, account.ID, account.Balance)
+	}
+	if sweepAccountID == account.ID {
+		return nil, fmt.Errorf("%w: account %d cannot receive its own balance", ErrInvalidSweepAccount, account.ID)
+	}
+
+	target, err := q.GetAccount(ctx, sweepAccountID)
+	if err != nil {
+		return nil, err
+	}
+	// the currency enum scans as []byte, which cannot be compared with !=
+	if fmt.Sprint(target.Currency) != fmt.Sprint(account.Currency) {
+		return nil, fmt.Errorf("%w: account %d holds %s, account %d holds %s",
+			ErrInvalidSweepAccount, target.ID, target.Currency, account.ID, account.Currency)
+	}
+
+	result := &TransferTxResult{}
+	result.Transfer, err = bookTransfer(ctx, q, CreateTransfersParams{
+		FromAccountID: account.ID,
+		ToAccountID:   target.ID,
+		Amount:        account.Balance,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := moveMoney(ctx, q, result); err != nil {
+		return nil, err
+	}
+	// the account being closed may be dormant, only the receiver must be active
+	if err := requireActive(result.ToAccount); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
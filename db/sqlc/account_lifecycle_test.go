@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createAccountWithBalance(t *testing.T, currency string, balance int64) Account {
+	account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    createVerifiedUser(t, 2).Username,
+		Balance:  balance,
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestChangeAccountStatusTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+
+	for _, step := range []struct {
+		transition AccountTransition
+		status     string
+	}{
+		{AccountFreeze, AccountStatusFrozen},
+		{AccountUnfreeze, AccountStatusActive},
+		{AccountMarkDormant, AccountStatusDormant},
+		{AccountUnfreeze, AccountStatusActive},
+	} {
+		result, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+			AccountID:  account.ID,
+			Transition: step.transition,
+		})
+		require.NoError(t, err)
+		require.Equal(t, step.status, result.Account.Status)
+		require.Nil(t, result.Sweep)
+	}
+
+	// an active account cannot be unfrozen or reopened
+	for _, transition := range []AccountTransition{AccountUnfreeze, AccountReopen} {
+		_, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+			AccountID:  account.ID,
+			Transition: transition,
+		})
+		require.ErrorIs(t, err, ErrInvalidAccountTransition)
+	}
+
+	_, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  0,
+		Transition: AccountFreeze,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestChangeAccountStatusTxClose(t *testing.T) {
+	store := NewStore(testDB)
+	empty := createAccountWithBalance(t, "USD", 0)
+
+	result, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  empty.ID,
+		Transition: AccountClose,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusClosed, result.Account.Status)
+	require.Nil(t, result.Sweep)
+
+	result, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  empty.ID,
+		Transition: AccountReopen,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusActive, result.Account.Status)
+
+	// a frozen account has to be unfrozen first
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  empty.ID,
+		Transition: AccountFreeze,
+	})
+	require.NoError(t, err)
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  empty.ID,
+		Transition: AccountClose,
+	})
+	require.ErrorIs(t, err, ErrInvalidAccountTransition)
+}
+
+func TestChangeAccountStatusTxSweep(t *testing.T) {
+	store := NewStore(testDB)
+	account := createAccountWithBalance(t, "USD", 100)
+	receiver := createAccountWithBalance(t, "USD", 10)
+	euros := createAccountWithBalance(t, "EUR", 10)
+
+	testCases := []struct {
+		name           string
+		sweepAccountID int64
+		err            error
+	}{
+		{name: "NoSweepAccount", sweepAccountID: 0, err: ErrAccountNotEmpty},
+		{name: "Itself", sweepAccountID: account.ID, err: ErrInvalidSweepAccount},
+		{name: "OtherCurrency", sweepAccountID: euros.ID, err: ErrInvalidSweepAccount},
+		{name: "Missing", sweepAccountID: receiver.ID + 1000000, err: sql.ErrNoRows},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+				AccountID:      account.ID,
+				Transition:     AccountClose,
+				SweepAccountID: tc.sweepAccountID,
+			})
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	result, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:      account.ID,
+		Transition:     AccountClose,
+		SweepAccountID: receiver.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusClosed, result.Account.Status)
+	require.Zero(t, result.Account.Balance)
+	require.NotNil(t, result.Sweep)
+	require.Equal(t, int64(100), result.Sweep.Transfer.Amount)
+	require.Equal(t, int64(110), result.Sweep.ToAccount.Balance)
+	require.Equal(t, int64(-100), result.Sweep.FromEntry.Amount)
+}
+
+func TestTransferTxNotActive(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createAccountWithBalance(t, "USD", 100)
+	account2 := createAccountWithBalance(t, "USD", 0)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  account2.ID,
+		Transition: AccountFreeze,
+	})
+	require.NoError(t, err)
+
+	// neither direction moves money, and nothing is left behind
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+
+	_, err = store.ReverseTransferTx(context.Background(), original.Transfer.ID)
+	require.ErrorIs(t, err, ErrAccountNotActive)
+
+	entries, err := store.ListEntriesByAccount(context.Background(), ListEntriesByAccountParams{
+		AccountID: account2.ID,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	updated, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), updated.Balance)
+}
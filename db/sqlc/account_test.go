@@ -2,7 +2,7 @@ package db
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"testing"
 	"time"
 
@@ -48,6 +48,30 @@ func TestListAccounts(t *testing.T) {
 	}
 }
 
+func TestListOwnerAccounts(t *testing.T) {
+	owner := createVerifiedUser(t, 2).Username
+	for i := 0; i < 3; i++ {
+		_, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+			Owner:    owner,
+			Currency: randomCurrency(),
+		})
+		require.NoError(t, err)
+	}
+	createRandomAccount(t)
+
+	accounts, err := testQueries.ListOwnerAccounts(context.Background(), ListOwnerAccountsParams{
+		Owner:  owner,
+		Limit:  5,
+		Offset: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+
+	for _, account := range accounts {
+		require.Equal(t, owner, account.Owner)
+	}
+}
+
 func TestAddAccountBalance(t *testing.T) {
 	account1 := createRandomAccount(t)
 
@@ -105,22 +129,65 @@ func TestAddAccountBalance(t *testing.T) {
 	}
 }
 
-func TestDeleteAccount(t *testing.T) {
+func TestUpdateAccountStatus(t *testing.T) {
 	account1 := createRandomAccount(t)
-	err := testQueries.DeleteAccount(context.Background(), account1.ID)
+
+	account2, err := testQueries.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:     account1.ID,
+		Status: AccountStatusFrozen,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, account2.ID)
+	require.Equal(t, account1.Balance, account2.Balance)
+	require.Equal(t, AccountStatusFrozen, account2.Status)
+}
+
+func TestListUnbalancedAccounts(t *testing.T) {
+	// createRandomAccount starts with a balance but no entries
+	account := createRandomAccount(t)
+	for account.Balance == 0 {
+		account = createRandomAccount(t)
+	}
+
+	accounts, err := testQueries.ListUnbalancedAccounts(context.Background())
 	require.NoError(t, err)
 
-	// Verify account is deleted
-	account2, err := testQueries.GetAccount(context.Background(), account1.ID)
-	require.Error(t, err)
-	require.EqualError(t, err, sql.ErrNoRows.Error())
-	require.Empty(t, account2)
+	var found bool
+	for _, row := range accounts {
+		require.NotEqual(t, row.Balance, row.EntriesTotal)
+		if row.ID == account.ID {
+			found = true
+			require.Equal(t, account.Balance, row.Balance)
+			require.Zero(t, row.EntriesTotal)
+		}
+	}
+	require.True(t, found)
+}
+
+func TestSumBalancesByCurrency(t *testing.T) {
+	before, err := testQueries.SumBalancesByCurrency(context.Background())
+	require.NoError(t, err)
+
+	account := createRandomAccount(t)
+
+	after, err := testQueries.SumBalancesByCurrency(context.Background())
+	require.NoError(t, err)
+
+	totals := func(rows []SumBalancesByCurrencyRow) map[string]int64 {
+		m := make(map[string]int64, len(rows))
+		for _, row := range rows {
+			m[row.Currency] = row.TotalBalance
+		}
+		return m
+	}
+	currency := fmt.Sprint(account.Currency)
+	require.Equal(t, totals(before)[currency]+account.Balance, totals(after)[currency])
 }
 
 // Helper function to create a random account
 func createRandomAccount(t *testing.T) Account {
 	arg := CreateAccountParams{
-		Owner:    randomString(6),
+		Owner:    createVerifiedUser(t, 2).Username,
 		Balance:  randomInt(0, 1000),
 		Currency: randomCurrency(),
 	}
@@ -133,6 +200,8 @@ func createRandomAccount(t *testing.T) Account {
 	require.Equal(t, arg.Balance, account.Balance)
 	require.Equal(t, arg.Currency, account.Currency)
 
+	require.Equal(t, AccountStatusActive, account.Status)
+
 	require.NotZero(t, account.ID)
 	require.NotZero(t, account.CreatedAt)
 
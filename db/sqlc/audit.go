@@ -0,0 +1,216 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrAuditChainBroken means an audit event was changed, removed or inserted
+// after it was written
+var ErrAuditChainBroken = errors.New("audit chain is broken")
+
+// auditVerifyBatchSize is the number of events VerifyAuditChain reads at once
+const auditVerifyBatchSize = 1000
+
+// auditPIIFields are the keys of personal data. The audit log cannot be
+// erased, so their values are redacted wherever they appear in a state.
+var auditPIIFields = map[string]bool{
+	"full_name":       true,
+	"email":           true,
+	"legal_name":      true,
+	"date_of_birth":   true,
+	"address":         true,
+	"document_number": true,
+}
+
+// auditRedacted replaces the value of a redacted field
+const auditRedacted = "[redacted]"
+
+type AppendAuditEventTxParams struct {
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Target string `json:"target"`
+	// Before and After are JSON documents; empty means null
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	RequestID string          `json:"request_id"`
+	IP        string          `json:"ip"`
+}
+
+// AppendAuditEventTx adds an event to the end of the audit chain, with the
+// personal data in its states redacted. The table is locked until the
+// transaction ends, so concurrent appends chain one after the other.
+func (store *SQLStore) AppendAuditEventTx(ctx context.Context, arg AppendAuditEventTxParams) (AuditEvent, error) {
+	var event AuditEvent
+
+	var err error
+	if arg.Before, err = redactAuditState(arg.Before); err != nil {
+		return event, err
+	}
+	if arg.After, err = redactAuditState(arg.After); err != nil {
+		return event, err
+	}
+
+	err = store.execTx(ctx, "AppendAuditEventTx", func(q *Queries) error {
+		if err := q.LockAuditEvents(ctx); err != nil {
+			return err
+		}
+
+		prevHash := ""
+		last, err := q.GetLastAuditEvent(ctx)
+		switch {
+		case err == nil:
+			prevHash = last.Hash
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+
+		event = AuditEvent{
+			Actor:     arg.Actor,
+			Action:    arg.Action,
+			Target:    arg.Target,
+			Before:    jsonOrNull(arg.Before),
+			After:     jsonOrNull(arg.After),
+			RequestID: arg.RequestID,
+			Ip:        arg.IP,
+			// Postgres keeps microseconds, and the hash must match what is read back
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+			PrevHash:  prevHash,
+		}
+		event.Hash = AuditEventHash(event)
+
+		event, err = q.CreateAuditEvent(ctx, CreateAuditEventParams{
+			Actor:     event.Actor,
+			Action:    event.Action,
+			Target:    event.Target,
+			Before:    event.Before,
+			After:     event.After,
+			RequestID: event.RequestID,
+			Ip:        event.Ip,
+			CreatedAt: event.CreatedAt,
+			PrevHash:  event.PrevHash,
+			Hash:      event.Hash,
+		})
+		return err
+	})
+	return event, err
+}
+
+// AuditChainStatus summarizes a verified audit chain
+type AuditChainStatus struct {
+	Events   int64  `json:"events"`
+	LastID   int64  `json:"last_id"`
+	LastHash string `json:"last_hash"`
+}
+
+// VerifyAuditChain recomputes the hash of every audit event and checks that
+// each one refers to its predecessor. A mismatch returns an error wrapping
+// ErrAuditChainBroken that names the first bad event, along with the status
+// of the chain up to it.
+func (store *SQLStore) VerifyAuditChain(ctx context.Context) (AuditChainStatus, error) {
+	var status AuditChainStatus
+
+	for {
+		events, err := store.ListAuditEventsAfter(ctx, ListAuditEventsAfterParams{
+			ID:    status.LastID,
+			Limit: auditVerifyBatchSize,
+		})
+		if err != nil {
+			return status, err
+		}
+
+		for _, event := range events {
+			if event.PrevHash != status.LastHash {
+				return status, fmt.Errorf("%w: event %d does not follow event %d", ErrAuditChainBroken, event.ID, status.LastID)
+			}
+			if AuditEventHash(event) != event.Hash {
+				return status, fmt.Errorf("%w: event %d was modified", ErrAuditChainBroken, event.ID)
+			}
+			status.Events++
+			status.LastID = event.ID
+			status.LastHash = event.Hash
+		}
+
+		if len(events) < auditVerifyBatchSize {
+			return status, nil
+		}
+	}
+}
+
+// AuditEventHash is the hex encoded SHA-256 of the JSON array of the
+// previous hash and the columns of event, apart from its id and own hash
+func AuditEventHash(event AuditEvent) string {
+	// a JSON array of strings cannot be ambiguous, unlike a plain
+	// concatenation of the fields
+	data, _ := json.Marshal([]string{
+		event.PrevHash,
+		event.Actor,
+		event.Action,
+		event.Target,
+		string(event.Before),
+		string(event.After),
+		event.RequestID,
+		event.Ip,
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactAuditState replaces the values of auditPIIFields at any depth of
+// the JSON state. A state without personal data is returned as written.
+func redactAuditState(state json.RawMessage) (json.RawMessage, error) {
+	if len(state) == 0 {
+		return state, nil
+	}
+
+	// numbers stay as written, int64 ids would not survive a float64
+	decoder := json.NewDecoder(bytes.NewReader(state))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid audit state: %w", err)
+	}
+	if !redactPII(value) {
+		return state, nil
+	}
+	return json.Marshal(value)
+}
+
+// redactPII redacts the personal data in a decoded JSON value in place and
+// reports whether there was any
+func redactPII(value any) bool {
+	redacted := false
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if !auditPIIFields[key] {
+				redacted = redactPII(field) || redacted
+				continue
+			}
+			if field != nil && field != "" {
+				v[key] = auditRedacted
+				redacted = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			redacted = redactPII(item) || redacted
+		}
+	}
+	return redacted
+}
+
+func jsonOrNull(data json.RawMessage) json.RawMessage {
+	if len(data) == 0 {
+		return json.RawMessage("null")
+	}
+	return data
+}
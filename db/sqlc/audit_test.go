@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func appendRandomAuditEvent(t *testing.T, store Store) AuditEvent {
+	arg := AppendAuditEventTxParams{
+		Actor:     randomString(8),
+		Action:    "account.updated",
+		Target:    "account:" + randomString(4),
+		Before:    json.RawMessage(`{"status": "active"}`),
+		After:     json.RawMessage(`{"status": "frozen"}`),
+		RequestID: randomString(16),
+		IP:        "127.0.0.1",
+	}
+
+	event, err := store.AppendAuditEventTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, event.ID)
+	require.Equal(t, arg.Actor, event.Actor)
+	require.Equal(t, arg.Target, event.Target)
+	// json columns keep the text as written, whitespace included
+	require.Equal(t, string(arg.Before), string(event.Before))
+	require.Equal(t, arg.RequestID, event.RequestID)
+	require.WithinDuration(t, time.Now(), event.CreatedAt, time.Second)
+	require.Equal(t, AuditEventHash(event), event.Hash)
+	return event
+}
+
+func TestAppendAuditEventTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	event1 := appendRandomAuditEvent(t, store)
+	event2 := appendRandomAuditEvent(t, store)
+	require.Equal(t, event1.Hash, event2.PrevHash)
+
+	// a missing state is stored as null
+	event3, err := store.AppendAuditEventTx(context.Background(), AppendAuditEventTxParams{
+		Actor:  "anonymous",
+		Action: "user.created",
+		Target: "user:" + randomString(8),
+		After:  json.RawMessage(`{}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "null", string(event3.Before))
+	require.Equal(t, event2.Hash, event3.PrevHash)
+
+	// the stored row hashes to the same value
+	last, err := testQueries.GetLastAuditEvent(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, event3.Hash, AuditEventHash(last))
+}
+
+func TestAppendAuditEventTxRedactsPII(t *testing.T) {
+	store := NewStore(testDB)
+	email := randomString(8) + "@example.com"
+
+	event, err := store.AppendAuditEventTx(context.Background(), AppendAuditEventTxParams{
+		Actor:  "admin",
+		Action: "user.updated",
+		Target: "user:" + randomString(8),
+		Before: json.RawMessage(`{"username":"alice","email":"` + email + `"}`),
+		After:  json.RawMessage(`{"username":"alice","profile":{"full_name":"Alice Smith"}}`),
+	})
+	require.NoError(t, err)
+
+	stored, err := testQueries.GetLastAuditEvent(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, event.ID, stored.ID)
+	require.NotContains(t, string(stored.Before), email)
+	require.NotContains(t, string(stored.After), "Alice Smith")
+	require.Contains(t, string(stored.Before), `"username":"alice"`)
+	require.Equal(t, AuditEventHash(stored), stored.Hash)
+}
+
+func TestRedactAuditState(t *testing.T) {
+	state, err := redactAuditState(json.RawMessage(`{"id": 9007199254740993, "email": "alice@example.com", ` +
+		`"users": [{"full_name": "Alice Smith", "email_verified": true}], "address": ""}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": 9007199254740993, "email": "[redacted]", `+
+		`"users": [{"full_name": "[redacted]", "email_verified": true}], "address": ""}`, string(state))
+	require.Contains(t, string(state), "9007199254740993")
+
+	// states without personal data keep their text
+	state, err = redactAuditState(json.RawMessage(`{"status": "active"}`))
+	require.NoError(t, err)
+	require.Equal(t, `{"status": "active"}`, string(state))
+
+	state, err = redactAuditState(nil)
+	require.NoError(t, err)
+	require.Nil(t, state)
+
+	_, err = redactAuditState(json.RawMessage(`{`))
+	require.Error(t, err)
+}
+
+func TestAppendAuditEventTxConcurrent(t *testing.T) {
+	store := NewStore(testDB)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.AppendAuditEventTx(context.Background(), AppendAuditEventTxParams{
+				Actor:  randomString(8),
+				Action: "transfer.created",
+				Target: "transfer:1",
+			})
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	status, err := store.VerifyAuditChain(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, status.Events, int64(10))
+
+	last, err := testQueries.GetLastAuditEvent(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, last.ID, status.LastID)
+	require.Equal(t, last.Hash, status.LastHash)
+}
+
+func TestAuditEventsAppendOnly(t *testing.T) {
+	event := appendRandomAuditEvent(t, NewStore(testDB))
+
+	_, err := testDB.Exec("UPDATE audit_events SET actor = 'mallory' WHERE id = $1", event.ID)
+	require.ErrorContains(t, err, "append-only")
+
+	_, err = testDB.Exec("DELETE FROM audit_events WHERE id = $1", event.ID)
+	require.ErrorContains(t, err, "append-only")
+}
+
+func TestListAuditEvents(t *testing.T) {
+	store := NewStore(testDB)
+	event := appendRandomAuditEvent(t, store)
+	appendRandomAuditEvent(t, store)
+
+	events, err := testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor: sql.NullString{String: event.Actor, Valid: true},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, event.ID, events[0].ID)
+
+	events, err = testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Target: sql.NullString{String: event.Target, Valid: true},
+		Since:  sql.NullTime{Time: event.CreatedAt.Add(time.Second), Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestAuditEventHash(t *testing.T) {
+	event := AuditEvent{
+		Actor:     "alice",
+		Action:    "account.updated",
+		Target:    "account:1",
+		Before:    json.RawMessage(`{"status":"active"}`),
+		After:     json.RawMessage(`{"status":"frozen"}`),
+		CreatedAt: time.Now(),
+		PrevHash:  "abc",
+	}
+	hash := AuditEventHash(event)
+	require.Len(t, hash, 64)
+
+	// the time zone of the database session does not matter
+	local := event
+	local.CreatedAt = event.CreatedAt.In(time.FixedZone("UTC+7", 7*60*60))
+	require.Equal(t, hash, AuditEventHash(local))
+
+	for _, tamper := range []func(*AuditEvent){
+		func(e *AuditEvent) { e.PrevHash = "abd" },
+		func(e *AuditEvent) { e.Actor = "mallory" },
+		func(e *AuditEvent) { e.After = json.RawMessage(`{"status":"active"}`) },
+		func(e *AuditEvent) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) },
+		// moving text between fields is not a collision
+		func(e *AuditEvent) { e.Actor, e.Action = "aliceaccount", ".updated" },
+	} {
+		changed := event
+		tamper(&changed)
+		require.NotEqual(t, hash, AuditEventHash(changed))
+	}
+}
@@ -99,6 +99,129 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 	return items, nil
 }
 
+const listEntriesByAccount = `-- name: ListEntriesByAccount :many
+SELECT id, account_id, amount, created_at FROM entries
+WHERE account_id = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3
+`
+
+type ListEntriesByAccountParams struct {
+	AccountID int64 `json:"account_id"`
+	Limit     int32 `json:"limit"`
+	Offset    int32 `json:"offset"`
+}
+
+// List the entries of one account
+func (q *Queries) ListEntriesByAccount(ctx context.Context, arg ListEntriesByAccountParams) ([]Entry, error) {
+	rows, err := q.db.QueryContext(ctx, listEntriesByAccount, arg.AccountID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEntriesByOwner = `-- name: ListEntriesByOwner :many
+SELECT e.id, e.account_id, e.amount, e.created_at FROM entries e
+JOIN account a ON a.id = e.account_id
+WHERE a.owner = $1
+ORDER BY e.id
+`
+
+// List the entries of all accounts of a user
+func (q *Queries) ListEntriesByOwner(ctx context.Context, owner string) ([]Entry, error) {
+	rows, err := q.db.QueryContext(ctx, listEntriesByOwner, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOwnerEntries = `-- name: ListOwnerEntries :many
+SELECT e.id, e.account_id, e.amount, e.created_at FROM entries e
+JOIN account a ON a.id = e.account_id
+WHERE a.owner = $1
+ORDER BY e.id
+LIMIT $2
+OFFSET $3
+`
+
+type ListOwnerEntriesParams struct {
+	Owner  string `json:"owner"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+// List a page of the entries of all accounts of a user
+func (q *Queries) ListOwnerEntries(ctx context.Context, arg ListOwnerEntriesParams) ([]Entry, error) {
+	rows, err := q.db.QueryContext(ctx, listOwnerEntries, arg.Owner, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateEntries = `-- name: UpdateEntries :one
 UPDATE entries
 SET amount = $2
@@ -53,6 +53,50 @@ func TestListEntries(t *testing.T) {
 	}
 }
 
+func TestListEntriesByAccount(t *testing.T) {
+	account := createRandomAccount(t)
+	other := createRandomAccount(t)
+
+	for i := 0; i < 6; i++ {
+		createRandomEntry(t, account)
+		createRandomEntry(t, other)
+	}
+
+	entries, err := testQueries.ListEntriesByAccount(context.Background(), ListEntriesByAccountParams{
+		AccountID: account.ID,
+		Limit:     5,
+		Offset:    1,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+
+	for _, entry := range entries {
+		require.Equal(t, account.ID, entry.AccountID)
+	}
+}
+
+func TestListOwnerEntries(t *testing.T) {
+	account := createRandomAccount(t)
+	other := createRandomAccount(t)
+
+	for i := 0; i < 6; i++ {
+		createRandomEntry(t, account)
+		createRandomEntry(t, other)
+	}
+
+	entries, err := testQueries.ListOwnerEntries(context.Background(), ListOwnerEntriesParams{
+		Owner:  account.Owner,
+		Limit:  5,
+		Offset: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+
+	for _, entry := range entries {
+		require.Equal(t, account.ID, entry.AccountID)
+	}
+}
+
 func TestUpdateEntry(t *testing.T) {
 	account := createRandomAccount(t)
 	entry1 := createRandomEntry(t, account)
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// KYC profile statuses. Only a verified profile lifts its user out of
+// tier 0.
+const (
+	KYCStatusPending  = "pending"
+	KYCStatusVerified = "verified"
+	KYCStatusRejected = "rejected"
+)
+
+// Identity documents accepted for a KYC profile
+const (
+	KYCDocumentPassport       = "passport"
+	KYCDocumentNationalID     = "national_id"
+	KYCDocumentDrivingLicence = "driving_licence"
+)
+
+var (
+	ErrTransferLimitExceeded = errors.New("transfer amount exceeds the limit of the KYC tier")
+	ErrBalanceLimitExceeded  = errors.New("balance would exceed the limit of the KYC tier")
+)
+
+// checkKYCLimits fails when a transfer is larger than the tier of the
+// sending owner allows, or leaves more money in the receiving account than
+// the tier of its owner allows. It is given the result of moveMoney, whose
+// balance updates hold the row locks, so concurrent transfers cannot push
+// a balance past the limit together.
+func checkKYCLimits(ctx context.Context, q *Queries, result TransferTxResult) error {
+	fromTier, err := q.GetKYCTierByAccount(ctx, result.FromAccount.ID)
+	if err != nil {
+		return err
+	}
+	if result.Transfer.Amount > fromTier.MaxTransferAmount {
+		return fmt.Errorf("%w: tier %d (%s) of account %d allows transfers up to %d, transfer is %d",
+			ErrTransferLimitExceeded, fromTier.Tier, fromTier.Name, result.FromAccount.ID,
+			fromTier.MaxTransferAmount, result.Transfer.Amount)
+	}
+
+	toTier, err := q.GetKYCTierByAccount(ctx, result.ToAccount.ID)
+	if err != nil {
+		return err
+	}
+	if result.ToAccount.Balance > toTier.MaxBalance {
+		return fmt.Errorf("%w: tier %d (%s) of account %d allows a balance up to %d, transfer leaves %d",
+			ErrBalanceLimitExceeded, toTier.Tier, toTier.Name, result.ToAccount.ID,
+			toTier.MaxBalance, result.ToAccount.Balance)
+	}
+	return nil
+}
+
+// openKYCProfile decrypts the profile returned by a query, unless the query
+// failed
+func (store *SQLStore) openKYCProfile(ctx context.Context, profile KycProfile, err error) (KycProfile, error) {
+	if err != nil {
+		return profile, err
+	}
+	for _, field := range []*string{&profile.LegalName, &profile.DateOfBirth, &profile.Address, &profile.DocumentNumber} {
+		if *field, err = store.decrypt(ctx, *field); err != nil {
+			return KycProfile{}, err
+		}
+	}
+	return profile, nil
+}
+
+func (store *SQLStore) openKYCProfiles(ctx context.Context, profiles []KycProfile, err error) ([]KycProfile, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range profiles {
+		if profiles[i], err = store.openKYCProfile(ctx, profiles[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return profiles, nil
+}
+
+// SubmitKYCProfile encrypts the personal data of the profile. The document
+// type and country stay readable for reporting.
+func (store *SQLStore) SubmitKYCProfile(ctx context.Context, arg SubmitKYCProfileParams) (KycProfile, error) {
+	var err error
+	for _, field := range []*string{&arg.LegalName, &arg.DateOfBirth, &arg.Address, &arg.DocumentNumber} {
+		if *field, err = store.encrypt(ctx, *field); err != nil {
+			return KycProfile{}, err
+		}
+	}
+	profile, err := store.Queries.SubmitKYCProfile(ctx, arg)
+	return store.openKYCProfile(ctx, profile, err)
+}
+
+func (store *SQLStore) GetKYCProfile(ctx context.Context, username string) (KycProfile, error) {
+	profile, err := store.Queries.GetKYCProfile(ctx, username)
+	return store.openKYCProfile(ctx, profile, err)
+}
+
+func (store *SQLStore) ListKYCProfiles(ctx context.Context, arg ListKYCProfilesParams) ([]KycProfile, error) {
+	profiles, err := store.Queries.ListKYCProfiles(ctx, arg)
+	return store.openKYCProfiles(ctx, profiles, err)
+}
+
+func (store *SQLStore) ListKYCProfilesAfter(ctx context.Context, arg ListKYCProfilesAfterParams) ([]KycProfile, error) {
+	profiles, err := store.Queries.ListKYCProfilesAfter(ctx, arg)
+	return store.openKYCProfiles(ctx, profiles, err)
+}
+
+func (store *SQLStore) ReviewKYCProfile(ctx context.Context, arg ReviewKYCProfileParams) (KycProfile, error) {
+	profile, err := store.Queries.ReviewKYCProfile(ctx, arg)
+	return store.openKYCProfile(ctx, profile, err)
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/hiiamanop/simple_bank/pii"
+	"github.com/stretchr/testify/require"
+)
+
+func submitRandomKYCProfile(t *testing.T, user User) KycProfile {
+	arg := SubmitKYCProfileParams{
+		Username:        user.Username,
+		LegalName:       randomString(6) + " " + randomString(8),
+		DateOfBirth:     "1990-01-31",
+		Address:         randomString(10) + " Street 1, Berlin",
+		DocumentType:    KYCDocumentPassport,
+		DocumentNumber:  randomString(9),
+		DocumentCountry: "DE",
+	}
+
+	profile, err := testStore.SubmitKYCProfile(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.LegalName, profile.LegalName)
+	require.Equal(t, arg.DocumentNumber, profile.DocumentNumber)
+	require.Equal(t, KYCStatusPending, profile.Status)
+	require.Zero(t, profile.Tier)
+	require.False(t, profile.ReviewedAt.Valid)
+	return profile
+}
+
+// createVerifiedUser creates a user whose KYC profile is verified for tier
+func createVerifiedUser(t *testing.T, tier int32) User {
+	user := createRandomUser(t)
+	submitRandomKYCProfile(t, user)
+	_, err := testStore.ReviewKYCProfile(context.Background(), ReviewKYCProfileParams{
+		Status:     KYCStatusVerified,
+		Tier:       tier,
+		ReviewedBy: "reviewer",
+		Username:   user.Username,
+	})
+	require.NoError(t, err)
+	return user
+}
+
+func TestKYCProfileEncrypted(t *testing.T) {
+	user := createRandomUser(t)
+	profile := submitRandomKYCProfile(t, user)
+
+	row, err := testQueries.GetKYCProfile(context.Background(), user.Username)
+	require.NoError(t, err)
+	for _, value := range []string{row.LegalName, row.DateOfBirth, row.Address, row.DocumentNumber} {
+		require.True(t, pii.IsEncrypted(value))
+	}
+	require.Equal(t, KYCDocumentPassport, row.DocumentType)
+
+	found, err := testStore.GetKYCProfile(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, profile, found)
+}
+
+func TestReviewKYCProfile(t *testing.T) {
+	user := createRandomUser(t)
+	submitRandomKYCProfile(t, user)
+
+	arg := ReviewKYCProfileParams{
+		Status:     KYCStatusVerified,
+		Tier:       1,
+		ReviewNote: "passport checked",
+		ReviewedBy: "reviewer",
+		Username:   user.Username,
+	}
+	profile, err := testStore.ReviewKYCProfile(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, KYCStatusVerified, profile.Status)
+	require.Equal(t, int32(1), profile.Tier)
+	require.Equal(t, "reviewer", profile.ReviewedBy)
+	require.True(t, profile.ReviewedAt.Valid)
+
+	// only pending profiles are reviewed
+	_, err = testStore.ReviewKYCProfile(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a changed profile goes back to review
+	profile = submitRandomKYCProfile(t, user)
+	require.Empty(t, profile.ReviewedBy)
+
+	pending, err := testStore.ListKYCProfiles(context.Background(), ListKYCProfilesParams{
+		Status: sql.NullString{String: KYCStatusPending, Valid: true},
+		Limit:  1000,
+	})
+	require.NoError(t, err)
+	require.Contains(t, pending, profile)
+	for _, p := range pending {
+		require.Equal(t, KYCStatusPending, p.Status)
+	}
+}
+
+func TestGetKYCTierByAccount(t *testing.T) {
+	account := createRandomAccount(t)
+	tier, err := testQueries.GetKYCTierByAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), tier.Tier)
+
+	// a pending or rejected profile leaves the owner in tier 0
+	user := createRandomUser(t)
+	submitRandomKYCProfile(t, user)
+	pending, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Currency: "USD",
+	})
+	require.NoError(t, err)
+	tier, err = testQueries.GetKYCTierByAccount(context.Background(), pending.ID)
+	require.NoError(t, err)
+	require.Zero(t, tier.Tier)
+	require.Zero(t, tier.MaxTransferAmount)
+}
+
+func TestTransferTxKYCLimits(t *testing.T) {
+	store := NewStore(testDB)
+	basic := createVerifiedUser(t, 1)
+	tier1, err := testQueries.GetKYCTier(context.Background(), 1)
+	require.NoError(t, err)
+
+	createAccount := func(owner string, balance int64) Account {
+		account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+			Owner:    owner,
+			Balance:  balance,
+			Currency: "USD",
+		})
+		require.NoError(t, err)
+		return account
+	}
+	from := createAccount(basic.Username, tier1.MaxTransferAmount*2)
+	to := createAccountWithBalance(t, "USD", 0)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        tier1.MaxTransferAmount + 1,
+	})
+	require.ErrorIs(t, err, ErrTransferLimitExceeded)
+
+	// the receiving account may not go past the balance limit
+	full := createAccount(basic.Username, tier1.MaxBalance)
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   full.ID,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, ErrBalanceLimitExceeded)
+
+	// an unverified owner cannot send money
+	unverified := createAccount(createRandomUser(t).Username, 100)
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: unverified.ID,
+		ToAccountID:   to.ID,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, ErrTransferLimitExceeded)
+
+	// nothing was booked
+	for _, account := range []Account{from, to, full, unverified} {
+		after, err := testQueries.GetAccount(context.Background(), account.ID)
+		require.NoError(t, err)
+		require.Equal(t, account.Balance, after.Balance)
+	}
+}
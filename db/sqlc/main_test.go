@@ -7,6 +7,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/hiiamanop/simple_bank/pii"
 	"github.com/hiiamanop/simple_bank/util"
 	_ "github.com/lib/pq"
 )
@@ -14,6 +15,10 @@ import (
 var testQueries *Queries
 var testDB *sql.DB
 
+// testStore encrypts personal data with testKeys, generated for the test run
+var testStore Store
+var testKeys *pii.KeyFile
+
 func TestMain(m *testing.M) {
 	var err error // Declare err first
 
@@ -28,5 +33,11 @@ func TestMain(m *testing.M) {
 	}
 
 	testQueries = New(testDB)
+
+	testKeys, err = pii.NewKeyFile()
+	if err != nil {
+		log.Fatal("cannot generate PII keys:", err)
+	}
+	testStore = NewStore(testDB, WithCipher(pii.NewCipher(testKeys)))
 	os.Exit(m.Run())
 }
@@ -5,6 +5,8 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 )
 
@@ -14,6 +16,43 @@ type Account struct {
 	Balance   int64       `json:"balance"`
 	Currency  interface{} `json:"currency"`
 	CreatedAt time.Time   `json:"created_at"`
+	// active, frozen, dormant or closed; only active accounts send or receive money
+	Status string `json:"status"`
+}
+
+type AccountApprover struct {
+	AccountID int64     `json:"account_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ApprovalPolicy struct {
+	AccountID int64 `json:"account_id"`
+	// transfers of a larger amount from the account need approval
+	Threshold int64 `json:"threshold"`
+	// number of approvers who must approve a transfer
+	RequiredApprovals int32     `json:"required_approvals"`
+	UpdatedBy         string    `json:"updated_by"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type AuditEvent struct {
+	ID int64 `json:"id"`
+	// username of the caller, anonymous or cli:<os user>
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Target string `json:"target"`
+	// state of the target before the change, json rather than jsonb to keep the hashed text
+	Before json.RawMessage `json:"before"`
+	// state of the target after the change
+	After     json.RawMessage `json:"after"`
+	RequestID string          `json:"request_id"`
+	Ip        string          `json:"ip"`
+	CreatedAt time.Time       `json:"created_at"`
+	// hash of the previous event, empty for the first one
+	PrevHash string `json:"prev_hash"`
+	// SHA-256 over prev_hash and the other columns, hex encoded
+	Hash string `json:"hash"`
 }
 
 type Entry struct {
@@ -24,6 +63,108 @@ type Entry struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type KycProfile struct {
+	Username string `json:"username"`
+	// encrypted, see package pii
+	LegalName string `json:"legal_name"`
+	// encrypted, YYYY-MM-DD
+	DateOfBirth string `json:"date_of_birth"`
+	// encrypted, see package pii
+	Address string `json:"address"`
+	// passport, national_id or driving_licence; legacy for customers verified before KYC
+	DocumentType string `json:"document_type"`
+	// encrypted, see package pii
+	DocumentNumber string `json:"document_number"`
+	// ISO 3166-1 alpha-2 code of the issuing country
+	DocumentCountry string `json:"document_country"`
+	// pending, verified or rejected
+	Status string `json:"status"`
+	// tier granted by the reviewer, 0 unless verified
+	Tier       int32  `json:"tier"`
+	ReviewNote string `json:"review_note"`
+	// username of the reviewer, empty while pending
+	ReviewedBy  string       `json:"reviewed_by"`
+	ReviewedAt  sql.NullTime `json:"reviewed_at"`
+	SubmittedAt time.Time    `json:"submitted_at"`
+}
+
+type KycTier struct {
+	Tier int32  `json:"tier"`
+	Name string `json:"name"`
+	// highest balance a transfer may leave in a receiving account
+	MaxBalance int64 `json:"max_balance"`
+	// largest amount a single transfer may send
+	MaxTransferAmount int64 `json:"max_transfer_amount"`
+}
+
+type MonitoringAlert struct {
+	ID int64 `json:"id"`
+	// name of the rule in the monitoring rules file
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	// why the rule matched
+	Reason string `json:"reason"`
+	// flagged transfer; a held transfer stays held until the alert is released or rejected
+	TransferID    sql.NullInt64 `json:"transfer_id"`
+	FromAccountID int64         `json:"from_account_id"`
+	ToAccountID   int64         `json:"to_account_id"`
+	// amount of the transfer, kept for held transfers that were not executed
+	Amount int64 `json:"amount"`
+	// open, investigating, dismissed or reported; held, released or rejected for blocked transfers
+	Status string `json:"status"`
+	// username of the analyst working on the alert
+	AssignedTo     string `json:"assigned_to"`
+	ResolutionNote string `json:"resolution_note"`
+	// username of the analyst who closed the alert
+	ResolvedBy string       `json:"resolved_by"`
+	ResolvedAt sql.NullTime `json:"resolved_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type RateLimitBucket struct {
+	Key    string  `json:"key"`
+	Tokens float64 `json:"tokens"`
+	// outcome of the last request, returned by the upsert
+	Allowed   bool      `json:"allowed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RecoveryCode struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// SHA-256 of the code, hex encoded
+	HashedCode string       `json:"hashed_code"`
+	UsedAt     sql.NullTime `json:"used_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type ScreeningHit struct {
+	ID int64 `json:"id"`
+	// what the screening blocked: onboarding or transfer
+	Action string `json:"action"`
+	// user whose name was screened; at onboarding the requested username, which may not exist
+	Username string `json:"username"`
+	// screened name, encrypted
+	Name string `json:"name"`
+	// blind index of the normalised name
+	NameIndex string `json:"name_index"`
+	// ofac_sdn or eu_consolidated
+	List string `json:"list"`
+	// identifier of the entry within the list
+	EntryID string `json:"entry_id"`
+	// name or alias of the entry that matched
+	EntryName string `json:"entry_name"`
+	Program   string `json:"program"`
+	// similarity of the names in [0, 1]
+	Score float64 `json:"score"`
+	// pending until a reviewer clears the hit as a false positive or confirms it
+	Status     string       `json:"status"`
+	ReviewNote string       `json:"review_note"`
+	ReviewedBy string       `json:"reviewed_by"`
+	ReviewedAt sql.NullTime `json:"reviewed_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
 type Transfer struct {
 	ID            int64 `json:"id"`
 	FromAccountID int64 `json:"from_account_id"`
@@ -31,13 +172,114 @@ type Transfer struct {
 	// must be positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// pending or held, processing, then completed or failed; completed transfers can be reversed, pending ones cancelled and held ones rejected
+	Status string `json:"status"`
+	// error code of a failed transfer
+	FailureReason string `json:"failure_reason"`
+	// time of the last status change
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TransferApproval struct {
+	ID            int64 `json:"id"`
+	FromAccountID int64 `json:"from_account_id"`
+	ToAccountID   int64 `json:"to_account_id"`
+	Amount        int64 `json:"amount"`
+	// username of the maker, who cannot approve the transfer
+	InitiatedBy string `json:"initiated_by"`
+	// approvals the policy required when the transfer was requested
+	RequiredApprovals int32 `json:"required_approvals"`
+	Approvals         int32 `json:"approvals"`
+	// pending_approval, then approved and executed or failed; rejected or expired without moving money
+	Status string `json:"status"`
+	// the transfer, pending until the final approval executes it
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	// error code of the transfer that failed after the final approval
+	FailureReason string    `json:"failure_reason"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	// time of the final approval, the rejection or the expiry
+	DecidedAt sql.NullTime `json:"decided_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type TransferApprovalDecision struct {
+	ApprovalID int64     `json:"approval_id"`
+	Approver   string    `json:"approver"`
+	Decision   string    `json:"decision"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TransferEvent struct {
+	ID         int64 `json:"id"`
+	TransferID int64 `json:"transfer_id"`
+	// status the transfer entered
+	Status string `json:"status"`
+	// error code of a failure, rule that held the transfer, or why it was cancelled
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TransferLimit struct {
+	// account or user; user limits count the transfers from all accounts of the user
+	Scope string `json:"scope"`
+	// account id or username, empty for the default of the scope
+	Subject string `json:"subject"`
+	// largest single transfer; NULL inherits the default, or means no limit in the default
+	MaxAmount sql.NullInt64 `json:"max_amount"`
+	// total sent in the last 24 hours
+	DailyAmount sql.NullInt64 `json:"daily_amount"`
+	// total sent in the last 30 days
+	MonthlyAmount sql.NullInt64 `json:"monthly_amount"`
+	// number of transfers sent in the last hour
+	HourlyCount sql.NullInt32 `json:"hourly_count"`
+	Note        string        `json:"note"`
+	UpdatedBy   string        `json:"updated_by"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+type TransferReversal struct {
+	TransferID int64     `json:"transfer_id"`
+	ReversalID int64     `json:"reversal_id"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type User struct {
-	Username          string    `json:"username"`
-	HashedPassword    string    `json:"hashed_password"`
-	FullName          string    `json:"full_name"`
+	Username       string `json:"username"`
+	HashedPassword string `json:"hashed_password"`
+	// encrypted, see package pii
+	FullName string `json:"full_name"`
+	// encrypted, see package pii
 	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	// depositor or admin
+	Role string `json:"role"`
+	// consecutive failed logins since the last successful one
+	FailedLoginAttempts int32     `json:"failed_login_attempts"`
+	LockedUntil         time.Time `json:"locked_until"`
+	// base32 TOTP secret, set on enrolment and in use once totp_enabled
+	TotpSecret      string `json:"totp_secret"`
+	TotpEnabled     bool   `json:"totp_enabled"`
+	IsEmailVerified bool   `json:"is_email_verified"`
+	// set when the user is deleted; the row stays for the ledger
+	DeletedAt sql.NullTime `json:"deleted_at"`
+	// set when the personal data of a deleted user was pseudonymised
+	ErasedAt sql.NullTime `json:"erased_at"`
+	// blind index of the email, HMAC-SHA256 hex encoded
+	EmailIndex string `json:"email_index"`
+}
+
+type VerifyEmail struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// encrypted address the token was sent to
+	Email string `json:"email"`
+	// email_verification or password_reset
+	Purpose string `json:"purpose"`
+	// SHA-256 of the token, hex encoded
+	HashedToken string       `json:"hashed_token"`
+	UsedAt      sql.NullTime `json:"used_at"`
+	ExpiredAt   time.Time    `json:"expired_at"`
+	CreatedAt   time.Time    `json:"created_at"`
 }
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hiiamanop/simple_bank/monitoring"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Monitoring alert statuses. Alerts of executed transfers are open until an
+// analyst dismisses or reports them; alerts of held transfers are held, as
+// is their transfer, until the transfer is released or rejected.
+const (
+	AlertStatusOpen          = "open"
+	AlertStatusInvestigating = "investigating"
+	AlertStatusDismissed     = "dismissed"
+	AlertStatusReported      = "reported"
+	AlertStatusHeld          = "held"
+	AlertStatusReleased      = "released"
+	AlertStatusRejected      = "rejected"
+)
+
+var (
+	ErrTransferHeld = errors.New("transfer is held for review")
+	ErrAlertNotHeld = errors.New("alert is not a held transfer")
+)
+
+// WithMonitor evaluates every transfer made by TransferTx against the rules
+// of engine. Matches are written to monitoring_alerts with the transfer,
+// and a match of a blocking rule holds the transfer instead.
+func WithMonitor(engine *monitoring.Engine) StoreOption {
+	return func(store *SQLStore) {
+		store.monitor = engine
+	}
+}
+
+// transferHistory answers the questions of the monitoring rules within the
+// transaction of the transfer
+type transferHistory struct {
+	q *Queries
+}
+
+func (h transferHistory) CountSent(ctx context.Context, accountID int64, since time.Time, minAmount, maxAmount int64) (int64, error) {
+	return h.q.CountTransfersSentInRange(ctx, CountTransfersSentInRangeParams{
+		AccountID: accountID,
+		Since:     since,
+		MinAmount: minAmount,
+		MaxAmount: maxAmount,
+	})
+}
+
+func (h transferHistory) SumReceived(ctx context.Context, accountID int64, since time.Time) (int64, error) {
+	return h.q.SumTransfersReceivedSince(ctx, SumTransfersReceivedSinceParams{
+		AccountID: accountID,
+		Since:     since,
+	})
+}
+
+// monitorTransfer evaluates the monitoring rules on the transfer of result.
+// It writes an alert for every match, unless a blocking rule matched, in
+// which case it returns the matches to hold the transfer with.
+func (store *SQLStore) monitorTransfer(ctx context.Context, q *Queries, result TransferTxResult) ([]MonitoringAlert, []monitoring.Hit, error) {
+	if store.monitor == nil {
+		return nil, nil, nil
+	}
+
+	hits, err := store.monitor.Evaluate(ctx, transferHistory{q}, monitoring.Transfer{
+		ID:                 result.Transfer.ID,
+		FromAccountID:      result.Transfer.FromAccountID,
+		ToAccountID:        result.Transfer.ToAccountID,
+		Amount:             result.Transfer.Amount,
+		Currency:           fmt.Sprint(result.FromAccount.Currency),
+		CreatedAt:          result.Transfer.CreatedAt,
+		ToAccountCreatedAt: result.ToAccount.CreatedAt,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if monitoring.Blocking(hits) {
+		return nil, hits, nil
+	}
+
+	var alerts []MonitoringAlert
+	for _, hit := range hits {
+		alert, err := q.CreateMonitoringAlert(ctx, CreateMonitoringAlertParams{
+			Rule:          hit.Rule,
+			Severity:      hit.Severity,
+			Reason:        hit.Reason,
+			TransferID:    sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+			FromAccountID: result.Transfer.FromAccountID,
+			ToAccountID:   result.Transfer.ToAccountID,
+			Amount:        result.Transfer.Amount,
+			Status:        AlertStatusOpen,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil, nil
+}
+
+// holdTransfer books a transfer that matched a blocking rule and was rolled
+// back as held, and writes its alert. The pending transfer of an approval is
+// held instead of a new one. The alert names the first blocking rule and
+// gives the reasons of all matches.
+func (store *SQLStore) holdTransfer(ctx context.Context, arg TransferTxParams, hits []monitoring.Hit) (TransferTxResult, error) {
+	var rule monitoring.Hit
+	reasons := make([]string, len(hits))
+	for i, hit := range hits {
+		if hit.Block && rule.Rule == "" {
+			rule = hit
+		}
+		reasons[i] = hit.Rule + ": " + hit.Reason
+	}
+
+	var result TransferTxResult
+
+	err := store.execTx(ctx, "TransferTx", func(q *Queries) error {
+		transfer, err := heldTransferOf(ctx, q, arg)
+		if err != nil {
+			return err
+		}
+		result.Transfer, err = changeTransferStatus(ctx, q, transfer, TransferHold, rule.Rule)
+		if err != nil {
+			return err
+		}
+
+		alert, err := q.CreateMonitoringAlert(ctx, CreateMonitoringAlertParams{
+			Rule:          rule.Rule,
+			Severity:      rule.Severity,
+			Reason:        strings.Join(reasons, "; "),
+			TransferID:    sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+			FromAccountID: arg.FromAccountID,
+			ToAccountID:   arg.ToAccountID,
+			Amount:        arg.Amount,
+			Status:        AlertStatusHeld,
+		})
+		result.Alerts = []MonitoringAlert{alert}
+		return err
+	})
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+	return result, fmt.Errorf("%w: alert %d", ErrTransferHeld, result.Alerts[0].ID)
+}
+
+// heldTransferOf returns the pending transfer to hold for arg: the transfer
+// of its approval, or a new one
+func heldTransferOf(ctx context.Context, q *Queries, arg TransferTxParams) (Transfer, error) {
+	if arg.ApprovalID == 0 {
+		return bookPendingTransfer(ctx, q, CreatePendingTransferParams{
+			FromAccountID: arg.FromAccountID,
+			ToAccountID:   arg.ToAccountID,
+			Amount:        arg.Amount,
+		})
+	}
+
+	approval, err := q.GetTransferApproval(ctx, arg.ApprovalID)
+	if err != nil {
+		return Transfer{}, err
+	}
+	return q.GetTransfers(ctx, approval.TransferID.Int64)
+}
+
+type ReleaseHeldTransferTxParams struct {
+	AlertID        int64  `json:"alert_id"`
+	ResolutionNote string `json:"resolution_note"`
+	ResolvedBy     string `json:"resolved_by"`
+}
+
+type ReleaseHeldTransferTxResult struct {
+	Alert    MonitoringAlert  `json:"alert"`
+	Transfer TransferTxResult `json:"transfer"`
+}
+
+// ReleaseHeldTransferTx executes the held transfer of an alert, which the
+// analyst has reviewed, and closes the alert as released. The accounts and
+// the limits are checked as for any transfer, but the monitoring rules are
+// not evaluated again. It fails with ErrAlertNotHeld unless the alert holds
+// a transfer, and with ErrNegativeBalance when the sender has spent the
+// money while the transfer was held.
+func (store *SQLStore) ReleaseHeldTransferTx(ctx context.Context, arg ReleaseHeldTransferTxParams) (ReleaseHeldTransferTxResult, error) {
+	ctx, span := startTxSpan(ctx, "ReleaseHeldTransferTx",
+		attribute.Int64("alert.id", arg.AlertID),
+	)
+	defer span.End()
+
+	var result ReleaseHeldTransferTxResult
+
+	err := store.execTx(ctx, "ReleaseHeldTransferTx", func(q *Queries) error {
+		transfer, err := lockHeldAlert(ctx, q, arg.AlertID)
+		if err != nil {
+			return err
+		}
+
+		result.Transfer.Transfer, err = changeTransferStatus(ctx, q, transfer, TransferRelease, "")
+		if err != nil {
+			return err
+		}
+		if err := settleTransfer(ctx, q, &result.Transfer); err != nil {
+			return err
+		}
+		result.Transfer.Transfer, err = changeTransferStatus(ctx, q, result.Transfer.Transfer, TransferComplete, "")
+		if err != nil {
+			return err
+		}
+
+		result.Alert, err = q.DecideHeldMonitoringAlert(ctx, DecideHeldMonitoringAlertParams{
+			Status:         AlertStatusReleased,
+			ResolutionNote: arg.ResolutionNote,
+			ResolvedBy:     arg.ResolvedBy,
+			ID:             arg.AlertID,
+		})
+		return err
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+type RejectHeldTransferTxParams struct {
+	AlertID        int64  `json:"alert_id"`
+	ResolutionNote string `json:"resolution_note"`
+	ResolvedBy     string `json:"resolved_by"`
+}
+
+// RejectHeldTransferTx closes an alert as rejected and cancels the transfer
+// it holds, whose money never moved. It fails with ErrAlertNotHeld unless
+// the alert holds a transfer.
+func (store *SQLStore) RejectHeldTransferTx(ctx context.Context, arg RejectHeldTransferTxParams) (MonitoringAlert, error) {
+	ctx, span := startTxSpan(ctx, "RejectHeldTransferTx",
+		attribute.Int64("alert.id", arg.AlertID),
+	)
+	defer span.End()
+
+	var alert MonitoringAlert
+
+	err := store.execTx(ctx, "RejectHeldTransferTx", func(q *Queries) error {
+		transfer, err := lockHeldAlert(ctx, q, arg.AlertID)
+		if err != nil {
+			return err
+		}
+
+		_, err = changeTransferStatus(ctx, q, transfer, TransferReject, TransferReasonMonitoringReject)
+		if err != nil {
+			return err
+		}
+
+		alert, err = q.DecideHeldMonitoringAlert(ctx, DecideHeldMonitoringAlertParams{
+			Status:         AlertStatusRejected,
+			ResolutionNote: arg.ResolutionNote,
+			ResolvedBy:     arg.ResolvedBy,
+			ID:             arg.AlertID,
+		})
+		return err
+	})
+
+	recordError(span, err)
+	return alert, err
+}
+
+// lockHeldAlert locks a held alert for its decision and returns the
+// transfer it holds
+func lockHeldAlert(ctx context.Context, q *Queries, alertID int64) (Transfer, error) {
+	alert, err := q.GetMonitoringAlertForUpdate(ctx, alertID)
+	if err != nil {
+		return Transfer{}, err
+	}
+	if alert.Status != AlertStatusHeld || !alert.TransferID.Valid {
+		return Transfer{}, fmt.Errorf("%w: alert %d is %s", ErrAlertNotHeld, alert.ID, alert.Status)
+	}
+	return q.GetTransfers(ctx, alert.TransferID.Int64)
+}
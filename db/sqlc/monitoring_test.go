@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/hiiamanop/simple_bank/monitoring"
+	"github.com/stretchr/testify/require"
+)
+
+func newMonitoredStore(t *testing.T) Store {
+	engine, err := monitoring.NewEngine([]monitoring.Rule{
+		{Name: "round", Type: monitoring.RuleRoundAmount, Severity: monitoring.SeverityLow, Multiple: 1000, MinAmount: 1000},
+		// every test account is new
+		{Name: "new_account", Type: monitoring.RuleNewAccountReceipt, Severity: monitoring.SeverityHigh, Block: true,
+			AccountAge: monitoring.Duration(time.Hour), MinAmount: 5000},
+	})
+	require.NoError(t, err)
+	return NewStore(testDB, WithMonitor(engine))
+}
+
+func TestTransferTxMonitoring(t *testing.T) {
+	store := newMonitoredStore(t)
+	account1 := createAccountWithBalance(t, "USD", 10000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+
+	// no rule matches
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Alerts)
+
+	// the alert is written with the transfer
+	result, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        2000,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Alerts, 1)
+
+	alerts, err := store.ListTransferMonitoringAlerts(context.Background(), sql.NullInt64{Int64: result.Transfer.ID, Valid: true})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, "round", alerts[0].Rule)
+	require.Equal(t, AlertStatusOpen, alerts[0].Status)
+	require.Equal(t, int64(2000), alerts[0].Amount)
+	require.NotEmpty(t, alerts[0].Reason)
+}
+
+func TestTransferTxHeld(t *testing.T) {
+	store := newMonitoredStore(t)
+	account1 := createAccountWithBalance(t, "USD", 10000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        6000,
+	})
+	require.ErrorIs(t, err, ErrTransferHeld)
+	require.Len(t, result.Alerts, 1)
+	require.Equal(t, TransferStatusHeld, result.Transfer.Status)
+
+	held := result.Alerts[0]
+	require.Equal(t, "new_account", held.Rule)
+	require.Equal(t, monitoring.SeverityHigh, held.Severity)
+	require.Equal(t, AlertStatusHeld, held.Status)
+	require.Equal(t, sql.NullInt64{Int64: result.Transfer.ID, Valid: true}, held.TransferID)
+	// the reasons of all matches are kept
+	require.Contains(t, held.Reason, "round: ")
+	require.Contains(t, held.Reason, "new_account: ")
+
+	// no money moved
+	account, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(10000), account.Balance)
+
+	released, err := store.ReleaseHeldTransferTx(context.Background(), ReleaseHeldTransferTxParams{
+		AlertID:        held.ID,
+		ResolutionNote: "known customer",
+		ResolvedBy:     "analyst",
+	})
+	require.NoError(t, err)
+	require.Equal(t, AlertStatusReleased, released.Alert.Status)
+	require.Equal(t, held.TransferID, released.Alert.TransferID)
+	require.Equal(t, result.Transfer.ID, released.Transfer.Transfer.ID)
+	require.Equal(t, TransferStatusCompleted, released.Transfer.Transfer.Status)
+	require.Equal(t, "analyst", released.Alert.ResolvedBy)
+	require.True(t, released.Alert.ResolvedAt.Valid)
+	require.Equal(t, int64(4000), released.Transfer.FromAccount.Balance)
+	require.Equal(t, int64(6000), released.Transfer.ToAccount.Balance)
+
+	// a transfer is released once
+	_, err = store.ReleaseHeldTransferTx(context.Background(), ReleaseHeldTransferTxParams{
+		AlertID:    held.ID,
+		ResolvedBy: "analyst",
+	})
+	require.ErrorIs(t, err, ErrAlertNotHeld)
+
+	// the transfer went through its lifecycle
+	events, err := store.ListTransferEvents(context.Background(), result.Transfer.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	require.Equal(t, TransferStatusPending, events[0].Status)
+	require.Equal(t, TransferStatusHeld, events[1].Status)
+	require.Equal(t, "new_account", events[1].Reason)
+	require.Equal(t, TransferStatusProcessing, events[2].Status)
+	require.Equal(t, TransferStatusCompleted, events[3].Status)
+}
+
+func TestReleaseHeldTransferTxSpentMoney(t *testing.T) {
+	store := newMonitoredStore(t)
+	account1 := createAccountWithBalance(t, "USD", 10000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        6000,
+	})
+	require.ErrorIs(t, err, ErrTransferHeld)
+	held := result.Alerts[0]
+
+	// the sender spends the money while the transfer is held
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        4999,
+	})
+	require.NoError(t, err)
+
+	_, err = store.ReleaseHeldTransferTx(context.Background(), ReleaseHeldTransferTxParams{
+		AlertID:    held.ID,
+		ResolvedBy: "analyst",
+	})
+	require.ErrorIs(t, err, ErrNegativeBalance)
+
+	// nothing changed
+	account, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(5001), account.Balance)
+	alert, err := store.GetMonitoringAlert(context.Background(), held.ID)
+	require.NoError(t, err)
+	require.Equal(t, AlertStatusHeld, alert.Status)
+	transfer, err := store.GetTransfers(context.Background(), result.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusHeld, transfer.Status)
+}
+
+func TestRejectHeldTransferTx(t *testing.T) {
+	store := newMonitoredStore(t)
+	account1 := createAccountWithBalance(t, "USD", 10000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        6000,
+	})
+	require.ErrorIs(t, err, ErrTransferHeld)
+	held := result.Alerts[0]
+
+	alert, err := store.RejectHeldTransferTx(context.Background(), RejectHeldTransferTxParams{
+		AlertID:        held.ID,
+		ResolutionNote: "mule account",
+		ResolvedBy:     "analyst",
+	})
+	require.NoError(t, err)
+	require.Equal(t, AlertStatusRejected, alert.Status)
+	require.Equal(t, held.TransferID, alert.TransferID)
+
+	transfer, err := store.GetTransfers(context.Background(), result.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusCancelled, transfer.Status)
+	events, err := store.ListTransferEvents(context.Background(), transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferReasonMonitoringReject, events[len(events)-1].Reason)
+
+	// no money moved
+	account, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(10000), account.Balance)
+
+	// a rejected transfer is not released
+	_, err = store.ReleaseHeldTransferTx(context.Background(), ReleaseHeldTransferTxParams{
+		AlertID:    held.ID,
+		ResolvedBy: "analyst",
+	})
+	require.ErrorIs(t, err, ErrAlertNotHeld)
+}
+
+func TestMonitoringAlertCase(t *testing.T) {
+	store := newMonitoredStore(t)
+	account1 := createAccountWithBalance(t, "USD", 10000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        1000,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Alerts, 1)
+	id := result.Alerts[0].ID
+
+	alert, err := store.AssignMonitoringAlert(context.Background(), AssignMonitoringAlertParams{
+		AssignedTo: "analyst",
+		ID:         id,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AlertStatusInvestigating, alert.Status)
+	require.Equal(t, "analyst", alert.AssignedTo)
+
+	alert, err = store.CloseMonitoringAlert(context.Background(), CloseMonitoringAlertParams{
+		Status:         AlertStatusDismissed,
+		ResolutionNote: "salary",
+		ResolvedBy:     "analyst",
+		ID:             id,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AlertStatusDismissed, alert.Status)
+
+	// closed alerts are neither reassigned nor closed again
+	_, err = store.AssignMonitoringAlert(context.Background(), AssignMonitoringAlertParams{
+		AssignedTo: "other",
+		ID:         id,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = store.CloseMonitoringAlert(context.Background(), CloseMonitoringAlertParams{
+		Status:     AlertStatusReported,
+		ResolvedBy: "other",
+		ID:         id,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// only held alerts are decided
+	_, err = store.DecideHeldMonitoringAlert(context.Background(), DecideHeldMonitoringAlertParams{
+		Status:     AlertStatusRejected,
+		ResolvedBy: "analyst",
+		ID:         id,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,435 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hiiamanop/simple_bank/pii"
+)
+
+// ErrNoCipher means the store was created without WithCipher, so it cannot
+// read or write personal data
+var ErrNoCipher = errors.New("store has no PII cipher")
+
+// piiBatchSize is the number of rows ReencryptPII reads at once
+const piiBatchSize = 100
+
+// WithCipher encrypts the names and email addresses of users, the personal
+// data of KYC profiles and the names of screening hits with cipher. The
+// store methods take and return plaintext, so callers never see the
+// encrypted values. Only UpdateUserPII, UpdateVerifyEmailAddress,
+// UpdateKYCProfilePII and UpdateScreeningHitPII store their arguments as
+// they are.
+func WithCipher(cipher *pii.Cipher) StoreOption {
+	return func(store *SQLStore) {
+		store.cipher = cipher
+	}
+}
+
+func (store *SQLStore) encrypt(ctx context.Context, value string) (string, error) {
+	if store.cipher == nil {
+		return "", ErrNoCipher
+	}
+	return store.cipher.Encrypt(ctx, value)
+}
+
+func (store *SQLStore) decrypt(ctx context.Context, value string) (string, error) {
+	if store.cipher == nil {
+		return "", ErrNoCipher
+	}
+	return store.cipher.Decrypt(ctx, value)
+}
+
+func (store *SQLStore) emailIndex(ctx context.Context, email string) (string, error) {
+	if store.cipher == nil {
+		return "", ErrNoCipher
+	}
+	return store.cipher.BlindIndex(ctx, email)
+}
+
+// encryptEmail returns the encrypted email and its blind index
+func (store *SQLStore) encryptEmail(ctx context.Context, email string) (encrypted string, index string, err error) {
+	if index, err = store.emailIndex(ctx, email); err != nil {
+		return "", "", err
+	}
+	if encrypted, err = store.encrypt(ctx, email); err != nil {
+		return "", "", err
+	}
+	return encrypted, index, nil
+}
+
+// openUser decrypts the user returned by a query, unless the query failed
+func (store *SQLStore) openUser(ctx context.Context, user User, err error) (User, error) {
+	if err != nil {
+		return user, err
+	}
+	if user.FullName, err = store.decrypt(ctx, user.FullName); err != nil {
+		return User{}, err
+	}
+	if user.Email, err = store.decrypt(ctx, user.Email); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// openVerifyEmail decrypts the token returned by a query, unless the query
+// failed
+func (store *SQLStore) openVerifyEmail(ctx context.Context, verifyEmail VerifyEmail, err error) (VerifyEmail, error) {
+	if err != nil {
+		return verifyEmail, err
+	}
+	if verifyEmail.Email, err = store.decrypt(ctx, verifyEmail.Email); err != nil {
+		return VerifyEmail{}, err
+	}
+	return verifyEmail, nil
+}
+
+func (store *SQLStore) openUsers(ctx context.Context, users []User, err error) ([]User, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i], err = store.openUser(ctx, users[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func (store *SQLStore) openVerifyEmails(ctx context.Context, verifyEmails []VerifyEmail, err error) ([]VerifyEmail, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range verifyEmails {
+		if verifyEmails[i], err = store.openVerifyEmail(ctx, verifyEmails[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return verifyEmails, nil
+}
+
+// CreateUser encrypts the name and email of the new user
+func (store *SQLStore) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	var err error
+	if arg.Email, arg.EmailIndex, err = store.encryptEmail(ctx, arg.Email); err != nil {
+		return User{}, err
+	}
+	if arg.FullName, err = store.encrypt(ctx, arg.FullName); err != nil {
+		return User{}, err
+	}
+	user, err := store.Queries.CreateUser(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *SQLStore) EnableTOTP(ctx context.Context, username string) (User, error) {
+	user, err := store.Queries.EnableTOTP(ctx, username)
+	return store.openUser(ctx, user, err)
+}
+
+// EraseUser stores the placeholder email like any other, so that it is
+// unique and can be re-encrypted
+func (store *SQLStore) EraseUser(ctx context.Context, arg EraseUserParams) (User, error) {
+	var err error
+	if arg.Email, arg.EmailIndex, err = store.encryptEmail(ctx, arg.Email); err != nil {
+		return User{}, err
+	}
+	user, err := store.Queries.EraseUser(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *SQLStore) GetUser(ctx context.Context, username string) (User, error) {
+	user, err := store.Queries.GetUser(ctx, username)
+	return store.openUser(ctx, user, err)
+}
+
+// GetUserByEmail finds a user by the blind index of email
+func (store *SQLStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	index, err := store.emailIndex(ctx, email)
+	if err != nil {
+		return User{}, err
+	}
+	user, err := store.Queries.GetUserByEmail(ctx, index)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *SQLStore) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	users, err := store.Queries.ListUsers(ctx, arg)
+	return store.openUsers(ctx, users, err)
+}
+
+func (store *SQLStore) RecordFailedLogin(ctx context.Context, arg RecordFailedLoginParams) (User, error) {
+	user, err := store.Queries.RecordFailedLogin(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *SQLStore) ResetFailedLogins(ctx context.Context, username string) (User, error) {
+	user, err := store.Queries.ResetFailedLogins(ctx, username)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *SQLStore) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) (User, error) {
+	user, err := store.Queries.SetTOTPSecret(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *SQLStore) SoftDeleteUser(ctx context.Context, username string) (User, error) {
+	user, err := store.Queries.SoftDeleteUser(ctx, username)
+	return store.openUser(ctx, user, err)
+}
+
+// UpdateUser encrypts a changed name or email
+func (store *SQLStore) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	var err error
+	if arg.Email.Valid {
+		if arg.Email.String, arg.EmailIndex.String, err = store.encryptEmail(ctx, arg.Email.String); err != nil {
+			return User{}, err
+		}
+		arg.EmailIndex.Valid = true
+	}
+	if arg.FullName.Valid {
+		if arg.FullName.String, err = store.encrypt(ctx, arg.FullName.String); err != nil {
+			return User{}, err
+		}
+	}
+	user, err := store.Queries.UpdateUser(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *SQLStore) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	user, err := store.Queries.UpdateUserRole(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *SQLStore) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	user, err := store.Queries.VerifyUserEmail(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+// CreateVerifyEmail encrypts the address the token is sent to
+func (store *SQLStore) CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error) {
+	var err error
+	if arg.Email, err = store.encrypt(ctx, arg.Email); err != nil {
+		return VerifyEmail{}, err
+	}
+	verifyEmail, err := store.Queries.CreateVerifyEmail(ctx, arg)
+	return store.openVerifyEmail(ctx, verifyEmail, err)
+}
+
+func (store *SQLStore) ListVerifyEmails(ctx context.Context, username string) ([]VerifyEmail, error) {
+	verifyEmails, err := store.Queries.ListVerifyEmails(ctx, username)
+	return store.openVerifyEmails(ctx, verifyEmails, err)
+}
+
+func (store *SQLStore) ListVerifyEmailsAfter(ctx context.Context, arg ListVerifyEmailsAfterParams) ([]VerifyEmail, error) {
+	verifyEmails, err := store.Queries.ListVerifyEmailsAfter(ctx, arg)
+	return store.openVerifyEmails(ctx, verifyEmails, err)
+}
+
+func (store *SQLStore) UseVerifyEmail(ctx context.Context, arg UseVerifyEmailParams) (VerifyEmail, error) {
+	verifyEmail, err := store.Queries.UseVerifyEmail(ctx, arg)
+	return store.openVerifyEmail(ctx, verifyEmail, err)
+}
+
+// ReencryptPIIResult counts the rows looked at by ReencryptPII
+type ReencryptPIIResult struct {
+	Users         int64 `json:"users"`
+	VerifyEmails  int64 `json:"verify_emails"`
+	KYCProfiles   int64 `json:"kyc_profiles"`
+	ScreeningHits int64 `json:"screening_hits"`
+	// Rewritten rows were moved to the current key or encrypted for the
+	// first time
+	Rewritten int64 `json:"rewritten"`
+	// Skipped rows changed while they were re-encrypted
+	Skipped int64 `json:"skipped"`
+}
+
+// ReencryptPII moves all personal data to the current key: data keys that
+// were wrapped with an older key are rewrapped, plaintext from before
+// encryption is encrypted and stale blind indexes are recomputed. A row that
+// changes meanwhile is skipped rather than overwritten.
+func (store *SQLStore) ReencryptPII(ctx context.Context) (ReencryptPIIResult, error) {
+	var result ReencryptPIIResult
+	if store.cipher == nil {
+		return result, ErrNoCipher
+	}
+
+	for offset := int32(0); ; offset += piiBatchSize {
+		users, err := store.Queries.ListUsers(ctx, ListUsersParams{Limit: piiBatchSize, Offset: offset})
+		if err != nil {
+			return result, err
+		}
+		for _, user := range users {
+			if err := store.reencryptUser(ctx, user, &result); err != nil {
+				return result, err
+			}
+		}
+		if len(users) < piiBatchSize {
+			break
+		}
+	}
+
+	var lastID int64
+	for {
+		verifyEmails, err := store.Queries.ListVerifyEmailsAfter(ctx, ListVerifyEmailsAfterParams{
+			ID:    lastID,
+			Limit: piiBatchSize,
+		})
+		if err != nil {
+			return result, err
+		}
+		for _, verifyEmail := range verifyEmails {
+			if err := store.reencryptVerifyEmail(ctx, verifyEmail, &result); err != nil {
+				return result, err
+			}
+			lastID = verifyEmail.ID
+		}
+		if len(verifyEmails) < piiBatchSize {
+			break
+		}
+	}
+
+	var lastUsername string
+	for {
+		profiles, err := store.Queries.ListKYCProfilesAfter(ctx, ListKYCProfilesAfterParams{
+			Username: lastUsername,
+			Limit:    piiBatchSize,
+		})
+		if err != nil {
+			return result, err
+		}
+		for _, profile := range profiles {
+			if err := store.reencryptKYCProfile(ctx, profile, &result); err != nil {
+				return result, err
+			}
+			lastUsername = profile.Username
+		}
+		if len(profiles) < piiBatchSize {
+			break
+		}
+	}
+
+	lastID = 0
+	for {
+		hits, err := store.Queries.ListScreeningHitsAfter(ctx, ListScreeningHitsAfterParams{
+			ID:    lastID,
+			Limit: piiBatchSize,
+		})
+		if err != nil {
+			return result, err
+		}
+		for _, hit := range hits {
+			if err := store.reencryptScreeningHit(ctx, hit, &result); err != nil {
+				return result, err
+			}
+			lastID = hit.ID
+		}
+		if len(hits) < piiBatchSize {
+			return result, nil
+		}
+	}
+}
+
+func (store *SQLStore) reencryptUser(ctx context.Context, user User, result *ReencryptPIIResult) error {
+	result.Users++
+
+	fullName, nameChanged, err := store.cipher.Rewrap(ctx, user.FullName)
+	if err != nil {
+		return err
+	}
+	email, emailChanged, err := store.cipher.Rewrap(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	plainEmail, err := store.cipher.Decrypt(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	index, err := store.cipher.BlindIndex(ctx, plainEmail)
+	if err != nil {
+		return err
+	}
+	if !nameChanged && !emailChanged && index == user.EmailIndex {
+		return nil
+	}
+
+	rows, err := store.Queries.UpdateUserPII(ctx, UpdateUserPIIParams{
+		FullName:    fullName,
+		Email:       email,
+		EmailIndex:  index,
+		Username:    user.Username,
+		OldFullName: user.FullName,
+		OldEmail:    user.Email,
+	})
+	if err != nil {
+		return err
+	}
+	countRewrite(result, rows)
+	return nil
+}
+
+func (store *SQLStore) reencryptVerifyEmail(ctx context.Context, verifyEmail VerifyEmail, result *ReencryptPIIResult) error {
+	result.VerifyEmails++
+
+	email, changed, err := store.cipher.Rewrap(ctx, verifyEmail.Email)
+	if err != nil || !changed {
+		return err
+	}
+
+	rows, err := store.Queries.UpdateVerifyEmailAddress(ctx, UpdateVerifyEmailAddressParams{
+		Email:    email,
+		ID:       verifyEmail.ID,
+		OldEmail: verifyEmail.Email,
+	})
+	if err != nil {
+		return err
+	}
+	countRewrite(result, rows)
+	return nil
+}
+
+func (store *SQLStore) reencryptKYCProfile(ctx context.Context, profile KycProfile, result *ReencryptPIIResult) error {
+	result.KYCProfiles++
+
+	arg := UpdateKYCProfilePIIParams{
+		Username:          profile.Username,
+		OldLegalName:      profile.LegalName,
+		OldDateOfBirth:    profile.DateOfBirth,
+		OldAddress:        profile.Address,
+		OldDocumentNumber: profile.DocumentNumber,
+	}
+	var changed bool
+	for _, field := range []struct {
+		value     string
+		rewrapped *string
+	}{
+		{profile.LegalName, &arg.LegalName},
+		{profile.DateOfBirth, &arg.DateOfBirth},
+		{profile.Address, &arg.Address},
+		{profile.DocumentNumber, &arg.DocumentNumber},
+	} {
+		var fieldChanged bool
+		var err error
+		if *field.rewrapped, fieldChanged, err = store.cipher.Rewrap(ctx, field.value); err != nil {
+			return err
+		}
+		changed = changed || fieldChanged
+	}
+	if !changed {
+		return nil
+	}
+
+	rows, err := store.Queries.UpdateKYCProfilePII(ctx, arg)
+	if err != nil {
+		return err
+	}
+	countRewrite(result, rows)
+	return nil
+}
+
+func countRewrite(result *ReencryptPIIResult, rows int64) {
+	if rows > 0 {
+		result.Rewritten++
+	} else {
+		result.Skipped++
+	}
+}
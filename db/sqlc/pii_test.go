@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/hiiamanop/simple_bank/pii"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserPIIEncrypted(t *testing.T) {
+	user := createRandomUser(t)
+
+	// the row holds ciphertext, the store returns plaintext
+	row, err := testQueries.GetUser(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.True(t, pii.IsEncrypted(row.FullName))
+	require.True(t, pii.IsEncrypted(row.Email))
+	require.NotContains(t, row.Email, user.Email)
+	require.Equal(t, user.EmailIndex, row.EmailIndex)
+
+	found, err := testStore.GetUserByEmail(context.Background(), user.Email)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, found.Username)
+	require.Equal(t, user.Email, found.Email)
+
+	// the blind index keeps emails unique
+	_, err = testStore.CreateUser(context.Background(), CreateUserParams{
+		Username:       randomString(8),
+		HashedPassword: randomString(16),
+		FullName:       randomString(6),
+		Email:          user.Email,
+	})
+	var pqErr *pq.Error
+	require.ErrorAs(t, err, &pqErr)
+	require.Equal(t, pq.ErrorCode("23505"), pqErr.Code)
+
+	// changing the email keeps it searchable
+	email := strings.ToLower(randomString(6)) + "@example.com"
+	updated, err := testStore.UpdateUser(context.Background(), UpdateUserParams{
+		Username: user.Username,
+		Email:    sql.NullString{String: email, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, email, updated.Email)
+
+	found, err = testStore.GetUserByEmail(context.Background(), email)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, found.Username)
+	_, err = testStore.GetUserByEmail(context.Background(), user.Email)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestStoreWithoutCipher(t *testing.T) {
+	user := createRandomUser(t)
+
+	_, err := NewStore(testDB).GetUser(context.Background(), user.Username)
+	require.ErrorIs(t, err, ErrNoCipher)
+	_, err = NewStore(testDB).ReencryptPII(context.Background())
+	require.ErrorIs(t, err, ErrNoCipher)
+}
+
+func TestReencryptPII(t *testing.T) {
+	// a user written before encryption was introduced
+	legacy, err := testQueries.CreateUser(context.Background(), CreateUserParams{
+		Username:       randomString(8),
+		HashedPassword: randomString(16),
+		FullName:       randomString(6),
+		Email:          randomString(6) + "@example.com",
+		EmailIndex:     "unindexed:" + randomString(8),
+	})
+	require.NoError(t, err)
+	user := createRandomUser(t)
+	verifyEmail := createRandomVerifyEmail(t, user, VerifyEmailPurposeEmailVerification)
+	profile := submitRandomKYCProfile(t, user)
+
+	_, err = testKeys.Rotate()
+	require.NoError(t, err)
+
+	result, err := testStore.ReencryptPII(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, result.Rewritten, int64(4))
+
+	for _, username := range []string{legacy.Username, user.Username} {
+		row, err := testQueries.GetUser(context.Background(), username)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(row.Email, "pii1:"+testKeys.Current+":"))
+		require.True(t, strings.HasPrefix(row.FullName, "pii1:"+testKeys.Current+":"))
+	}
+
+	found, err := testStore.GetUserByEmail(context.Background(), legacy.Email)
+	require.NoError(t, err)
+	require.Equal(t, legacy.FullName, found.FullName)
+
+	emails, err := testQueries.ListVerifyEmails(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, verifyEmail.ID, emails[0].ID)
+	require.True(t, strings.HasPrefix(emails[0].Email, "pii1:"+testKeys.Current+":"))
+
+	row, err := testQueries.GetKYCProfile(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(row.LegalName, "pii1:"+testKeys.Current+":"))
+	reencrypted, err := testStore.GetKYCProfile(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, profile, reencrypted)
+
+	// nothing is left to do
+	result, err = testStore.ReencryptPII(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, result.Rewritten)
+}
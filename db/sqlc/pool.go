@@ -0,0 +1,22 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+// Open opens the configured database with the connection pool limits of
+// config. Like sql.Open it does not connect yet.
+func Open(config util.Config) (*sql.DB, error) {
+	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetMaxOpenConns(config.DBMaxOpenConns)
+	conn.SetMaxIdleConns(config.DBMaxIdleConns)
+	conn.SetConnMaxLifetime(config.DBConnMaxLifetime)
+	conn.SetConnMaxIdleTime(config.DBConnMaxIdleTime)
+	return conn, nil
+}
@@ -6,41 +6,231 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 type Querier interface {
+	AddAccountApprover(ctx context.Context, arg AddAccountApproverParams) error
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	// Count an approval. The last required one approves the transfer. No row
+	// means the approval does not exist or is not pending.
+	ApproveTransferApproval(ctx context.Context, id int64) (TransferApproval, error)
+	// Assign an alert that is not closed to an analyst. An open alert is then
+	// under investigation. No row means the alert does not exist or is closed.
+	AssignMonitoringAlert(ctx context.Context, arg AssignMonitoringAlertParams) (MonitoringAlert, error)
+	// Close an alert of an executed transfer as dismissed or reported. No row
+	// means the alert does not exist or is not open.
+	CloseMonitoringAlert(ctx context.Context, arg CloseMonitoringAlertParams) (MonitoringAlert, error)
+	// Count the transfers an account sent since a time with an amount in
+	// [min_amount, max_amount)
+	CountTransfersSentInRange(ctx context.Context, arg CountTransfersSentInRangeParams) (int64, error)
 	// Create a new account
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error)
 	// Create a new entries
 	CreateEntries(ctx context.Context, arg CreateEntriesParams) (Entry, error)
-	// Create a new transfers
+	CreateMonitoringAlert(ctx context.Context, arg CreateMonitoringAlertParams) (MonitoringAlert, error)
+	// Create a transfer that moves its money later, e.g. after approval
+	CreatePendingTransfer(ctx context.Context, arg CreatePendingTransferParams) (Transfer, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error)
+	CreateTransferApproval(ctx context.Context, arg CreateTransferApprovalParams) (TransferApproval, error)
+	CreateTransferApprovalDecision(ctx context.Context, arg CreateTransferApprovalDecisionParams) (TransferApprovalDecision, error)
+	CreateTransferEvent(ctx context.Context, arg CreateTransferEventParams) (TransferEvent, error)
+	// Create a new transfers, completed as its money moves in the same transaction
 	CreateTransfers(ctx context.Context, arg CreateTransfersParams) (Transfer, error)
+	CreateTransferReversal(ctx context.Context, arg CreateTransferReversalParams) (TransferReversal, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
-	// Delete an account
-	DeleteAccount(ctx context.Context, id int64) error
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
+	// Release or reject a held transfer, which the alert links since it was
+	// held. No row means the alert does not exist or is not held.
+	DecideHeldMonitoringAlert(ctx context.Context, arg DecideHeldMonitoringAlertParams) (MonitoringAlert, error)
+	DeleteAccountApprovers(ctx context.Context, accountID int64) error
+	DeleteApprovalPolicy(ctx context.Context, accountID int64) (int64, error)
 	// Delete an entries
 	DeleteEntries(ctx context.Context, id int64) error
+	DeleteIdleRateLimitBuckets(ctx context.Context, idleSince time.Time) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	DeleteTransferLimit(ctx context.Context, arg DeleteTransferLimitParams) (int64, error)
 	// Delete a transfers
 	DeleteTransfers(ctx context.Context, id int64) error
-	DeleteUser(ctx context.Context, username string) error
+	DeleteVerifyEmails(ctx context.Context, username string) error
+	EnableTOTP(ctx context.Context, username string) (User, error)
+	// Replace the personal data of a deleted user with a pseudonym and remove
+	// its credentials. Rows that refer to the username follow the rename.
+	EraseUser(ctx context.Context, arg EraseUserParams) (User, error)
+	// Mark an approved transfer as executed. No row means the approval does
+	// not exist or is not approved, e.g. it was executed already.
+	ExecuteTransferApproval(ctx context.Context, id int64) (TransferApproval, error)
+	// Expire the pending approvals that were not decided in time
+	ExpireTransferApprovals(ctx context.Context) ([]TransferApproval, error)
+	// Record why the transfer of an approved transfer failed. No row means the
+	// approval does not exist or is not approved.
+	FailTransferApproval(ctx context.Context, arg FailTransferApprovalParams) (TransferApproval, error)
 	// Get an account by id
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	// Get an account by id and lock it until the transaction ends
+	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	// Sum up what an account sent in the last hour, day and 30 days. The
+	// transfer being checked, reversed transfers and their reversals, and
+	// transfers that moved no money are left out.
+	GetAccountTransferTotals(ctx context.Context, arg GetAccountTransferTotalsParams) (GetAccountTransferTotalsRow, error)
+	GetApprovalPolicy(ctx context.Context, accountID int64) (ApprovalPolicy, error)
 	// Get an entries by id
 	GetEntries(ctx context.Context, id int64) (Entry, error)
+	GetKYCProfile(ctx context.Context, username string) (KycProfile, error)
+	GetKYCTier(ctx context.Context, tier int32) (KycTier, error)
+	// Get the tier of the owner of an account. Owners without a verified
+	// profile are in tier 0.
+	GetKYCTierByAccount(ctx context.Context, id int64) (KycTier, error)
+	GetLastAuditEvent(ctx context.Context) (AuditEvent, error)
+	GetMonitoringAlert(ctx context.Context, id int64) (MonitoringAlert, error)
+	GetMonitoringAlertForUpdate(ctx context.Context, id int64) (MonitoringAlert, error)
+	GetScreeningHit(ctx context.Context, id int64) (ScreeningHit, error)
+	GetTransferApproval(ctx context.Context, id int64) (TransferApproval, error)
+	GetTransferApprovalForUpdate(ctx context.Context, id int64) (TransferApproval, error)
 	// Get a transfers by id
 	GetTransfers(ctx context.Context, id int64) (Transfer, error)
+	GetTransferLimit(ctx context.Context, arg GetTransferLimitParams) (TransferLimit, error)
+	GetTransferReversal(ctx context.Context, transferID int64) (TransferReversal, error)
+	GetTransferReversalByReversalID(ctx context.Context, reversalID int64) (TransferReversal, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	// Find a user by the blind index of the email
+	GetUserByEmail(ctx context.Context, emailIndex string) (User, error)
+	// Sum up what all accounts of a user sent in the last hour, day and 30
+	// days. The transfer being checked, reversed transfers and their reversals,
+	// and transfers that moved no money are left out.
+	GetUserTransferTotals(ctx context.Context, arg GetUserTransferTotalsParams) (GetUserTransferTotalsRow, error)
+	IsAccountApprover(ctx context.Context, arg IsAccountApproverParams) (bool, error)
+	ListAccountApprovers(ctx context.Context, accountID int64) ([]string, error)
 	// List all accounts
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	// List all accounts of a user
+	ListAccountsByOwner(ctx context.Context, owner string) ([]Account, error)
+	// List events newest first, optionally filtered by actor, target and a
+	// time range
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
+	// List events in chain order, for verification
+	ListAuditEventsAfter(ctx context.Context, arg ListAuditEventsAfterParams) ([]AuditEvent, error)
+	// List the events by a user, about the user or about any of its accounts,
+	// in chain order
+	ListAuditEventsByUser(ctx context.Context, username string) ([]AuditEvent, error)
 	// List all entries
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	// List the entries of one account
+	ListEntriesByAccount(ctx context.Context, arg ListEntriesByAccountParams) ([]Entry, error)
+	// List the entries of all accounts of a user
+	ListEntriesByOwner(ctx context.Context, owner string) ([]Entry, error)
+	// List profiles oldest submission first, optionally filtered by status,
+	// e.g. the pending ones for the review queue
+	ListKYCProfiles(ctx context.Context, arg ListKYCProfilesParams) ([]KycProfile, error)
+	// Page through all profiles in username order
+	ListKYCProfilesAfter(ctx context.Context, arg ListKYCProfilesAfterParams) ([]KycProfile, error)
+	ListKYCTiers(ctx context.Context) ([]KycTier, error)
+	// List alerts oldest first, optionally filtered by status, e.g. the open
+	// ones for the case queue
+	ListMonitoringAlerts(ctx context.Context, arg ListMonitoringAlertsParams) ([]MonitoringAlert, error)
+	// List a page of the accounts of a user
+	ListOwnerAccounts(ctx context.Context, arg ListOwnerAccountsParams) ([]Account, error)
+	// List a page of the entries of all accounts of a user
+	ListOwnerEntries(ctx context.Context, arg ListOwnerEntriesParams) ([]Entry, error)
+	// List a page of the transfers from or to any account of a user
+	ListOwnerTransfers(ctx context.Context, arg ListOwnerTransfersParams) ([]Transfer, error)
+	// List hits oldest first, optionally filtered by status, e.g. the pending
+	// ones for the review queue
+	ListScreeningHits(ctx context.Context, arg ListScreeningHitsParams) ([]ScreeningHit, error)
+	// Walk all hits in id order, for re-encryption
+	ListScreeningHitsAfter(ctx context.Context, arg ListScreeningHitsAfterParams) ([]ScreeningHit, error)
+	ListTransferApprovalDecisions(ctx context.Context, approvalID int64) ([]TransferApprovalDecision, error)
+	// List approvals newest first, optionally filtered by status and by a user,
+	// who sees the approvals they initiated or may decide on
+	ListTransferApprovals(ctx context.Context, arg ListTransferApprovalsParams) ([]TransferApproval, error)
+	// List the status changes of a transfer, oldest first
+	ListTransferEvents(ctx context.Context, transferID int64) ([]TransferEvent, error)
 	// List all transfers
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	ListTransferLimits(ctx context.Context) ([]TransferLimit, error)
+	// List the defaults and the overrides that apply to an account and its owner
+	ListTransferLimitsFor(ctx context.Context, arg ListTransferLimitsForParams) ([]TransferLimit, error)
+	ListTransferMonitoringAlerts(ctx context.Context, transferID sql.NullInt64) ([]MonitoringAlert, error)
+	// List the transfers from or to any account of a user
+	ListTransfersByOwner(ctx context.Context, owner string) ([]Transfer, error)
+	// List accounts whose balance differs from the sum of their entries
+	ListUnbalancedAccounts(ctx context.Context) ([]ListUnbalancedAccountsRow, error)
 	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	// List the tokens ever sent to a user
+	ListVerifyEmails(ctx context.Context, username string) ([]VerifyEmail, error)
+	// Page through all tokens in id order
+	ListVerifyEmailsAfter(ctx context.Context, arg ListVerifyEmailsAfterParams) ([]VerifyEmail, error)
+	// Serialize appends, so that every event chains to the one before it
+	LockAuditEvents(ctx context.Context) error
+	// Lock the user until the transaction ends, so that concurrent transfers
+	// from different accounts of the user are checked against its limits one
+	// at a time
+	LockUserTransfers(ctx context.Context, username string) error
+	// Count a failed login and lock the user out until lock_until once the
+	// count reaches max_attempts. A lockout that has expired starts a new
+	// count, so the next failure does not lock the user out again. The
+	// increment is atomic, so concurrent guesses cannot slip past the limit.
+	RecordFailedLogin(ctx context.Context, arg RecordFailedLoginParams) (User, error)
+	// Record that a name matched a list entry. A name that matched the entry
+	// before keeps its hit, and with it the decision of the reviewer.
+	RecordScreeningHit(ctx context.Context, arg RecordScreeningHitParams) (ScreeningHit, error)
+	// No row means the approval does not exist or is not pending
+	RejectTransferApproval(ctx context.Context, id int64) (TransferApproval, error)
+	// Clear the failed login count and lift a lockout
+	ResetFailedLogins(ctx context.Context, username string) (User, error)
+	// Record the decision on a pending profile. No row means the profile does
+	// not exist or is not pending anymore.
+	ReviewKYCProfile(ctx context.Context, arg ReviewKYCProfileParams) (KycProfile, error)
+	// Clear or confirm a pending hit. No row means the hit does not exist or
+	// was reviewed already.
+	ReviewScreeningHit(ctx context.Context, arg ReviewScreeningHitParams) (ScreeningHit, error)
+	// Store a new secret for enrolment. It is not used until EnableTOTP.
+	SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) (User, error)
+	// Mark a user as deleted. The row stays, since the ledger refers to it.
+	SoftDeleteUser(ctx context.Context, username string) (User, error)
+	// Create or replace the KYC profile of a user. A changed profile goes back
+	// to review, and the user is in tier 0 until it is verified again.
+	SubmitKYCProfile(ctx context.Context, arg SubmitKYCProfileParams) (KycProfile, error)
+	// Total balance held in each currency
+	SumBalancesByCurrency(ctx context.Context) ([]SumBalancesByCurrencyRow, error)
+	SumTransfersReceivedSince(ctx context.Context, arg SumTransfersReceivedSinceParams) (int64, error)
+	// Refill the bucket for the time since its last update and take one token
+	// if there is one, in a single statement so concurrent instances agree
+	TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error)
+	UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error)
 	UpdateEntries(ctx context.Context, arg UpdateEntriesParams) (Entry, error)
+	// Replace the personal data of a profile with a re-encrypted copy, unless
+	// it changed since it was read
+	UpdateKYCProfilePII(ctx context.Context, arg UpdateKYCProfilePIIParams) (int64, error)
+	// Store the re-encrypted name and recomputed index of a hit, unless the
+	// name changed since it was read
+	UpdateScreeningHitPII(ctx context.Context, arg UpdateScreeningHitPIIParams) (int64, error)
 	UpdateTransfer(ctx context.Context, arg UpdateTransferParams) (Transfer, error)
+	// Move a transfer from one status to another. No row means the transfer
+	// does not exist or is no longer in from_status.
+	UpdateTransferStatus(ctx context.Context, arg UpdateTransferStatusParams) (Transfer, error)
+	// A changed email has to be verified again
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	// Replace the personal data of a user with a re-encrypted copy, unless it
+	// changed since it was read
+	UpdateUserPII(ctx context.Context, arg UpdateUserPIIParams) (int64, error)
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	// Replace the address with a re-encrypted copy, unless it changed since it
+	// was read
+	UpdateVerifyEmailAddress(ctx context.Context, arg UpdateVerifyEmailAddressParams) (int64, error)
+	UpsertApprovalPolicy(ctx context.Context, arg UpsertApprovalPolicyParams) (ApprovalPolicy, error)
+	UpsertTransferLimit(ctx context.Context, arg UpsertTransferLimitParams) (TransferLimit, error)
+	// Mark an unused recovery code as used. No row means the code is wrong or
+	// was used before.
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCode, error)
+	// Mark an unused and unexpired token as used. No row means the token is
+	// wrong, expired or was used before.
+	UseVerifyEmail(ctx context.Context, arg UseVerifyEmailParams) (VerifyEmail, error)
+	// Mark the email of a user as verified, unless it changed since the token
+	// was sent
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
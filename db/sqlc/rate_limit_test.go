@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeRateLimitToken(t *testing.T) {
+	arg := TakeRateLimitTokenParams{
+		Key:        "test:" + randomString(12),
+		Capacity:   2,
+		RefillRate: 2.0 / 3600,
+	}
+
+	row, err := testQueries.TakeRateLimitToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, row.Allowed)
+	require.InDelta(t, 1, row.Tokens, 0.01)
+
+	row, err = testQueries.TakeRateLimitToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, row.Allowed)
+	require.InDelta(t, 0, row.Tokens, 0.01)
+
+	row, err = testQueries.TakeRateLimitToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, row.Allowed)
+	require.Less(t, row.Tokens, 1.0)
+}
+
+func TestDeleteIdleRateLimitBuckets(t *testing.T) {
+	arg := TakeRateLimitTokenParams{Key: "test:" + randomString(12), Capacity: 1, RefillRate: 1}
+	_, err := testQueries.TakeRateLimitToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	deleted, err := testQueries.DeleteIdleRateLimitBuckets(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Positive(t, deleted)
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hiiamanop/simple_bank/screening"
+)
+
+// Screening hit statuses. A pending or confirmed hit blocks its name until
+// a reviewer clears it.
+const (
+	ScreeningStatusPending   = "pending"
+	ScreeningStatusCleared   = "cleared"
+	ScreeningStatusConfirmed = "confirmed"
+)
+
+// Actions that screen a name
+const (
+	ScreeningActionOnboarding = "onboarding"
+	ScreeningActionTransfer   = "transfer"
+)
+
+// screeningNameIndex returns the blind index of the normalised name, so
+// that spellings which normalise alike share their hits
+func (store *SQLStore) screeningNameIndex(ctx context.Context, name string) (string, error) {
+	if store.cipher == nil {
+		return "", ErrNoCipher
+	}
+	return store.cipher.BlindIndex(ctx, strings.Join(screening.Normalize(name), " "))
+}
+
+// openScreeningHit decrypts the hit returned by a query, unless the query
+// failed
+func (store *SQLStore) openScreeningHit(ctx context.Context, hit ScreeningHit, err error) (ScreeningHit, error) {
+	if err != nil {
+		return hit, err
+	}
+	if hit.Name, err = store.decrypt(ctx, hit.Name); err != nil {
+		return ScreeningHit{}, err
+	}
+	return hit, nil
+}
+
+func (store *SQLStore) openScreeningHits(ctx context.Context, hits []ScreeningHit, err error) ([]ScreeningHit, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		if hits[i], err = store.openScreeningHit(ctx, hits[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return hits, nil
+}
+
+// RecordScreeningHit encrypts the screened name and indexes it. The index
+// of arg is ignored.
+func (store *SQLStore) RecordScreeningHit(ctx context.Context, arg RecordScreeningHitParams) (ScreeningHit, error) {
+	var err error
+	if arg.NameIndex, err = store.screeningNameIndex(ctx, arg.Name); err != nil {
+		return ScreeningHit{}, err
+	}
+	if arg.Name, err = store.encrypt(ctx, arg.Name); err != nil {
+		return ScreeningHit{}, err
+	}
+	hit, err := store.Queries.RecordScreeningHit(ctx, arg)
+	return store.openScreeningHit(ctx, hit, err)
+}
+
+func (store *SQLStore) GetScreeningHit(ctx context.Context, id int64) (ScreeningHit, error) {
+	hit, err := store.Queries.GetScreeningHit(ctx, id)
+	return store.openScreeningHit(ctx, hit, err)
+}
+
+func (store *SQLStore) ListScreeningHits(ctx context.Context, arg ListScreeningHitsParams) ([]ScreeningHit, error) {
+	hits, err := store.Queries.ListScreeningHits(ctx, arg)
+	return store.openScreeningHits(ctx, hits, err)
+}
+
+func (store *SQLStore) ListScreeningHitsAfter(ctx context.Context, arg ListScreeningHitsAfterParams) ([]ScreeningHit, error) {
+	hits, err := store.Queries.ListScreeningHitsAfter(ctx, arg)
+	return store.openScreeningHits(ctx, hits, err)
+}
+
+func (store *SQLStore) ReviewScreeningHit(ctx context.Context, arg ReviewScreeningHitParams) (ScreeningHit, error) {
+	hit, err := store.Queries.ReviewScreeningHit(ctx, arg)
+	return store.openScreeningHit(ctx, hit, err)
+}
+
+func (store *SQLStore) reencryptScreeningHit(ctx context.Context, hit ScreeningHit, result *ReencryptPIIResult) error {
+	result.ScreeningHits++
+
+	name, changed, err := store.cipher.Rewrap(ctx, hit.Name)
+	if err != nil {
+		return err
+	}
+	plainName, err := store.cipher.Decrypt(ctx, hit.Name)
+	if err != nil {
+		return err
+	}
+	index, err := store.screeningNameIndex(ctx, plainName)
+	if err != nil {
+		return err
+	}
+	if !changed && index == hit.NameIndex {
+		return nil
+	}
+
+	rows, err := store.Queries.UpdateScreeningHitPII(ctx, UpdateScreeningHitPIIParams{
+		Name:      name,
+		NameIndex: index,
+		ID:        hit.ID,
+		OldName:   hit.Name,
+	})
+	if err != nil {
+		return err
+	}
+	countRewrite(result, rows)
+	return nil
+}
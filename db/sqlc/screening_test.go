@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/hiiamanop/simple_bank/pii"
+	"github.com/hiiamanop/simple_bank/screening"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordScreeningHit(t *testing.T) {
+	username := util.RandomOwner()
+	arg := RecordScreeningHitParams{
+		Action:    ScreeningActionOnboarding,
+		Username:  username,
+		Name:      "José " + username,
+		List:      screening.ListOFAC,
+		EntryID:   util.RandomString(8),
+		EntryName: "GARCIA, Jose",
+		Program:   "SDGT",
+		Score:     0.93,
+	}
+
+	hit, err := testStore.RecordScreeningHit(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Name, hit.Name)
+	require.Equal(t, ScreeningStatusPending, hit.Status)
+	require.Equal(t, 0.93, hit.Score)
+
+	// the name is encrypted at rest
+	raw, err := testQueries.GetScreeningHit(context.Background(), hit.ID)
+	require.NoError(t, err)
+	require.True(t, pii.IsEncrypted(raw.Name))
+
+	// a spelling that normalises alike finds the same hit
+	arg.Name = "JOSE " + username
+	arg.Score = 0.95
+	again, err := testStore.RecordScreeningHit(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, hit.ID, again.ID)
+	require.Equal(t, 0.95, again.Score)
+
+	reviewed, err := testStore.ReviewScreeningHit(context.Background(), ReviewScreeningHitParams{
+		Status:     ScreeningStatusCleared,
+		ReviewNote: "different date of birth",
+		ReviewedBy: "reviewer",
+		ID:         hit.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, ScreeningStatusCleared, reviewed.Status)
+	require.True(t, reviewed.ReviewedAt.Valid)
+
+	// a hit is reviewed once, and the decision holds for the name
+	_, err = testStore.ReviewScreeningHit(context.Background(), ReviewScreeningHitParams{
+		Status:     ScreeningStatusConfirmed,
+		ReviewedBy: "reviewer",
+		ID:         hit.ID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	again, err = testStore.RecordScreeningHit(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, ScreeningStatusCleared, again.Status)
+}
@@ -3,37 +3,183 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hiiamanop/simple_bank/monitoring"
+	"github.com/hiiamanop/simple_bank/pii"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Store interface {
 	Querier
+	ExecTx(ctx context.Context, fn func(Store) error) error
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	ReverseTransferTx(ctx context.Context, transferID int64) (TransferTxResult, error)
+	AdjustBalanceTx(ctx context.Context, arg AdjustBalanceTxParams) (AdjustBalanceTxResult, error)
+	ChangeAccountStatusTx(ctx context.Context, arg ChangeAccountStatusTxParams) (ChangeAccountStatusTxResult, error)
+	EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (User, error)
+	VerifyEmailTx(ctx context.Context, hashedToken string) (User, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error)
+	AppendAuditEventTx(ctx context.Context, arg AppendAuditEventTxParams) (AuditEvent, error)
+	VerifyAuditChain(ctx context.Context) (AuditChainStatus, error)
+	DeleteUserTx(ctx context.Context, username string) (User, error)
+	EraseUserTx(ctx context.Context, username string) (User, error)
+	ExportUserData(ctx context.Context, username string) (UserData, error)
+	ReencryptPII(ctx context.Context) (ReencryptPIIResult, error)
+	GetTransferAllowance(ctx context.Context, accountID int64) (TransferAllowance, error)
+	ReleaseHeldTransferTx(ctx context.Context, arg ReleaseHeldTransferTxParams) (ReleaseHeldTransferTxResult, error)
+	RejectHeldTransferTx(ctx context.Context, arg RejectHeldTransferTxParams) (MonitoringAlert, error)
+	SetApprovalPolicyTx(ctx context.Context, arg SetApprovalPolicyTxParams) (SetApprovalPolicyTxResult, error)
+	CreateTransferApprovalTx(ctx context.Context, arg CreateTransferApprovalTxParams) (CreateTransferApprovalTxResult, error)
+	DecideTransferApprovalTx(ctx context.Context, arg DecideTransferApprovalTxParams) (DecideTransferApprovalTxResult, error)
+	FailTransferApprovalTx(ctx context.Context, arg FailTransferApprovalTxParams) (TransferApproval, error)
+	ExpireTransferApprovalsTx(ctx context.Context) ([]TransferApproval, error)
+}
+
+// Account statuses. Only active accounts can send or receive money.
+const (
+	AccountStatusActive  = "active"
+	AccountStatusFrozen  = "frozen"
+	AccountStatusDormant = "dormant"
+	AccountStatusClosed  = "closed"
+)
+
+// Purposes of the tokens in verify_emails
+const (
+	VerifyEmailPurposeEmailVerification = "email_verification"
+	VerifyEmailPurposePasswordReset     = "password_reset"
+)
+
+var (
+	ErrTransferAlreadyReversed = errors.New("transfer has already been reversed")
+	ErrTransferIsReversal      = errors.New("a reversal cannot be reversed")
+	ErrNegativeBalance         = errors.New("balance cannot become negative")
+	ErrAccountNotActive        = errors.New("account is not active")
+)
+
+// maxTxAttempts bounds how often a transaction that lost a serialization
+// conflict or deadlock is run again
+const maxTxAttempts = 3
+
+// TxObserver is notified about the transactions run by the store, e.g. to
+// export metrics. name identifies the transaction, such as "TransferTx".
+type TxObserver interface {
+	TxRetried(name string, err error)
+	TxFinished(name string, duration time.Duration, err error)
+}
+
+// StoreOption configures a SQLStore
+type StoreOption func(*SQLStore)
+
+// WithTxObserver reports every transaction of the store to observer
+func WithTxObserver(observer TxObserver) StoreOption {
+	return func(store *SQLStore) {
+		store.observer = observer
+	}
 }
 
 type SQLStore struct {
 	*Queries
-	db *sql.DB
+	db       *sql.DB
+	observer TxObserver
+	cipher   *pii.Cipher
+	monitor  *monitoring.Engine
+	// inTx means Queries run in the transaction of ExecTx, which the
+	// transactions of the store join
+	inTx bool
 }
 
-func NewStore(db *sql.DB) Store {
-	return &SQLStore{
+func NewStore(db *sql.DB, opts ...StoreOption) Store {
+	store := &SQLStore{
 		db:      db,
-		Queries: New(db),
+		Queries: NewTraced(db),
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	return store
+}
+
+// ExecTx runs fn in a transaction. The queries and transactions of the
+// Store passed to fn run inside it, so that e.g. a change and its audit
+// event are committed or rolled back together. Like any transaction of the
+// store, fn is run again after a serialization failure or a deadlock.
+func (store *SQLStore) ExecTx(ctx context.Context, fn func(Store) error) error {
+	return store.execTx(ctx, "ExecTx", func(q *Queries) error {
+		txStore := *store
+		txStore.Queries = q
+		txStore.inTx = true
+		return fn(&txStore)
+	})
+}
+
+// execTx runs fn in a transaction, retrying it when Postgres aborted it
+// because of a serialization failure or a deadlock. Within ExecTx, fn runs in
+// its transaction instead.
+func (store *SQLStore) execTx(ctx context.Context, name string, fn func(*Queries) error) error {
+	if store.inTx {
+		return fn(store.Queries)
+	}
+
+	start := time.Now()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = store.runTx(ctx, fn)
+		if err == nil || attempt == maxTxAttempts || !isRetryable(err) || ctx.Err() != nil {
+			break
+		}
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("error", err.Error()),
+		))
+		slog.WarnContext(ctx, "retrying transaction",
+			slog.String("tx", name),
+			slog.Int("attempt", attempt),
+			slog.Any("error", err),
+		)
+		if store.observer != nil {
+			store.observer.TxRetried(name, err)
+		}
+	}
+
+	if store.observer != nil {
+		store.observer.TxFinished(name, time.Since(start), err)
+	}
+	return err
+}
+
+// isRetryable reports whether err means the transaction can safely be run
+// again from the start
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return true
+	default:
+		return false
 	}
 }
 
-func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
+func (store *SQLStore) runTx(ctx context.Context, fn func(*Queries) error) error {
 	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	q := New(tx)
+	q := NewTraced(tx)
 	err = fn(q)
 	if err != nil {
 		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+			return fmt.Errorf("tx err: %w, rb err: %v", err, rbErr)
 		}
 		return err
 	}
@@ -44,6 +190,9 @@ type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	Amount        int64 `json:"amount"`
+	// ApprovalID is the approved transfer approval whose pending transfer
+	// is executed, see DecideTransferApprovalTx
+	ApprovalID int64 `json:"approval_id,omitempty"`
 }
 
 type TransferTxResult struct {
@@ -52,57 +201,371 @@ type TransferTxResult struct {
 	ToAccount   Account  `json:"to_account"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
+	// Alerts are raised by the monitoring rules that the transfer matched,
+	// see WithMonitor
+	Alerts []MonitoringAlert `json:"alerts,omitempty"`
+	// Approval is the approval executed by the transfer, if any
+	Approval *TransferApproval `json:"approval,omitempty"`
 }
 
-// TransferTx performs a money transfer from one account to another.
+// TransferTx performs a money transfer from one account to another. It
+// fails with ErrNegativeBalance when the sender does not hold the amount,
+// with ErrAccountNotActive unless both accounts are active, and with
+// ErrTransferLimitExceeded or ErrBalanceLimitExceeded when the transfer
+// breaks a limit of the KYC tier of either owner. It fails with
+// ErrLimitExceeded when the transfer breaks a transfer limit of the sending
+// account or its owner. A transfer that matches a blocking monitoring rule
+// is not executed but held for review, which fails with ErrTransferHeld and
+// returns the held transfer and its alert. With an ApprovalID the pending
+// transfer of that approval is executed instead of a new one, which fails
+// with ErrApprovalNotApproved unless the approval is approved and not yet
+// executed.
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
+	ctx, span := startTxSpan(ctx, "TransferTx",
+		attribute.Int64("transfer.from_account_id", arg.FromAccountID),
+		attribute.Int64("transfer.to_account_id", arg.ToAccountID),
+		attribute.Int64("transfer.amount", arg.Amount),
+	)
+	defer span.End()
+
 	var result TransferTxResult
+	var held []monitoring.Hit
 
-	err := store.execTx(ctx, func(q *Queries) error {
+	err := store.execTx(ctx, "TransferTx", func(q *Queries) error {
 		var err error
+		if arg.ApprovalID != 0 {
+			err = executeApproval(ctx, q, arg.ApprovalID, &result)
+		} else {
+			err = executeTransfer(ctx, q, arg, &result)
+		}
+		if err != nil {
+			return err
+		}
+
+		result.Alerts, held, err = store.monitorTransfer(ctx, q, result)
+		if err == nil && held != nil {
+			// roll the transfer back, the alert is written below
+			err = ErrTransferHeld
+		}
+		return err
+	})
+	if held != nil && errors.Is(err, ErrTransferHeld) {
+		result, err = store.holdTransfer(ctx, arg, held)
+	}
+
+	recordError(span, err)
+	return result, err
+}
+
+// executeTransfer books a transfer from arg and checks the accounts and the
+// limits
+func executeTransfer(ctx context.Context, q *Queries, arg TransferTxParams, result *TransferTxResult) error {
+	var err error
+
+	// Create transfer record
+	result.Transfer, err = bookTransfer(ctx, q, CreateTransfersParams{
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount,
+	})
+	if err != nil {
+		return err
+	}
+	return settleTransfer(ctx, q, result)
+}
+
+// settleTransfer moves the money of result.Transfer and checks the balance,
+// the accounts and the limits. Only a check of the balance inside the
+// transaction cannot be outrun by a concurrent transfer.
+func settleTransfer(ctx context.Context, q *Queries, result *TransferTxResult) error {
+	if err := moveMoney(ctx, q, result); err != nil {
+		return err
+	}
+	if result.FromAccount.Balance < 0 {
+		return fmt.Errorf("%w: account %d would have balance %d",
+			ErrNegativeBalance, result.FromAccount.ID, result.FromAccount.Balance)
+	}
+	if err := requireActive(result.FromAccount, result.ToAccount); err != nil {
+		return err
+	}
+	if err := checkKYCLimits(ctx, q, *result); err != nil {
+		return err
+	}
+	return checkTransferLimits(ctx, q, *result)
+}
+
+// ReverseTransferTx books a compensating transfer that returns the money of
+// an earlier transfer, which becomes reversed. A transfer can only be
+// reversed once, and only while both accounts are active. It fails with
+// ErrInvalidTransferTransition unless the transfer is completed, with
+// ErrTransferIsReversal for a reversal and with ErrNegativeBalance when
+// the receiver no longer holds the money.
+func (store *SQLStore) ReverseTransferTx(ctx context.Context, transferID int64) (TransferTxResult, error) {
+	ctx, span := startTxSpan(ctx, "ReverseTransferTx",
+		attribute.Int64("transfer.id", transferID),
+	)
+	defer span.End()
 
-		// Create transfer record
-		result.Transfer, err = q.CreateTransfers(ctx, CreateTransfersParams(arg))
+	var result TransferTxResult
+
+	err := store.execTx(ctx, "ReverseTransferTx", func(q *Queries) error {
+		original, err := q.GetTransfers(ctx, transferID)
 		if err != nil {
 			return err
 		}
 
-		// Create entries
-		result.FromEntry, err = q.CreateEntries(ctx, CreateEntriesParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
+		_, err = q.GetTransferReversal(ctx, transferID)
+		if err == nil {
+			return fmt.Errorf("%w: transfer %d", ErrTransferAlreadyReversed, transferID)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		_, err = q.GetTransferReversalByReversalID(ctx, transferID)
+		if err == nil {
+			return fmt.Errorf("%w: transfer %d", ErrTransferIsReversal, transferID)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		if _, err := changeTransferStatus(ctx, q, original, TransferReverse, ""); err != nil {
+			return err
+		}
+
+		result.Transfer, err = bookTransfer(ctx, q, CreateTransfersParams{
+			FromAccountID: original.ToAccountID,
+			ToAccountID:   original.FromAccountID,
+			Amount:        original.Amount,
 		})
 		if err != nil {
 			return err
 		}
 
-		result.ToEntry, err = q.CreateEntries(ctx, CreateEntriesParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
+		_, err = q.CreateTransferReversal(ctx, CreateTransferReversalParams{
+			TransferID: original.ID,
+			ReversalID: result.Transfer.ID,
 		})
 		if err != nil {
 			return err
 		}
 
-		// 3. Get accounts for update (always get smaller ID first to prevent deadlock)
-		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, q,
-				arg.FromAccountID, -arg.Amount,
-				arg.ToAccountID, arg.Amount,
-			)
-		} else {
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, q,
-				arg.ToAccountID, arg.Amount,
-				arg.FromAccountID, -arg.Amount,
-			)
+		if err := moveMoney(ctx, q, &result); err != nil {
+			return err
+		}
+
+		// the receiver may have spent the money since
+		if result.FromAccount.Balance < 0 {
+			return fmt.Errorf("%w: account %d would have balance %d",
+				ErrNegativeBalance, result.FromAccount.ID, result.FromAccount.Balance)
+		}
+		return requireActive(result.FromAccount, result.ToAccount)
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+// moveMoney creates the entries of result.Transfer and updates both balances
+func moveMoney(ctx context.Context, q *Queries, result *TransferTxResult) error {
+	var err error
+	transfer := result.Transfer
+
+	// Create entries
+	result.FromEntry, err = q.CreateEntries(ctx, CreateEntriesParams{
+		AccountID: transfer.FromAccountID,
+		Amount:    -transfer.Amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	result.ToEntry, err = q.CreateEntries(ctx, CreateEntriesParams{
+		AccountID: transfer.ToAccountID,
+		Amount:    transfer.Amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Update accounts (always update the smaller ID first to prevent deadlock)
+	if transfer.FromAccountID < transfer.ToAccountID {
+		result.FromAccount, result.ToAccount, err = addMoney(ctx, q,
+			transfer.FromAccountID, -transfer.Amount,
+			transfer.ToAccountID, transfer.Amount,
+		)
+	} else {
+		result.ToAccount, result.FromAccount, err = addMoney(ctx, q,
+			transfer.ToAccountID, transfer.Amount,
+			transfer.FromAccountID, -transfer.Amount,
+		)
+	}
+
+	return err
+}
+
+// requireActive fails unless all accounts are active. It is given the rows
+// returned by the balance updates, which hold the row locks, so it also
+// sees a status changed by a concurrent transaction.
+func requireActive(accounts ...Account) error {
+	for _, account := range accounts {
+		if account.Status != AccountStatusActive {
+			return fmt.Errorf("%w: account %d is %s", ErrAccountNotActive, account.ID, account.Status)
+		}
+	}
+	return nil
+}
+
+type AdjustBalanceTxParams struct {
+	AccountID int64 `json:"account_id"`
+	Amount    int64 `json:"amount"`
+}
+
+type AdjustBalanceTxResult struct {
+	Account Account `json:"account"`
+	Entry   Entry   `json:"entry"`
+}
+
+// AdjustBalanceTx posts a manual adjustment to an active account, recording
+// an entry so that the ledger still reconciles with the balance.
+func (store *SQLStore) AdjustBalanceTx(ctx context.Context, arg AdjustBalanceTxParams) (AdjustBalanceTxResult, error) {
+	ctx, span := startTxSpan(ctx, "AdjustBalanceTx",
+		attribute.Int64("account.id", arg.AccountID),
+		attribute.Int64("adjustment.amount", arg.Amount),
+	)
+	defer span.End()
+
+	var result AdjustBalanceTxResult
+
+	err := store.execTx(ctx, "AdjustBalanceTx", func(q *Queries) error {
+		var err error
+
+		result.Entry, err = q.CreateEntries(ctx, CreateEntriesParams(arg))
+		if err != nil {
+			return err
 		}
 
-		return nil
+		result.Account, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     arg.AccountID,
+			Amount: arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		if result.Account.Balance < 0 {
+			return fmt.Errorf("%w: account %d would have balance %d",
+				ErrNegativeBalance, arg.AccountID, result.Account.Balance)
+		}
+		return requireActive(result.Account)
 	})
 
+	recordError(span, err)
 	return result, err
 }
 
+type EnableTOTPTxParams struct {
+	Username string `json:"username"`
+	// HashedRecoveryCodes replace any recovery codes issued before
+	HashedRecoveryCodes []string `json:"hashed_recovery_codes"`
+}
+
+// EnableTOTPTx turns on two-factor authentication with the enrolled secret
+// and stores a fresh set of recovery codes
+func (store *SQLStore) EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, "EnableTOTPTx", func(q *Queries) error {
+		if err := q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+		for _, hashedCode := range arg.HashedRecoveryCodes {
+			_, err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username:   arg.Username,
+				HashedCode: hashedCode,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		user, err = q.EnableTOTP(ctx, arg.Username)
+		return err
+	})
+	return store.openUser(ctx, user, err)
+}
+
+// VerifyEmailTx uses an email verification token and marks the address it
+// was sent to as verified. sql.ErrNoRows means the token is invalid, or the
+// email of the user changed since.
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, hashedToken string) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, "VerifyEmailTx", func(q *Queries) error {
+		verifyEmail, err := q.UseVerifyEmail(ctx, UseVerifyEmailParams{
+			HashedToken: hashedToken,
+			Purpose:     VerifyEmailPurposeEmailVerification,
+		})
+		if err != nil {
+			return err
+		}
+
+		// the token was sent to the current email if their indexes match
+		email, err := store.decrypt(ctx, verifyEmail.Email)
+		if err != nil {
+			return err
+		}
+		index, err := store.emailIndex(ctx, email)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.VerifyUserEmail(ctx, VerifyUserEmailParams{
+			Username:   verifyEmail.Username,
+			EmailIndex: index,
+		})
+		return err
+	})
+	return store.openUser(ctx, user, err)
+}
+
+type ResetPasswordTxParams struct {
+	HashedToken    string `json:"hashed_token"`
+	HashedPassword string `json:"hashed_password"`
+}
+
+// ResetPasswordTx uses a password reset token to replace the password of
+// its user. Proving access to the email also lifts a login lockout.
+// sql.ErrNoRows means the token is invalid.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, "ResetPasswordTx", func(q *Queries) error {
+		verifyEmail, err := q.UseVerifyEmail(ctx, UseVerifyEmailParams{
+			HashedToken: arg.HashedToken,
+			Purpose:     VerifyEmailPurposePasswordReset,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.UpdateUser(ctx, UpdateUserParams{
+			Username:          verifyEmail.Username,
+			HashedPassword:    sql.NullString{String: arg.HashedPassword, Valid: true},
+			PasswordChangedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		user, err = q.ResetFailedLogins(ctx, verifyEmail.Username)
+		return err
+	})
+	return store.openUser(ctx, user, err)
+}
+
 func addMoney(
 	ctx context.Context,
 	q *Queries,
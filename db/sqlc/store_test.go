@@ -2,6 +2,9 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -10,8 +13,8 @@ import (
 func TestTransferTx(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account1 := createAccountWithBalance(t, "USD", 1000)
+	account2 := createAccountWithBalance(t, "USD", 1000)
 
 	// Log initial balances
 	t.Log(">> before:", account1.Balance, account2.Balance)
@@ -115,8 +118,8 @@ func TestTransferTx(t *testing.T) {
 func TestTransferTxDeadlock(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account1 := createAccountWithBalance(t, "USD", 1000)
+	account2 := createAccountWithBalance(t, "USD", 1000)
 
 	t.Log(">> before:", account1.Balance, account2.Balance)
 
@@ -162,3 +165,235 @@ func TestTransferTxDeadlock(t *testing.T) {
 	require.Equal(t, account1.Balance, updatedAccount1.Balance)
 	require.Equal(t, account2.Balance, updatedAccount2.Balance)
 }
+
+func TestTransferTxConcurrentOverdraw(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createAccountWithBalance(t, "USD", 100)
+	account2 := createAccountWithBalance(t, "USD", 0)
+
+	// each transfer alone is covered, together they would overdraw account1
+	n := 5
+	amount := int64(50)
+	errs := make(chan error)
+
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(context.Background(), TransferTxParams{
+				FromAccountID: account1.ID,
+				ToAccountID:   account2.ID,
+				Amount:        amount,
+			})
+
+			errs <- err
+		}()
+	}
+
+	completed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			completed++
+			continue
+		}
+		require.ErrorIs(t, err, ErrNegativeBalance)
+	}
+	require.Equal(t, 2, completed)
+
+	updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, updatedAccount1.Balance)
+
+	updatedAccount2, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount2.Balance)
+}
+
+func TestReverseTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createAccountWithBalance(t, "USD", 1000)
+	account2 := createAccountWithBalance(t, "USD", 1000)
+	amount := int64(10)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+	})
+	require.NoError(t, err)
+
+	result, err := store.ReverseTransferTx(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.ID, result.Transfer.FromAccountID)
+	require.Equal(t, account1.ID, result.Transfer.ToAccountID)
+	require.Equal(t, amount, result.Transfer.Amount)
+	require.Equal(t, account1.Balance, result.ToAccount.Balance)
+	require.Equal(t, account2.Balance, result.FromAccount.Balance)
+
+	reversal, err := store.GetTransferReversal(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Transfer.ID, reversal.ReversalID)
+
+	// the original keeps its history and ends reversed
+	transfer, err := store.GetTransfers(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusReversed, transfer.Status)
+	events, err := store.ListTransferEvents(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, TransferStatusCompleted, events[0].Status)
+	require.Equal(t, TransferStatusReversed, events[1].Status)
+	require.Equal(t, TransferStatusCompleted, result.Transfer.Status)
+
+	// a transfer can only be reversed once
+	_, err = store.ReverseTransferTx(context.Background(), original.Transfer.ID)
+	require.ErrorIs(t, err, ErrTransferAlreadyReversed)
+}
+
+func TestReverseTransferTxOfReversal(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createAccountWithBalance(t, "USD", 1000)
+	account2 := createAccountWithBalance(t, "USD", 1000)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	reversal, err := store.ReverseTransferTx(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+
+	_, err = store.ReverseTransferTx(context.Background(), reversal.Transfer.ID)
+	require.ErrorIs(t, err, ErrTransferIsReversal)
+
+	transfer, err := store.GetTransfers(context.Background(), reversal.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusCompleted, transfer.Status)
+}
+
+func TestReverseTransferTxSpentMoney(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createAccountWithBalance(t, "USD", 1000)
+	account2 := createAccountWithBalance(t, "USD", 100)
+	account3 := createAccountWithBalance(t, "USD", 0)
+	amount := account2.Balance + 10
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+	})
+	require.NoError(t, err)
+
+	// the receiver spends everything it holds
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account3.ID,
+		Amount:        original.ToAccount.Balance,
+	})
+	require.NoError(t, err)
+
+	_, err = store.ReverseTransferTx(context.Background(), original.Transfer.ID)
+	require.ErrorIs(t, err, ErrNegativeBalance)
+
+	// nothing changed
+	receiver, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Zero(t, receiver.Balance)
+	transfer, err := store.GetTransfers(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusCompleted, transfer.Status)
+	_, err = store.GetTransferReversal(context.Background(), original.Transfer.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestExecTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := createAccountWithBalance(t, "USD", 100)
+	target := fmt.Sprintf("account:%d", account.ID)
+
+	adjust := func(tx Store) error {
+		if _, err := tx.AdjustBalanceTx(context.Background(), AdjustBalanceTxParams{
+			AccountID: account.ID,
+			Amount:    25,
+		}); err != nil {
+			return err
+		}
+		_, err := tx.AppendAuditEventTx(context.Background(), AppendAuditEventTxParams{
+			Actor:  "cli:operator",
+			Action: "account.adjusted",
+			Target: target,
+		})
+		return err
+	}
+
+	// a failure rolls back the adjustment and its audit event
+	errFailed := errors.New("failed")
+	err := store.ExecTx(context.Background(), func(tx Store) error {
+		if err := adjust(tx); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+
+	unchanged, err := store.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), unchanged.Balance)
+	last, err := store.GetLastAuditEvent(context.Background())
+	if err == nil {
+		require.NotEqual(t, target, last.Target)
+	}
+
+	// otherwise both are committed
+	require.NoError(t, store.ExecTx(context.Background(), adjust))
+
+	adjusted, err := store.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(125), adjusted.Balance)
+	last, err = store.GetLastAuditEvent(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, target, last.Target)
+}
+
+func TestAdjustBalanceTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+
+	result, err := store.AdjustBalanceTx(context.Background(), AdjustBalanceTxParams{
+		AccountID: account.ID,
+		Amount:    25,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account.Balance+25, result.Account.Balance)
+	require.Equal(t, account.ID, result.Entry.AccountID)
+	require.Equal(t, int64(25), result.Entry.Amount)
+
+	// adjustments cannot overdraw the account
+	_, err = store.AdjustBalanceTx(context.Background(), AdjustBalanceTxParams{
+		AccountID: account.ID,
+		Amount:    -(result.Account.Balance + 1),
+	})
+	require.ErrorIs(t, err, ErrNegativeBalance)
+
+	unchanged, err := store.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Account.Balance, unchanged.Balance)
+
+	// nor move the balance of a frozen account
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  account.ID,
+		Transition: AccountFreeze,
+	})
+	require.NoError(t, err)
+	_, err = store.AdjustBalanceTx(context.Background(), AdjustBalanceTxParams{
+		AccountID: account.ID,
+		Amount:    25,
+	})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/hiiamanop/simple_bank/db/sqlc"
+
+var tracer = otel.Tracer(tracerName)
+
+// tracedDBTX creates a client span for every query sent through db. The span
+// is named after the sqlc query, e.g. "GetAccount".
+type tracedDBTX struct {
+	db DBTX
+}
+
+// NewTraced returns Queries whose queries are traced
+func NewTraced(db DBTX) *Queries {
+	return New(&tracedDBTX{db: db})
+}
+
+func (t *tracedDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	result, err := t.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (t *tracedDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	stmt, err := t.db.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+func (t *tracedDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (t *tracedDBTX) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	row := t.db.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != sql.ErrNoRows {
+		recordError(span, err)
+	}
+	return row
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, queryName(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// queryName extracts the name from the "-- name: GetAccount :one" header
+// that sqlc puts in front of every query
+func queryName(query string) string {
+	header, _, _ := strings.Cut(query, "\n")
+	fields := strings.Fields(header)
+	if len(fields) >= 3 && fields[0] == "--" && fields[1] == "name:" {
+		return fields[2]
+	}
+	return "query"
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// startTxSpan creates the span that groups the queries of a transaction
+func startTxSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryName(t *testing.T) {
+	testCases := []struct {
+		query string
+		name  string
+	}{
+		{query: getAccount, name: "GetAccount"},
+		{query: listUnbalancedAccounts, name: "ListUnbalancedAccounts"},
+		{query: "SELECT 1", name: "query"},
+		{query: "", name: "query"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.name, queryName(tc.query))
+	}
+}
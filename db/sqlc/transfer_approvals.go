@@ -0,0 +1,365 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Transfer approval statuses. A transfer that needs approval waits as
+// pending_approval without moving money, and its transfer as pending. The
+// final approval makes it approved, and executing it executed or failed. A
+// rejected or expired transfer never moves money and its transfer is
+// cancelled.
+const (
+	ApprovalStatusPending  = "pending_approval"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusExecuted = "executed"
+	ApprovalStatusFailed   = "failed"
+	ApprovalStatusRejected = "rejected"
+	ApprovalStatusExpired  = "expired"
+)
+
+// Decisions of an approver
+const (
+	ApprovalDecisionApprove = "approve"
+	ApprovalDecisionReject  = "reject"
+)
+
+var (
+	ErrTooFewApprovers     = errors.New("policy requires more approvals than it has approvers")
+	ErrApprovalNotPending  = errors.New("transfer approval is not pending")
+	ErrApprovalExpired     = errors.New("transfer approval has expired")
+	ErrApprovalNotApproved = errors.New("transfer approval is not approved")
+	ErrNotApprover         = errors.New("user is not an approver of the account")
+	ErrApproverIsInitiator = errors.New("the initiator of a transfer cannot decide on it")
+	ErrApprovalDecided     = errors.New("approver has already decided on the transfer")
+)
+
+type CreateTransferApprovalTxParams struct {
+	FromAccountID     int64     `json:"from_account_id"`
+	ToAccountID       int64     `json:"to_account_id"`
+	Amount            int64     `json:"amount"`
+	InitiatedBy       string    `json:"initiated_by"`
+	RequiredApprovals int32     `json:"required_approvals"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+type CreateTransferApprovalTxResult struct {
+	Approval TransferApproval `json:"approval"`
+	Transfer Transfer         `json:"transfer"`
+}
+
+// CreateTransferApprovalTx requests the approval of a transfer. The
+// transfer is created pending and moves its money when the final approval
+// executes it with TransferTx.
+func (store *SQLStore) CreateTransferApprovalTx(ctx context.Context, arg CreateTransferApprovalTxParams) (CreateTransferApprovalTxResult, error) {
+	ctx, span := startTxSpan(ctx, "CreateTransferApprovalTx",
+		attribute.Int64("transfer.from_account_id", arg.FromAccountID),
+		attribute.Int64("transfer.to_account_id", arg.ToAccountID),
+		attribute.Int64("transfer.amount", arg.Amount),
+	)
+	defer span.End()
+
+	var result CreateTransferApprovalTxResult
+
+	err := store.execTx(ctx, "CreateTransferApprovalTx", func(q *Queries) error {
+		var err error
+		result.Transfer, err = bookPendingTransfer(ctx, q, CreatePendingTransferParams{
+			FromAccountID: arg.FromAccountID,
+			ToAccountID:   arg.ToAccountID,
+			Amount:        arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Approval, err = q.CreateTransferApproval(ctx, CreateTransferApprovalParams{
+			FromAccountID:     arg.FromAccountID,
+			ToAccountID:       arg.ToAccountID,
+			Amount:            arg.Amount,
+			InitiatedBy:       arg.InitiatedBy,
+			RequiredApprovals: arg.RequiredApprovals,
+			ExpiresAt:         arg.ExpiresAt,
+			TransferID:        sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+		})
+		return err
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+type SetApprovalPolicyTxParams struct {
+	AccountID         int64    `json:"account_id"`
+	Threshold         int64    `json:"threshold"`
+	RequiredApprovals int32    `json:"required_approvals"`
+	Approvers         []string `json:"approvers"`
+	UpdatedBy         string   `json:"updated_by"`
+}
+
+type SetApprovalPolicyTxResult struct {
+	Policy    ApprovalPolicy `json:"policy"`
+	Approvers []string       `json:"approvers"`
+}
+
+// SetApprovalPolicyTx sets the approval policy of an account and replaces
+// its approvers. It fails with ErrTooFewApprovers when the policy requires
+// more approvals than it has approvers, with sql.ErrNoRows when an approver
+// does not exist and with ErrUserDeleted when one is deleted.
+func (store *SQLStore) SetApprovalPolicyTx(ctx context.Context, arg SetApprovalPolicyTxParams) (SetApprovalPolicyTxResult, error) {
+	ctx, span := startTxSpan(ctx, "SetApprovalPolicyTx",
+		attribute.Int64("account.id", arg.AccountID),
+	)
+	defer span.End()
+
+	approvers := slices.Clone(arg.Approvers)
+	slices.Sort(approvers)
+	approvers = slices.Compact(approvers)
+	if len(approvers) < int(arg.RequiredApprovals) {
+		err := fmt.Errorf("%w: %d approvals, %d approvers", ErrTooFewApprovers, arg.RequiredApprovals, len(approvers))
+		recordError(span, err)
+		return SetApprovalPolicyTxResult{}, err
+	}
+
+	var result SetApprovalPolicyTxResult
+
+	err := store.execTx(ctx, "SetApprovalPolicyTx", func(q *Queries) error {
+		var err error
+		result.Policy, err = q.UpsertApprovalPolicy(ctx, UpsertApprovalPolicyParams{
+			AccountID:         arg.AccountID,
+			Threshold:         arg.Threshold,
+			RequiredApprovals: arg.RequiredApprovals,
+			UpdatedBy:         arg.UpdatedBy,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteAccountApprovers(ctx, arg.AccountID); err != nil {
+			return err
+		}
+		for _, username := range approvers {
+			user, err := q.GetUser(ctx, username)
+			if err != nil {
+				return fmt.Errorf("approver %s: %w", username, err)
+			}
+			if user.DeletedAt.Valid {
+				return fmt.Errorf("%w: approver %s", ErrUserDeleted, username)
+			}
+			err = q.AddAccountApprover(ctx, AddAccountApproverParams{
+				AccountID: arg.AccountID,
+				Username:  username,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		result.Approvers = approvers
+		return nil
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+type DecideTransferApprovalTxParams struct {
+	ApprovalID int64  `json:"approval_id"`
+	Approver   string `json:"approver"`
+	Decision   string `json:"decision"`
+	Note       string `json:"note"`
+}
+
+type DecideTransferApprovalTxResult struct {
+	Approval TransferApproval         `json:"approval"`
+	Decision TransferApprovalDecision `json:"decision"`
+}
+
+// DecideTransferApprovalTx records the decision of an approver on a pending
+// transfer. A rejection rejects the approval and cancels the transfer, and
+// the last required approval approves it; the caller then executes it with
+// TransferTx. It fails with ErrApprovalNotPending or ErrApprovalExpired
+// unless the transfer awaits approval, with ErrNotApprover unless the
+// approver is a designated approver of the sending account, with
+// ErrApproverIsInitiator when the approver initiated the transfer, and with
+// ErrApprovalDecided when the approver decided before.
+func (store *SQLStore) DecideTransferApprovalTx(ctx context.Context, arg DecideTransferApprovalTxParams) (DecideTransferApprovalTxResult, error) {
+	ctx, span := startTxSpan(ctx, "DecideTransferApprovalTx",
+		attribute.Int64("approval.id", arg.ApprovalID),
+		attribute.String("approval.decision", arg.Decision),
+	)
+	defer span.End()
+
+	var result DecideTransferApprovalTxResult
+
+	err := store.execTx(ctx, "DecideTransferApprovalTx", func(q *Queries) error {
+		approval, err := q.GetTransferApprovalForUpdate(ctx, arg.ApprovalID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case approval.Status != ApprovalStatusPending:
+			return fmt.Errorf("%w: approval %d is %s", ErrApprovalNotPending, approval.ID, approval.Status)
+		case !time.Now().Before(approval.ExpiresAt):
+			return fmt.Errorf("%w: approval %d expired at %s", ErrApprovalExpired, approval.ID, approval.ExpiresAt.Format(time.RFC3339))
+		case approval.InitiatedBy == arg.Approver:
+			return fmt.Errorf("%w: %s initiated approval %d", ErrApproverIsInitiator, arg.Approver, approval.ID)
+		}
+
+		approver, err := q.IsAccountApprover(ctx, IsAccountApproverParams{
+			AccountID: approval.FromAccountID,
+			Username:  arg.Approver,
+		})
+		if err != nil {
+			return err
+		}
+		if !approver {
+			return fmt.Errorf("%w: %s, account %d", ErrNotApprover, arg.Approver, approval.FromAccountID)
+		}
+
+		decisions, err := q.ListTransferApprovalDecisions(ctx, approval.ID)
+		if err != nil {
+			return err
+		}
+		for _, decision := range decisions {
+			if decision.Approver == arg.Approver {
+				return fmt.Errorf("%w: %s decided to %s approval %d", ErrApprovalDecided, arg.Approver, decision.Decision, approval.ID)
+			}
+		}
+
+		result.Decision, err = q.CreateTransferApprovalDecision(ctx, CreateTransferApprovalDecisionParams{
+			ApprovalID: approval.ID,
+			Approver:   arg.Approver,
+			Decision:   arg.Decision,
+			Note:       arg.Note,
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.Decision != ApprovalDecisionReject {
+			result.Approval, err = q.ApproveTransferApproval(ctx, approval.ID)
+			return err
+		}
+		result.Approval, err = q.RejectTransferApproval(ctx, approval.ID)
+		if err != nil {
+			return err
+		}
+		return cancelApprovalTransfer(ctx, q, result.Approval, TransferReasonApprovalRejected)
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+type FailTransferApprovalTxParams struct {
+	ApprovalID int64 `json:"approval_id"`
+	// FailureReason is the error code of the failed execution
+	FailureReason string `json:"failure_reason"`
+}
+
+// FailTransferApprovalTx records that an approved transfer could not be
+// executed, failing the approval and its transfer with the reason. A held
+// transfer stays held, its monitoring alert decides on it.
+func (store *SQLStore) FailTransferApprovalTx(ctx context.Context, arg FailTransferApprovalTxParams) (TransferApproval, error) {
+	ctx, span := startTxSpan(ctx, "FailTransferApprovalTx",
+		attribute.Int64("approval.id", arg.ApprovalID),
+		attribute.String("approval.failure_reason", arg.FailureReason),
+	)
+	defer span.End()
+
+	var approval TransferApproval
+
+	err := store.execTx(ctx, "FailTransferApprovalTx", func(q *Queries) error {
+		var err error
+		approval, err = q.FailTransferApproval(ctx, FailTransferApprovalParams{
+			FailureReason: arg.FailureReason,
+			ID:            arg.ApprovalID,
+		})
+		if err != nil || !approval.TransferID.Valid {
+			return err
+		}
+
+		transfer, err := q.GetTransfers(ctx, approval.TransferID.Int64)
+		if err != nil || transfer.Status == TransferStatusHeld {
+			return err
+		}
+		_, err = changeTransferStatus(ctx, q, transfer, TransferFail, arg.FailureReason)
+		return err
+	})
+
+	recordError(span, err)
+	return approval, err
+}
+
+// ExpireTransferApprovalsTx expires the pending approvals that were not
+// decided in time and cancels their transfers
+func (store *SQLStore) ExpireTransferApprovalsTx(ctx context.Context) ([]TransferApproval, error) {
+	ctx, span := startTxSpan(ctx, "ExpireTransferApprovalsTx")
+	defer span.End()
+
+	var approvals []TransferApproval
+
+	err := store.execTx(ctx, "ExpireTransferApprovalsTx", func(q *Queries) error {
+		var err error
+		approvals, err = q.ExpireTransferApprovals(ctx)
+		if err != nil {
+			return err
+		}
+		for _, approval := range approvals {
+			if err := cancelApprovalTransfer(ctx, q, approval, TransferReasonApprovalExpired); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	recordError(span, err)
+	return approvals, err
+}
+
+// cancelApprovalTransfer cancels the pending transfer of an approval that
+// was rejected or expired
+func cancelApprovalTransfer(ctx context.Context, q *Queries, approval TransferApproval, reason string) error {
+	if !approval.TransferID.Valid {
+		return nil
+	}
+	transfer, err := q.GetTransfers(ctx, approval.TransferID.Int64)
+	if err != nil {
+		return err
+	}
+	_, err = changeTransferStatus(ctx, q, transfer, TransferCancel, reason)
+	return err
+}
+
+// executeApproval marks an approved approval as executed and moves the
+// money of its pending transfer
+func executeApproval(ctx context.Context, q *Queries, approvalID int64, result *TransferTxResult) error {
+	approval, err := q.ExecuteTransferApproval(ctx, approvalID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: approval %d", ErrApprovalNotApproved, approvalID)
+	}
+	if err != nil {
+		return err
+	}
+	result.Approval = &approval
+
+	transfer, err := q.GetTransfers(ctx, approval.TransferID.Int64)
+	if err != nil {
+		return err
+	}
+	result.Transfer, err = changeTransferStatus(ctx, q, transfer, TransferProcess, "")
+	if err != nil {
+		return err
+	}
+	if err := settleTransfer(ctx, q, result); err != nil {
+		return err
+	}
+	result.Transfer, err = changeTransferStatus(ctx, q, result.Transfer, TransferComplete, "")
+	return err
+}
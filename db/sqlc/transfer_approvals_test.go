@@ -0,0 +1,258 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createPendingApproval(t *testing.T, from, to Account, amount int64, required int32, expiresAt time.Time) TransferApproval {
+	result, err := NewStore(testDB).CreateTransferApprovalTx(context.Background(), CreateTransferApprovalTxParams{
+		FromAccountID:     from.ID,
+		ToAccountID:       to.ID,
+		Amount:            amount,
+		InitiatedBy:       from.Owner,
+		RequiredApprovals: required,
+		ExpiresAt:         expiresAt,
+	})
+	require.NoError(t, err)
+	require.Equal(t, ApprovalStatusPending, result.Approval.Status)
+	require.Equal(t, TransferStatusPending, result.Transfer.Status)
+	require.Equal(t, result.Transfer.ID, result.Approval.TransferID.Int64)
+	return result.Approval
+}
+
+// requireTransferHistory checks the statuses the transfer of approval went
+// through, in order
+func requireTransferHistory(t *testing.T, approval TransferApproval, statuses ...string) []TransferEvent {
+	events, err := testQueries.ListTransferEvents(context.Background(), approval.TransferID.Int64)
+	require.NoError(t, err)
+	require.Len(t, events, len(statuses))
+	for i, event := range events {
+		require.Equal(t, statuses[i], event.Status)
+	}
+	return events
+}
+
+func TestSetApprovalPolicyTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+	approver1 := createRandomUser(t)
+	approver2 := createRandomUser(t)
+
+	arg := SetApprovalPolicyTxParams{
+		AccountID:         account.ID,
+		Threshold:         1000,
+		RequiredApprovals: 2,
+		Approvers:         []string{approver2.Username, approver1.Username, approver1.Username},
+		UpdatedBy:         "admin",
+	}
+	result, err := store.SetApprovalPolicyTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), result.Policy.Threshold)
+	require.Equal(t, int32(2), result.Policy.RequiredApprovals)
+	require.ElementsMatch(t, []string{approver1.Username, approver2.Username}, result.Approvers)
+
+	// the approvers are replaced
+	arg.RequiredApprovals = 1
+	arg.Approvers = []string{approver2.Username}
+	_, err = store.SetApprovalPolicyTx(context.Background(), arg)
+	require.NoError(t, err)
+	approvers, err := store.ListAccountApprovers(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{approver2.Username}, approvers)
+
+	arg.RequiredApprovals = 2
+	_, err = store.SetApprovalPolicyTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrTooFewApprovers)
+
+	arg.Approvers = []string{approver2.Username, randomString(8)}
+	_, err = store.SetApprovalPolicyTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a failed update keeps the policy
+	approvers, err = store.ListAccountApprovers(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{approver2.Username}, approvers)
+}
+
+func TestDecideTransferApprovalTx(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createAccountWithBalance(t, "USD", 10000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+	approver1 := createRandomUser(t)
+	approver2 := createRandomUser(t)
+	outsider := createRandomUser(t)
+
+	_, err := store.SetApprovalPolicyTx(context.Background(), SetApprovalPolicyTxParams{
+		AccountID:         account1.ID,
+		Threshold:         1000,
+		RequiredApprovals: 2,
+		Approvers:         []string{account1.Owner, approver1.Username, approver2.Username},
+	})
+	require.NoError(t, err)
+
+	approval := createPendingApproval(t, account1, account2, 5000, 2, time.Now().Add(time.Hour))
+	decide := func(approver, decision string) (DecideTransferApprovalTxResult, error) {
+		return store.DecideTransferApprovalTx(context.Background(), DecideTransferApprovalTxParams{
+			ApprovalID: approval.ID,
+			Approver:   approver,
+			Decision:   decision,
+			Note:       "checked the invoice",
+		})
+	}
+
+	// the maker is an approver of the account, but not of their own transfer
+	_, err = decide(account1.Owner, ApprovalDecisionApprove)
+	require.ErrorIs(t, err, ErrApproverIsInitiator)
+	_, err = decide(outsider.Username, ApprovalDecisionApprove)
+	require.ErrorIs(t, err, ErrNotApprover)
+
+	result, err := decide(approver1.Username, ApprovalDecisionApprove)
+	require.NoError(t, err)
+	require.Equal(t, ApprovalStatusPending, result.Approval.Status)
+	require.Equal(t, int32(1), result.Approval.Approvals)
+	require.Equal(t, ApprovalDecisionApprove, result.Decision.Decision)
+
+	_, err = decide(approver1.Username, ApprovalDecisionApprove)
+	require.ErrorIs(t, err, ErrApprovalDecided)
+
+	// the final approval moves no money by itself
+	result, err = decide(approver2.Username, ApprovalDecisionApprove)
+	require.NoError(t, err)
+	require.Equal(t, ApprovalStatusApproved, result.Approval.Status)
+	require.True(t, result.Approval.DecidedAt.Valid)
+	account, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(10000), account.Balance)
+
+	_, err = decide(outsider.Username, ApprovalDecisionReject)
+	require.ErrorIs(t, err, ErrApprovalNotPending)
+
+	// TransferTx executes the approval once
+	arg := TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        approval.Amount,
+		ApprovalID:    approval.ID,
+	}
+	transfer, err := store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotNil(t, transfer.Approval)
+	require.Equal(t, ApprovalStatusExecuted, transfer.Approval.Status)
+	require.Equal(t, transfer.Transfer.ID, transfer.Approval.TransferID.Int64)
+	require.Equal(t, int64(5000), transfer.FromAccount.Balance)
+	require.Equal(t, approval.TransferID.Int64, transfer.Transfer.ID)
+	require.Equal(t, TransferStatusCompleted, transfer.Transfer.Status)
+	requireTransferHistory(t, approval,
+		TransferStatusPending, TransferStatusProcessing, TransferStatusCompleted)
+
+	_, err = store.TransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrApprovalNotApproved)
+	account, err = store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(5000), account.Balance)
+
+	decisions, err := store.ListTransferApprovalDecisions(context.Background(), approval.ID)
+	require.NoError(t, err)
+	require.Len(t, decisions, 2)
+}
+
+func TestRejectAndExpireTransferApproval(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createAccountWithBalance(t, "USD", 10000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+	approver := createRandomUser(t)
+
+	_, err := store.SetApprovalPolicyTx(context.Background(), SetApprovalPolicyTxParams{
+		AccountID:         account1.ID,
+		Threshold:         1000,
+		RequiredApprovals: 1,
+		Approvers:         []string{approver.Username},
+	})
+	require.NoError(t, err)
+
+	// one rejection is final
+	rejected := createPendingApproval(t, account1, account2, 5000, 1, time.Now().Add(time.Hour))
+	result, err := store.DecideTransferApprovalTx(context.Background(), DecideTransferApprovalTxParams{
+		ApprovalID: rejected.ID,
+		Approver:   approver.Username,
+		Decision:   ApprovalDecisionReject,
+	})
+	require.NoError(t, err)
+	require.Equal(t, ApprovalStatusRejected, result.Approval.Status)
+	events := requireTransferHistory(t, rejected, TransferStatusPending, TransferStatusCancelled)
+	require.Equal(t, TransferReasonApprovalRejected, events[1].Reason)
+
+	expired := createPendingApproval(t, account1, account2, 5000, 1, time.Now().Add(-time.Second))
+	_, err = store.DecideTransferApprovalTx(context.Background(), DecideTransferApprovalTxParams{
+		ApprovalID: expired.ID,
+		Approver:   approver.Username,
+		Decision:   ApprovalDecisionApprove,
+	})
+	require.ErrorIs(t, err, ErrApprovalExpired)
+
+	approvals, err := store.ExpireTransferApprovalsTx(context.Background())
+	require.NoError(t, err)
+	var ids []int64
+	for _, approval := range approvals {
+		require.Equal(t, ApprovalStatusExpired, approval.Status)
+		ids = append(ids, approval.ID)
+	}
+	require.Contains(t, ids, expired.ID)
+	require.NotContains(t, ids, rejected.ID)
+	events = requireTransferHistory(t, expired, TransferStatusPending, TransferStatusCancelled)
+	require.Equal(t, TransferReasonApprovalExpired, events[1].Reason)
+
+	// a transfer that is not approved cannot be executed
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        5000,
+		ApprovalID:    expired.ID,
+	})
+	require.ErrorIs(t, err, ErrApprovalNotApproved)
+}
+
+func TestFailTransferApprovalTx(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createAccountWithBalance(t, "USD", 10000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+	approver := createRandomUser(t)
+
+	_, err := store.SetApprovalPolicyTx(context.Background(), SetApprovalPolicyTxParams{
+		AccountID:         account1.ID,
+		Threshold:         1000,
+		RequiredApprovals: 1,
+		Approvers:         []string{approver.Username},
+	})
+	require.NoError(t, err)
+
+	approval := createPendingApproval(t, account1, account2, 5000, 1, time.Now().Add(time.Hour))
+	_, err = store.DecideTransferApprovalTx(context.Background(), DecideTransferApprovalTxParams{
+		ApprovalID: approval.ID,
+		Approver:   approver.Username,
+		Decision:   ApprovalDecisionApprove,
+	})
+	require.NoError(t, err)
+
+	failed, err := store.FailTransferApprovalTx(context.Background(), FailTransferApprovalTxParams{
+		ApprovalID:    approval.ID,
+		FailureReason: "insufficient_balance",
+	})
+	require.NoError(t, err)
+	require.Equal(t, ApprovalStatusFailed, failed.Status)
+
+	transfer, err := store.GetTransfers(context.Background(), approval.TransferID.Int64)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusFailed, transfer.Status)
+	require.Equal(t, "insufficient_balance", transfer.FailureReason)
+	requireTransferHistory(t, approval, TransferStatusPending, TransferStatusFailed)
+
+	// a failed transfer cannot be reversed
+	_, err = store.ReverseTransferTx(context.Background(), transfer.ID)
+	require.ErrorIs(t, err, ErrInvalidTransferTransition)
+}
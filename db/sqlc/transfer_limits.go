@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Scopes of transfer limits. Account limits count the transfers of one
+// account, user limits those of all accounts of the user.
+const (
+	LimitScopeAccount = "account"
+	LimitScopeUser    = "user"
+)
+
+// Rules of transfer limits, named like the columns of transfer_limits
+const (
+	LimitRuleMaxAmount     = "max_amount"
+	LimitRuleDailyAmount   = "daily_amount"
+	LimitRuleMonthlyAmount = "monthly_amount"
+	LimitRuleHourlyCount   = "hourly_count"
+)
+
+var ErrLimitExceeded = errors.New("transfer limit exceeded")
+
+// LimitUsage is how much of one limit is used. Amounts are in the minor
+// unit of the account currency, HourlyCount rules count transfers.
+type LimitUsage struct {
+	Scope     string `json:"scope"`
+	Rule      string `json:"rule"`
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining int64  `json:"remaining"`
+}
+
+// TransferAllowance is what an account may still send under the limits of
+// the account and of its owner. Rules without a limit are left out.
+type TransferAllowance struct {
+	AccountID int64        `json:"account_id"`
+	Owner     string       `json:"owner"`
+	Limits    []LimitUsage `json:"limits"`
+}
+
+// GetTransferAllowance returns the limits that apply to the transfers of an
+// account and how much of each is left
+func (store *SQLStore) GetTransferAllowance(ctx context.Context, accountID int64) (TransferAllowance, error) {
+	var allowance TransferAllowance
+
+	err := store.execTx(ctx, "GetTransferAllowance", func(q *Queries) error {
+		account, err := q.GetAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		allowance, err = transferAllowance(ctx, q, account, 0)
+		return err
+	})
+	return allowance, err
+}
+
+// checkTransferLimits fails with ErrLimitExceeded when the transfer of
+// result breaks a limit of the sending account or its owner. The sending
+// account is locked by moveMoney, and the owner is locked here, so that
+// concurrent transfers are counted one after the other.
+func checkTransferLimits(ctx context.Context, q *Queries, result TransferTxResult) error {
+	if err := q.LockUserTransfers(ctx, result.FromAccount.Owner); err != nil {
+		return err
+	}
+	allowance, err := transferAllowance(ctx, q, result.FromAccount, result.Transfer.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, usage := range allowance.Limits {
+		need := result.Transfer.Amount
+		if usage.Rule == LimitRuleHourlyCount {
+			need = 1
+		}
+		if need > usage.Remaining {
+			subject := fmt.Sprintf("account %d", allowance.AccountID)
+			if usage.Scope == LimitScopeUser {
+				subject = "user " + allowance.Owner
+			}
+			return fmt.Errorf("%w: %s of %s is %d, %d remaining, transfer needs %d",
+				ErrLimitExceeded, usage.Rule, subject, usage.Limit, usage.Remaining, need)
+		}
+	}
+	return nil
+}
+
+// transferAllowance adds up the transfers sent by account and its owner,
+// leaving out the transfer excludeID, and compares them with their limits
+func transferAllowance(ctx context.Context, q *Queries, account Account, excludeID int64) (TransferAllowance, error) {
+	allowance := TransferAllowance{AccountID: account.ID, Owner: account.Owner, Limits: []LimitUsage{}}
+	accountSubject := strconv.FormatInt(account.ID, 10)
+
+	rules, err := q.ListTransferLimitsFor(ctx, ListTransferLimitsForParams{
+		AccountID: accountSubject,
+		Owner:     account.Owner,
+	})
+	if err != nil {
+		return allowance, err
+	}
+
+	accountTotals, err := q.GetAccountTransferTotals(ctx, GetAccountTransferTotalsParams{
+		AccountID: account.ID,
+		ExcludeID: excludeID,
+	})
+	if err != nil {
+		return allowance, err
+	}
+	userTotals, err := q.GetUserTransferTotals(ctx, GetUserTransferTotalsParams{
+		Owner:     account.Owner,
+		ExcludeID: excludeID,
+	})
+	if err != nil {
+		return allowance, err
+	}
+
+	allowance.Limits = append(allowance.Limits, limitUsages(
+		EffectiveTransferLimit(rules, LimitScopeAccount, accountSubject),
+		GetUserTransferTotalsRow(accountTotals),
+	)...)
+	allowance.Limits = append(allowance.Limits, limitUsages(
+		EffectiveTransferLimit(rules, LimitScopeUser, account.Owner),
+		userTotals,
+	)...)
+	return allowance, nil
+}
+
+// EffectiveTransferLimit merges the default of scope with the override of
+// subject. A rule set in the override replaces the one of the default, and
+// a rule set in neither is not limited.
+func EffectiveTransferLimit(rules []TransferLimit, scope, subject string) TransferLimit {
+	limit := TransferLimit{Scope: scope, Subject: subject}
+	// the default sorts before any override
+	for _, rule := range rules {
+		if rule.Scope != scope || (rule.Subject != "" && rule.Subject != subject) {
+			continue
+		}
+		limit.MaxAmount = orInt64(rule.MaxAmount, limit.MaxAmount)
+		limit.DailyAmount = orInt64(rule.DailyAmount, limit.DailyAmount)
+		limit.MonthlyAmount = orInt64(rule.MonthlyAmount, limit.MonthlyAmount)
+		if rule.HourlyCount.Valid {
+			limit.HourlyCount = rule.HourlyCount
+		}
+	}
+	return limit
+}
+
+func orInt64(value, fallback sql.NullInt64) sql.NullInt64 {
+	if value.Valid {
+		return value
+	}
+	return fallback
+}
+
+func limitUsages(limit TransferLimit, totals GetUserTransferTotalsRow) []LimitUsage {
+	var usages []LimitUsage
+	add := func(rule string, value sql.NullInt64, used int64) {
+		if value.Valid {
+			usages = append(usages, LimitUsage{
+				Scope:     limit.Scope,
+				Rule:      rule,
+				Limit:     value.Int64,
+				Used:      used,
+				Remaining: max(value.Int64-used, 0),
+			})
+		}
+	}
+	add(LimitRuleMaxAmount, limit.MaxAmount, 0)
+	add(LimitRuleDailyAmount, limit.DailyAmount, totals.DailyAmount)
+	add(LimitRuleMonthlyAmount, limit.MonthlyAmount, totals.MonthlyAmount)
+	add(LimitRuleHourlyCount, sql.NullInt64{Int64: int64(limit.HourlyCount.Int32), Valid: limit.HourlyCount.Valid}, totals.HourlyCount)
+	return usages
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveTransferLimit(t *testing.T) {
+	rules := []TransferLimit{
+		{Scope: LimitScopeAccount, Subject: "", MaxAmount: sql.NullInt64{Int64: 500, Valid: true}},
+		{Scope: LimitScopeUser, Subject: "", DailyAmount: sql.NullInt64{Int64: 1000, Valid: true}, HourlyCount: sql.NullInt32{Int32: 5, Valid: true}},
+		{Scope: LimitScopeUser, Subject: "alice", DailyAmount: sql.NullInt64{Int64: 50, Valid: true}},
+		{Scope: LimitScopeUser, Subject: "bob", DailyAmount: sql.NullInt64{Int64: 20, Valid: true}},
+	}
+
+	limit := EffectiveTransferLimit(rules, LimitScopeUser, "alice")
+	require.Equal(t, sql.NullInt64{Int64: 50, Valid: true}, limit.DailyAmount)
+	require.Equal(t, sql.NullInt32{Int32: 5, Valid: true}, limit.HourlyCount)
+	require.False(t, limit.MaxAmount.Valid)
+	require.False(t, limit.MonthlyAmount.Valid)
+
+	limit = EffectiveTransferLimit(rules, LimitScopeUser, "carol")
+	require.Equal(t, sql.NullInt64{Int64: 1000, Valid: true}, limit.DailyAmount)
+
+	limit = EffectiveTransferLimit(rules, LimitScopeAccount, "1")
+	require.Equal(t, sql.NullInt64{Int64: 500, Valid: true}, limit.MaxAmount)
+	require.False(t, limit.DailyAmount.Valid)
+}
+
+func TestTransferTxLimits(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createAccountWithBalance(t, "USD", 1000)
+	account2 := createAccountWithBalance(t, "USD", 0)
+
+	_, err := store.UpsertTransferLimit(context.Background(), UpsertTransferLimitParams{
+		Scope:       LimitScopeAccount,
+		Subject:     strconv.FormatInt(account1.ID, 10),
+		DailyAmount: sql.NullInt64{Int64: 100, Valid: true},
+		HourlyCount: sql.NullInt32{Int32: 3, Valid: true},
+		UpdatedBy:   "admin",
+	})
+	require.NoError(t, err)
+
+	transfer := func(amount int64) error {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		})
+		return err
+	}
+
+	require.NoError(t, transfer(60))
+
+	err = transfer(50)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	require.ErrorContains(t, err, "daily_amount of account")
+	require.ErrorContains(t, err, "40 remaining")
+
+	// the failed transfer was rolled back and does not count
+	require.NoError(t, transfer(40))
+
+	allowance, err := store.GetTransferAllowance(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Contains(t, allowance.Limits, LimitUsage{
+		Scope: LimitScopeAccount, Rule: LimitRuleDailyAmount, Limit: 100, Used: 100, Remaining: 0,
+	})
+	require.Contains(t, allowance.Limits, LimitUsage{
+		Scope: LimitScopeAccount, Rule: LimitRuleHourlyCount, Limit: 3, Used: 2, Remaining: 1,
+	})
+
+	// deleting the override restores the defaults
+	rows, err := store.DeleteTransferLimit(context.Background(), DeleteTransferLimitParams{
+		Scope:   LimitScopeAccount,
+		Subject: strconv.FormatInt(account1.ID, 10),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+	require.NoError(t, transfer(50))
+}
+
+func TestTransferTxUserLimits(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createAccountWithBalance(t, "USD", 1000)
+	account2, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    account1.Owner,
+		Balance:  1000,
+		Currency: "EUR",
+	})
+	require.NoError(t, err)
+	usd := createAccountWithBalance(t, "USD", 0)
+	eur := createAccountWithBalance(t, "EUR", 0)
+
+	_, err = store.UpsertTransferLimit(context.Background(), UpsertTransferLimitParams{
+		Scope:       LimitScopeUser,
+		Subject:     account1.Owner,
+		HourlyCount: sql.NullInt32{Int32: 1, Valid: true},
+		UpdatedBy:   "admin",
+	})
+	require.NoError(t, err)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   usd.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	// the count covers all accounts of the user
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   eur.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	require.ErrorContains(t, err, "hourly_count of user "+account1.Owner)
+}
+
+func TestTransferTxLimitsReversal(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createAccountWithBalance(t, "USD", 1000)
+	account2 := createAccountWithBalance(t, "USD", 1000)
+
+	for _, account := range []Account{account1, account2} {
+		_, err := store.UpsertTransferLimit(context.Background(), UpsertTransferLimitParams{
+			Scope:       LimitScopeAccount,
+			Subject:     strconv.FormatInt(account.ID, 10),
+			DailyAmount: sql.NullInt64{Int64: 100, Valid: true},
+			HourlyCount: sql.NullInt32{Int32: 1, Valid: true},
+			UpdatedBy:   "admin",
+		})
+		require.NoError(t, err)
+	}
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+	_, err = store.ReverseTransferTx(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+
+	// neither the reversed transfer nor its reversal counts
+	for _, account := range []Account{account1, account2} {
+		totals, err := store.GetAccountTransferTotals(context.Background(), GetAccountTransferTotalsParams{
+			AccountID: account.ID,
+		})
+		require.NoError(t, err)
+		require.Zero(t, totals.HourlyCount)
+		require.Zero(t, totals.DailyAmount)
+	}
+	userTotals, err := store.GetUserTransferTotals(context.Background(), GetUserTransferTotalsParams{
+		Owner: account1.Owner,
+	})
+	require.NoError(t, err)
+	require.Zero(t, userTotals.DailyAmount)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Transfer statuses. A transfer is pending until its money moves, e.g.
+// while it waits for approval, or held while a monitoring alert is
+// reviewed, processing while the money moves, and then completed or failed.
+// A completed transfer can be reversed, a pending one cancelled and a held
+// one rejected. Transfers that move their money at once are created
+// completed.
+const (
+	TransferStatusPending    = "pending"
+	TransferStatusHeld       = "held"
+	TransferStatusProcessing = "processing"
+	TransferStatusCompleted  = "completed"
+	TransferStatusFailed     = "failed"
+	TransferStatusReversed   = "reversed"
+	TransferStatusCancelled  = "cancelled"
+)
+
+// Reasons for cancelling a transfer. A failed transfer has the error code of
+// the failure as its reason, a held one the monitoring rule that held it.
+const (
+	TransferReasonApprovalRejected = "approval_rejected"
+	TransferReasonApprovalExpired  = "approval_expired"
+	TransferReasonMonitoringReject = "monitoring_rejected"
+)
+
+var ErrInvalidTransferTransition = errors.New("transfer status does not allow this")
+
+// TransferTransition is a step in the lifecycle of a transfer. It moves a
+// transfer in any of the From statuses to the To status.
+type TransferTransition struct {
+	Name string   `json:"name"`
+	From []string `json:"from"`
+	To   string   `json:"to"`
+}
+
+// The transitions of the transfer lifecycle
+var (
+	TransferProcess = TransferTransition{
+		Name: "process",
+		From: []string{TransferStatusPending},
+		To:   TransferStatusProcessing,
+	}
+	TransferComplete = TransferTransition{
+		Name: "complete",
+		From: []string{TransferStatusProcessing},
+		To:   TransferStatusCompleted,
+	}
+	TransferFail = TransferTransition{
+		Name: "fail",
+		From: []string{TransferStatusPending, TransferStatusProcessing},
+		To:   TransferStatusFailed,
+	}
+	TransferReverse = TransferTransition{
+		Name: "reverse",
+		From: []string{TransferStatusCompleted},
+		To:   TransferStatusReversed,
+	}
+	TransferCancel = TransferTransition{
+		Name: "cancel",
+		From: []string{TransferStatusPending},
+		To:   TransferStatusCancelled,
+	}
+	TransferHold = TransferTransition{
+		Name: "hold",
+		From: []string{TransferStatusPending},
+		To:   TransferStatusHeld,
+	}
+	TransferRelease = TransferTransition{
+		Name: "release",
+		From: []string{TransferStatusHeld},
+		To:   TransferStatusProcessing,
+	}
+	TransferReject = TransferTransition{
+		Name: "reject",
+		From: []string{TransferStatusHeld},
+		To:   TransferStatusCancelled,
+	}
+)
+
+// Allows reports whether the transition applies to a transfer in status
+func (t TransferTransition) Allows(status string) bool {
+	return slices.Contains(t.From, status)
+}
+
+// bookTransfer creates a transfer whose money moves in the same
+// transaction, and records that it was created completed
+func bookTransfer(ctx context.Context, q *Queries, arg CreateTransfersParams) (Transfer, error) {
+	transfer, err := q.CreateTransfers(ctx, arg)
+	if err != nil {
+		return transfer, err
+	}
+	return transfer, recordTransferEvent(ctx, q, transfer, "")
+}
+
+// bookPendingTransfer creates a transfer whose money moves later
+func bookPendingTransfer(ctx context.Context, q *Queries, arg CreatePendingTransferParams) (Transfer, error) {
+	transfer, err := q.CreatePendingTransfer(ctx, arg)
+	if err != nil {
+		return transfer, err
+	}
+	return transfer, recordTransferEvent(ctx, q, transfer, "")
+}
+
+// changeTransferStatus applies a lifecycle transition to transfer. reason
+// is the error code of a failure, or why the transfer was cancelled. It
+// fails with ErrInvalidTransferTransition when the transfer is not, or no
+// longer, in one of the statuses the transition starts from.
+func changeTransferStatus(ctx context.Context, q *Queries, transfer Transfer, t TransferTransition, reason string) (Transfer, error) {
+	if !t.Allows(transfer.Status) {
+		return transfer, fmt.Errorf("%w: cannot %s transfer %d, it is %s",
+			ErrInvalidTransferTransition, t.Name, transfer.ID, transfer.Status)
+	}
+
+	var failureReason string
+	if t.To == TransferStatusFailed {
+		failureReason = reason
+	}
+	updated, err := q.UpdateTransferStatus(ctx, UpdateTransferStatusParams{
+		Status:        t.To,
+		FailureReason: failureReason,
+		ID:            transfer.ID,
+		FromStatus:    transfer.Status,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// a concurrent transaction changed the status since it was read
+		return transfer, fmt.Errorf("%w: cannot %s transfer %d, it is no longer %s",
+			ErrInvalidTransferTransition, t.Name, transfer.ID, transfer.Status)
+	}
+	if err != nil {
+		return transfer, err
+	}
+	return updated, recordTransferEvent(ctx, q, updated, reason)
+}
+
+// recordTransferEvent adds the current status of transfer to its history
+func recordTransferEvent(ctx context.Context, q *Queries, transfer Transfer, reason string) error {
+	_, err := q.CreateTransferEvent(ctx, CreateTransferEventParams{
+		TransferID: transfer.ID,
+		Status:     transfer.Status,
+		Reason:     reason,
+	})
+	return err
+}
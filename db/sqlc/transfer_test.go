@@ -24,6 +24,7 @@ func CreateRandomTransfer(t *testing.T) Transfer {
 	require.Equal(t, arg.Amount, transfer.Amount)
 	require.NotZero(t, transfer.ID)
 	require.NotZero(t, transfer.CreatedAt)
+	require.Equal(t, TransferStatusCompleted, transfer.Status)
 	return transfer
 }
 
@@ -89,3 +90,28 @@ func TestListTransfer(t *testing.T) {
 	}
 
 }
+
+func TestListOwnerTransfers(t *testing.T) {
+	sent := CreateRandomTransfer(t)
+	account, err := testQueries.GetAccount(context.Background(), sent.FromAccountID)
+	require.NoError(t, err)
+
+	other := createRandomAccount(t)
+	received, err := testQueries.CreateTransfers(context.Background(), CreateTransfersParams{
+		FromAccountID: other.ID,
+		ToAccountID:   account.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+	CreateRandomTransfer(t)
+
+	transfers, err := testQueries.ListOwnerTransfers(context.Background(), ListOwnerTransfersParams{
+		Owner:  account.Owner,
+		Limit:  5,
+		Offset: 0,
+	})
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+	require.Equal(t, sent.ID, transfers[0].ID)
+	require.Equal(t, received.ID, transfers[1].ID)
+}
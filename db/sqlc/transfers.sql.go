@@ -9,8 +9,35 @@ import (
 	"context"
 )
 
+const createPendingTransfer = `-- name: CreatePendingTransfer :one
+INSERT INTO transfers (from_account_id, to_account_id, amount) VALUES ($1, $2, $3) RETURNING id, from_account_id, to_account_id, amount, created_at, status, failure_reason, updated_at
+`
+
+type CreatePendingTransferParams struct {
+	FromAccountID int64 `json:"from_account_id"`
+	ToAccountID   int64 `json:"to_account_id"`
+	Amount        int64 `json:"amount"`
+}
+
+// Create a transfer that moves its money later, e.g. after approval
+func (q *Queries) CreatePendingTransfer(ctx context.Context, arg CreatePendingTransferParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, createPendingTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.Status,
+		&i.FailureReason,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const createTransfers = `-- name: CreateTransfers :one
-INSERT INTO transfers (from_account_id, to_account_id, amount) VALUES ($1, $2, $3) RETURNING id, from_account_id, to_account_id, amount, created_at
+INSERT INTO transfers (from_account_id, to_account_id, amount, status) VALUES ($1, $2, $3, 'completed') RETURNING id, from_account_id, to_account_id, amount, created_at, status, failure_reason, updated_at
 `
 
 type CreateTransfersParams struct {
@@ -19,7 +46,7 @@ type CreateTransfersParams struct {
 	Amount        int64 `json:"amount"`
 }
 
-// Create a new transfers
+// Create a new transfers, completed as its money moves in the same transaction
 func (q *Queries) CreateTransfers(ctx context.Context, arg CreateTransfersParams) (Transfer, error) {
 	row := q.db.QueryRowContext(ctx, createTransfers, arg.FromAccountID, arg.ToAccountID, arg.Amount)
 	var i Transfer
@@ -29,6 +56,9 @@ func (q *Queries) CreateTransfers(ctx context.Context, arg CreateTransfersParams
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.Status,
+		&i.FailureReason,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
@@ -44,7 +74,7 @@ func (q *Queries) DeleteTransfers(ctx context.Context, id int64) error {
 }
 
 const getTransfers = `-- name: GetTransfers :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers WHERE id = $1
+SELECT id, from_account_id, to_account_id, amount, created_at, status, failure_reason, updated_at FROM transfers WHERE id = $1
 `
 
 // Get a transfers by id
@@ -57,12 +87,15 @@ func (q *Queries) GetTransfers(ctx context.Context, id int64) (Transfer, error)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.Status,
+		&i.FailureReason,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, status, failure_reason, updated_at FROM transfers
 ORDER BY id
 LIMIT $1
 OFFSET $2
@@ -89,6 +122,97 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.Status,
+			&i.FailureReason,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransfersByOwner = `-- name: ListTransfersByOwner :many
+SELECT id, from_account_id, to_account_id, amount, created_at, status, failure_reason, updated_at FROM transfers
+WHERE from_account_id IN (SELECT id FROM account WHERE owner = $1)
+   OR to_account_id IN (SELECT id FROM account WHERE owner = $1)
+ORDER BY id
+`
+
+// List the transfers from or to any account of a user
+func (q *Queries) ListTransfersByOwner(ctx context.Context, owner string) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listTransfersByOwner, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.Status,
+			&i.FailureReason,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOwnerTransfers = `-- name: ListOwnerTransfers :many
+SELECT id, from_account_id, to_account_id, amount, created_at, status, failure_reason, updated_at FROM transfers
+WHERE from_account_id IN (SELECT id FROM account WHERE owner = $1)
+   OR to_account_id IN (SELECT id FROM account WHERE owner = $1)
+ORDER BY id
+LIMIT $2
+OFFSET $3
+`
+
+type ListOwnerTransfersParams struct {
+	Owner  string `json:"owner"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+// List a page of the transfers from or to any account of a user
+func (q *Queries) ListOwnerTransfers(ctx context.Context, arg ListOwnerTransfersParams) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listOwnerTransfers, arg.Owner, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.Status,
+			&i.FailureReason,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -107,7 +231,7 @@ const updateTransfer = `-- name: UpdateTransfer :one
 UPDATE transfers
 SET amount = $2
 WHERE id = $1
-RETURNING id, from_account_id, to_account_id, amount, created_at
+RETURNING id, from_account_id, to_account_id, amount, created_at, status, failure_reason, updated_at
 `
 
 type UpdateTransferParams struct {
@@ -124,6 +248,49 @@ func (q *Queries) UpdateTransfer(ctx context.Context, arg UpdateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.Status,
+		&i.FailureReason,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateTransferStatus = `-- name: UpdateTransferStatus :one
+UPDATE transfers
+SET
+  status = $1,
+  failure_reason = $2,
+  updated_at = now()
+WHERE id = $3 AND status = $4
+RETURNING id, from_account_id, to_account_id, amount, created_at, status, failure_reason, updated_at
+`
+
+type UpdateTransferStatusParams struct {
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason"`
+	ID            int64  `json:"id"`
+	FromStatus    string `json:"from_status"`
+}
+
+// Move a transfer from one status to another. No row means the transfer
+// does not exist or is no longer in from_status.
+func (q *Queries) UpdateTransferStatus(ctx context.Context, arg UpdateTransferStatusParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, updateTransferStatus,
+		arg.Status,
+		arg.FailureReason,
+		arg.ID,
+		arg.FromStatus,
+	)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.Status,
+		&i.FailureReason,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
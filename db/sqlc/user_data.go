@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUserDeleted         = errors.New("user is deleted")
+	ErrUserNotDeleted      = errors.New("user is not deleted")
+	ErrUserErased          = errors.New("user is already erased")
+	ErrUserHasOpenAccounts = errors.New("user has accounts that are not closed")
+)
+
+// ErasedEmailDomain is the domain of the placeholder email of an erased
+// user. The .invalid top level domain is reserved, so no mail is delivered.
+const ErasedEmailDomain = "erased.invalid"
+
+// DeleteUserTx soft-deletes a user, who then cannot log in anymore. The row
+// stays because the ledger refers to it. All accounts of the user must be
+// closed first, so that no money is left with an owner who is gone.
+func (store *SQLStore) DeleteUserTx(ctx context.Context, username string) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, "DeleteUserTx", func(q *Queries) error {
+		var err error
+		user, err = q.GetUser(ctx, username)
+		if err != nil {
+			return err
+		}
+		if user.DeletedAt.Valid {
+			return fmt.Errorf("%w: %s", ErrUserDeleted, username)
+		}
+
+		accounts, err := q.ListAccountsByOwner(ctx, username)
+		if err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			if account.Status != AccountStatusClosed {
+				return fmt.Errorf("%w: account %d is %s", ErrUserHasOpenAccounts, account.ID, account.Status)
+			}
+		}
+
+		user, err = q.SoftDeleteUser(ctx, username)
+		return err
+	})
+	return store.openUser(ctx, user, err)
+}
+
+// EraseUserTx pseudonymises a deleted user: the username becomes a random
+// pseudonym, the name and email are cleared and the credentials, email
+// tokens and recovery codes are removed. Accounts, entries, transfers and
+// the KYC profile are kept for the legal retention period and follow the
+// new username.
+func (store *SQLStore) EraseUserTx(ctx context.Context, username string) (User, error) {
+	pseudonym, err := newPseudonym()
+	if err != nil {
+		return User{}, err
+	}
+	email, emailIndex, err := store.encryptEmail(ctx, pseudonym+"@"+ErasedEmailDomain)
+	if err != nil {
+		return User{}, err
+	}
+
+	var user User
+	err = store.execTx(ctx, "EraseUserTx", func(q *Queries) error {
+		var err error
+		user, err = q.GetUser(ctx, username)
+		if err != nil {
+			return err
+		}
+		switch {
+		case user.ErasedAt.Valid:
+			return fmt.Errorf("%w: %s", ErrUserErased, username)
+		case !user.DeletedAt.Valid:
+			return fmt.Errorf("%w: %s has to be deleted first", ErrUserNotDeleted, username)
+		}
+
+		if err := q.DeleteVerifyEmails(ctx, username); err != nil {
+			return err
+		}
+		if err := q.DeleteRecoveryCodes(ctx, username); err != nil {
+			return err
+		}
+
+		user, err = q.EraseUser(ctx, EraseUserParams{
+			Pseudonym:  pseudonym,
+			Email:      email,
+			EmailIndex: emailIndex,
+			Username:   username,
+		})
+		return err
+	})
+	return store.openUser(ctx, user, err)
+}
+
+// newPseudonym returns a random username such as "erased1f2e3d4c5b6a7988".
+// It is alphanumeric like the usernames accepted by the API.
+func newPseudonym() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "erased" + hex.EncodeToString(b), nil
+}
+
+// UserData is everything stored about a user
+type UserData struct {
+	User         User          `json:"user"`
+	Accounts     []Account     `json:"accounts"`
+	Entries      []Entry       `json:"entries"`
+	Transfers    []Transfer    `json:"transfers"`
+	VerifyEmails []VerifyEmail `json:"verify_emails"`
+	AuditEvents  []AuditEvent  `json:"audit_events"`
+	// KYCProfile is nil when the user never submitted one
+	KYCProfile *KycProfile `json:"kyc_profile"`
+}
+
+// ExportUserData collects the data stored about a user in one transaction.
+// The result contains secrets such as the password hash, which callers
+// must leave out of anything they hand out.
+func (store *SQLStore) ExportUserData(ctx context.Context, username string) (UserData, error) {
+	var data UserData
+
+	err := store.execTx(ctx, "ExportUserData", func(q *Queries) error {
+		var err error
+		if data.User, err = q.GetUser(ctx, username); err != nil {
+			return err
+		}
+		if data.Accounts, err = q.ListAccountsByOwner(ctx, username); err != nil {
+			return err
+		}
+		if data.Entries, err = q.ListEntriesByOwner(ctx, username); err != nil {
+			return err
+		}
+		if data.Transfers, err = q.ListTransfersByOwner(ctx, username); err != nil {
+			return err
+		}
+		if data.VerifyEmails, err = q.ListVerifyEmails(ctx, username); err != nil {
+			return err
+		}
+		if data.AuditEvents, err = q.ListAuditEventsByUser(ctx, username); err != nil {
+			return err
+		}
+		profile, err := q.GetKYCProfile(ctx, username)
+		switch {
+		case err == nil:
+			data.KYCProfile = &profile
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return data, err
+	}
+
+	if data.User, err = store.openUser(ctx, data.User, nil); err != nil {
+		return UserData{}, err
+	}
+	if data.VerifyEmails, err = store.openVerifyEmails(ctx, data.VerifyEmails, nil); err != nil {
+		return UserData{}, err
+	}
+	if data.KYCProfile != nil {
+		if *data.KYCProfile, err = store.openKYCProfile(ctx, *data.KYCProfile, nil); err != nil {
+			return UserData{}, err
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteUserTx(t *testing.T) {
+	store := testStore
+	account := createAccountWithBalance(t, "USD", 0)
+
+	_, err := store.DeleteUserTx(context.Background(), account.Owner)
+	require.ErrorIs(t, err, ErrUserHasOpenAccounts)
+
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  account.ID,
+		Transition: AccountClose,
+	})
+	require.NoError(t, err)
+
+	user, err := store.DeleteUserTx(context.Background(), account.Owner)
+	require.NoError(t, err)
+	require.True(t, user.DeletedAt.Valid)
+	require.False(t, user.ErasedAt.Valid)
+
+	_, err = store.DeleteUserTx(context.Background(), account.Owner)
+	require.ErrorIs(t, err, ErrUserDeleted)
+
+	_, err = store.DeleteUserTx(context.Background(), randomString(12))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestEraseUserTx(t *testing.T) {
+	store := testStore
+	account := createAccountWithBalance(t, "USD", 0)
+	user, err := testStore.GetUser(context.Background(), account.Owner)
+	require.NoError(t, err)
+	createRandomVerifyEmail(t, user, VerifyEmailPurposeEmailVerification)
+
+	_, err = store.EraseUserTx(context.Background(), user.Username)
+	require.ErrorIs(t, err, ErrUserNotDeleted)
+
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID:  account.ID,
+		Transition: AccountClose,
+	})
+	require.NoError(t, err)
+	_, err = store.DeleteUserTx(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	erased, err := store.EraseUserTx(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.True(t, erased.ErasedAt.Valid)
+	require.True(t, strings.HasPrefix(erased.Username, "erased"))
+	require.Equal(t, erased.Username+"@"+ErasedEmailDomain, erased.Email)
+	require.Empty(t, erased.FullName)
+	require.Empty(t, erased.HashedPassword)
+
+	// the ledger follows the pseudonym
+	closed, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, erased.Username, closed.Owner)
+
+	_, err = testStore.GetUser(context.Background(), user.Username)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	emails, err := testStore.ListVerifyEmails(context.Background(), erased.Username)
+	require.NoError(t, err)
+	require.Empty(t, emails)
+
+	_, err = store.EraseUserTx(context.Background(), erased.Username)
+	require.ErrorIs(t, err, ErrUserErased)
+}
+
+func TestExportUserData(t *testing.T) {
+	store := testStore
+	account1 := createAccountWithBalance(t, "USD", 0)
+	account2 := createAccountWithBalance(t, "USD", 100)
+	entry := createRandomEntry(t, account1)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	data, err := store.ExportUserData(context.Background(), account1.Owner)
+	require.NoError(t, err)
+	require.Equal(t, account1.Owner, data.User.Username)
+	require.Len(t, data.Accounts, 1)
+	require.Equal(t, account1.ID, data.Accounts[0].ID)
+
+	var entryIDs []int64
+	for _, e := range data.Entries {
+		entryIDs = append(entryIDs, e.ID)
+	}
+	require.Contains(t, entryIDs, entry.ID)
+	require.Contains(t, entryIDs, result.ToEntry.ID)
+	require.NotContains(t, entryIDs, result.FromEntry.ID)
+
+	require.Len(t, data.Transfers, 1)
+	require.Equal(t, result.Transfer.ID, data.Transfers[0].ID)
+
+	profile, err := store.GetKYCProfile(context.Background(), account1.Owner)
+	require.NoError(t, err)
+	require.Equal(t, &profile, data.KYCProfile)
+
+	_, err = store.ExportUserData(context.Background(), randomString(12))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -8,6 +8,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -15,10 +16,11 @@ INSERT INTO users (
   username,
   hashed_password,
   full_name,
-  email
+  email,
+  email_index
 ) VALUES (
-  $1, $2, $3, $4
-) RETURNING username, hashed_password, full_name, email, password_changed_at, created_at
+  $1, $2, $3, $4, $5
+) RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
 `
 
 type CreateUserParams struct {
@@ -26,6 +28,7 @@ type CreateUserParams struct {
 	HashedPassword string `json:"hashed_password"`
 	FullName       string `json:"full_name"`
 	Email          string `json:"email"`
+	EmailIndex     string `json:"email_index"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -34,6 +37,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.HashedPassword,
 		arg.FullName,
 		arg.Email,
+		arg.EmailIndex,
 	)
 	var i User
 	err := row.Scan(
@@ -43,22 +47,104 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
 	)
 	return i, err
 }
 
-const deleteUser = `-- name: DeleteUser :exec
-DELETE FROM users
-WHERE username = $1
+const enableTOTP = `-- name: EnableTOTP :one
+UPDATE users
+SET totp_enabled = true
+WHERE username = $1 AND totp_secret <> ''
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
 `
 
-func (q *Queries) DeleteUser(ctx context.Context, username string) error {
-	_, err := q.db.ExecContext(ctx, deleteUser, username)
-	return err
+func (q *Queries) EnableTOTP(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableTOTP, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
+}
+
+const eraseUser = `-- name: EraseUser :one
+UPDATE users
+SET
+  username = $1,
+  email = $2,
+  email_index = $3,
+  full_name = '',
+  hashed_password = '',
+  totp_secret = '',
+  totp_enabled = false,
+  is_email_verified = false,
+  erased_at = now()
+WHERE username = $4 AND deleted_at IS NOT NULL AND erased_at IS NULL
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
+`
+
+type EraseUserParams struct {
+	Pseudonym  string `json:"pseudonym"`
+	Email      string `json:"email"`
+	EmailIndex string `json:"email_index"`
+	Username   string `json:"username"`
+}
+
+// Replace the personal data of a deleted user with a pseudonym and remove
+// its credentials. Rows that refer to the username follow the rename.
+func (q *Queries) EraseUser(ctx context.Context, arg EraseUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, eraseUser,
+		arg.Pseudonym,
+		arg.Email,
+		arg.EmailIndex,
+		arg.Username,
+	)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, hashed_password, full_name, email, password_changed_at, created_at FROM users
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index FROM users
 WHERE username = $1 LIMIT 1
 `
 
@@ -72,12 +158,50 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index FROM users
+WHERE email_index = $1 LIMIT 1
+`
+
+// Find a user by the blind index of the email
+func (q *Queries) GetUserByEmail(ctx context.Context, emailIndex string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, emailIndex)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
 	)
 	return i, err
 }
 
 const listUsers = `-- name: ListUsers :many
-SELECT username, hashed_password, full_name, email, password_changed_at, created_at FROM users
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index FROM users
 ORDER BY username
 LIMIT $1
 OFFSET $2
@@ -104,6 +228,15 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 			&i.Email,
 			&i.PasswordChangedAt,
 			&i.CreatedAt,
+			&i.Role,
+			&i.FailedLoginAttempts,
+			&i.LockedUntil,
+			&i.TotpSecret,
+			&i.TotpEnabled,
+			&i.IsEmailVerified,
+			&i.DeletedAt,
+			&i.ErasedAt,
+			&i.EmailIndex,
 		); err != nil {
 			return nil, err
 		}
@@ -118,31 +251,188 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 	return items, nil
 }
 
+const recordFailedLogin = `-- name: RecordFailedLogin :one
+UPDATE users
+SET
+  failed_login_attempts = CASE
+    WHEN locked_until > '0001-01-01 00:00:00Z' AND locked_until <= now() THEN 1
+    ELSE failed_login_attempts + 1
+  END,
+  locked_until = CASE
+    WHEN locked_until > '0001-01-01 00:00:00Z' AND locked_until <= now() THEN
+      CASE WHEN 1 >= $1::integer THEN $2::timestamptz ELSE '0001-01-01 00:00:00Z' END
+    WHEN failed_login_attempts + 1 >= $1::integer THEN $2::timestamptz
+    ELSE locked_until
+  END
+WHERE username = $3
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
+`
+
+type RecordFailedLoginParams struct {
+	MaxAttempts int32     `json:"max_attempts"`
+	LockUntil   time.Time `json:"lock_until"`
+	Username    string    `json:"username"`
+}
+
+// Count a failed login and lock the user out until lock_until once the
+// count reaches max_attempts. A lockout that has expired starts a new
+// count, so the next failure does not lock the user out again. The
+// increment is atomic, so concurrent guesses cannot slip past the limit.
+func (q *Queries) RecordFailedLogin(ctx context.Context, arg RecordFailedLoginParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, recordFailedLogin, arg.MaxAttempts, arg.LockUntil, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
+}
+
+const resetFailedLogins = `-- name: ResetFailedLogins :one
+UPDATE users
+SET
+  failed_login_attempts = 0,
+  locked_until = '0001-01-01 00:00:00Z'
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
+`
+
+// Clear the failed login count and lift a lockout
+func (q *Queries) ResetFailedLogins(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, resetFailedLogins, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :one
+UPDATE users
+SET
+  totp_secret = $2,
+  totp_enabled = false
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
+`
+
+type SetTOTPSecretParams struct {
+	Username   string `json:"username"`
+	TotpSecret string `json:"totp_secret"`
+}
+
+// Store a new secret for enrolment. It is not used until EnableTOTP.
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setTOTPSecret, arg.Username, arg.TotpSecret)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = now()
+WHERE username = $1 AND deleted_at IS NULL
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
+`
+
+// Mark a user as deleted. The row stays, since the ledger refers to it.
+func (q *Queries) SoftDeleteUser(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteUser, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET
   hashed_password = COALESCE($1, hashed_password),
   full_name = COALESCE($2, full_name),
   email = COALESCE($3, email),
-  password_changed_at = COALESCE($4, password_changed_at)
+  email_index = COALESCE($4, email_index),
+  password_changed_at = COALESCE($5, password_changed_at),
+  is_email_verified = is_email_verified AND COALESCE($4 = email_index, true)
 WHERE
-  username = $5
-RETURNING username, hashed_password, full_name, email, password_changed_at, created_at
+  username = $6
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
 `
 
 type UpdateUserParams struct {
 	HashedPassword    sql.NullString `json:"hashed_password"`
 	FullName          sql.NullString `json:"full_name"`
 	Email             sql.NullString `json:"email"`
+	EmailIndex        sql.NullString `json:"email_index"`
 	PasswordChangedAt sql.NullTime   `json:"password_changed_at"`
 	Username          string         `json:"username"`
 }
 
+// A changed email has to be verified again
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
 	row := q.db.QueryRowContext(ctx, updateUser,
 		arg.HashedPassword,
 		arg.FullName,
 		arg.Email,
+		arg.EmailIndex,
 		arg.PasswordChangedAt,
 		arg.Username,
 	)
@@ -154,6 +444,125 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
+}
+
+const updateUserPII = `-- name: UpdateUserPII :execrows
+UPDATE users
+SET
+  full_name = $1,
+  email = $2,
+  email_index = $3
+WHERE
+  username = $4
+  AND full_name = $5
+  AND email = $6
+`
+
+type UpdateUserPIIParams struct {
+	FullName    string `json:"full_name"`
+	Email       string `json:"email"`
+	EmailIndex  string `json:"email_index"`
+	Username    string `json:"username"`
+	OldFullName string `json:"old_full_name"`
+	OldEmail    string `json:"old_email"`
+}
+
+// Replace the personal data of a user with a re-encrypted copy, unless it
+// changed since it was read
+func (q *Queries) UpdateUserPII(ctx context.Context, arg UpdateUserPIIParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserPII,
+		arg.FullName,
+		arg.Email,
+		arg.EmailIndex,
+		arg.Username,
+		arg.OldFullName,
+		arg.OldEmail,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
+`
+
+type UpdateUserRoleParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.Username, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
+	)
+	return i, err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = true
+WHERE username = $1 AND email_index = $2
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, failed_login_attempts, locked_until, totp_secret, totp_enabled, is_email_verified, deleted_at, erased_at, email_index
+`
+
+type VerifyUserEmailParams struct {
+	Username   string `json:"username"`
+	EmailIndex string `json:"email_index"`
+}
+
+// Mark the email of a user as verified, unless it changed since the token
+// was sent
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.Username, arg.EmailIndex)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsEmailVerified,
+		&i.DeletedAt,
+		&i.ErasedAt,
+		&i.EmailIndex,
 	)
 	return i, err
 }
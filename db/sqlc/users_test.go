@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUser(t *testing.T) {
+	createRandomUser(t)
+}
+
+// Helper function to create a random user that accounts can belong to
+func createRandomUser(t *testing.T) User {
+	arg := CreateUserParams{
+		Username:       randomString(8),
+		HashedPassword: randomString(16),
+		FullName:       randomString(6),
+		Email:          randomString(6) + "@example.com",
+	}
+
+	user, err := testStore.CreateUser(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, user)
+
+	require.Equal(t, arg.Username, user.Username)
+	require.Equal(t, arg.FullName, user.FullName)
+	require.Equal(t, arg.Email, user.Email)
+	require.NotZero(t, user.CreatedAt)
+
+	return user
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	user := createRandomUser(t)
+	require.Equal(t, "depositor", user.Role)
+	require.Zero(t, user.FailedLoginAttempts)
+
+	lockUntil := time.Now().Add(time.Minute).Truncate(time.Microsecond)
+	arg := RecordFailedLoginParams{Username: user.Username, MaxAttempts: 2, LockUntil: lockUntil}
+
+	user, err := testQueries.RecordFailedLogin(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), user.FailedLoginAttempts)
+	require.True(t, user.LockedUntil.Before(time.Now()))
+
+	user, err = testQueries.RecordFailedLogin(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), user.FailedLoginAttempts)
+	require.WithinDuration(t, lockUntil, user.LockedUntil, time.Millisecond)
+
+	user, err = testQueries.ResetFailedLogins(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Zero(t, user.FailedLoginAttempts)
+	require.True(t, user.LockedUntil.Before(time.Now()))
+}
+
+func TestRecordFailedLoginAfterLockout(t *testing.T) {
+	user := createRandomUser(t)
+
+	// a lockout that has already expired
+	arg := RecordFailedLoginParams{Username: user.Username, MaxAttempts: 2, LockUntil: time.Now().Add(-time.Second)}
+	for i := 0; i < 2; i++ {
+		_, err := testQueries.RecordFailedLogin(context.Background(), arg)
+		require.NoError(t, err)
+	}
+
+	// the next failure starts a new count instead of locking again
+	arg.LockUntil = time.Now().Add(time.Minute)
+	user, err := testQueries.RecordFailedLogin(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), user.FailedLoginAttempts)
+	require.True(t, user.LockedUntil.Before(time.Now()))
+
+	user, err = testQueries.RecordFailedLogin(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), user.FailedLoginAttempts)
+	require.True(t, user.LockedUntil.After(time.Now()))
+}
+
+func TestEnableTOTPTx(t *testing.T) {
+	store := testStore
+	user := createRandomUser(t)
+	require.False(t, user.TotpEnabled)
+
+	// without a secret there is nothing to enable
+	_, err := store.EnableTOTPTx(context.Background(), EnableTOTPTxParams{Username: user.Username})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQueries.SetTOTPSecret(context.Background(), SetTOTPSecretParams{
+		Username:   user.Username,
+		TotpSecret: randomString(32),
+	})
+	require.NoError(t, err)
+
+	user, err = store.EnableTOTPTx(context.Background(), EnableTOTPTxParams{
+		Username:            user.Username,
+		HashedRecoveryCodes: []string{"first", "second"},
+	})
+	require.NoError(t, err)
+	require.True(t, user.TotpEnabled)
+
+	arg := UseRecoveryCodeParams{Username: user.Username, HashedCode: "first"}
+	code, err := testQueries.UseRecoveryCode(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, code.UsedAt.Valid)
+
+	// a recovery code works only once
+	_, err = testQueries.UseRecoveryCode(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func createRandomVerifyEmail(t *testing.T, user User, purpose string) VerifyEmail {
+	arg := CreateVerifyEmailParams{
+		Username:    user.Username,
+		Email:       user.Email,
+		Purpose:     purpose,
+		HashedToken: randomString(64),
+		ExpiredAt:   time.Now().Add(time.Hour),
+	}
+
+	verifyEmail, err := testStore.CreateVerifyEmail(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.HashedToken, verifyEmail.HashedToken)
+	require.False(t, verifyEmail.UsedAt.Valid)
+	return verifyEmail
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	store := testStore
+	user := createRandomUser(t)
+	require.False(t, user.IsEmailVerified)
+
+	// a reset token cannot verify the email
+	reset := createRandomVerifyEmail(t, user, VerifyEmailPurposePasswordReset)
+	_, err := store.VerifyEmailTx(context.Background(), reset.HashedToken)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	verifyEmail := createRandomVerifyEmail(t, user, VerifyEmailPurposeEmailVerification)
+	user, err = store.VerifyEmailTx(context.Background(), verifyEmail.HashedToken)
+	require.NoError(t, err)
+	require.True(t, user.IsEmailVerified)
+
+	// tokens work only once
+	_, err = store.VerifyEmailTx(context.Background(), verifyEmail.HashedToken)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a new email has to be verified again
+	user, err = testStore.UpdateUser(context.Background(), UpdateUserParams{
+		Username: user.Username,
+		Email:    sql.NullString{String: randomString(6) + "@example.com", Valid: true},
+	})
+	require.NoError(t, err)
+	require.False(t, user.IsEmailVerified)
+}
+
+func TestVerifyEmailTxChangedEmail(t *testing.T) {
+	store := testStore
+	user := createRandomUser(t)
+	verifyEmail := createRandomVerifyEmail(t, user, VerifyEmailPurposeEmailVerification)
+
+	_, err := testStore.UpdateUser(context.Background(), UpdateUserParams{
+		Username: user.Username,
+		Email:    sql.NullString{String: randomString(6) + "@example.com", Valid: true},
+	})
+	require.NoError(t, err)
+
+	// the token was sent to the old email
+	_, err = store.VerifyEmailTx(context.Background(), verifyEmail.HashedToken)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	store := testStore
+	user := createRandomUser(t)
+
+	_, err := testQueries.RecordFailedLogin(context.Background(), RecordFailedLoginParams{
+		Username:    user.Username,
+		MaxAttempts: 1,
+		LockUntil:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	reset := createRandomVerifyEmail(t, user, VerifyEmailPurposePasswordReset)
+	arg := ResetPasswordTxParams{HashedToken: reset.HashedToken, HashedPassword: randomString(16)}
+	updated, err := store.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.HashedPassword, updated.HashedPassword)
+	require.True(t, updated.PasswordChangedAt.After(user.PasswordChangedAt))
+	require.Zero(t, updated.FailedLoginAttempts)
+	require.True(t, updated.LockedUntil.Before(time.Now()))
+
+	_, err = store.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
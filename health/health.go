@@ -0,0 +1,146 @@
+// Package health runs the readiness checks of the service.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hiiamanop/simple_bank/db/migration"
+	"github.com/hiiamanop/simple_bank/worker"
+)
+
+// DefaultTimeout bounds one run of all checks
+const DefaultTimeout = 2 * time.Second
+
+// Check returns nil when the dependency it checks is ready
+type Check func(ctx context.Context) error
+
+// Status values of a Report and of its checks
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Report is the outcome of running all checks
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Ready reports whether every check passed
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Checker runs named checks concurrently, each bounded by a timeout
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.Mutex
+	checks map[string]Check
+}
+
+// NewChecker creates a checker without checks, which is always ready
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add registers check under name, replacing any check with the same name
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Run runs all checks. A failing check reports its error message.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	report := Report{Status: StatusOK, Checks: make(map[string]string, len(checks))}
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := StatusOK
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// Database pings db
+func Database(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Migrations checks that db is at the version of the newest embedded
+// migration and not left dirty by a failed migration
+func Migrations(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		latest, err := migration.LatestVersion()
+		if err != nil {
+			return err
+		}
+
+		version, dirty, err := migration.CurrentVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("migration %d is dirty", version)
+		}
+		if version != latest {
+			return fmt.Errorf("database at version %d, expected %d", version, latest)
+		}
+		return nil
+	}
+}
+
+// Workers checks that every background worker of group is running
+func Workers(group *worker.Group) Check {
+	return func(ctx context.Context) error {
+		var stopped []string
+		for name, running := range group.Running() {
+			if !running {
+				stopped = append(stopped, name)
+			}
+		}
+		if len(stopped) > 0 {
+			sort.Strings(stopped)
+			return fmt.Errorf("workers not running: %s", strings.Join(stopped, ", "))
+		}
+		return nil
+	}
+}
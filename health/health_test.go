@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hiiamanop/simple_bank/worker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	checker := NewChecker(time.Second)
+	require.Equal(t, Report{Status: StatusOK, Checks: map[string]string{}}, checker.Run(context.Background()))
+
+	checker.Add("ok", func(ctx context.Context) error { return nil })
+	report := checker.Run(context.Background())
+	require.True(t, report.Ready())
+	require.Equal(t, map[string]string{"ok": StatusOK}, report.Checks)
+
+	checker.Add("broken", func(ctx context.Context) error { return errors.New("connection refused") })
+	report = checker.Run(context.Background())
+	require.False(t, report.Ready())
+	require.Equal(t, StatusUnavailable, report.Status)
+	require.Equal(t, map[string]string{"ok": StatusOK, "broken": "connection refused"}, report.Checks)
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	checker := NewChecker(10 * time.Millisecond)
+	checker.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := checker.Run(context.Background())
+	require.False(t, report.Ready())
+	require.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"])
+}
+
+func TestWorkers(t *testing.T) {
+	group := worker.NewGroup()
+	check := Workers(group)
+	require.NoError(t, check(context.Background()))
+
+	group.Go("running", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	group.Go("crashed", func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	defer group.Stop(context.Background())
+
+	require.Eventually(t, func() bool {
+		return check(context.Background()) != nil
+	}, time.Second, time.Millisecond)
+	require.EqualError(t, check(context.Background()), "workers not running: crashed")
+}
@@ -0,0 +1,65 @@
+// Package logging sets up the structured JSON logger of the service. Loggers
+// created here add the request ID found in the context to every record and
+// redact credentials and email addresses before anything is written.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// New creates a JSON logger writing records of at least level to w. Pass a
+// *slog.LevelVar to change the level while the logger is in use.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	return level, nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID of the context to every record, so
+// that everything logged while serving a request can be correlated
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func logRecord(t *testing.T, fn func(logger *slog.Logger)) map[string]any {
+	var buf bytes.Buffer
+	fn(New(&buf, slog.LevelDebug))
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	return record
+}
+
+func TestRequestID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+	require.Equal(t, "req-1", RequestID(ctx))
+	require.Empty(t, RequestID(context.Background()))
+
+	record := logRecord(t, func(logger *slog.Logger) {
+		logger.With("component", "test").InfoContext(ctx, "hello")
+	})
+	require.Equal(t, "req-1", record["request_id"])
+	require.Equal(t, "test", record["component"])
+
+	record = logRecord(t, func(logger *slog.Logger) {
+		logger.Info("hello")
+	})
+	require.NotContains(t, record, "request_id")
+}
+
+func TestRedaction(t *testing.T) {
+	record := logRecord(t, func(logger *slog.Logger) {
+		logger.Info("user jane.doe@example.com signed up",
+			slog.String("password", "secret123"),
+			slog.String("hashed_password", "$2a$10$abc"),
+			slog.String("Authorization", "Bearer abc"),
+			slog.String("access_token", "abc"),
+			slog.String("email", "jane.doe@example.com"),
+			slog.Any("error", errors.New("duplicate email jane.doe@example.com")),
+			slog.Group("user", slog.String("password", "secret123"), slog.String("username", "jane")),
+			slog.Int("amount", 10),
+		)
+	})
+
+	require.Equal(t, "user j***@example.com signed up", record["msg"])
+	require.Equal(t, Redacted, record["password"])
+	require.Equal(t, Redacted, record["hashed_password"])
+	require.Equal(t, Redacted, record["Authorization"])
+	require.Equal(t, Redacted, record["access_token"])
+	require.Equal(t, "j***@example.com", record["email"])
+	require.Equal(t, "duplicate email j***@example.com", record["error"])
+	require.Equal(t, map[string]any{"password": Redacted, "username": "jane"}, record["user"])
+	require.Equal(t, 10.0, record["amount"])
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	require.NoError(t, err)
+	require.Equal(t, slog.LevelWarn, level)
+
+	_, err = ParseLevel("loud")
+	require.Error(t, err)
+}
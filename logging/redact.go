@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are matched case-insensitively against every part of an
+// attribute key, so that "password", "hashed_password" and "Authorization"
+// are all redacted
+var sensitiveKeys = []string{
+	"password",
+	"token",
+	"secret",
+	"authorization",
+	"cookie",
+	"otp",
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, RedactString(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, RedactString(err.Error()))
+		}
+	}
+	return attr
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString masks the email addresses in s, keeping the first letter and
+// the domain for debugging: "jane@example.com" becomes "j***@example.com"
+func RedactString(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		local, domain, _ := strings.Cut(email, "@")
+		return local[:1] + "***@" + domain
+	})
+}
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSender writes every message to an .eml file, so that flows can be
+// followed without a mail server
+type FileSender struct {
+	dir  string
+	from string
+	now  func() time.Time
+}
+
+// NewFileSender writes messages from the from address into dir, which is
+// created when missing
+func NewFileSender(dir, from string) *FileSender {
+	return &FileSender{dir: dir, from: from, now: time.Now}
+}
+
+// Send writes msg to a new file named after the time it was sent
+func (sender *FileSender) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(sender.dir, 0o700); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+
+	now := sender.now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000Z"), randomID())
+	if err := os.WriteFile(filepath.Join(sender.dir, name), msg.bytes(sender.from, now), 0o600); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package mail sends the emails of the service, such as email verification
+// and password reset links.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/hiiamanop/simple_bank/util"
+)
+
+// Backends supported by MAIL_BACKEND
+const (
+	BackendLog  = "log"
+	BackendFile = "file"
+	BackendSMTP = "smtp"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New creates the sender selected by MAIL_BACKEND
+func New(config util.Config, logger *slog.Logger) (Sender, error) {
+	switch config.MailBackend {
+	case BackendLog, "":
+		return NewLogSender(logger), nil
+	case BackendFile:
+		return NewFileSender(config.MailDir, config.MailFrom), nil
+	case BackendSMTP:
+		return NewSMTPSender(config.SMTPAddress, config.SMTPUsername, config.SMTPPassword, config.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", config.MailBackend)
+	}
+}
+
+// LogSender logs messages instead of sending them, for development
+type LogSender struct {
+	logger *slog.Logger
+}
+
+// NewLogSender logs every message to logger at info level
+func NewLogSender(logger *slog.Logger) *LogSender {
+	return &LogSender{logger: logger}
+}
+
+// Send logs msg, including its body
+func (sender *LogSender) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	sender.logger.InfoContext(ctx, "mail sent",
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", msg.Body),
+	)
+	return nil
+}
+
+// validate rejects line breaks in the headers, which would allow injecting
+// further headers or recipients
+func (msg Message) validate() error {
+	if msg.To == "" {
+		return errors.New("mail: missing recipient")
+	}
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return errors.New("mail: line break in header")
+	}
+	return nil
+}
+
+// bytes formats msg as an RFC 5322 message from the from address
+func (msg Message) bytes(from string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", randomID(), domain(from))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	// crypto/rand does not fail on supported platforms
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// domain returns the domain of an address such as "Bank <no-reply@bank.com>"
+func domain(address string) string {
+	if parsed, err := netmail.ParseAddress(address); err == nil {
+		if _, domain, ok := strings.Cut(parsed.Address, "@"); ok {
+			return domain
+		}
+	}
+	return "localhost"
+}
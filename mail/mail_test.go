@@ -0,0 +1,178 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+const testFrom = "SimpleBank <no-reply@simplebank.local>"
+
+var testMessage = Message{
+	To:      "alice@example.com",
+	Subject: "Verify your email",
+	Body:    "Hello Alice,\nopen the link.\n",
+}
+
+func TestNew(t *testing.T) {
+	for backend, want := range map[string]any{
+		BackendLog:  &LogSender{},
+		BackendFile: &FileSender{},
+		BackendSMTP: &SMTPSender{},
+	} {
+		sender, err := New(util.Config{MailBackend: backend}, slog.Default())
+		require.NoError(t, err)
+		require.IsType(t, want, sender)
+	}
+
+	_, err := New(util.Config{MailBackend: "carrier-pigeon"}, slog.Default())
+	require.ErrorContains(t, err, "unknown mail backend")
+}
+
+func TestHeaderInjection(t *testing.T) {
+	sender := NewLogSender(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	msg := testMessage
+	msg.Subject = "Hello\r\nBcc: mallory@example.com"
+	require.ErrorContains(t, sender.Send(context.Background(), msg), "line break")
+
+	msg = testMessage
+	msg.To = ""
+	require.ErrorContains(t, sender.Send(context.Background(), msg), "missing recipient")
+}
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sender := NewFileSender(dir, testFrom)
+
+	require.NoError(t, sender.Send(context.Background(), testMessage))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.True(t, strings.HasSuffix(files[0].Name(), ".eml"))
+
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "From: "+testFrom+"\r\n")
+	require.Contains(t, string(data), "To: alice@example.com\r\n")
+	require.Contains(t, string(data), "Subject: Verify your email\r\n")
+	require.Contains(t, string(data), "@simplebank.local>\r\n")
+	require.True(t, strings.HasSuffix(string(data), "\r\n\r\nHello Alice,\r\nopen the link.\r\n"))
+}
+
+// fakeSMTP is a minimal SMTP server that accepts one message and hands
+// over the envelope and data
+type fakeSMTP struct {
+	address  string
+	received chan smtpSession
+}
+
+type smtpSession struct {
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeSMTP{address: listener.Addr().String(), received: make(chan smtpSession, 1)}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		server.serve(conn)
+	}()
+	return server
+}
+
+func (server *fakeSMTP) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var session smtpSession
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			session.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			session.to = append(session.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			session.data = data.String()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			server.received <- session
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	server := newFakeSMTP(t)
+	sender := NewSMTPSender(server.address, "", "", testFrom)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, sender.Send(ctx, testMessage))
+
+	select {
+	case session := <-server.received:
+		require.Equal(t, "no-reply@simplebank.local", session.from)
+		require.Equal(t, []string{"alice@example.com"}, session.to)
+		require.Contains(t, session.data, "Subject: Verify your email\r\n")
+		require.Contains(t, session.data, "Hello Alice,\r\n")
+	case <-ctx.Done():
+		t.Fatal("no message received")
+	}
+}
+
+func TestSMTPSenderUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	sender := NewSMTPSender(address, "", "", testFrom)
+	require.Error(t, sender.Send(context.Background(), testMessage))
+}
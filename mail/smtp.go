@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPSender delivers messages through an SMTP server. It upgrades the
+// connection with STARTTLS when the server offers it, and authenticates
+// with PLAIN when a username is set.
+type SMTPSender struct {
+	address  string
+	username string
+	password string
+	from     string
+	now      func() time.Time
+}
+
+// NewSMTPSender sends messages from the from address through the server at
+// address, a host:port
+func NewSMTPSender(address, username, password, from string) *SMTPSender {
+	return &SMTPSender{
+		address:  address,
+		username: username,
+		password: password,
+		from:     from,
+		now:      time.Now,
+	}
+}
+
+// Send delivers msg. The connection is closed when ctx is done.
+func (sender *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	from, err := netmail.ParseAddress(sender.from)
+	if err != nil {
+		return fmt.Errorf("mail: invalid sender: %w", err)
+	}
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("mail: invalid recipient: %w", err)
+	}
+
+	host, _, err := net.SplitHostPort(sender.address)
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", sender.address)
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mail: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("mail: starttls: %w", err)
+		}
+	}
+	if sender.username != "" {
+		auth := smtp.PlainAuth("", sender.username, sender.password, host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("mail: auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if _, err := w.Write(msg.bytes(sender.from, sender.now())); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	return client.Quit()
+}
@@ -1,31 +1,202 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/hiiamanop/simple_bank/api"
+	"github.com/hiiamanop/simple_bank/db/migration"
 	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/hiiamanop/simple_bank/health"
+	"github.com/hiiamanop/simple_bank/logging"
+	"github.com/hiiamanop/simple_bank/mail"
+	"github.com/hiiamanop/simple_bank/metrics"
+	"github.com/hiiamanop/simple_bank/monitoring"
+	"github.com/hiiamanop/simple_bank/pii"
+	"github.com/hiiamanop/simple_bank/ratelimit"
+	"github.com/hiiamanop/simple_bank/screening"
+	"github.com/hiiamanop/simple_bank/tracing"
 	"github.com/hiiamanop/simple_bank/util"
+	"github.com/hiiamanop/simple_bank/worker"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 func main() {
-	config, err := util.LoadConfig(".")
+	configWatcher, err := util.WatchConfig(".")
 	if err != nil {
-		log.Fatal("cannot load config", err)
+		fatal("cannot load config", err)
 	}
+	config := configWatcher.Current()
 
-	dbConn, err := sql.Open(config.DBDriver, config.DBSource)
+	var logLevel slog.LevelVar
+	if err := setLogLevel(&logLevel, config); err != nil {
+		fatal("cannot configure logging", err)
+	}
+	logger := logging.New(os.Stdout, &logLevel)
+	slog.SetDefault(logger)
+
+	if config.MigrateOnStart {
+		if err := migration.Up(config.DBSource); err != nil {
+			fatal("cannot migrate db", err)
+		}
+		logger.Info("db migrated successfully")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), config)
 	if err != nil {
-		log.Fatal("cannot connect to db:", err)
+		fatal("cannot setup tracing", err)
 	}
 
-	store := db.NewStore(dbConn)
-	server := api.NewServer(store)
+	dbConn, err := db.Open(config)
+	if err != nil {
+		fatal("cannot connect to db", err)
+	}
 
-	err = server.Start(config.ServerAddress)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(dbConn, "simple_bank"),
+	)
+	serverMetrics := metrics.New(registry)
+
+	piiKeys, err := pii.LoadKeyFile(config.PIIKeyFile)
 	if err != nil {
-		log.Fatal("cannot start server:", err)
+		fatal("cannot load PII keys, create them with \"simplebank pii keygen\"", err)
+	}
+
+	storeOptions := []db.StoreOption{
+		db.WithTxObserver(serverMetrics),
+		db.WithCipher(pii.NewCipher(piiKeys)),
 	}
+	var monitor *monitoring.Engine
+	if config.MonitoringRulesFile != "" {
+		monitor, err = monitoring.LoadRules(config.MonitoringRulesFile)
+		if err != nil {
+			fatal("cannot load monitoring rules", err)
+		}
+		storeOptions = append(storeOptions, db.WithMonitor(monitor))
+	}
+
+	var screener *screening.Screener
+	if config.SanctionsOFACFile != "" || config.SanctionsEUFile != "" {
+		screener, err = screening.Load(config.SanctionsOFACFile, config.SanctionsEUFile, config.ScreeningThreshold)
+		if err != nil {
+			fatal("cannot load sanctions lists", err)
+		}
+		logger.Info("loaded sanctions lists", slog.Int("entries", screener.Len()))
+	}
+
+	store := db.NewStore(dbConn, storeOptions...)
+	serverMetrics.RegisterLedger(store)
+
+	workers := worker.NewGroup()
+
+	readiness := health.NewChecker(health.DefaultTimeout)
+	readiness.Add("database", health.Database(dbConn))
+	readiness.Add("migrations", health.Migrations(dbConn))
+	readiness.Add("workers", health.Workers(workers))
+
+	var limiter ratelimit.Limiter = ratelimit.NewMemory()
+	if config.RateLimitBackend == "postgres" {
+		limiter = ratelimit.NewPostgres(store)
+	}
+
+	mailer, err := mail.New(config, logger)
+	if err != nil {
+		fatal("cannot configure mail", err)
+	}
+
+	server := api.NewServer(config, store,
+		api.WithMetrics(serverMetrics),
+		api.WithLogger(logger),
+		api.WithReadiness(readiness),
+		api.WithRateLimiter(limiter),
+		api.WithMailSender(mailer),
+		api.WithMonitor(monitor),
+		api.WithScreener(screener),
+	)
+
+	workers.Go("idempotency-janitor", server.PruneIdempotencyKeys)
+	workers.Go("approval-expirer", server.ExpireTransferApprovals)
+	workers.Go("rate-limit-janitor", ratelimit.Janitor(limiter, time.Minute, func() time.Duration {
+		return configWatcher.Current().LongestRateLimitPeriod()
+	}))
+
+	configWatcher.Subscribe(func(config util.Config) {
+		if err := setLogLevel(&logLevel, config); err != nil {
+			logger.Error("cannot reload log level", slog.Any("error", err))
+		}
+		server.Reload(config)
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	logger.Info("starting server", slog.String("address", config.ServerAddress))
+	go func() {
+		serverErr <- server.Start(config.ServerAddress)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			fatal("cannot start server", err)
+		}
+	case <-ctx.Done():
+		logger.Info("shutting down")
+	}
+	stop()
+
+	shutdown(config, server, workers, dbConn, shutdownTracing)
+}
+
+// shutdown drains in-flight requests first, so that running transfers can
+// still use the workers and the database, then stops the workers, closes
+// the database and finally flushes the spans recorded along the way
+func shutdown(
+	config util.Config,
+	server *api.Server,
+	workers *worker.Group,
+	dbConn *sql.DB,
+	shutdownTracing func(context.Context) error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("cannot drain http server", slog.Any("error", err))
+	}
+	if err := workers.Stop(ctx); err != nil {
+		slog.Error("cannot stop workers", slog.Any("error", err))
+	}
+	if err := dbConn.Close(); err != nil {
+		slog.Error("cannot close db", slog.Any("error", err))
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("cannot flush traces", slog.Any("error", err))
+	}
+	slog.Info("server stopped")
+}
+
+func setLogLevel(logLevel *slog.LevelVar, config util.Config) error {
+	level, err := logging.ParseLevel(config.LogLevel)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
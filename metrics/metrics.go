@@ -0,0 +1,150 @@
+// Package metrics defines the Prometheus metrics exported by the service.
+// Metrics are registered on an injected registry so that servers and tests
+// never share global state.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "simplebank"
+
+// ledgerQueryTimeout bounds the queries run while scraping the ledger gauges
+const ledgerQueryTimeout = 5 * time.Second
+
+// Metrics holds the collectors of the service
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests   *prometheus.HistogramVec
+	txDuration     *prometheus.HistogramVec
+	txRetries      *prometheus.CounterVec
+	txFailures     *prometheus.CounterVec
+	transferVolume *prometheus.CounterVec
+}
+
+// New creates the metrics and registers them on registry
+func New(registry *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registry: registry,
+		httpRequests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		txDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_tx_duration_seconds",
+			Help:      "Duration of database transactions, including retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"tx", "outcome"}),
+		txRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_tx_retries_total",
+			Help:      "Transactions retried after a serialization failure or deadlock.",
+		}, []string{"tx"}),
+		txFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_tx_failures_total",
+			Help:      "Transactions that failed after all attempts.",
+		}, []string{"tx"}),
+		transferVolume: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfer_volume_total",
+			Help:      "Amount transferred between accounts by currency.",
+		}, []string{"currency"}),
+	}
+
+	registry.MustRegister(
+		m.httpRequests,
+		m.txDuration,
+		m.txRetries,
+		m.txFailures,
+		m.transferVolume,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveHTTPRequest records a handled request. route is the route template,
+// such as /api/v1/accounts/:id, so that IDs do not blow up the cardinality.
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	m.httpRequests.
+		WithLabelValues(method, route, strconv.Itoa(status)).
+		Observe(duration.Seconds())
+}
+
+// TransferCompleted adds a successful transfer to the volume of its currency
+func (m *Metrics) TransferCompleted(currency string, amount int64) {
+	m.transferVolume.WithLabelValues(currency).Add(float64(amount))
+}
+
+// TxRetried implements db.TxObserver
+func (m *Metrics) TxRetried(name string, err error) {
+	m.txRetries.WithLabelValues(name).Inc()
+}
+
+// TxFinished implements db.TxObserver
+func (m *Metrics) TxFinished(name string, duration time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		m.txFailures.WithLabelValues(name).Inc()
+	}
+	m.txDuration.WithLabelValues(name, outcome).Observe(duration.Seconds())
+}
+
+// BalanceSummer is the part of db.Store needed for the balance gauges
+type BalanceSummer interface {
+	SumBalancesByCurrency(ctx context.Context) ([]db.SumBalancesByCurrencyRow, error)
+}
+
+// RegisterLedger exports the total balance per currency, queried from store
+// on every scrape
+func (m *Metrics) RegisterLedger(store BalanceSummer) {
+	m.registry.MustRegister(&ledgerCollector{
+		store: store,
+		balances: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "account_balance_total"),
+			"Sum of all account balances by currency.",
+			[]string{"currency"}, nil,
+		),
+	})
+}
+
+type ledgerCollector struct {
+	store    BalanceSummer
+	balances *prometheus.Desc
+}
+
+func (c *ledgerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.balances
+}
+
+func (c *ledgerCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), ledgerQueryTimeout)
+	defer cancel()
+
+	rows, err := c.store.SumBalancesByCurrency(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.balances, fmt.Errorf("cannot sum balances: %w", err))
+		return
+	}
+
+	for _, row := range rows {
+		ch <- prometheus.MustNewConstMetric(c.balances, prometheus.GaugeValue, float64(row.TotalBalance), row.Currency)
+	}
+}
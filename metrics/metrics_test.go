@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBalances struct {
+	rows []db.SumBalancesByCurrencyRow
+	err  error
+}
+
+func (f fakeBalances) SumBalancesByCurrency(ctx context.Context) ([]db.SumBalancesByCurrencyRow, error) {
+	return f.rows, f.err
+}
+
+func TestTxObserver(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	m.TxRetried("TransferTx", errors.New("deadlock"))
+	m.TxFinished("TransferTx", 10*time.Millisecond, nil)
+	m.TxFinished("TransferTx", 10*time.Millisecond, sql.ErrConnDone)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.txRetries.WithLabelValues("TransferTx")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.txFailures.WithLabelValues("TransferTx")))
+	require.Equal(t, 2, testutil.CollectAndCount(m.txDuration))
+}
+
+func TestTransferVolume(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	m.TransferCompleted("USD", 10)
+	m.TransferCompleted("USD", 5)
+	m.TransferCompleted("EUR", 7)
+
+	require.Equal(t, 15.0, testutil.ToFloat64(m.transferVolume.WithLabelValues("USD")))
+	require.Equal(t, 7.0, testutil.ToFloat64(m.transferVolume.WithLabelValues("EUR")))
+}
+
+func TestLedgerCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := New(registry)
+	m.RegisterLedger(fakeBalances{rows: []db.SumBalancesByCurrencyRow{
+		{Currency: "EUR", TotalBalance: 300},
+		{Currency: "USD", TotalBalance: 1200},
+	}})
+
+	expected := `
+# HELP simplebank_account_balance_total Sum of all account balances by currency.
+# TYPE simplebank_account_balance_total gauge
+simplebank_account_balance_total{currency="EUR"} 300
+simplebank_account_balance_total{currency="USD"} 1200
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "simplebank_account_balance_total")
+	require.NoError(t, err)
+}
+
+func TestLedgerCollectorError(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := New(registry)
+	m.RegisterLedger(fakeBalances{err: sql.ErrConnDone})
+
+	_, err := registry.Gather()
+	require.ErrorIs(t, err, sql.ErrConnDone)
+}
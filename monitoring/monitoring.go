@@ -0,0 +1,96 @@
+// Package monitoring flags suspicious transfers for anti money laundering
+// review. An Engine evaluates every transfer against rules loaded from a
+// file; a rule that matches is a Hit, which the store records as an alert
+// or, for blocking rules, turns into a transfer held for review.
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Transfer is what the rules know about the transfer being evaluated
+type Transfer struct {
+	ID            int64
+	FromAccountID int64
+	ToAccountID   int64
+	Amount        int64
+	Currency      string
+	CreatedAt     time.Time
+	// ToAccountCreatedAt is when the receiving account was opened
+	ToAccountCreatedAt time.Time
+}
+
+// History answers the questions of the rules about earlier transfers. It
+// sees the transfer being evaluated, which the store has already written in
+// the same transaction.
+type History interface {
+	// CountSent counts the transfers sent from accountID at or after since
+	// with an amount in [minAmount, maxAmount)
+	CountSent(ctx context.Context, accountID int64, since time.Time, minAmount, maxAmount int64) (int64, error)
+	// SumReceived adds up the transfers received by accountID at or after
+	// since
+	SumReceived(ctx context.Context, accountID int64, since time.Time) (int64, error)
+}
+
+// Hit is a rule that matched a transfer
+type Hit struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Block    bool   `json:"block"`
+	// Reason explains the match to the analyst
+	Reason string `json:"reason"`
+}
+
+// Engine evaluates transfers against a fixed set of rules. It is safe for
+// concurrent use.
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine validates rules and returns an engine that evaluates them
+func NewEngine(rules []Rule) (*Engine, error) {
+	names := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, rule.Name, err)
+		}
+		// alerts name their rule
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %d: name %q is used twice", i+1, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return &Engine{rules: rules}, nil
+}
+
+// Rules returns the rules of the engine
+func (engine *Engine) Rules() []Rule {
+	return append([]Rule(nil), engine.rules...)
+}
+
+// Evaluate returns the rules that match transfer, in the order of the rules
+func (engine *Engine) Evaluate(ctx context.Context, history History, transfer Transfer) ([]Hit, error) {
+	var hits []Hit
+	for _, rule := range engine.rules {
+		reason, err := rule.match(ctx, history, transfer)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		if reason != "" {
+			hits = append(hits, Hit{Rule: rule.Name, Severity: rule.Severity, Block: rule.Block, Reason: reason})
+		}
+	}
+	return hits, nil
+}
+
+// Blocking reports whether any of hits is of a blocking rule
+func Blocking(hits []Hit) bool {
+	for _, hit := range hits {
+		if hit.Block {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package monitoring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHistory answers every question with fixed numbers and records the
+// windows asked about
+type fakeHistory struct {
+	sent     int64
+	received int64
+	since    []time.Time
+}
+
+func (h *fakeHistory) CountSent(ctx context.Context, accountID int64, since time.Time, minAmount, maxAmount int64) (int64, error) {
+	h.since = append(h.since, since)
+	return h.sent, nil
+}
+
+func (h *fakeHistory) SumReceived(ctx context.Context, accountID int64, since time.Time) (int64, error) {
+	h.since = append(h.since, since)
+	return h.received, nil
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	engine, err := NewEngine([]Rule{
+		{Name: "structuring", Type: RuleStructuring, Severity: SeverityHigh, Threshold: 1000, Margin: 100, Count: 3, Window: Duration(24 * time.Hour)},
+		{Name: "rapid", Type: RuleRapidMovement, Severity: SeverityMedium, Window: Duration(time.Hour), Ratio: 0.9, MinAmount: 500},
+		{Name: "new_account", Type: RuleNewAccountReceipt, Severity: SeverityMedium, Block: true, AccountAge: Duration(24 * time.Hour), MinAmount: 900},
+		{Name: "round", Type: RuleRoundAmount, Severity: SeverityLow, Multiple: 100, MinAmount: 500},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		history  fakeHistory
+		transfer Transfer
+		rules    []string
+	}{
+		{
+			name:     "None",
+			transfer: Transfer{Amount: 950, CreatedAt: now, ToAccountCreatedAt: now.AddDate(-1, 0, 0)},
+		},
+		{
+			name:     "Structuring",
+			history:  fakeHistory{sent: 3},
+			transfer: Transfer{Amount: 950, CreatedAt: now, ToAccountCreatedAt: now.AddDate(-1, 0, 0)},
+			rules:    []string{"structuring"},
+		},
+		{
+			name:     "AtThreshold",
+			history:  fakeHistory{sent: 3},
+			transfer: Transfer{Amount: 1001, CreatedAt: now, ToAccountCreatedAt: now.AddDate(-1, 0, 0)},
+		},
+		{
+			name:     "RapidMovement",
+			history:  fakeHistory{received: 1000},
+			transfer: Transfer{Amount: 901, CreatedAt: now, ToAccountCreatedAt: now.AddDate(-1, 0, 0)},
+			rules:    []string{"rapid"},
+		},
+		{
+			name:     "NewAccountRoundAmount",
+			transfer: Transfer{Amount: 2000, CreatedAt: now, ToAccountCreatedAt: now.Add(-time.Hour)},
+			rules:    []string{"new_account", "round"},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			hits, err := engine.Evaluate(context.Background(), &tc.history, tc.transfer)
+			require.NoError(t, err)
+
+			var rules []string
+			for _, hit := range hits {
+				require.NotEmpty(t, hit.Reason)
+				rules = append(rules, hit.Rule)
+			}
+			require.Equal(t, tc.rules, rules)
+			require.Equal(t, tc.name == "NewAccountRoundAmount", Blocking(hits))
+			for _, since := range tc.history.since {
+				require.True(t, since.Before(now))
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "rules.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	engine, err := LoadRules(write(`{"rules": [
+		{"name": "rapid", "type": "rapid_movement", "severity": "medium", "window": "1h", "ratio": 0.9, "min_amount": 500}
+	]}`))
+	require.NoError(t, err)
+	require.Len(t, engine.Rules(), 1)
+	require.Equal(t, Duration(time.Hour), engine.Rules()[0].Window)
+
+	_, err = LoadRules(write(`{"rules": [{"name": "x", "type": "velocity", "severity": "low"}]}`))
+	require.ErrorContains(t, err, `type "velocity"`)
+
+	_, err = LoadRules(write(`{"rules": [{"name": "x", "type": "round_amount", "severity": "low", "multiple": 100, "min_amount": 1, "limit": 5}]}`))
+	require.ErrorContains(t, err, "unknown field")
+
+	_, err = LoadRules(write(`{"rules": [
+		{"name": "x", "type": "round_amount", "severity": "low", "multiple": 100, "min_amount": 1},
+		{"name": "x", "type": "round_amount", "severity": "high", "multiple": 1000, "min_amount": 1}
+	]}`))
+	require.ErrorContains(t, err, "used twice")
+
+	// the rules shipped with the repository are valid
+	_, err = LoadRules("../monitoring_rules.json")
+	require.NoError(t, err)
+}
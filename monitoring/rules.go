@@ -0,0 +1,190 @@
+package monitoring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Types of rules
+const (
+	// RuleStructuring matches transfers just below a reporting threshold,
+	// when an account sends several of them within a window
+	RuleStructuring = "structuring"
+	// RuleRapidMovement matches an account that sends on most of the money
+	// it received within a window
+	RuleRapidMovement = "rapid_movement"
+	// RuleNewAccountReceipt matches a large transfer to a recently opened
+	// account
+	RuleNewAccountReceipt = "new_account_receipt"
+	// RuleRoundAmount matches large transfers of a round amount
+	RuleRoundAmount = "round_amount"
+)
+
+// Severities of rules, in increasing order
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+var (
+	ruleTypes  = []string{RuleStructuring, RuleRapidMovement, RuleNewAccountReceipt, RuleRoundAmount}
+	severities = []string{SeverityLow, SeverityMedium, SeverityHigh}
+)
+
+// Rule is one monitoring rule. Which of the parameters apply depends on the
+// type; amounts are in the minor unit of the currency.
+type Rule struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	// Block holds a matching transfer for review instead of executing it
+	Block bool `json:"block,omitempty"`
+
+	// Threshold and Margin make the band [Threshold-Margin, Threshold) of
+	// structuring, which matches when Count transfers within Window fall
+	// in the band
+	Threshold int64 `json:"threshold,omitempty"`
+	Margin    int64 `json:"margin,omitempty"`
+	Count     int64 `json:"count,omitempty"`
+	// Window is the period looked back on by structuring and rapid movement
+	Window Duration `json:"window,omitempty"`
+	// Ratio is the share of the money received within Window that rapid
+	// movement allows to send on, e.g. 0.9
+	Ratio float64 `json:"ratio,omitempty"`
+	// MinAmount is the smallest amount that rapid movement, new account
+	// receipts and round amounts look at
+	MinAmount int64 `json:"min_amount,omitempty"`
+	// AccountAge is how long an account counts as new
+	AccountAge Duration `json:"account_age,omitempty"`
+	// Multiple is what a round amount is a multiple of, e.g. 100000
+	Multiple int64 `json:"multiple,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "24h" in JSON
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ruleFile is the format of the rules file:
+//
+//	{"rules": [{"name": "round_amounts", "type": "round_amount", "severity": "low", "min_amount": 1000000, "multiple": 100000}]}
+type ruleFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules reads the rules file at path and returns an engine for its rules
+func LoadRules(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file ruleFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	engine, err := NewEngine(file.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return engine, nil
+}
+
+func (rule Rule) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(rule.Name != "", "name is required")
+	check(slices.Contains(severities, rule.Severity), "severity %q is not one of %s", rule.Severity, strings.Join(severities, ", "))
+	switch rule.Type {
+	case RuleStructuring:
+		check(rule.Threshold > 0, "threshold must be positive")
+		check(rule.Margin > 0 && rule.Margin < rule.Threshold, "margin must be positive and less than the threshold")
+		check(rule.Count > 0, "count must be positive")
+		check(rule.Window > 0, "window must be positive")
+	case RuleRapidMovement:
+		check(rule.Window > 0, "window must be positive")
+		check(rule.Ratio > 0 && rule.Ratio <= 1, "ratio must be in (0, 1]")
+		check(rule.MinAmount > 0, "min_amount must be positive")
+	case RuleNewAccountReceipt:
+		check(rule.AccountAge > 0, "account_age must be positive")
+		check(rule.MinAmount > 0, "min_amount must be positive")
+	case RuleRoundAmount:
+		check(rule.Multiple > 0, "multiple must be positive")
+		check(rule.MinAmount > 0, "min_amount must be positive")
+	default:
+		check(false, "type %q is not one of %s", rule.Type, strings.Join(ruleTypes, ", "))
+	}
+	return errors.Join(errs...)
+}
+
+// match returns why the rule matches transfer, or "" when it does not
+func (rule Rule) match(ctx context.Context, history History, transfer Transfer) (string, error) {
+	window := time.Duration(rule.Window)
+
+	switch rule.Type {
+	case RuleStructuring:
+		low := rule.Threshold - rule.Margin
+		if transfer.Amount < low || transfer.Amount >= rule.Threshold {
+			return "", nil
+		}
+		count, err := history.CountSent(ctx, transfer.FromAccountID, transfer.CreatedAt.Add(-window), low, rule.Threshold)
+		if err != nil || count < rule.Count {
+			return "", err
+		}
+		return fmt.Sprintf("account %d sent %d transfers between %d and %d within %s",
+			transfer.FromAccountID, count, low, rule.Threshold, window), nil
+
+	case RuleRapidMovement:
+		received, err := history.SumReceived(ctx, transfer.FromAccountID, transfer.CreatedAt.Add(-window))
+		if err != nil || received < rule.MinAmount || float64(transfer.Amount) < rule.Ratio*float64(received) {
+			return "", err
+		}
+		return fmt.Sprintf("account %d sent %d after receiving %d within %s",
+			transfer.FromAccountID, transfer.Amount, received, window), nil
+
+	case RuleNewAccountReceipt:
+		age := transfer.CreatedAt.Sub(transfer.ToAccountCreatedAt)
+		if transfer.Amount < rule.MinAmount || age >= time.Duration(rule.AccountAge) {
+			return "", nil
+		}
+		return fmt.Sprintf("account %d received %d when it was %s old",
+			transfer.ToAccountID, transfer.Amount, age.Round(time.Minute)), nil
+
+	case RuleRoundAmount:
+		if transfer.Amount < rule.MinAmount || transfer.Amount%rule.Multiple != 0 {
+			return "", nil
+		}
+		return fmt.Sprintf("amount %d is a multiple of %d", transfer.Amount, rule.Multiple), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,152 @@
+package pii
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// KeyFile is a KeyProvider that reads its keys from a local JSON file:
+//
+//	{"current": "k2", "keys": {"k1": "<base64>", "k2": "<base64>"}, "index_key": "<base64>"}
+//
+// Keys are KeySize random bytes. Old keys stay in the file until no data key
+// is wrapped with them anymore.
+type KeyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string][]byte `json:"keys"`
+	Index   []byte            `json:"index_key"`
+}
+
+// NewKeyFile generates a key file with one key and an index key
+func NewKeyFile() (*KeyFile, error) {
+	file := &KeyFile{Keys: map[string][]byte{}}
+	var err error
+	if file.Index, err = randomKey(); err != nil {
+		return nil, err
+	}
+	if _, err = file.Rotate(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// LoadKeyFile reads and validates the key file at path
+func LoadKeyFile(path string) (*KeyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file KeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// Save writes the key file to path, readable by the owner only. It replaces
+// an existing file atomically, so a crash cannot lose the keys.
+func (file *KeyFile) Save(path string) error {
+	if err := file.validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Rotate adds a new key and makes it the current one. Values encrypted
+// before keep working; Cipher.Rewrap moves them to the new key.
+func (file *KeyFile) Rotate() (string, error) {
+	key, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+
+	next := 1
+	for id := range file.Keys {
+		if n, err := strconv.Atoi(strings.TrimPrefix(id, "k")); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	id := "k" + strconv.Itoa(next)
+	file.Keys[id] = key
+	file.Current = id
+	return id, nil
+}
+
+// CurrentKeyID returns the ID of the current key
+func (file *KeyFile) CurrentKeyID(ctx context.Context) (string, error) {
+	return file.Current, nil
+}
+
+// WrapKey encrypts dataKey with the current key
+func (file *KeyFile) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(file.Keys[file.Current], dataKey)
+	return file.Current, wrapped, err
+}
+
+// UnwrapKey decrypts a data key wrapped with the key keyID
+func (file *KeyFile) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := file.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return open(key, wrapped)
+}
+
+// IndexKey returns the key of blind indexes
+func (file *KeyFile) IndexKey(ctx context.Context) ([]byte, error) {
+	return file.Index, nil
+}
+
+func (file *KeyFile) validate() error {
+	if len(file.Index) != KeySize {
+		return fmt.Errorf("index_key must be %d bytes", KeySize)
+	}
+	if _, ok := file.Keys[file.Current]; !ok {
+		return fmt.Errorf("current key %q is not in keys", file.Current)
+	}
+	for id, key := range file.Keys {
+		// the ID is part of every encrypted value
+		if id == "" || strings.Contains(id, ":") {
+			return fmt.Errorf("key ID %q must be non-empty and must not contain a colon", id)
+		}
+		if len(key) != KeySize {
+			return fmt.Errorf("key %q must be %d bytes", id, KeySize)
+		}
+	}
+	return nil
+}
+
+func randomKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
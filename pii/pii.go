@@ -0,0 +1,215 @@
+// Package pii encrypts personal data, such as names and email addresses,
+// before it is stored. Every value is encrypted with its own data key, which
+// is in turn encrypted (wrapped) with a key encryption key from a
+// KeyProvider. Rotating the key encryption key only rewraps the data keys.
+package pii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by Decrypt and the key providers
+var (
+	ErrMalformed  = errors.New("encrypted value is malformed")
+	ErrUnknownKey = errors.New("unknown key")
+)
+
+// KeySize is the size of data keys and of the keys in a KeyFile
+const KeySize = 32
+
+// prefix marks encrypted values. Values without it are plaintext written
+// before encryption was introduced, and are returned as they are.
+const prefix = "pii1:"
+
+// KeyProvider holds the key encryption keys. Keys are identified by an ID,
+// so that data keys wrapped before a rotation can still be unwrapped.
+type KeyProvider interface {
+	// CurrentKeyID returns the ID of the key that WrapKey uses
+	CurrentKeyID(ctx context.Context) (string, error)
+	// WrapKey encrypts dataKey with the current key and returns its ID
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped with the key keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	// IndexKey returns the key of blind indexes. Changing it changes every
+	// index, so it is not rotated with the other keys.
+	IndexKey(ctx context.Context) ([]byte, error)
+}
+
+// Cipher encrypts and decrypts values with envelope encryption
+type Cipher struct {
+	keys KeyProvider
+}
+
+// NewCipher creates a cipher whose data keys are wrapped by keys
+func NewCipher(keys KeyProvider) *Cipher {
+	return &Cipher{keys: keys}
+}
+
+// envelope is a parsed encrypted value:
+// "pii1:<key id>:<wrapped data key>:<nonce and ciphertext>"
+type envelope struct {
+	keyID      string
+	wrappedKey []byte
+	sealed     []byte
+}
+
+// Encrypt encrypts plaintext with a new data key. Encrypting the same value
+// twice gives different results; use BlindIndex to look values up.
+func (c *Cipher) Encrypt(ctx context.Context, plaintext string) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	keyID, wrapped, err := c.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return "", fmt.Errorf("cannot wrap data key: %w", err)
+	}
+	return envelope{keyID: keyID, wrappedKey: wrapped, sealed: sealed}.String(), nil
+}
+
+// Decrypt returns the plaintext of value. A value that was never encrypted
+// is returned unchanged.
+func (c *Cipher) Decrypt(ctx context.Context, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := c.keys.UnwrapKey(ctx, env.keyID, env.wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("cannot unwrap data key: %w", err)
+	}
+	plaintext, err := open(dataKey, env.sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap returns value with its data key wrapped by the current key, and
+// encrypts values that are still plaintext. changed is false when value is
+// already up to date.
+func (c *Cipher) Rewrap(ctx context.Context, value string) (rewrapped string, changed bool, err error) {
+	if !IsEncrypted(value) {
+		rewrapped, err = c.Encrypt(ctx, value)
+		return rewrapped, err == nil, err
+	}
+
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return "", false, err
+	}
+	current, err := c.keys.CurrentKeyID(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if env.keyID == current {
+		return value, false, nil
+	}
+
+	dataKey, err := c.keys.UnwrapKey(ctx, env.keyID, env.wrappedKey)
+	if err != nil {
+		return "", false, fmt.Errorf("cannot unwrap data key: %w", err)
+	}
+	if env.keyID, env.wrappedKey, err = c.keys.WrapKey(ctx, dataKey); err != nil {
+		return "", false, fmt.Errorf("cannot wrap data key: %w", err)
+	}
+	return env.String(), true, nil
+}
+
+// BlindIndex returns the hex encoded HMAC-SHA256 of value, which finds and
+// deduplicates encrypted values without decrypting them. Equal values have
+// equal indexes, so only index values that are unique or hard to guess.
+func (c *Cipher) BlindIndex(ctx context.Context, value string) (string, error) {
+	key, err := c.keys.IndexKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// IsEncrypted reports whether value was written by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func (env envelope) String() string {
+	return prefix + env.keyID + ":" +
+		base64.RawStdEncoding.EncodeToString(env.wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(env.sealed)
+}
+
+func parseEnvelope(value string) (envelope, error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return envelope{}, ErrMalformed
+	}
+
+	env := envelope{keyID: parts[0]}
+	var err error
+	if env.wrappedKey, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return envelope{}, ErrMalformed
+	}
+	if env.sealed, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return envelope{}, ErrMalformed
+	}
+	return env, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM and prepends the random nonce
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open reverses seal
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,175 @@
+package pii
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyFile(t *testing.T) *KeyFile {
+	file, err := NewKeyFile()
+	require.NoError(t, err)
+	return file
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	c := NewCipher(newTestKeyFile(t))
+
+	encrypted, err := c.Encrypt(ctx, "alice@example.com")
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(encrypted))
+	require.NotContains(t, encrypted, "alice")
+
+	// every value has its own data key and nonce
+	again, err := c.Encrypt(ctx, "alice@example.com")
+	require.NoError(t, err)
+	require.NotEqual(t, encrypted, again)
+
+	for _, value := range []string{encrypted, again} {
+		plaintext, err := c.Decrypt(ctx, value)
+		require.NoError(t, err)
+		require.Equal(t, "alice@example.com", plaintext)
+	}
+
+	empty, err := c.Encrypt(ctx, "")
+	require.NoError(t, err)
+	plaintext, err := c.Decrypt(ctx, empty)
+	require.NoError(t, err)
+	require.Empty(t, plaintext)
+
+	// values written before encryption pass through
+	plaintext, err = c.Decrypt(ctx, "bob@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "bob@example.com", plaintext)
+}
+
+func TestDecryptTampered(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeyFile(t)
+	c := NewCipher(keys)
+
+	encrypted, err := c.Encrypt(ctx, "Alice")
+	require.NoError(t, err)
+
+	// change a character inside the ciphertext, away from the padding bits
+	i := len(encrypted) - 10
+	flipped := "A"
+	if encrypted[i] == 'A' {
+		flipped = "B"
+	}
+	for _, value := range []string{
+		encrypted[:i] + flipped + encrypted[i+1:],
+		"pii1:k1:%%%:abc",
+		"pii1:k1:abc",
+		"pii1::abc:abc",
+	} {
+		_, err := c.Decrypt(ctx, value)
+		require.ErrorIs(t, err, ErrMalformed, value)
+	}
+
+	_, err = c.Decrypt(ctx, strings.Replace(encrypted, "pii1:k1:", "pii1:k9:", 1))
+	require.ErrorIs(t, err, ErrUnknownKey)
+
+	// a different key file cannot decrypt it
+	_, err = NewCipher(newTestKeyFile(t)).Decrypt(ctx, encrypted)
+	require.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestRewrap(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeyFile(t)
+	c := NewCipher(keys)
+
+	encrypted, err := c.Encrypt(ctx, "alice@example.com")
+	require.NoError(t, err)
+
+	_, changed, err := c.Rewrap(ctx, encrypted)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	id, err := keys.Rotate()
+	require.NoError(t, err)
+	require.Equal(t, "k2", id)
+
+	rewrapped, changed, err := c.Rewrap(ctx, encrypted)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.True(t, strings.HasPrefix(rewrapped, "pii1:k2:"))
+
+	// the old key can be removed once everything is rewrapped
+	delete(keys.Keys, "k1")
+	plaintext, err := c.Decrypt(ctx, rewrapped)
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", plaintext)
+
+	// plaintext is encrypted
+	rewrapped, changed, err = c.Rewrap(ctx, "bob@example.com")
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.True(t, IsEncrypted(rewrapped))
+}
+
+func TestBlindIndex(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeyFile(t)
+	c := NewCipher(keys)
+
+	index, err := c.BlindIndex(ctx, "alice@example.com")
+	require.NoError(t, err)
+	require.Len(t, index, 64)
+
+	again, err := c.BlindIndex(ctx, "alice@example.com")
+	require.NoError(t, err)
+	require.Equal(t, index, again)
+
+	// rotating the key encryption key keeps the indexes
+	_, err = keys.Rotate()
+	require.NoError(t, err)
+	again, err = c.BlindIndex(ctx, "alice@example.com")
+	require.NoError(t, err)
+	require.Equal(t, index, again)
+
+	other, err := c.BlindIndex(ctx, "Alice@example.com")
+	require.NoError(t, err)
+	require.NotEqual(t, index, other)
+
+	other, err = NewCipher(newTestKeyFile(t)).BlindIndex(ctx, "alice@example.com")
+	require.NoError(t, err)
+	require.NotEqual(t, index, other)
+}
+
+func TestKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pii_keys.json")
+	keys := newTestKeyFile(t)
+	require.Equal(t, "k1", keys.Current)
+
+	require.NoError(t, keys.Save(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	loaded, err := LoadKeyFile(path)
+	require.NoError(t, err)
+	require.Equal(t, keys, loaded)
+
+	_, err = LoadKeyFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	validKey := strings.Repeat("A", 43) + "="
+	for name, content := range map[string]string{
+		"NotJSON":       `keys`,
+		"NoCurrent":     `{"current":"k2","keys":{"k1":"` + validKey + `"},"index_key":"` + validKey + `"}`,
+		"ShortKey":      `{"current":"k1","keys":{"k1":"AAAA"},"index_key":"` + validKey + `"}`,
+		"ShortIndexKey": `{"current":"k1","keys":{"k1":"` + validKey + `"},"index_key":"AAAA"}`,
+	} {
+		bad := filepath.Join(t.TempDir(), "keys.json")
+		require.NoError(t, os.WriteFile(bad, []byte(content), 0o600))
+		_, err := LoadKeyFile(bad)
+		require.Error(t, err, name)
+	}
+}
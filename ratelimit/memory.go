@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// Memory keeps the buckets in the memory of one instance
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemory creates an empty in-memory limiter
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.capacity(), updatedAt: now}
+		m.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	b.tokens = min(limit.capacity(), b.tokens+elapsed*limit.refillRate())
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(limit, b.tokens, allowed), nil
+}
+
+func (m *Memory) Prune(ctx context.Context, idle time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idleSince := m.now().Add(-idle)
+	for key, b := range m.buckets {
+		if b.updatedAt.Before(idleSince) {
+			delete(m.buckets, key)
+		}
+	}
+	return nil
+}
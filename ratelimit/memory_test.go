@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTake(t *testing.T) {
+	limiter := NewMemory()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 2, Period: time.Minute}
+	ctx := context.Background()
+
+	result, err := limiter.Take(ctx, "ip:1", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 1, result.Remaining)
+	require.Equal(t, 30*time.Second, result.Reset)
+
+	result, err = limiter.Take(ctx, "ip:1", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Zero(t, result.Remaining)
+
+	result, err = limiter.Take(ctx, "ip:1", limit)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 30*time.Second, result.RetryAfter)
+
+	// other keys have their own bucket
+	result, err = limiter.Take(ctx, "ip:2", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	now = now.Add(30 * time.Second)
+	result, err = limiter.Take(ctx, "ip:1", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Zero(t, result.Remaining)
+}
+
+func TestMemoryPrune(t *testing.T) {
+	limiter := NewMemory()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 1, Period: time.Hour}
+	ctx := context.Background()
+
+	_, err := limiter.Take(ctx, "old", limit)
+	require.NoError(t, err)
+	now = now.Add(2 * time.Hour)
+	_, err = limiter.Take(ctx, "new", limit)
+	require.NoError(t, err)
+
+	require.NoError(t, limiter.Prune(ctx, time.Hour))
+	require.NotContains(t, limiter.buckets, "old")
+	require.Contains(t, limiter.buckets, "new")
+}
+
+func TestJanitor(t *testing.T) {
+	limiter := NewMemory()
+	_, err := limiter.Take(context.Background(), "ip:1", Limit{Requests: 1, Period: time.Minute})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Janitor(limiter, time.Millisecond, func() time.Duration { return 0 })(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+		return len(limiter.buckets) == 0
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	db "github.com/hiiamanop/simple_bank/db/sqlc"
+)
+
+// BucketStore is the part of db.Store used by the Postgres limiter
+type BucketStore interface {
+	TakeRateLimitToken(ctx context.Context, arg db.TakeRateLimitTokenParams) (db.TakeRateLimitTokenRow, error)
+	DeleteIdleRateLimitBuckets(ctx context.Context, idleSince time.Time) (int64, error)
+}
+
+// Postgres keeps the buckets in the rate_limit_buckets table, so that all
+// instances of the service enforce one shared limit
+type Postgres struct {
+	store BucketStore
+}
+
+// NewPostgres creates a limiter backed by store
+func NewPostgres(store BucketStore) *Postgres {
+	return &Postgres{store: store}
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	row, err := p.store.TakeRateLimitToken(ctx, db.TakeRateLimitTokenParams{
+		Key:        key,
+		Capacity:   limit.capacity(),
+		RefillRate: limit.refillRate(),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, row.Tokens, row.Allowed), nil
+}
+
+func (p *Postgres) Prune(ctx context.Context, idle time.Duration) error {
+	_, err := p.store.DeleteIdleRateLimitBuckets(ctx, time.Now().Add(-idle))
+	return err
+}
@@ -0,0 +1,92 @@
+// Package ratelimit implements token bucket rate limiting with an in-memory
+// backend for single instances and a Postgres backend shared by all
+// instances of the service.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit allows Requests per Period. A bucket holds up to Requests tokens, so
+// a client may burst the whole allowance, and refills continuously.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Unlimited reports whether the limit is disabled
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+func (l Limit) capacity() float64 {
+	return float64(l.Requests)
+}
+
+// refillRate is the number of tokens added per second
+func (l Limit) refillRate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result describes the state of a bucket after a request
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining is the number of whole tokens left
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when
+	// the request was allowed
+	RetryAfter time.Duration
+}
+
+// newResult derives a Result from the tokens left in the bucket
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.refillRate()
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(0, int(math.Floor(tokens))),
+		Reset:     secondsToDuration((limit.capacity() - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(max(0, seconds) * float64(time.Second)))
+}
+
+// Limiter takes tokens from the bucket identified by key
+type Limiter interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Prune forgets the buckets that were not used for idle. A forgotten
+	// bucket starts full, so idle must be at least the longest period.
+	Prune(ctx context.Context, idle time.Duration) error
+}
+
+// Janitor returns a background task that prunes limiter every interval. idle
+// is asked for the idle time on every run, so that it can follow limits
+// that are reloaded at runtime.
+func Janitor(limiter Limiter, interval time.Duration, idle func() time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				if err := limiter.Prune(ctx, idle()); err != nil && ctx.Err() == nil {
+					return err
+				}
+			}
+		}
+	}
+}
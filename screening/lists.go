@@ -0,0 +1,172 @@
+package screening
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// OFAC SDN CSV columns, see https://ofac.treasury.gov/specially-designated-nationals-list-data-formats-data-schemas
+const (
+	sdnEntNum = iota
+	sdnName
+	sdnType
+	sdnProgram
+	sdnRemarks = 11
+)
+
+// sdnEmpty is how the SDN CSV writes an empty field
+const sdnEmpty = "-0-"
+
+// sdnAlias finds the aliases in the remarks of an SDN entry, e.g.
+// "a.k.a. 'ABU HAFS'"
+var sdnAlias = regexp.MustCompile(`a\.k\.a\. '([^']+)'`)
+
+// LoadOFAC reads the OFAC SDN list in its CSV format, sdn.csv. Vessels and
+// aircraft are left out, as only people and organisations are screened.
+func LoadOFAC(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var entries []Entry
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		// the file ends with a SUB character on a line of its own
+		if len(record) <= sdnRemarks {
+			continue
+		}
+
+		switch sdnField(record[sdnType]) {
+		case "vessel", "aircraft":
+			continue
+		}
+		entry := Entry{
+			List:    ListOFAC,
+			ID:      sdnField(record[sdnEntNum]),
+			Name:    sdnField(record[sdnName]),
+			Program: sdnField(record[sdnProgram]),
+		}
+		if entry.ID == "" || entry.Name == "" {
+			return nil, fmt.Errorf("%s: line %d: entry without number or name", path, line)
+		}
+		for _, alias := range sdnAlias.FindAllStringSubmatch(record[sdnRemarks], -1) {
+			entry.Aliases = append(entry.Aliases, alias[1])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func sdnField(value string) string {
+	value = strings.TrimSpace(value)
+	if value == sdnEmpty {
+		return ""
+	}
+	return value
+}
+
+// euSanctionEntity is the part of a sanctionEntity element of the EU
+// consolidated list that is screened
+type euSanctionEntity struct {
+	LogicalID         string `xml:"logicalId,attr"`
+	EUReferenceNumber string `xml:"euReferenceNumber,attr"`
+	Regulations       []struct {
+		Programme string `xml:"programme,attr"`
+	} `xml:"regulation"`
+	NameAliases []struct {
+		WholeName string `xml:"wholeName,attr"`
+	} `xml:"nameAlias"`
+}
+
+// LoadEU reads the EU consolidated financial sanctions list in its XML
+// format. The first name of an entity is its name, the others its aliases.
+func LoadEU(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// the list is large, so decode one entity at a time
+	decoder := xml.NewDecoder(file)
+	var entries []Entry
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "sanctionEntity" {
+			continue
+		}
+
+		var entity euSanctionEntity
+		if err := decoder.DecodeElement(&entity, &start); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		entry := Entry{List: ListEU, ID: entity.EUReferenceNumber}
+		if entry.ID == "" {
+			entry.ID = entity.LogicalID
+		}
+		if len(entity.Regulations) > 0 {
+			entry.Program = entity.Regulations[0].Programme
+		}
+		for _, alias := range entity.NameAliases {
+			name := strings.TrimSpace(alias.WholeName)
+			switch {
+			case name == "":
+			case entry.Name == "":
+				entry.Name = name
+			default:
+				entry.Aliases = append(entry.Aliases, name)
+			}
+		}
+		if entry.ID == "" || entry.Name == "" {
+			return nil, fmt.Errorf("%s: sanction entity without reference or name", path)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Load reads the lists that are configured, an empty path skips a list,
+// and returns a screener for them
+func Load(ofacPath, euPath string, threshold float64) (*Screener, error) {
+	var lists [][]Entry
+	if ofacPath != "" {
+		entries, err := LoadOFAC(ofacPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the OFAC SDN list: %w", err)
+		}
+		lists = append(lists, entries)
+	}
+	if euPath != "" {
+		entries, err := LoadEU(euPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the EU consolidated list: %w", err)
+		}
+		lists = append(lists, entries)
+	}
+	return NewScreener(threshold, lists...)
+}
@@ -0,0 +1,53 @@
+package screening
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations spells letters that do not decompose into a Latin base
+// letter and a mark. Cyrillic follows the ICAO passport romanisation.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l", 'ı': "i",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "iu",
+	'я': "ia", 'і': "i", 'ї': "i", 'є': "ie", 'ґ': "g",
+}
+
+// umlauts folds the German spelling of umlauts into the base letter, so
+// that "Mueller" meets "Müller", which decomposes into "muller". Both sides
+// of a comparison are folded alike.
+var umlauts = strings.NewReplacer("ae", "a", "oe", "o", "ue", "u")
+
+// Normalize reduces a name to lower case ASCII tokens: letters are
+// transliterated, accents dropped and punctuation treated as a space. The
+// tokens keep their order, so "HUSSEIN, Saddam" becomes [hussein saddam].
+func Normalize(name string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(strings.ToLower(name)) {
+		spelling, transliterated := transliterations[r]
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// accents of decomposed letters
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		case transliterated:
+			b.WriteString(spelling)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			// scripts without a transliteration are kept as they are
+			b.WriteRune(r)
+		default:
+			b.WriteByte(' ')
+		}
+	}
+
+	tokens := strings.Fields(b.String())
+	for i, token := range tokens {
+		tokens[i] = umlauts.Replace(token)
+	}
+	return tokens
+}
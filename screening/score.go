@@ -0,0 +1,102 @@
+package screening
+
+import (
+	"slices"
+	"strings"
+)
+
+// minTokenScore is the lowest similarity at which two tokens count as the
+// same word spelt differently
+const minTokenScore = 0.8
+
+// nameScore compares two normalised names. It is the better of comparing
+// them as a whole, with the tokens sorted so that "Hussein Saddam" meets
+// "Saddam Hussein", and pairing their tokens one by one, which forgives a
+// typo in one word without letting a shared first name alone match.
+func nameScore(a, b []string) float64 {
+	return max(jaroWinkler(sortedJoin(a), sortedJoin(b)), tokenScore(a, b))
+}
+
+func sortedJoin(tokens []string) string {
+	sorted := slices.Clone(tokens)
+	slices.Sort(sorted)
+	return strings.Join(sorted, " ")
+}
+
+// tokenScore pairs every token of a with the most similar unpaired token of
+// b. It is the Dice coefficient of the pairs: the similarities of the pairs,
+// counted for both names, over the number of tokens of both.
+func tokenScore(a, b []string) float64 {
+	used := make([]bool, len(b))
+	var total float64
+	for _, token := range a {
+		best, bestIndex := 0.0, -1
+		for i, other := range b {
+			if used[i] {
+				continue
+			}
+			if score := jaroWinkler(token, other); score > best {
+				best, bestIndex = score, i
+			}
+		}
+		if best >= minTokenScore {
+			used[bestIndex] = true
+			total += best
+		}
+	}
+	return 2 * total / float64(len(a)+len(b))
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of a and b in [0, 1],
+// which favours strings that share a prefix
+func jaroWinkler(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+
+	window := max(len(s), len(t))/2 - 1
+	window = max(window, 0)
+	sMatched := make([]bool, len(s))
+	tMatched := make([]bool, len(t))
+	var matches int
+	for i := range s {
+		for j := max(0, i-window); j < min(len(t), i+window+1); j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	// half the matched characters that are out of order
+	var transpositions, j int
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+
+	var prefix int
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
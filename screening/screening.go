@@ -0,0 +1,128 @@
+// Package screening checks names against sanctions and watch lists. Lists
+// are loaded from local files, the OFAC SDN list as CSV and the EU
+// consolidated list as XML, and a Screener scores a name against every
+// name and alias on them. Names are compared after transliteration, so
+// "Müller", "Mueller" and "МЮЛЛЕР" come close.
+package screening
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Lists an Entry can come from
+const (
+	ListOFAC = "ofac_sdn"
+	ListEU   = "eu_consolidated"
+)
+
+// DefaultThreshold is the lowest score that counts as a match when none is
+// configured
+const DefaultThreshold = 0.9
+
+// Entry is a sanctioned person or organisation
+type Entry struct {
+	List string `json:"list"`
+	// ID identifies the entry within its list, e.g. the OFAC entity number
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	// Program is the sanctions programme, e.g. "SDGT" or "IRQ"
+	Program string `json:"program,omitempty"`
+}
+
+// Match is an entry that a screened name resembles
+type Match struct {
+	Entry Entry `json:"entry"`
+	// MatchedName is the name or alias of the entry that scored best
+	MatchedName string `json:"matched_name"`
+	// Score is the similarity in [0, 1], 1 for names that are equal after
+	// normalisation
+	Score float64 `json:"score"`
+}
+
+// Screener matches names against a fixed set of entries. It is safe for
+// concurrent use.
+type Screener struct {
+	threshold float64
+	entries   []indexedEntry
+}
+
+// indexedEntry keeps the normalised names of an entry, which are compared
+// on every screening
+type indexedEntry struct {
+	entry Entry
+	names []string
+	// tokens holds the tokens of names, in the same order
+	tokens [][]string
+}
+
+// NewScreener returns a screener that reports entries of lists scoring at
+// least threshold
+func NewScreener(threshold float64, lists ...[]Entry) (*Screener, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("threshold %v is not in (0, 1]", threshold)
+	}
+
+	screener := &Screener{threshold: threshold}
+	for _, list := range lists {
+		for _, entry := range list {
+			indexed := indexedEntry{entry: entry}
+			for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+				if tokens := Normalize(name); len(tokens) > 0 {
+					indexed.names = append(indexed.names, name)
+					indexed.tokens = append(indexed.tokens, tokens)
+				}
+			}
+			if len(indexed.names) > 0 {
+				screener.entries = append(screener.entries, indexed)
+			}
+		}
+	}
+	return screener, nil
+}
+
+// Threshold returns the lowest score that counts as a match
+func (screener *Screener) Threshold() float64 {
+	return screener.threshold
+}
+
+// Len returns the number of entries screened against
+func (screener *Screener) Len() int {
+	return len(screener.entries)
+}
+
+// Screen returns the entries that name matches, best match first. Each
+// entry is reported once, with the best scoring of its names.
+func (screener *Screener) Screen(name string) []Match {
+	query := Normalize(name)
+	if len(query) == 0 {
+		return nil
+	}
+
+	var matches []Match
+	for _, indexed := range screener.entries {
+		best := Match{Entry: indexed.entry}
+		for i, tokens := range indexed.tokens {
+			if score := nameScore(query, tokens); score > best.Score {
+				best.Score = score
+				best.MatchedName = indexed.names[i]
+			}
+		}
+		if best.Score >= screener.threshold {
+			matches = append(matches, best)
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return matches
+}
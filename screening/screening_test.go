@@ -0,0 +1,94 @@
+package screening
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	require.Equal(t, []string{"hussein", "saddam"}, Normalize("HUSSEIN, Saddam"))
+	require.Equal(t, []string{"jose", "garcia"}, Normalize("José García"))
+	require.Equal(t, []string{"sergei", "miuller"}, Normalize("Сергей Мюллер"))
+	require.Equal(t, Normalize("Müller"), Normalize("Mueller"))
+	require.Equal(t, []string{"al", "masri"}, Normalize("al-Masri"))
+	require.Equal(t, []string{"strasse"}, Normalize("Straße"))
+	require.Empty(t, Normalize(" - "))
+}
+
+func TestJaroWinkler(t *testing.T) {
+	require.Equal(t, 1.0, jaroWinkler("saddam", "saddam"))
+	require.InDelta(t, 0.961, jaroWinkler("martha", "marhta"), 0.001)
+	require.InDelta(t, 0.840, jaroWinkler("dwayne", "duane"), 0.001)
+	require.Zero(t, jaroWinkler("abc", "xyz"))
+	require.Zero(t, jaroWinkler("", "abc"))
+}
+
+func TestLoadOFAC(t *testing.T) {
+	entries, err := LoadOFAC("testdata/sdn.csv")
+	require.NoError(t, err)
+	// the vessel is left out
+	require.Len(t, entries, 3)
+
+	require.Equal(t, Entry{
+		List:    ListOFAC,
+		ID:      "2676",
+		Name:    "HUSSEIN, Saddam",
+		Aliases: []string{"AL-TIKRITI, Saddam Hussein"},
+		Program: "IRAQ2",
+	}, entries[1])
+	require.Equal(t, []string{"ATEF, Muhammad", "EL KHABIR, Abu Hafs"}, entries[2].Aliases)
+
+	_, err = LoadOFAC("testdata/missing.csv")
+	require.Error(t, err)
+}
+
+func TestLoadEU(t *testing.T) {
+	entries, err := LoadEU("testdata/eu.xml")
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{List: ListEU, ID: "EU.1234.56", Name: "Sergey Müller", Aliases: []string{"Сергей Мюллер"}, Program: "UKR"},
+		{List: ListEU, ID: "99", Name: "Example Shipping Company", Program: "IRN"},
+	}, entries)
+}
+
+func TestScreen(t *testing.T) {
+	screener, err := Load("testdata/sdn.csv", "testdata/eu.xml", DefaultThreshold)
+	require.NoError(t, err)
+	require.Equal(t, 5, screener.Len())
+
+	testCases := []struct {
+		name  string
+		query string
+		id    string
+	}{
+		{name: "Exact", query: "Saddam Hussein", id: "2676"},
+		{name: "Typo", query: "Sadam Husein", id: "2676"},
+		{name: "Alias", query: "Muhammad Atef", id: "7560"},
+		{name: "Transliterated", query: "Sergei Mueller", id: "EU.1234.56"},
+		{name: "Cyrillic", query: "Сергей Мюллер", id: "EU.1234.56"},
+		{name: "Organisation", query: "Example Shipping Co", id: "99"},
+		{name: "SharedFirstName", query: "Saddam Smith"},
+		{name: "Unrelated", query: "Jane Doe"},
+		{name: "Empty", query: "..."},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			matches := screener.Screen(tc.query)
+			if tc.id == "" {
+				require.Empty(t, matches)
+				return
+			}
+			require.NotEmpty(t, matches)
+			require.Equal(t, tc.id, matches[0].Entry.ID)
+			require.GreaterOrEqual(t, matches[0].Score, DefaultThreshold)
+			require.NotEmpty(t, matches[0].MatchedName)
+		})
+	}
+
+	_, err = NewScreener(0)
+	require.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// JWTMaker creates HS256 signed JSON Web Tokens
+type JWTMaker struct {
+	secretKey []byte
+}
+
+type jwtClaims struct {
+	Role    string `json:"role"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// NewJWTMaker creates a JWTMaker. The secret key must be at least
+// MinSecretKeySize bytes, which util.Config validates.
+func NewJWTMaker(secretKey string) *JWTMaker {
+	return &JWTMaker{secretKey: []byte(secretKey)}
+}
+
+func (maker *JWTMaker) CreateToken(username string, role string, purpose string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, purpose, duration)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := jwtClaims{
+		Role:    payload.Role,
+		Purpose: payload.Purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        payload.ID.String(),
+			Subject:   payload.Username,
+			IssuedAt:  jwt.NewNumericDate(payload.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(payload.ExpiredAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(maker.secretKey)
+	return token, payload, err
+}
+
+func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
+	var claims jwtClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return maker.secretKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &Payload{
+		ID:        id,
+		Username:  claims.Subject,
+		Role:      claims.Role,
+		Purpose:   claims.Purpose,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiredAt: claims.ExpiresAt.Time,
+	}, nil
+}
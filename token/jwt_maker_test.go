@@ -0,0 +1,60 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTMaker(t *testing.T) {
+	maker := NewJWTMaker(util.RandomString(MinSecretKeySize))
+
+	username := util.RandomString(6)
+	duration := time.Minute
+	issuedAt := time.Now()
+
+	token, payload, err := maker.CreateToken(username, "admin", PurposeStepUp, duration)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	verified, err := maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, payload.ID, verified.ID)
+	require.Equal(t, username, verified.Username)
+	require.Equal(t, "admin", verified.Role)
+	require.Equal(t, PurposeStepUp, verified.Purpose)
+	require.WithinDuration(t, issuedAt, verified.IssuedAt, time.Second)
+	require.WithinDuration(t, issuedAt.Add(duration), verified.ExpiredAt, time.Second)
+}
+
+func TestExpiredJWTToken(t *testing.T) {
+	maker := NewJWTMaker(util.RandomString(MinSecretKeySize))
+
+	token, _, err := maker.CreateToken(util.RandomString(6), "depositor", PurposeAccess, -time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.ErrorIs(t, err, ErrExpiredToken)
+	require.Nil(t, payload)
+}
+
+func TestInvalidJWTToken(t *testing.T) {
+	maker := NewJWTMaker(util.RandomString(MinSecretKeySize))
+	other := NewJWTMaker(util.RandomString(MinSecretKeySize))
+
+	token, _, err := other.CreateToken(util.RandomString(6), "depositor", PurposeAccess, time.Minute)
+	require.NoError(t, err)
+	_, err = maker.VerifyToken(token)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// unsigned tokens are rejected
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = maker.VerifyToken(unsigned)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
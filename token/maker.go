@@ -0,0 +1,64 @@
+// Package token issues and verifies the bearer tokens of the API
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by VerifyToken
+var (
+	ErrInvalidToken = errors.New("token is invalid")
+	ErrExpiredToken = errors.New("token has expired")
+)
+
+// MinSecretKeySize is the minimum length of the symmetric key
+const MinSecretKeySize = 32
+
+// Purposes of tokens. An access token authenticates requests; a step-up
+// token proves a recent second factor verification and authorizes
+// sensitive operations on top of an access token.
+const (
+	PurposeAccess = "access"
+	PurposeStepUp = "step_up"
+)
+
+// Maker creates and verifies tokens
+type Maker interface {
+	// CreateToken creates a token for username and role, used for purpose
+	// and valid for duration
+	CreateToken(username string, role string, purpose string, duration time.Duration) (string, *Payload, error)
+	// VerifyToken checks that the token is valid and returns its payload
+	VerifyToken(token string) (*Payload, error)
+}
+
+// Payload contains the data carried by a token
+type Payload struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Purpose   string    `json:"purpose"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+// NewPayload creates a payload for username and role, used for purpose and
+// valid for duration
+func NewPayload(username string, role string, purpose string, duration time.Duration) (*Payload, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Payload{
+		ID:        id,
+		Username:  username,
+		Role:      role,
+		Purpose:   purpose,
+		IssuedAt:  now,
+		ExpiredAt: now.Add(duration),
+	}, nil
+}
@@ -0,0 +1,73 @@
+// Package tracing configures the OpenTelemetry tracer provider of the
+// service.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hiiamanop/simple_bank/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName identifies the service in the exported spans
+const ServiceName = "simple_bank"
+
+// Exporters supported by TRACING_EXPORTER
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation. The returned function flushes pending spans and must be
+// called on shutdown. With the "none" exporter spans are still created, so
+// that trace IDs propagate, but nothing is exported.
+func Setup(ctx context.Context, config util.Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	switch config.TracingExporter {
+	case ExporterNone, "":
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("cannot create stdout exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpoint(config.OTLPEndpoint),
+			otlptracehttp.WithInsecure(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create otlp exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.TracingExporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,23 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiiamanop/simple_bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{"", ExporterNone, ExporterStdout, ExporterOTLP} {
+		shutdown, err := Setup(context.Background(), util.Config{
+			TracingExporter: exporter,
+			OTLPEndpoint:    "localhost:4318",
+		})
+		require.NoError(t, err, exporter)
+		require.NoError(t, shutdown(context.Background()), exporter)
+	}
+
+	_, err := Setup(context.Background(), util.Config{TracingExporter: "jaeger"})
+	require.ErrorContains(t, err, "unknown tracing exporter")
+}
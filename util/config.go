@@ -1,25 +1,257 @@
 package util
 
-import "github.com/spf13/viper"
+import (
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/spf13/viper"
+)
+
+// Config stores all configuration of the application. The values are read
+// from app.env or from environment variables; the defaults are listed in
+// setDefaults. List values are comma separated.
 type Config struct {
 	DBDriver      string `mapstructure:"DB_DRIVER"`
 	DBSource      string `mapstructure:"DB_SOURCE"`
 	ServerAddress string `mapstructure:"SERVER_ADDRESS"`
+	// LogLevel is the minimum level logged: debug, info, warn or error
+	LogLevel string `mapstructure:"LOG_LEVEL"`
+	// MigrateOnStart applies pending migrations before the server starts
+	MigrateOnStart bool `mapstructure:"MIGRATE_ON_START"`
+
+	// TokenSymmetricKey signs the access tokens, at least 32 characters
+	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+
+	// PIIKeyFile holds the keys that encrypt names and email addresses,
+	// created with "simplebank pii keygen"
+	PIIKeyFile string `mapstructure:"PII_KEY_FILE"`
+
+	// MonitoringRulesFile holds the transaction monitoring rules that every
+	// transfer is evaluated against, empty disables monitoring
+	MonitoringRulesFile string `mapstructure:"MONITORING_RULES_FILE"`
+
+	// SanctionsOFACFile and SanctionsEUFile are local copies of the OFAC SDN
+	// list (sdn.csv) and the EU consolidated list (XML). New users and the
+	// parties of transfers are screened against the lists configured; with
+	// neither there is no screening.
+	SanctionsOFACFile string `mapstructure:"SANCTIONS_OFAC_FILE"`
+	SanctionsEUFile   string `mapstructure:"SANCTIONS_EU_FILE"`
+	// ScreeningThreshold is the lowest name similarity, in (0, 1], that
+	// counts as a hit
+	ScreeningThreshold float64 `mapstructure:"SCREENING_THRESHOLD"`
+
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string `mapstructure:"TOTP_ISSUER"`
+	// StepUpTokenDuration is how long a two-factor verification stays fresh
+	StepUpTokenDuration time.Duration `mapstructure:"STEP_UP_TOKEN_DURATION"`
+	// StepUpTransferAmount is the largest transfer allowed without a fresh
+	// step-up token, 0 requires none
+	StepUpTransferAmount int64 `mapstructure:"STEP_UP_TRANSFER_AMOUNT"`
+
+	// TransferApprovalTTL is how long a transfer that needs approval waits
+	// for its approvers before it expires
+	TransferApprovalTTL time.Duration `mapstructure:"TRANSFER_APPROVAL_TTL"`
+
+	// AppBaseURL is the web app that the links in emails point to
+	AppBaseURL string `mapstructure:"APP_BASE_URL"`
+	// VerifyEmailTokenDuration and PasswordResetTokenDuration bound how long
+	// the tokens sent by email can be used
+	VerifyEmailTokenDuration   time.Duration `mapstructure:"VERIFY_EMAIL_TOKEN_DURATION"`
+	PasswordResetTokenDuration time.Duration `mapstructure:"PASSWORD_RESET_TOKEN_DURATION"`
+
+	// MailBackend delivers emails by smtp, writes them to files in MailDir
+	// or only logs them
+	MailBackend string `mapstructure:"MAIL_BACKEND"`
+	// MailFrom is the sender address, e.g. "SimpleBank <no-reply@example.com>"
+	MailFrom string `mapstructure:"MAIL_FROM"`
+	MailDir  string `mapstructure:"MAIL_DIR"`
+	// SMTPAddress is the host:port of the SMTP server. SMTPUsername enables
+	// PLAIN authentication, which requires TLS except on localhost.
+	SMTPAddress  string `mapstructure:"SMTP_ADDRESS"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+
+	// LoginMaxAttempts consecutive failed logins lock a user out for
+	// LoginLockoutDuration. Every failure is answered after a delay that
+	// starts at LoginFailureDelay and doubles with each further failure,
+	// up to LoginMaxFailureDelay.
+	LoginMaxAttempts     int           `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginLockoutDuration time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginFailureDelay    time.Duration `mapstructure:"LOGIN_FAILURE_DELAY"`
+	LoginMaxFailureDelay time.Duration `mapstructure:"LOGIN_MAX_FAILURE_DELAY"`
+
+	DBMaxOpenConns    int           `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns    int           `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime time.Duration `mapstructure:"DB_CONN_MAX_IDLE_TIME"`
+
+	HTTPReadTimeout       time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	HTTPReadHeaderTimeout time.Duration `mapstructure:"HTTP_READ_HEADER_TIMEOUT"`
+	HTTPWriteTimeout      time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout       time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	HTTPMaxHeaderBytes    int           `mapstructure:"HTTP_MAX_HEADER_BYTES"`
+	// HTTPMaxBodyBytes limits request bodies, 0 disables the limit
+	HTTPMaxBodyBytes int64 `mapstructure:"HTTP_MAX_BODY_BYTES"`
+	// ShutdownTimeout bounds how long in-flight requests and background
+	// workers are given to finish after a shutdown signal
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set
+	TLSCertFile string `mapstructure:"TLS_CERT_FILE"`
+	TLSKeyFile  string `mapstructure:"TLS_KEY_FILE"`
+	// TLSClientCAFile enables mutual TLS: clients must present a
+	// certificate signed by one of these CAs
+	TLSClientCAFile string `mapstructure:"TLS_CLIENT_CA_FILE"`
+	// TrustedProxies lists the IPs and CIDRs whose X-Forwarded-For header is
+	// trusted for the client IP. Empty trusts no proxy.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
+	// CORSAllowOrigins lists the allowed origins, "*" allows all origins and
+	// "https://*.example.com" all subdomains
+	CORSAllowOrigins     []string      `mapstructure:"CORS_ALLOW_ORIGINS"`
+	CORSAllowMethods     []string      `mapstructure:"CORS_ALLOW_METHODS"`
+	CORSAllowHeaders     []string      `mapstructure:"CORS_ALLOW_HEADERS"`
+	CORSAllowCredentials bool          `mapstructure:"CORS_ALLOW_CREDENTIALS"`
+	CORSMaxAge           time.Duration `mapstructure:"CORS_MAX_AGE"`
+
+	// RateLimitBackend keeps the token buckets in memory or in postgres,
+	// which shares the limits between all instances
+	RateLimitBackend string `mapstructure:"RATE_LIMIT_BACKEND"`
+	// The rate limits are "requests/period", e.g. "30/1m"; empty disables
+	// the limit. Transfers applies to creating transfers, auth to signing
+	// up and logging in, and default to the rest of the API.
+	RateLimitDefault   string `mapstructure:"RATE_LIMIT_DEFAULT"`
+	RateLimitTransfers string `mapstructure:"RATE_LIMIT_TRANSFERS"`
+	RateLimitAuth      string `mapstructure:"RATE_LIMIT_AUTH"`
+
+	// FeatureFlags lists the enabled optional features, see FeatureAPIDocs
+	FeatureFlags []string `mapstructure:"FEATURE_FLAGS"`
+
+	// TracingExporter selects where spans are sent: none, stdout or otlp
+	TracingExporter string `mapstructure:"TRACING_EXPORTER"`
+	// OTLPEndpoint is the host:port of the OTLP/HTTP collector
+	OTLPEndpoint string `mapstructure:"OTLP_ENDPOINT"`
 }
 
+// Optional features that can be switched with FEATURE_FLAGS
+const (
+	// FeatureAPIDocs serves the Swagger UI at /api/v1/docs and the OpenAPI
+	// spec it shows at /api/v1/openapi.json
+	FeatureAPIDocs = "api_docs"
+)
+
+// LoadConfig reads the configuration from app.env in path and the
+// environment, and validates it
 func LoadConfig(path string) (config Config, err error) {
-	viper.AddConfigPath(path)
-	viper.SetConfigName("app")
-	viper.SetConfigType("env")
+	v := newViper(path)
+	if err = v.ReadInConfig(); err != nil {
+		return
+	}
+	return decodeConfig(v)
+}
 
-	viper.AutomaticEnv()
+func newViper(path string) *viper.Viper {
+	v := viper.New()
+	v.AddConfigPath(path)
+	v.SetConfigName("app")
+	v.SetConfigType("env")
 
-	err = viper.ReadInConfig()
-	if err != nil {
+	setDefaults(v)
+	v.AutomaticEnv()
+	return v
+}
+
+func decodeConfig(v *viper.Viper) (config Config, err error) {
+	if err = v.Unmarshal(&config); err != nil {
 		return
 	}
 
-	err = viper.Unmarshal(&config)
+	config.normalize()
+	err = config.Validate()
 	return
 }
+
+// FeatureEnabled reports whether the feature is listed in FEATURE_FLAGS
+func (config Config) FeatureEnabled(feature string) bool {
+	return slices.Contains(config.FeatureFlags, feature)
+}
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("LOG_LEVEL", "info")
+	v.SetDefault("MIGRATE_ON_START", false)
+
+	v.SetDefault("ACCESS_TOKEN_DURATION", 15*time.Minute)
+	v.SetDefault("PII_KEY_FILE", "pii_keys.json")
+	v.SetDefault("MONITORING_RULES_FILE", "monitoring_rules.json")
+	v.SetDefault("SCREENING_THRESHOLD", 0.9)
+	v.SetDefault("TOTP_ISSUER", "SimpleBank")
+	v.SetDefault("STEP_UP_TOKEN_DURATION", 5*time.Minute)
+	v.SetDefault("STEP_UP_TRANSFER_AMOUNT", 100000)
+	v.SetDefault("TRANSFER_APPROVAL_TTL", 48*time.Hour)
+	v.SetDefault("APP_BASE_URL", "http://localhost:3000")
+	v.SetDefault("VERIFY_EMAIL_TOKEN_DURATION", 24*time.Hour)
+	v.SetDefault("PASSWORD_RESET_TOKEN_DURATION", time.Hour)
+	v.SetDefault("MAIL_BACKEND", "log")
+	v.SetDefault("MAIL_FROM", "SimpleBank <no-reply@simplebank.local>")
+	v.SetDefault("MAIL_DIR", "outbox")
+	v.SetDefault("SMTP_ADDRESS", "localhost:1025")
+	v.SetDefault("LOGIN_MAX_ATTEMPTS", 5)
+	v.SetDefault("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	v.SetDefault("LOGIN_FAILURE_DELAY", 250*time.Millisecond)
+	v.SetDefault("LOGIN_MAX_FAILURE_DELAY", 4*time.Second)
+
+	v.SetDefault("DB_MAX_OPEN_CONNS", 25)
+	v.SetDefault("DB_MAX_IDLE_CONNS", 25)
+	v.SetDefault("DB_CONN_MAX_LIFETIME", 30*time.Minute)
+	v.SetDefault("DB_CONN_MAX_IDLE_TIME", 5*time.Minute)
+
+	v.SetDefault("HTTP_READ_TIMEOUT", 10*time.Second)
+	v.SetDefault("HTTP_READ_HEADER_TIMEOUT", 5*time.Second)
+	v.SetDefault("HTTP_WRITE_TIMEOUT", 15*time.Second)
+	v.SetDefault("HTTP_IDLE_TIMEOUT", 60*time.Second)
+	v.SetDefault("HTTP_MAX_HEADER_BYTES", 1<<20)
+	v.SetDefault("HTTP_MAX_BODY_BYTES", 1<<20)
+	v.SetDefault("SHUTDOWN_TIMEOUT", 30*time.Second)
+
+	v.SetDefault("TRUSTED_PROXIES", []string{})
+
+	v.SetDefault("CORS_ALLOW_ORIGINS", []string{"http://localhost:3000"})
+	v.SetDefault("CORS_ALLOW_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"})
+	v.SetDefault("CORS_ALLOW_HEADERS", []string{
+		"Origin", "Content-Length", "Content-Type", "Authorization", "Idempotency-Key", "X-Request-ID", "X-Step-Up-Token",
+	})
+	v.SetDefault("CORS_ALLOW_CREDENTIALS", true)
+	v.SetDefault("CORS_MAX_AGE", 12*time.Hour)
+
+	v.SetDefault("RATE_LIMIT_BACKEND", "memory")
+	v.SetDefault("RATE_LIMIT_DEFAULT", "300/1m")
+	v.SetDefault("RATE_LIMIT_TRANSFERS", "30/1m")
+	v.SetDefault("RATE_LIMIT_AUTH", "10/1m")
+
+	v.SetDefault("FEATURE_FLAGS", []string{FeatureAPIDocs})
+
+	v.SetDefault("TRACING_EXPORTER", "none")
+	v.SetDefault("OTLP_ENDPOINT", "localhost:4318")
+}
+
+// normalize trims the entries of list values and drops empty ones, so that
+// "a, b," reads as [a b]
+func (config *Config) normalize() {
+	for _, list := range []*[]string{
+		&config.TrustedProxies,
+		&config.CORSAllowOrigins,
+		&config.CORSAllowMethods,
+		&config.CORSAllowHeaders,
+		&config.FeatureFlags,
+	} {
+		cleaned := (*list)[:0]
+		for _, value := range *list {
+			if value = strings.TrimSpace(value); value != "" {
+				cleaned = append(cleaned, value)
+			}
+		}
+		*list = cleaned
+	}
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseRateLimit parses a rate limit of the form "requests/period", such as
+// "30/1m" or "1000/1h". An empty limit parses as zero, which means
+// unlimited.
+func ParseRateLimit(limit string) (requests int, period time.Duration, err error) {
+	if limit == "" {
+		return 0, 0, nil
+	}
+
+	count, duration, ok := strings.Cut(limit, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("rate limit %q is not requests/period", limit)
+	}
+	requests, err = strconv.Atoi(count)
+	if err != nil || requests <= 0 {
+		return 0, 0, fmt.Errorf("rate limit %q: requests must be a positive integer", limit)
+	}
+	period, err = time.ParseDuration(duration)
+	if err != nil || period <= 0 {
+		return 0, 0, fmt.Errorf("rate limit %q: period must be a positive duration", limit)
+	}
+	return requests, period, nil
+}
+
+// rateLimits maps the setting names to the configured rate limits
+func (config Config) rateLimits() map[string]string {
+	return map[string]string{
+		"RATE_LIMIT_DEFAULT":   config.RateLimitDefault,
+		"RATE_LIMIT_TRANSFERS": config.RateLimitTransfers,
+		"RATE_LIMIT_AUTH":      config.RateLimitAuth,
+	}
+}
+
+// LongestRateLimitPeriod returns the longest period of the configured rate
+// limits. A bucket unused for that long is full again and can be forgotten.
+func (config Config) LongestRateLimitPeriod() time.Duration {
+	var longest time.Duration
+	for _, limit := range config.rateLimits() {
+		// validated by Validate
+		_, period, _ := ParseRateLimit(limit)
+		longest = max(longest, period)
+	}
+	return longest
+}
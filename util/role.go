@@ -0,0 +1,16 @@
+package util
+
+// Roles of users
+const (
+	DepositorRole = "depositor"
+	AdminRole     = "admin"
+)
+
+// IsSupportedRole reports whether role is one of the roles above
+func IsSupportedRole(role string) bool {
+	switch role {
+	case DepositorRole, AdminRole:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// minTokenKeySize matches token.MinSecretKeySize
+const minTokenKeySize = 32
+
+var (
+	tracingExporters  = []string{"none", "stdout", "otlp"}
+	httpMethods       = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	rateLimitBackends = []string{"memory", "postgres"}
+	mailBackends      = []string{"log", "file", "smtp"}
+)
+
+// Validate reports every invalid setting at once, so that a bad deployment
+// fails at startup with a complete list instead of one error at a time
+func (config Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(config.DBDriver != "", "DB_DRIVER is required")
+	check(config.DBSource != "", "DB_SOURCE is required")
+	if _, _, err := net.SplitHostPort(config.ServerAddress); err != nil {
+		errs = append(errs, fmt.Errorf("SERVER_ADDRESS %q: %w", config.ServerAddress, err))
+	}
+	var level slog.Level
+	check(level.UnmarshalText([]byte(config.LogLevel)) == nil, "LOG_LEVEL %q is not one of debug, info, warn, error", config.LogLevel)
+
+	check(len(config.TokenSymmetricKey) >= minTokenKeySize, "TOKEN_SYMMETRIC_KEY must be at least %d characters", minTokenKeySize)
+	check(config.AccessTokenDuration > 0, "ACCESS_TOKEN_DURATION must be positive")
+	check(config.PIIKeyFile != "", "PII_KEY_FILE is required")
+	check(config.ScreeningThreshold > 0 && config.ScreeningThreshold <= 1, "SCREENING_THRESHOLD must be in (0, 1]")
+	check(config.TOTPIssuer != "" && !strings.Contains(config.TOTPIssuer, ":"), "TOTP_ISSUER must be set and must not contain a colon")
+	check(config.StepUpTokenDuration > 0, "STEP_UP_TOKEN_DURATION must be positive")
+	check(config.StepUpTransferAmount >= 0, "STEP_UP_TRANSFER_AMOUNT must not be negative")
+	check(config.TransferApprovalTTL > 0, "TRANSFER_APPROVAL_TTL must be positive")
+	errs = append(errs, config.validateMail()...)
+	check(config.LoginMaxAttempts > 0, "LOGIN_MAX_ATTEMPTS must be positive")
+	check(config.LoginLockoutDuration > 0, "LOGIN_LOCKOUT_DURATION must be positive")
+	check(config.LoginFailureDelay >= 0, "LOGIN_FAILURE_DELAY must not be negative")
+	check(config.LoginMaxFailureDelay >= config.LoginFailureDelay, "LOGIN_MAX_FAILURE_DELAY must not be less than LOGIN_FAILURE_DELAY")
+
+	check(config.DBMaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+	check(config.DBMaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+	check(config.DBMaxOpenConns == 0 || config.DBMaxIdleConns <= config.DBMaxOpenConns,
+		"DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", config.DBMaxIdleConns, config.DBMaxOpenConns)
+	check(config.DBConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME must not be negative")
+	check(config.DBConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME must not be negative")
+
+	check(config.HTTPReadTimeout >= 0, "HTTP_READ_TIMEOUT must not be negative")
+	check(config.HTTPReadHeaderTimeout >= 0, "HTTP_READ_HEADER_TIMEOUT must not be negative")
+	check(config.HTTPWriteTimeout >= 0, "HTTP_WRITE_TIMEOUT must not be negative")
+	check(config.HTTPIdleTimeout >= 0, "HTTP_IDLE_TIMEOUT must not be negative")
+	check(config.HTTPMaxHeaderBytes >= 0, "HTTP_MAX_HEADER_BYTES must not be negative")
+	check(config.HTTPMaxBodyBytes >= 0, "HTTP_MAX_BODY_BYTES must not be negative")
+	check(config.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+
+	errs = append(errs, config.validateTLS()...)
+	for _, proxy := range config.TrustedProxies {
+		check(isIPOrPrefix(proxy), "TRUSTED_PROXIES: %q is neither an IP nor a CIDR", proxy)
+	}
+	errs = append(errs, config.validateCORS()...)
+
+	check(slices.Contains(rateLimitBackends, config.RateLimitBackend),
+		"RATE_LIMIT_BACKEND %q is not one of %s", config.RateLimitBackend, strings.Join(rateLimitBackends, ", "))
+	for name, limit := range config.rateLimits() {
+		if _, _, err := ParseRateLimit(limit); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	check(slices.Contains(tracingExporters, config.TracingExporter),
+		"TRACING_EXPORTER %q is not one of %s", config.TracingExporter, strings.Join(tracingExporters, ", "))
+	check(config.TracingExporter != "otlp" || config.OTLPEndpoint != "", "OTLP_ENDPOINT is required by the otlp exporter")
+
+	return errors.Join(errs...)
+}
+
+// TLSEnabled reports whether the server serves HTTPS
+func (config Config) TLSEnabled() bool {
+	return config.TLSCertFile != ""
+}
+
+func (config Config) validateTLS() []error {
+	var errs []error
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if config.TLSClientCAFile != "" && !config.TLSEnabled() {
+		errs = append(errs, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE"))
+	}
+
+	for name, path := range map[string]string{
+		"TLS_CERT_FILE":      config.TLSCertFile,
+		"TLS_KEY_FILE":       config.TLSKeyFile,
+		"TLS_CLIENT_CA_FILE": config.TLSClientCAFile,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func (config Config) validateCORS() []error {
+	var errs []error
+	for _, origin := range config.CORSAllowOrigins {
+		if origin == "*" {
+			if len(config.CORSAllowOrigins) > 1 {
+				errs = append(errs, errors.New(`CORS_ALLOW_ORIGINS: "*" cannot be combined with other origins`))
+			}
+			if config.CORSAllowCredentials {
+				errs = append(errs, errors.New(`CORS_ALLOW_ORIGINS: "*" cannot be used with CORS_ALLOW_CREDENTIALS`))
+			}
+			continue
+		}
+		if !isOrigin(origin) {
+			errs = append(errs, fmt.Errorf("CORS_ALLOW_ORIGINS: %q is not an http(s) origin", origin))
+		}
+	}
+
+	for _, method := range config.CORSAllowMethods {
+		if !slices.Contains(httpMethods, method) {
+			errs = append(errs, fmt.Errorf("CORS_ALLOW_METHODS: unknown method %q", method))
+		}
+	}
+	if config.CORSMaxAge < 0 {
+		errs = append(errs, errors.New("CORS_MAX_AGE must not be negative"))
+	}
+	return errs
+}
+
+func (config Config) validateMail() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	u, err := url.Parse(config.AppBaseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"APP_BASE_URL %q is not an http(s) URL", config.AppBaseURL)
+	check(config.VerifyEmailTokenDuration > 0, "VERIFY_EMAIL_TOKEN_DURATION must be positive")
+	check(config.PasswordResetTokenDuration > 0, "PASSWORD_RESET_TOKEN_DURATION must be positive")
+
+	check(slices.Contains(mailBackends, config.MailBackend),
+		"MAIL_BACKEND %q is not one of %s", config.MailBackend, strings.Join(mailBackends, ", "))
+	_, err = mail.ParseAddress(config.MailFrom)
+	check(err == nil, "MAIL_FROM %q is not an email address", config.MailFrom)
+	check(config.MailBackend != "file" || config.MailDir != "", "MAIL_DIR is required by the file backend")
+	if config.MailBackend == "smtp" {
+		if _, _, err := net.SplitHostPort(config.SMTPAddress); err != nil {
+			errs = append(errs, fmt.Errorf("SMTP_ADDRESS %q: %w", config.SMTPAddress, err))
+		}
+	}
+	return errs
+}
+
+// isOrigin accepts scheme://host[:port] with an optional single wildcard,
+// e.g. https://*.example.com
+func isOrigin(origin string) bool {
+	if strings.Count(origin, "*") > 1 {
+		return false
+	}
+	u, err := url.Parse(strings.Replace(origin, "*", "wildcard", 1))
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Path == "" && u.RawQuery == ""
+}
+
+func isIPOrPrefix(s string) bool {
+	if _, err := netip.ParseAddr(s); err == nil {
+		return true
+	}
+	_, err := netip.ParsePrefix(s)
+	return err == nil
+}
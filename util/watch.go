@@ -0,0 +1,102 @@
+package util
+
+import (
+	"log/slog"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadable copies the settings that running components can pick up
+// without a restart from next into config. Everything else, such as the
+// listen address or the database, keeps its value until the next restart.
+func (config Config) reloadable(next Config) Config {
+	config.LogLevel = next.LogLevel
+	config.CORSAllowOrigins = next.CORSAllowOrigins
+	config.CORSAllowMethods = next.CORSAllowMethods
+	config.CORSAllowHeaders = next.CORSAllowHeaders
+	config.CORSAllowCredentials = next.CORSAllowCredentials
+	config.CORSMaxAge = next.CORSMaxAge
+	config.RateLimitDefault = next.RateLimitDefault
+	config.RateLimitTransfers = next.RateLimitTransfers
+	config.RateLimitAuth = next.RateLimitAuth
+	config.FeatureFlags = next.FeatureFlags
+	return config
+}
+
+// ConfigWatcher reloads app.env when it changes. Valid changes to the
+// reloadable settings are published to the subscribers; invalid files are
+// rejected as a whole and the running configuration is kept.
+type ConfigWatcher struct {
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	subscribers []func(Config)
+}
+
+// WatchConfig loads the configuration like LoadConfig and keeps watching
+// app.env in path for changes
+func WatchConfig(path string) (*ConfigWatcher, error) {
+	v := newViper(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	config, err := decodeConfig(v)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &ConfigWatcher{}
+	watcher.current.Store(&config)
+
+	v.OnConfigChange(func(event fsnotify.Event) {
+		next, err := decodeConfig(v)
+		if err != nil {
+			slog.Error("rejected config reload", slog.String("file", event.Name), slog.Any("error", err))
+			return
+		}
+		watcher.apply(next)
+	})
+	v.WatchConfig()
+
+	return watcher, nil
+}
+
+// Current returns the configuration in effect
+func (watcher *ConfigWatcher) Current() Config {
+	return *watcher.current.Load()
+}
+
+// Subscribe calls fn with the new configuration after every reload that
+// changed a reloadable setting. fn runs on the watcher goroutine and must
+// not block.
+func (watcher *ConfigWatcher) Subscribe(fn func(Config)) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	watcher.subscribers = append(watcher.subscribers, fn)
+}
+
+// apply swaps in the reloadable settings of next and notifies the
+// subscribers
+func (watcher *ConfigWatcher) apply(next Config) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	current := watcher.Current()
+	updated := current.reloadable(next)
+	if !reflect.DeepEqual(updated, next) {
+		slog.Warn("config changes outside the reloadable settings need a restart")
+	}
+	if reflect.DeepEqual(current, updated) {
+		return
+	}
+
+	watcher.current.Store(&updated)
+	slog.Info("config reloaded")
+	for _, fn := range watcher.subscribers {
+		fn(updated)
+	}
+}
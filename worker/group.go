@@ -0,0 +1,99 @@
+// Package worker runs the background tasks of the service and stops them in
+// a well defined order during shutdown.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Func is a background task. It must return once ctx is cancelled.
+type Func func(ctx context.Context) error
+
+type task struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Group runs named background tasks
+type Group struct {
+	mu    sync.Mutex
+	tasks []*task
+}
+
+// NewGroup creates an empty group
+func NewGroup() *Group {
+	return &Group{}
+}
+
+// Go starts fn in its own goroutine. A task that returns before it is
+// stopped is logged and reported as not running.
+func (g *Group) Go(name string, fn Func) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &task{
+		name:   name,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	g.mu.Lock()
+	g.tasks = append(g.tasks, t)
+	g.mu.Unlock()
+
+	go func() {
+		defer close(t.done)
+		t.err = fn(ctx)
+		if ctx.Err() == nil {
+			slog.Error("worker stopped unexpectedly", slog.String("worker", name), slog.Any("error", t.err))
+		}
+	}()
+}
+
+// Running reports for every task whether it is still running
+func (g *Group) Running() map[string]bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	running := make(map[string]bool, len(g.tasks))
+	for _, t := range g.tasks {
+		select {
+		case <-t.done:
+			running[t.name] = false
+		default:
+			running[t.name] = true
+		}
+	}
+	return running
+}
+
+// Stop cancels the tasks in reverse start order, waiting for each one to
+// return before stopping the next, so later tasks can rely on earlier ones.
+// It gives up when ctx expires.
+func (g *Group) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	tasks := g.tasks
+	g.tasks = nil
+	g.mu.Unlock()
+
+	var errs []error
+	for i := len(tasks) - 1; i >= 0; i-- {
+		t := tasks[i]
+		t.cancel()
+
+		select {
+		case <-t.done:
+			if t.err != nil && !errors.Is(t.err, context.Canceled) {
+				errs = append(errs, fmt.Errorf("worker %s: %w", t.name, t.err))
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("worker %s did not stop: %w", t.name, ctx.Err())
+		}
+	}
+
+	return errors.Join(errs...)
+}
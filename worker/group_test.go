@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupStopsInReverseOrder(t *testing.T) {
+	group := NewGroup()
+
+	var mu sync.Mutex
+	var stopped []string
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		group.Go(name, func(ctx context.Context) error {
+			<-ctx.Done()
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+			return ctx.Err()
+		})
+	}
+
+	require.Equal(t, map[string]bool{"first": true, "second": true, "third": true}, group.Running())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, group.Stop(ctx))
+	require.Equal(t, []string{"third", "second", "first"}, stopped)
+	require.Empty(t, group.Running())
+}
+
+func TestGroupReportsStoppedTask(t *testing.T) {
+	group := NewGroup()
+	failure := errors.New("boom")
+
+	group.Go("crashing", func(ctx context.Context) error {
+		return failure
+	})
+
+	require.Eventually(t, func() bool {
+		return !group.Running()["crashing"]
+	}, time.Second, time.Millisecond)
+
+	err := group.Stop(context.Background())
+	require.ErrorIs(t, err, failure)
+}
+
+func TestGroupStopTimeout(t *testing.T) {
+	group := NewGroup()
+	release := make(chan struct{})
+	defer close(release)
+
+	group.Go("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := group.Stop(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}